@@ -444,6 +444,8 @@ generate-mocks: deps $(MINIMOCK_BIN)
 	$(MINIMOCK_BIN) -i controller.DBChecker -o ./test/generated/controller/dbchecker_mock.go -t DBCheckerMock
 	$(MINIMOCK_BIN) -i controller.StatusControllerConfiguration -o ./test/generated/controller/status_controller_configuration_mock.go -t StatusControllerConfigurationMock
 	$(MINIMOCK_BIN) -i controller.TenantUpdateControllerConfiguration -o ./test/generated/controller/tenants_update_controller_configuration_mock.go -t TenantUpdateControllerConfigurationMock
+	-mkdir -p test/generated/tenant
+	$(MINIMOCK_BIN) -i tenant.Client -o ./test/generated/tenant/client_mock.go -t ClientMock
 	
 
 #-------------------------------------------------------------------------------
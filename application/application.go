@@ -2,11 +2,21 @@ package application
 
 import (
 	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/authorization"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/admin-console/webhook"
 )
 
 //An Application stands for a particular implementation of the business logic of our application
 type Application interface {
 	AuditLogs() auditlog.Repository
+	EventTypes() auditlog.EventTypeRepository
+	Roles() authorization.RoleRepository
+	Webhooks() webhook.Repository
+	TenantUpdateJobs() tenantupdate.Repository
+	TenantUpdateSchedules() tenantupdate.ScheduleRepository
+	TenantUpdateRequests() tenantupdate.RequestRepository
+	TenantUpdateRollouts() tenantupdate.RolloutRepository
 }
 
 // A Transaction abstracts a database transaction. The repositories created for the transaction object make changes inside the the transaction
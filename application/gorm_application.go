@@ -5,6 +5,9 @@ import (
 	"strconv"
 
 	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/authorization"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/admin-console/webhook"
 
 	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
@@ -61,6 +64,34 @@ func (g *GormBase) AuditLogs() auditlog.Repository {
 	return auditlog.NewRepository(g.db)
 }
 
+func (g *GormBase) EventTypes() auditlog.EventTypeRepository {
+	return auditlog.NewEventTypeRepository(g.db)
+}
+
+func (g *GormBase) Roles() authorization.RoleRepository {
+	return authorization.NewRoleRepository(g.db)
+}
+
+func (g *GormBase) Webhooks() webhook.Repository {
+	return webhook.NewRepository(g.db)
+}
+
+func (g *GormBase) TenantUpdateJobs() tenantupdate.Repository {
+	return tenantupdate.NewRepository(g.db)
+}
+
+func (g *GormBase) TenantUpdateSchedules() tenantupdate.ScheduleRepository {
+	return tenantupdate.NewScheduleRepository(g.db)
+}
+
+func (g *GormBase) TenantUpdateRequests() tenantupdate.RequestRepository {
+	return tenantupdate.NewRequestRepository(g.db)
+}
+
+func (g *GormBase) TenantUpdateRollouts() tenantupdate.RolloutRepository {
+	return tenantupdate.NewRolloutRepository(g.db)
+}
+
 func (g *GormBase) DB() *gorm.DB {
 	return g.db
 }
@@ -0,0 +1,141 @@
+package auditlog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/closeable"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Interval the size of the time buckets in which the audit log records are counted
+type Interval string
+
+const (
+	// IntervalHour counts the audit log records by hour
+	IntervalHour Interval = "hour"
+	// IntervalDay counts the audit log records by day
+	IntervalDay Interval = "day"
+	// IntervalWeek counts the audit log records by week (starting on Monday)
+	IntervalWeek Interval = "week"
+	// IntervalMonth counts the audit log records by month
+	IntervalMonth Interval = "month"
+)
+
+// Grouping the criteria by which the audit log records are counted. The records are all counted together if no criteria is set.
+type Grouping struct {
+	// EventType counts the records by event type
+	EventType bool
+	// Username counts the records by username
+	Username bool
+	// Interval counts the records by time bucket (in UTC) of the given size, unless empty
+	Interval Interval
+}
+
+// Stat the number of audit log records in a group. Only the fields matching the grouping criteria are set.
+type Stat struct {
+	// EventTypeID the event type of the records in the group
+	EventTypeID *uuid.UUID
+	// Username the username of the records in the group (nil for the records without username)
+	Username *string
+	// Bucket the start of the time bucket of the records in the group
+	Bucket *time.Time
+	// Count the number of records in the group
+	Count int
+}
+
+// Aggregate counts the audit log records matching the given filter, by group. The (at most `limit`) groups are sorted
+// by time bucket if the records are grouped by interval, then from the largest to the smallest. The sort order of
+// the filter is ignored.
+// returns a BadParameterError if the filter, the grouping or the limit is invalid, or an InternalError if something
+// wrong happened while querying the database
+func (r *GormAuditLogRepository) Aggregate(ctx context.Context, filter Filter, grouping Grouping, limit int) ([]Stat, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "aggregate"}, time.Now())
+	if limit <= 0 {
+		return nil, errors.NewBadParameterError("limit", limit)
+	}
+	db, err := filter.apply(r.db.Model(&AuditLog{}))
+	if err != nil {
+		return nil, err
+	}
+	columns := []string{}
+	groups := []string{}
+	orders := []string{}
+	switch grouping.Interval {
+	case "":
+	case IntervalHour, IntervalDay, IntervalWeek, IntervalMonth:
+		// the buckets start at midnight UTC, whatever the timezone of the database session
+		columns = append(columns, fmt.Sprintf("date_trunc('%s', created_at at time zone 'UTC') at time zone 'UTC' as bucket", grouping.Interval))
+		groups = append(groups, "bucket")
+		orders = append(orders, "bucket")
+	default:
+		return nil, errors.NewBadParameterError("interval", grouping.Interval)
+	}
+	if grouping.EventType {
+		columns = append(columns, "event_type_id")
+		groups = append(groups, "event_type_id")
+	}
+	if grouping.Username {
+		columns = append(columns, "username")
+		groups = append(groups, "username")
+	}
+	columns = append(columns, "count(*) as count")
+	// the groups with the same count are sorted by event type and username, so that the order is stable
+	orders = append(orders, "count desc")
+	for _, g := range groups {
+		if g != "bucket" {
+			orders = append(orders, g)
+		}
+	}
+	db = db.Select(strings.Join(columns, ", "))
+	if len(groups) > 0 {
+		db = db.Group(strings.Join(groups, ", "))
+	}
+	rows, err := db.Order(strings.Join(orders, ", ")).Limit(limit).Rows()
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	defer closeable.Close(ctx, rows)
+	result := []Stat{}
+	for rows.Next() {
+		var bucket time.Time
+		var eventTypeID uuid.UUID
+		var username sql.NullString
+		stat := Stat{}
+		dest := []interface{}{}
+		if grouping.Interval != "" {
+			dest = append(dest, &bucket)
+		}
+		if grouping.EventType {
+			dest = append(dest, &eventTypeID)
+		}
+		if grouping.Username {
+			dest = append(dest, &username)
+		}
+		dest = append(dest, &stat.Count)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		if grouping.Interval != "" {
+			bucket = bucket.UTC()
+			stat.Bucket = &bucket
+		}
+		if grouping.EventType {
+			stat.EventTypeID = &eventTypeID
+		}
+		if grouping.Username && username.Valid {
+			stat.Username = &username.String
+		}
+		result = append(result, stat)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return result, nil
+}
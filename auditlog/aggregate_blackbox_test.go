@@ -0,0 +1,137 @@
+package auditlog_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *RepositoryBlackboxTestSuite) TestAggregate() {
+	// given
+	before := time.Now().Add(-time.Second)
+	prefix := fmt.Sprintf("user-agg-%s-", uuid.NewV4())
+	alice := prefix + "alice"
+	bob := prefix + "bob"
+	var last *auditlog.AuditLog
+	for _, r := range []struct {
+		username    string
+		eventTypeID uuid.UUID
+	}{
+		{alice, auditlog.UserSearch},
+		{alice, auditlog.StartTenantUpdate},
+		{bob, auditlog.UserSearch},
+		{alice, auditlog.UserSearch},
+	} {
+		last = &auditlog.AuditLog{
+			Username:    r.username,
+			EventTypeID: r.eventTypeID,
+			EventParams: auditlog.EventParams{},
+		}
+		err := s.repo.Create(context.Background(), last)
+		require.NoError(s.T(), err)
+	}
+	filter := auditlog.Filter{
+		UsernamePrefix: prefix,
+		CreatedAfter:   &before,
+	}
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		t.Run("total", func(t *testing.T) {
+			// when
+			stats, err := s.repo.Aggregate(context.Background(), filter, auditlog.Grouping{}, 10)
+			// then
+			require.NoError(t, err)
+			require.Len(t, stats, 1)
+			assert.Equal(t, 4, stats[0].Count)
+			assert.Nil(t, stats[0].EventTypeID)
+			assert.Nil(t, stats[0].Username)
+			assert.Nil(t, stats[0].Bucket)
+		})
+
+		t.Run("by event type", func(t *testing.T) {
+			// when
+			stats, err := s.repo.Aggregate(context.Background(), filter, auditlog.Grouping{EventType: true}, 10)
+			// then
+			require.NoError(t, err)
+			require.Len(t, stats, 2)
+			assert.Equal(t, auditlog.UserSearch, *stats[0].EventTypeID)
+			assert.Equal(t, 3, stats[0].Count)
+			assert.Equal(t, auditlog.StartTenantUpdate, *stats[1].EventTypeID)
+			assert.Equal(t, 1, stats[1].Count)
+		})
+
+		t.Run("by event type and username", func(t *testing.T) {
+			// when
+			stats, err := s.repo.Aggregate(context.Background(), filter, auditlog.Grouping{EventType: true, Username: true}, 10)
+			// then
+			require.NoError(t, err)
+			require.Len(t, stats, 3)
+			assert.Equal(t, auditlog.UserSearch, *stats[0].EventTypeID)
+			assert.Equal(t, alice, *stats[0].Username)
+			assert.Equal(t, 2, stats[0].Count)
+		})
+
+		t.Run("by username with limit", func(t *testing.T) {
+			// when
+			stats, err := s.repo.Aggregate(context.Background(), filter, auditlog.Grouping{Username: true}, 1)
+			// then
+			require.NoError(t, err)
+			require.Len(t, stats, 1)
+			assert.Equal(t, alice, *stats[0].Username)
+			assert.Equal(t, 3, stats[0].Count)
+		})
+
+		t.Run("by day", func(t *testing.T) {
+			// when
+			stats, err := s.repo.Aggregate(context.Background(), filter, auditlog.Grouping{Interval: auditlog.IntervalDay}, 10)
+			// then
+			require.NoError(t, err)
+			require.NotEmpty(t, stats) // records may have been created on both sides of midnight
+			y, m, d := last.CreatedAt.UTC().Date()
+			lastBucket := stats[len(stats)-1]
+			require.NotNil(t, lastBucket.Bucket)
+			assert.True(t, time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Equal(*lastBucket.Bucket))
+		})
+
+		t.Run("by event type filtered", func(t *testing.T) {
+			// given
+			f := filter
+			f.EventTypes = []string{auditlog.StartTenantUpdateEvent}
+			// when
+			stats, err := s.repo.Aggregate(context.Background(), f, auditlog.Grouping{Username: true}, 10)
+			// then
+			require.NoError(t, err)
+			require.Len(t, stats, 1)
+			assert.Equal(t, alice, *stats[0].Username)
+			assert.Equal(t, 1, stats[0].Count)
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("invalid interval", func(t *testing.T) {
+			// when
+			_, err := s.repo.Aggregate(context.Background(), filter, auditlog.Grouping{Interval: "year"}, 10)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("invalid limit", func(t *testing.T) {
+			// when
+			_, err := s.repo.Aggregate(context.Background(), filter, auditlog.Grouping{}, 0)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+	})
+}
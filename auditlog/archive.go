@@ -0,0 +1,167 @@
+package auditlog
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/admin-console/gormutil"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// The audit log records which expired are archived and then deleted. In order to keep the chain of records verifiable,
+// a tombstone with the metadata and hashes of each deleted record is kept in the `audit_log_archived` table, along with
+// the name of the archive in which the record was stored.
+
+const (
+	archivedTableName = "audit_log_archived"
+)
+
+// archivedRecord the tombstone of an audit log record which was archived and deleted
+type archivedRecord struct {
+	ID          uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:audit_log_id"`
+	CreatedAt   time.Time
+	EventTypeID uuid.UUID `sql:"type:uuid"`
+	// ChainSeq the position of the record in the chain, or nil if the record was created before the chaining was introduced
+	ChainSeq *int64
+	PrevHash string
+	Hash     string
+	// Archive the name of the archive in which the record was stored
+	Archive    string
+	ArchivedAt time.Time
+}
+
+// TableName implements gorm.tabler
+func (r archivedRecord) TableName() string {
+	return archivedTableName
+}
+
+// ArchiveFunc stores the given records durably (eg: in a file) and returns the name of the archive in which they were stored
+type ArchiveFunc func(records []AuditLog) (string, error)
+
+// ArchiveExpired archives and deletes at most `limit` audit log records of the given event type which were created before the given date,
+// oldest first. The records are passed to the given `archive` function and they are deleted (along with their idempotency keys)
+// only if this latter succeeded.
+// The records are locked until they are deleted, and locked records are skipped, so concurrent calls do not archive the same records.
+// returns the number of archived records, a BadParameterError if the `limit` is invalid (negative), the error returned by
+// the `archive` function, or an InternalError if something wrong happened while qyerying or updating the database
+func (r *GormAuditLogRepository) ArchiveExpired(ctx context.Context, eventTypeID uuid.UUID, before time.Time, limit int, archive ArchiveFunc) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "archive_expired"}, time.Now())
+	if limit <= 0 {
+		return 0, errors.NewBadParameterError("limit", limit)
+	}
+	count := 0
+	err := gormutil.InTransaction(r.db, func(tx *gorm.DB) error {
+		records := []AuditLog{}
+		err := tx.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+			Where("event_type_id = ? and created_at < ?", eventTypeID, before).
+			Order("created_at, audit_log_id").
+			Limit(limit).
+			Find(&records).Error
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		name, err := archive(records)
+		if err != nil {
+			return err
+		}
+		ids := make([]uuid.UUID, len(records))
+		archivedAt := time.Now()
+		for i, record := range records {
+			tombstone := archivedRecord{
+				ID:          record.ID,
+				CreatedAt:   record.CreatedAt,
+				EventTypeID: record.EventTypeID,
+				PrevHash:    record.PrevHash,
+				Hash:        record.Hash,
+				Archive:     name,
+				ArchivedAt:  archivedAt,
+			}
+			if record.ChainSeq > 0 {
+				seq := record.ChainSeq
+				tombstone.ChainSeq = &seq
+			}
+			if err := tx.Create(&tombstone).Error; err != nil {
+				return errors.NewInternalError(ctx, err)
+			}
+			ids[i] = record.ID
+		}
+		if err := deleteRecords(tx, records); err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		if err := deleteIdempotencyKeys(ctx, tx, ids); err != nil {
+			return err
+		}
+		count = len(records)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// verifyArchived verifies the links between the archived records whose sequence number is in [from, to], given the hash
+// of the record before `from` (nil if unknown). The content of these records is not verified, since it is no longer in the database.
+// returns the hash of the last archived record, or the first broken link, or an InternalError if something wrong happened
+// while qyerying or reading the returned rows
+func (r *GormAuditLogRepository) verifyArchived(ctx context.Context, from, to int64, prevHash *string) (*string, *ChainBreak, error) {
+	tombstones := []archivedRecord{}
+	err := r.db.Where("chain_seq >= ? and chain_seq <= ?", from, to).Order("chain_seq").Find(&tombstones).Error
+	if err != nil {
+		return nil, nil, errors.NewInternalError(ctx, err)
+	}
+	expectedSeq := from
+	for _, t := range tombstones {
+		id := t.ID
+		if *t.ChainSeq != expectedSeq {
+			break
+		}
+		if prevHash != nil && t.PrevHash != *prevHash {
+			return nil, &ChainBreak{
+				Seq:    expectedSeq,
+				ID:     &id,
+				Reason: "previous hash of the archived record does not match the hash of the previous record",
+			}, nil
+		}
+		hash := t.Hash
+		prevHash = &hash
+		expectedSeq++
+	}
+	if expectedSeq <= to {
+		return nil, &ChainBreak{
+			Seq:    expectedSeq,
+			Reason: "record is missing (and was not archived)",
+		}, nil
+	}
+	return prevHash, nil, nil
+}
+
+// deleteRecords deletes the given records, which are matched on their primary key, ie, on both their ID and their
+// creation date (which also lets the database only look for them in their partition)
+func deleteRecords(tx *gorm.DB, records []AuditLog) error {
+	for start := 0; start < len(records); start += insertBatchSize {
+		end := start + insertBatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*2)
+		for _, r := range records[start:end] {
+			values = append(values, "(?::uuid, ?::timestamptz)")
+			args = append(args, r.ID, r.CreatedAt)
+		}
+		err := tx.Exec("delete from "+recordTableName+" where (audit_log_id, created_at) in ("+strings.Join(values, ", ")+")", args...).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"time"
 
+	"github.com/fabric8-services/admin-console/gormutil"
 	"github.com/fabric8-services/fabric8-common/closeable"
 	"github.com/fabric8-services/fabric8-common/convert"
 	"github.com/fabric8-services/fabric8-common/errors"
@@ -21,12 +22,32 @@ import (
 
 // AuditLog an audit log record to track usage of the service
 type AuditLog struct {
+	// ID the ID of the record, which is a random UUID. The primary key of the (partitioned) table is the ID and the
+	// creation date, so the uniqueness of the ID alone is not enforced by the database.
 	ID          uuid.UUID   `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:audit_log_id"`
 	CreatedAt   time.Time   `json:"created_at,omitempty"`
 	IdentityID  uuid.UUID   `sql:"type:uuid"`
 	Username    string      `sql:"type:string"`
 	EventTypeID uuid.UUID   `sql:"type:uuid"`
 	EventParams EventParams `sql:"type:jsonb"`
+	// ChainSeq the position of the record in the chain of records
+	ChainSeq int64
+	// PrevHash the hash of the previous record in the chain
+	PrevHash string
+	// Hash the hash of this record, computed over its content and the hash of the previous record
+	Hash string
+	// StatusCode the HTTP status code of the response to the audited action, if it completed (not covered by the hash)
+	StatusCode *int
+	// Outcome the outcome of the audited action, if it completed (not covered by the hash)
+	Outcome Outcome
+	// LatencyMs the time it took to perform the audited action, in milliseconds, if it completed (not covered by the hash)
+	LatencyMs *int64
+	// ResponseSize the size of the response body to the audited action, in bytes, if it completed (not covered by the hash)
+	ResponseSize *int64
+	// ResultCount the number of results returned by the audited action, if relevant and if it completed (not covered by the hash)
+	ResultCount *int
+	// IdempotencyKey the optional key provided by the client, so that the record is not stored twice when the request is retried
+	IdempotencyKey string `gorm:"-"`
 }
 
 const (
@@ -105,12 +126,37 @@ func fromBytes(src interface{}, target interface{}) error {
 	return json.Unmarshal(s, target)
 }
 
+// BatchItemResult the outcome of the storage of a record of a batch
+type BatchItemResult struct {
+	// Created true if the record was stored, false if it was already stored with the same idempotency key or rejected
+	Created bool
+	// Conflict the DataConflictError which prevented the record from being stored, if its idempotency key was already
+	// used with a different payload
+	Conflict error
+}
+
 // Repository provides functions to create and view audit logs
 type Repository interface {
 	Create(ctx context.Context, auditLog *AuditLog) error
+	CreateBatch(ctx context.Context, auditLogs []*AuditLog) ([]BatchItemResult, error)
+	ReplayBatch(ctx context.Context, auditLogs []*AuditLog) ([]BatchItemResult, error)
+	RecordOutcome(ctx context.Context, auditLog *AuditLog, result ActionResult) error
 	LoadByID(ctx context.Context, id uuid.UUID) (AuditLog, error)
 	ListByIdentityID(ctx context.Context, identityID uuid.UUID, start int, limit int) ([]AuditLog, int, error)
 	ListByUsername(ctx context.Context, username string, start int, limit int) ([]AuditLog, int, error)
+	ListByUsernameAfter(ctx context.Context, username string, after *Cursor, limit int) ([]AuditLog, *Cursor, error)
+	CountByUsername(ctx context.Context, username string) (int, error)
+	ForEachByUsername(ctx context.Context, username string, fn func(AuditLog) error) error
+	EventParamKeysByUsername(ctx context.Context, username string) ([]string, error)
+	List(ctx context.Context, filter Filter, start int, limit int) ([]AuditLog, int, error)
+	VerifyChain(ctx context.Context, from int64) (*ChainBreak, error)
+	ArchiveExpired(ctx context.Context, eventTypeID uuid.UUID, before time.Time, limit int, archive ArchiveFunc) (int, error)
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error)
+	PublishPending(ctx context.Context, limit int, lease time.Duration, publish PublishFunc) (int, error)
+	CountPending(ctx context.Context) (int, error)
+	LastSeq(ctx context.Context) (int64, error)
+	ListAfterSeq(ctx context.Context, filter Filter, after int64, limit int) ([]AuditLog, error)
+	Aggregate(ctx context.Context, filter Filter, grouping Grouping, limit int) ([]Stat, error)
 }
 
 // NewRepository creates a GormRecordRepository
@@ -126,10 +172,108 @@ type GormAuditLogRepository struct {
 	db *gorm.DB
 }
 
-// Create stores the given auditLog
+// Create stores the given auditLog at the end of the chain of records, unless its idempotency key was already used:
+// in that case, its ID and creation date are set to those of the original record instead, or a DataConflictError is
+// returned if the original record had a different payload.
 func (r *GormAuditLogRepository) Create(ctx context.Context, auditLog *AuditLog) error {
 	defer goa.MeasureSince([]string{"goa", "db", "auditLog", "create"}, time.Now())
-	// check values
+	results, err := r.CreateBatch(ctx, []*AuditLog{auditLog})
+	if err != nil {
+		return err
+	}
+	return results[0].Conflict
+}
+
+// CreateBatch stores the given records at the end of the chain of records, along with their outbox entries, in a single transaction.
+// The creation date of the records is always the current time.
+// A record whose idempotency key was already used (by a previous record or by a previous entry of the batch)
+// is not stored again: its ID and creation date are set to those of the original record instead, unless its payload
+// differs from the original one, in which case it is rejected with a DataConflictError while the other records are stored.
+// returns the outcome of each record, a BadParameterError if a record is invalid, or an InternalError if something
+// wrong happened
+func (r *GormAuditLogRepository) CreateBatch(ctx context.Context, auditLogs []*AuditLog) ([]BatchItemResult, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLog", "create_batch"}, time.Now())
+	return r.createBatch(ctx, auditLogs, false)
+}
+
+// ReplayBatch stores the given records like `CreateBatch`, except that they keep their creation date (unless it is
+// missing or in the future). It is meant for the records which were created by the admin console itself but could not be
+// stored at that time, such as the records replayed from the spool, and must never be used with a date set by a client.
+// returns the outcome of each record, a BadParameterError if a record is invalid, or an InternalError if something
+// wrong happened
+func (r *GormAuditLogRepository) ReplayBatch(ctx context.Context, auditLogs []*AuditLog) ([]BatchItemResult, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLog", "replay_batch"}, time.Now())
+	return r.createBatch(ctx, auditLogs, true)
+}
+
+// createBatch stores the given records (see `CreateBatch`), and keeps their creation date if `keepCreatedAt` is true
+func (r *GormAuditLogRepository) createBatch(ctx context.Context, auditLogs []*AuditLog, keepCreatedAt bool) ([]BatchItemResult, error) {
+	for _, auditLog := range auditLogs {
+		if err := validate(auditLog); err != nil {
+			return nil, err
+		}
+	}
+	results := make([]BatchItemResult, len(auditLogs))
+	err := gormutil.InTransaction(r.db, func(tx *gorm.DB) error {
+		// lock the head of the chain first, so that concurrent requests with the same idempotency keys are serialized
+		if _, _, err := lockChainHead(ctx, tx); err != nil {
+			return err
+		}
+		originals, err := loadIdempotencyKeys(ctx, tx, auditLogs)
+		if err != nil {
+			return err
+		}
+		records := make([]*AuditLog, 0, len(auditLogs))
+		replays := map[int]*AuditLog{}
+		for i, auditLog := range auditLogs {
+			if auditLog.IdempotencyKey != "" {
+				if original, found := originals[auditLog.IdempotencyKey]; found {
+					if err := checkReplay(ctx, auditLog, original); err != nil {
+						if _, ok := err.(errors.DataConflictError); !ok {
+							return err
+						}
+						results[i].Conflict = err
+						continue
+					}
+					replays[i] = original.record
+					continue
+				}
+				// subsequent entries with the same key are replays of this one
+				hash, err := payloadHash(auditLog)
+				if err != nil {
+					return errors.NewInternalError(ctx, err)
+				}
+				originals[auditLog.IdempotencyKey] = idempotencyKey{
+					record:      auditLog,
+					payloadHash: hash,
+				}
+			}
+			records = append(records, auditLog)
+			results[i].Created = true
+		}
+		if len(records) > 0 {
+			if err := appendToChain(ctx, tx, keepCreatedAt, records...); err != nil {
+				return err
+			}
+			if err := storeOutboxEntries(ctx, tx, records); err != nil {
+				return err
+			}
+		}
+		// the ID and creation date of the records of this batch are only known once they were appended to the chain
+		for i, original := range replays {
+			auditLogs[i].ID = original.ID
+			auditLogs[i].CreatedAt = original.CreatedAt
+		}
+		return storeIdempotencyKeys(ctx, tx, records)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// validate checks that the given record can be stored
+func validate(auditLog *AuditLog) error {
 	if auditLog == nil {
 		return errors.NewBadParameterErrorFromString("missing audit log auditLog to persist")
 	}
@@ -139,10 +283,6 @@ func (r *GormAuditLogRepository) Create(ctx context.Context, auditLog *AuditLog)
 	if auditLog.IdentityID == uuid.Nil && auditLog.Username == "" {
 		return errors.NewBadParameterErrorFromString("identity_id and username cannot be both missing at the same time")
 	}
-	db := r.db.Create(auditLog)
-	if err := db.Error; err != nil {
-		return errors.NewInternalError(ctx, err)
-	}
 	return nil
 }
 
@@ -170,75 +310,124 @@ func (r *GormAuditLogRepository) LoadByID(ctx context.Context, id uuid.UUID) (Au
 func (r *GormAuditLogRepository) ListByIdentityID(ctx context.Context, identityID uuid.UUID, start int, limit int) ([]AuditLog, int, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "list_by_identity_id"}, time.Now())
 	db := r.db.Model(&AuditLog{}).Where("identity_id = ?", identityID)
-	unboundedDB := db
-	if start < 0 {
-		return nil, 0, errors.NewBadParameterError("start", start)
-	}
-	db = db.Offset(start)
+	return r.list(ctx, db, "created_at, audit_log_id", start, limit)
+}
+
+// ListByUsername returns audit log records that belong to a user (given her username), as well as the total number of records
+// returns BadParameterError if the `start` or `limit` are invalid (negative) or InternalError an error if something wrong happened
+// while qyerying or reading the returned rows
+func (r *GormAuditLogRepository) ListByUsername(ctx context.Context, username string, start int, limit int) ([]AuditLog, int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "list_by_username"}, time.Now())
+	db := r.db.Model(&AuditLog{}).Where("username = ?", username)
+	return r.list(ctx, db, "created_at, audit_log_id", start, limit)
+}
+
+// ListByUsernameAfter returns at most `limit` audit log records that belong to a user (given her username), sorted by creation date and ID,
+// starting after the given cursor (or from the first record if the cursor is nil). Also returns the cursor to the next records, or nil
+// if there is no more record to list.
+// Unlike `ListByUsername`, this function does not count the total number of records, and relies on the (username, created_at, audit_log_id)
+// index to skip the previous records, so it can be used to efficiently browse large histories.
+// returns BadParameterError if the `limit` is invalid (negative) or InternalError an error if something wrong happened
+// while qyerying or reading the returned rows
+func (r *GormAuditLogRepository) ListByUsernameAfter(ctx context.Context, username string, after *Cursor, limit int) ([]AuditLog, *Cursor, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "list_by_username_after"}, time.Now())
 	if limit <= 0 {
-		return nil, 0, errors.NewBadParameterError("limit", limit)
-	}
-	db = db.Limit(limit)
-	db = db.Order("created_at")
-	db = db.Select("count(*) over () as cnt2 , *")
-	if err := db.Error; err != nil {
-		return []AuditLog{}, 0, errors.NewInternalError(ctx, err)
+		return nil, nil, errors.NewBadParameterError("limit", limit)
 	}
-	rows, err := db.Rows()
-	defer closeable.Close(ctx, rows)
-	if err != nil {
-		return []AuditLog{}, 0, errors.NewInternalError(ctx, err)
+	db := r.db.Model(&AuditLog{}).Where("username = ?", username)
+	if after != nil {
+		db = db.Where("(created_at, audit_log_id) > (?, ?)", after.CreatedAt, after.ID)
 	}
-
 	result := []AuditLog{}
-	columns, err := rows.Columns()
+	// fetch an extra record to find out if there is a next page
+	err := db.Order("created_at, audit_log_id").Limit(limit + 1).Find(&result).Error
 	if err != nil {
-		return nil, 0, errors.NewInternalError(ctx, err)
+		return nil, nil, errors.NewInternalError(ctx, err)
+	}
+	if len(result) <= limit {
+		return result, nil, nil
 	}
+	result = result[:limit]
+	next := NewCursor(result[limit-1])
+	return result, &next, nil
+}
 
-	// need to set up a result for Scan() in order to extract total count.
+// CountByUsername returns the total number of audit log records that belong to a user (given her username)
+// returns InternalError an error if something wrong happened while qyerying the database
+func (r *GormAuditLogRepository) CountByUsername(ctx context.Context, username string) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "count_by_username"}, time.Now())
 	var count int
-	var ignore interface{}
-	columnValues := make([]interface{}, len(columns))
-
-	for index := range columnValues {
-		columnValues[index] = &ignore
+	err := r.db.Model(&AuditLog{}).Where("username = ?", username).Count(&count).Error
+	if err != nil {
+		return 0, errors.NewInternalError(ctx, err)
 	}
-	columnValues[0] = &count
-	first := true
+	return count, nil
+}
 
+// ForEachByUsername calls the given function on each audit log record that belongs to a user (given her username), sorted by creation date.
+// The records are read one at a time from the database, so they are never all loaded in memory.
+// Stops at the first error returned by the function, and returns it.
+// returns InternalError an error if something wrong happened while qyerying or reading the returned rows
+func (r *GormAuditLogRepository) ForEachByUsername(ctx context.Context, username string, fn func(AuditLog) error) error {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "for_each_by_username"}, time.Now())
+	rows, err := r.db.Model(&AuditLog{}).Where("username = ?", username).Order("created_at, audit_log_id").Rows()
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	defer closeable.Close(ctx, rows)
 	for rows.Next() {
-		value := AuditLog{}
-		db.ScanRows(rows, &value)
-		if first {
-			first = false
-			if err = rows.Scan(columnValues...); err != nil {
-				return nil, 0, errors.NewInternalError(ctx, err)
-			}
+		record := AuditLog{}
+		if err := r.db.ScanRows(rows, &record); err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		if err := fn(record); err != nil {
+			return err
 		}
-		result = append(result, value)
 	}
-	if first {
-		// means 0 rows were returned from the first query (maybe becaus of offset outside of total count),
-		// need to do a count(*) to find out total
-		orgDB := unboundedDB.Select("count(*)")
-		rows2, err := orgDB.Rows()
-		defer closeable.Close(ctx, rows2)
-		if err != nil {
-			return nil, 0, errors.NewInternalError(ctx, err)
+	if err := rows.Err(); err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// EventParamKeysByUsername returns the (sorted) names of all the event params found in the audit log records that belong to a user (given her username)
+// returns InternalError an error if something wrong happened while qyerying or reading the returned rows
+func (r *GormAuditLogRepository) EventParamKeysByUsername(ctx context.Context, username string) ([]string, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "event_param_keys_by_username"}, time.Now())
+	rows, err := r.db.Raw(`select distinct jsonb_object_keys(event_params) as k from audit_log
+		where username = ? and jsonb_typeof(event_params) = 'object' order by k`, username).Rows()
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	defer closeable.Close(ctx, rows)
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, errors.NewInternalError(ctx, err)
 		}
-		rows2.Next() // count(*) will always return a row
-		rows2.Scan(&count)
+		keys = append(keys, key)
 	}
-	return result, count, nil
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return keys, nil
 }
 
-// ListByUsername returns audit log records that belong to a user (given her username), as well as the total number of records
-// returns BadParameterError if the `start` or `limit` are invalid (negative) or InternalError an error if something wrong happened
+// List returns audit log records of all users that match the given filter, as well as the total number of matching records
+// returns BadParameterError if the filter, `start` or `limit` are invalid or InternalError an error if something wrong happened
 // while qyerying or reading the returned rows
-func (r *GormAuditLogRepository) ListByUsername(ctx context.Context, username string, start int, limit int) ([]AuditLog, int, error) {
-	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "list_by_username"}, time.Now())
-	db := r.db.Model(&AuditLog{}).Where("username = ?", username)
+func (r *GormAuditLogRepository) List(ctx context.Context, filter Filter, start int, limit int) ([]AuditLog, int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "list"}, time.Now())
+	db, err := filter.apply(r.db.Model(&AuditLog{}))
+	if err != nil {
+		return nil, 0, err
+	}
+	return r.list(ctx, db, filter.orderBy(), start, limit)
+}
+
+// list returns a page of the audit log records matched by the given (filtered) query, along with the total number of matching records
+func (r *GormAuditLogRepository) list(ctx context.Context, db *gorm.DB, order string, start int, limit int) ([]AuditLog, int, error) {
 	unboundedDB := db
 	if start < 0 {
 		return nil, 0, errors.NewBadParameterError("start", start)
@@ -248,7 +437,7 @@ func (r *GormAuditLogRepository) ListByUsername(ctx context.Context, username st
 		return nil, 0, errors.NewBadParameterError("limit", limit)
 	}
 	db = db.Limit(limit)
-	db = db.Order("created_at")
+	db = db.Order(order)
 	db = db.Select("count(*) over () as cnt2 , *")
 	if err := db.Error; err != nil {
 		return []AuditLog{}, 0, errors.NewInternalError(ctx, err)
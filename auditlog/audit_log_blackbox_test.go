@@ -13,6 +13,7 @@ import (
 	"github.com/fabric8-services/fabric8-common/resource"
 	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
 
+	"github.com/jinzhu/gorm"
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -41,7 +42,7 @@ func (s *RepositoryBlackboxTestSuite) TestCreateRecord() {
 
 		s.T().Run("with identity_id and username", func(t *testing.T) {
 			// given
-			before := time.Now()
+			before := time.Now().Truncate(time.Microsecond) // creation date is stored with microsecond precision
 			auditLog := auditlog.AuditLog{
 				EventTypeID: auditlog.UserSearch,
 				IdentityID:  uuid.NewV4(),
@@ -58,7 +59,7 @@ func (s *RepositoryBlackboxTestSuite) TestCreateRecord() {
 
 		s.T().Run("with username only", func(t *testing.T) {
 			// given
-			before := time.Now()
+			before := time.Now().Truncate(time.Microsecond) // creation date is stored with microsecond precision
 			auditLog := auditlog.AuditLog{
 				EventTypeID: auditlog.UserSearch,
 				Username:    "foo",
@@ -74,7 +75,7 @@ func (s *RepositoryBlackboxTestSuite) TestCreateRecord() {
 
 		s.T().Run("with identity_id only", func(t *testing.T) {
 			// given
-			before := time.Now()
+			before := time.Now().Truncate(time.Microsecond) // creation date is stored with microsecond precision
 			auditLog := auditlog.AuditLog{
 				EventTypeID: auditlog.UserSearch,
 				IdentityID:  uuid.NewV4(),
@@ -87,6 +88,22 @@ func (s *RepositoryBlackboxTestSuite) TestCreateRecord() {
 			assert.NotEqual(t, uuid.NullUUID{}, auditLog.ID)
 			assert.True(t, auditLog.CreatedAt.After(before)) // "is after before". hahahaha....
 		})
+
+		s.T().Run("with a creation date", func(t *testing.T) {
+			// given
+			before := time.Now().Truncate(time.Microsecond) // creation date is stored with microsecond precision
+			auditLog := auditlog.AuditLog{
+				EventTypeID: auditlog.UserSearch,
+				Username:    "foo",
+				EventParams: auditlog.EventParams{},
+				CreatedAt:   time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC),
+			}
+			// when
+			err := s.repo.Create(context.Background(), &auditLog)
+			// then the creation date is the current time
+			require.NoError(t, err)
+			assert.True(t, auditLog.CreatedAt.After(before))
+		})
 	})
 
 	s.T().Run("failure", func(t *testing.T) {
@@ -122,6 +139,146 @@ func (s *RepositoryBlackboxTestSuite) TestCreateRecord() {
 	})
 }
 
+func (s *RepositoryBlackboxTestSuite) TestCreateBatch() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		key := uuid.NewV4().String()
+		newBatch := func() []*auditlog.AuditLog {
+			return []*auditlog.AuditLog{
+				{
+					EventTypeID:    auditlog.UserDeactivationNotification,
+					Username:       "foo",
+					IdempotencyKey: key,
+				},
+				{
+					EventTypeID: auditlog.UserDeactivation,
+					Username:    "bar",
+				},
+				{
+					// same key as the first record
+					EventTypeID:    auditlog.UserDeactivationNotification,
+					Username:       "foo",
+					IdempotencyKey: key,
+				},
+			}
+		}
+		batch := newBatch()
+		// when
+		created, err := s.repo.CreateBatch(context.Background(), batch)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []auditlog.BatchItemResult{{Created: true}, {Created: true}, {Created: false}}, created)
+		assert.NotEqual(t, batch[0].ID, batch[1].ID)
+		assert.Equal(t, batch[0].ID, batch[2].ID)
+		for _, r := range batch[:2] {
+			_, err := s.repo.LoadByID(context.Background(), r.ID)
+			require.NoError(t, err)
+		}
+		brk, err := s.repo.VerifyChain(context.Background(), batch[0].ChainSeq-1)
+		require.NoError(t, err)
+		assert.Nil(t, brk)
+		// also verify that the created records were written in the outbox
+		for _, r := range batch[:2] {
+			assertOutboxEntries(t, s.DB, r.ID, 1)
+		}
+
+		t.Run("retry", func(t *testing.T) {
+			// given
+			retry := newBatch()
+			// when
+			created, err := s.repo.CreateBatch(context.Background(), retry)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []auditlog.BatchItemResult{{Created: false}, {Created: true}, {Created: false}}, created)
+			assert.Equal(t, batch[0].ID, retry[0].ID)
+			assert.True(t, batch[0].CreatedAt.Equal(retry[0].CreatedAt))
+			assert.NotEqual(t, batch[1].ID, retry[1].ID) // no idempotency key
+			assert.Equal(t, batch[0].ID, retry[2].ID)
+			assertOutboxEntries(t, s.DB, batch[0].ID, 1) // not written again
+			assertOutboxEntries(t, s.DB, retry[1].ID, 1)
+		})
+	})
+
+	s.T().Run("idempotency key reused with a different payload", func(t *testing.T) {
+		// given
+		key := uuid.NewV4().String()
+		err := s.repo.Create(context.Background(), &auditlog.AuditLog{
+			EventTypeID:    auditlog.UserDeactivation,
+			Username:       "foo",
+			EventParams:    auditlog.EventParams{"count": 1},
+			IdempotencyKey: key,
+		})
+		require.NoError(t, err)
+		batch := []*auditlog.AuditLog{
+			{
+				EventTypeID: auditlog.UserDeactivation,
+				Username:    "bar",
+			},
+			{
+				EventTypeID:    auditlog.UserDeactivation,
+				Username:       "foo",
+				EventParams:    auditlog.EventParams{"count": 2},
+				IdempotencyKey: key,
+			},
+		}
+		// when
+		results, err := s.repo.CreateBatch(context.Background(), batch)
+		// then
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.True(t, results[0].Created)
+		assert.False(t, results[1].Created)
+		require.Error(t, results[1].Conflict)
+		assert.IsType(t, errors.DataConflictError{}, results[1].Conflict)
+		// only the other record was recorded
+		_, err = s.repo.LoadByID(context.Background(), batch[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, uuid.Nil, batch[1].ID)
+
+		t.Run("same payload", func(t *testing.T) {
+			// given
+			retry := &auditlog.AuditLog{
+				EventTypeID:    auditlog.UserDeactivation,
+				Username:       "foo",
+				EventParams:    auditlog.EventParams{"count": 1},
+				IdempotencyKey: key,
+			}
+			// when
+			created, err := s.repo.CreateBatch(context.Background(), []*auditlog.AuditLog{retry})
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []auditlog.BatchItemResult{{Created: false}}, created)
+		})
+	})
+
+	s.T().Run("failure", func(t *testing.T) {
+		// given
+		batch := []*auditlog.AuditLog{
+			{
+				EventTypeID: auditlog.UserDeactivation,
+				Username:    "foo",
+			},
+			{
+				Username: "bar",
+			},
+		}
+		// when
+		_, err := s.repo.CreateBatch(context.Background(), batch)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, err)
+		assert.Equal(t, uuid.Nil, batch[0].ID) // nothing was recorded
+	})
+}
+
+func assertOutboxEntries(t *testing.T, db *gorm.DB, auditLogID uuid.UUID, expected int) {
+	entries := []auditlog.OutboxEntry{}
+	err := db.Where("audit_log_id = ?", auditLogID).Find(&entries).Error
+	require.NoError(t, err)
+	assert.Len(t, entries, expected)
+}
+
 func (s *RepositoryBlackboxTestSuite) TestLoadByID() {
 
 	s.T().Run("ok", func(t *testing.T) {
@@ -153,6 +310,119 @@ func (s *RepositoryBlackboxTestSuite) TestLoadByID() {
 	})
 }
 
+func (s *RepositoryBlackboxTestSuite) TestRecordOutcome() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		auditLog := auditlog.AuditLog{
+			EventTypeID: auditlog.StartTenantUpdate,
+			Username:    fmt.Sprintf("user-%v", uuid.NewV4()),
+		}
+		err := s.repo.Create(context.Background(), &auditLog)
+		require.NoError(t, err)
+		// when
+		err = s.repo.RecordOutcome(context.Background(), &auditLog, auditlog.ActionResult{
+			StatusCode:   409,
+			Latency:      1500 * time.Millisecond,
+			ResponseSize: 42,
+		})
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.LoadByID(context.Background(), auditLog.ID)
+		require.NoError(t, err)
+		require.NotNil(t, result.StatusCode)
+		assert.Equal(t, 409, *result.StatusCode)
+		assert.Equal(t, auditlog.OutcomeError, result.Outcome)
+		require.NotNil(t, result.LatencyMs)
+		assert.Equal(t, int64(1500), *result.LatencyMs)
+		require.NotNil(t, result.ResponseSize)
+		assert.Equal(t, int64(42), *result.ResponseSize)
+		assert.Nil(t, result.ResultCount)
+		// the outcome is not covered by the hash of the record
+		brk, err := s.repo.VerifyChain(context.Background(), auditLog.ChainSeq)
+		require.NoError(t, err)
+		assert.Nil(t, brk)
+	})
+
+	s.T().Run("with result count", func(t *testing.T) {
+		// given
+		auditLog := auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+			Username:    fmt.Sprintf("user-%v", uuid.NewV4()),
+			EventParams: auditlog.EventParams{
+				"query": "foo",
+			},
+		}
+		err := s.repo.Create(context.Background(), &auditLog)
+		require.NoError(t, err)
+		count := 3
+		// when
+		err = s.repo.RecordOutcome(context.Background(), &auditLog, auditlog.ActionResult{
+			StatusCode:   200,
+			Latency:      20 * time.Millisecond,
+			ResponseSize: 1024,
+			ResultCount:  &count,
+		})
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.LoadByID(context.Background(), auditLog.ID)
+		require.NoError(t, err)
+		assert.Equal(t, auditlog.OutcomeSuccess, result.Outcome)
+		require.NotNil(t, result.ResultCount)
+		assert.Equal(t, 3, *result.ResultCount)
+	})
+
+	s.T().Run("set at creation", func(t *testing.T) {
+		// given
+		statusCode := 403
+		auditLog := auditlog.AuditLog{
+			EventTypeID: auditlog.AccessDenied,
+			Username:    fmt.Sprintf("user-%v", uuid.NewV4()),
+			StatusCode:  &statusCode,
+			Outcome:     auditlog.OutcomeDenied,
+		}
+		// when
+		err := s.repo.Create(context.Background(), &auditLog)
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.LoadByID(context.Background(), auditLog.ID)
+		require.NoError(t, err)
+		require.NotNil(t, result.StatusCode)
+		assert.Equal(t, 403, *result.StatusCode)
+		assert.Equal(t, auditlog.OutcomeDenied, result.Outcome)
+		assert.Nil(t, result.LatencyMs)
+		// and the outcome cannot be changed afterwards
+		err = s.repo.RecordOutcome(context.Background(), &auditLog, auditlog.ActionResult{StatusCode: 200, Latency: time.Second})
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	s.T().Run("not completed", func(t *testing.T) {
+		// given
+		auditLog := auditlog.AuditLog{
+			EventTypeID: auditlog.StartTenantUpdate,
+			Username:    fmt.Sprintf("user-%v", uuid.NewV4()),
+		}
+		// when
+		err := s.repo.Create(context.Background(), &auditLog)
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.LoadByID(context.Background(), auditLog.ID)
+		require.NoError(t, err)
+		assert.Nil(t, result.StatusCode)
+		assert.Equal(t, auditlog.Outcome(""), result.Outcome)
+	})
+}
+
+func TestOutcomeOf(t *testing.T) {
+	assert.Equal(t, auditlog.OutcomeSuccess, auditlog.OutcomeOf(200))
+	assert.Equal(t, auditlog.OutcomeSuccess, auditlog.OutcomeOf(202))
+	assert.Equal(t, auditlog.OutcomeDenied, auditlog.OutcomeOf(401))
+	assert.Equal(t, auditlog.OutcomeDenied, auditlog.OutcomeOf(403))
+	assert.Equal(t, auditlog.OutcomeError, auditlog.OutcomeOf(409))
+	assert.Equal(t, auditlog.OutcomeError, auditlog.OutcomeOf(500))
+}
+
 func (s *RepositoryBlackboxTestSuite) TestListByIdentityID() {
 	// given 2 users with 12 auditLogs each
 	identity1 := uuid.NewV4()
@@ -345,3 +615,480 @@ func (s *RepositoryBlackboxTestSuite) TestListByUsername() {
 	})
 
 }
+
+func (s *RepositoryBlackboxTestSuite) TestListByUsernameAfter() {
+	// given a user with 12 auditLogs
+	username := fmt.Sprintf("user-%v", uuid.NewV4())
+	for i := 0; i < 12; i++ {
+		auditLog := auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"idx": i,
+			},
+		}
+		err := s.repo.Create(context.Background(), &auditLog)
+		require.NoError(s.T(), err)
+	}
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		t.Run("all pages of 5", func(t *testing.T) {
+			// when
+			var after *auditlog.Cursor
+			idx := 0
+			pages := 0
+			for {
+				auditLogs, next, err := s.repo.ListByUsernameAfter(context.Background(), username, after, 5)
+				// then
+				require.NoError(t, err)
+				pages++
+				for _, auditLog := range auditLogs {
+					assert.Equal(t, float64(idx), auditLog.EventParams["idx"])
+					idx++
+				}
+				if next == nil {
+					break
+				}
+				// verify that the cursor survives a roundtrip through its token
+				cursor, err := auditlog.ParseCursor(next.String())
+				require.NoError(t, err)
+				assert.Equal(t, next.ID, cursor.ID)
+				assert.True(t, next.CreatedAt.Equal(cursor.CreatedAt))
+				after = &cursor
+			}
+			assert.Equal(t, 12, idx)
+			assert.Equal(t, 3, pages)
+		})
+
+		t.Run("exact page", func(t *testing.T) {
+			// when
+			auditLogs, next, err := s.repo.ListByUsernameAfter(context.Background(), username, nil, 12)
+			// then
+			require.NoError(t, err)
+			assert.Len(t, auditLogs, 12)
+			assert.Nil(t, next)
+		})
+
+		t.Run("count", func(t *testing.T) {
+			// when
+			count, err := s.repo.CountByUsername(context.Background(), username)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 12, count)
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("invalid limit", func(t *testing.T) {
+			// when
+			_, _, err := s.repo.ListByUsernameAfter(context.Background(), username, nil, 0)
+			// then
+			require.Error(t, err)
+			require.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("invalid cursor", func(t *testing.T) {
+			// when
+			_, err := auditlog.ParseCursor("foo")
+			// then
+			require.Error(t, err)
+			require.IsType(t, errors.BadParameterError{}, err)
+		})
+	})
+}
+
+func (s *RepositoryBlackboxTestSuite) TestForEachByUsername() {
+	// given a user with 12 auditLogs
+	username := fmt.Sprintf("user-%v", uuid.NewV4())
+	for i := 0; i < 12; i++ {
+		auditLog := auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"idx":                   i,
+				fmt.Sprintf("p%d", i%3): "foo",
+			},
+		}
+		err := s.repo.Create(context.Background(), &auditLog)
+		require.NoError(s.T(), err)
+	}
+
+	s.T().Run("all records", func(t *testing.T) {
+		// when
+		idx := 0
+		err := s.repo.ForEachByUsername(context.Background(), username, func(r auditlog.AuditLog) error {
+			assert.Equal(t, float64(idx), r.EventParams["idx"])
+			idx++
+			return nil
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 12, idx)
+	})
+
+	s.T().Run("stop on error", func(t *testing.T) {
+		// when
+		count := 0
+		err := s.repo.ForEachByUsername(context.Background(), username, func(r auditlog.AuditLog) error {
+			count++
+			return fmt.Errorf("failure")
+		})
+		// then
+		require.Error(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	s.T().Run("event param keys", func(t *testing.T) {
+		// when
+		keys, err := s.repo.EventParamKeysByUsername(context.Background(), username)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"idx", "p0", "p1", "p2"}, keys)
+	})
+}
+
+func (s *RepositoryBlackboxTestSuite) TestList() {
+	// given 2 users with 3 audit logs each
+	prefix := fmt.Sprintf("user-%v", uuid.NewV4())
+	username1 := prefix + "-1"
+	identity1 := uuid.NewV4()
+	username2 := prefix + "-2"
+	identity2 := uuid.NewV4()
+	before := time.Now()
+	for identity, username := range map[uuid.UUID]string{
+		identity1: username1,
+		identity2: username2,
+	} {
+		for i, eventTypeID := range []uuid.UUID{auditlog.UserSearch, auditlog.StartTenantUpdate, auditlog.StopTenantUpdate} {
+			auditLog := auditlog.AuditLog{
+				EventTypeID: eventTypeID,
+				IdentityID:  identity,
+				Username:    username,
+				EventParams: auditlog.EventParams{
+					"idx":      i,
+					"username": username,
+				},
+			}
+			err := s.repo.Create(context.Background(), &auditLog)
+			require.NoError(s.T(), err)
+		}
+	}
+	after := time.Now()
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		t.Run("by username prefix", func(t *testing.T) {
+			// when
+			auditLogs, count, err := s.repo.List(context.Background(), auditlog.Filter{
+				UsernamePrefix: prefix,
+			}, 0, 10)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 6, count)
+			require.Len(t, auditLogs, 6)
+		})
+
+		t.Run("by username prefix with wildcard chars", func(t *testing.T) {
+			// when
+			auditLogs, count, err := s.repo.List(context.Background(), auditlog.Filter{
+				UsernamePrefix: "%",
+			}, 0, 10)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 0, count)
+			assert.Empty(t, auditLogs)
+		})
+
+		t.Run("by identity ID", func(t *testing.T) {
+			// when
+			auditLogs, count, err := s.repo.List(context.Background(), auditlog.Filter{
+				IdentityID: &identity2,
+			}, 0, 10)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 3, count)
+			require.Len(t, auditLogs, 3)
+			for _, auditLog := range auditLogs {
+				assert.Equal(t, username2, auditLog.Username)
+			}
+		})
+
+		t.Run("by event types", func(t *testing.T) {
+			// when
+			auditLogs, count, err := s.repo.List(context.Background(), auditlog.Filter{
+				UsernamePrefix: prefix,
+				EventTypes:     []string{auditlog.StartTenantUpdateEvent, auditlog.StopTenantUpdateEvent},
+			}, 0, 10)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 4, count)
+			require.Len(t, auditLogs, 4)
+			for _, auditLog := range auditLogs {
+				assert.NotEqual(t, auditlog.UserSearch, auditLog.EventTypeID)
+			}
+		})
+
+		t.Run("by time range", func(t *testing.T) {
+			// when
+			auditLogs, count, err := s.repo.List(context.Background(), auditlog.Filter{
+				UsernamePrefix: prefix,
+				CreatedAfter:   &before,
+				CreatedBefore:  &after,
+			}, 0, 10)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 6, count)
+			assert.Len(t, auditLogs, 6)
+			// when
+			auditLogs, count, err = s.repo.List(context.Background(), auditlog.Filter{
+				UsernamePrefix: prefix,
+				CreatedAfter:   &after,
+			}, 0, 10)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 0, count)
+			assert.Empty(t, auditLogs)
+		})
+
+		t.Run("by event params", func(t *testing.T) {
+			// when
+			auditLogs, count, err := s.repo.List(context.Background(), auditlog.Filter{
+				EventParams: auditlog.EventParams{
+					"username": username1,
+				},
+			}, 0, 10)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 3, count)
+			require.Len(t, auditLogs, 3)
+			for _, auditLog := range auditLogs {
+				assert.Equal(t, username1, auditLog.Username)
+			}
+		})
+
+		t.Run("sorted by descending creation date", func(t *testing.T) {
+			// when
+			auditLogs, count, err := s.repo.List(context.Background(), auditlog.Filter{
+				IdentityID: &identity1,
+				Sort:       auditlog.SortDescending,
+			}, 0, 2)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 3, count)
+			require.Len(t, auditLogs, 2) // full page
+			for idx, auditLog := range auditLogs {
+				assert.Equal(t, float64(2-idx), auditLog.EventParams["idx"])
+			}
+		})
+
+		t.Run("stable pages", func(t *testing.T) {
+			// given
+			all, _, err := s.repo.List(context.Background(), auditlog.Filter{
+				UsernamePrefix: prefix,
+			}, 0, 10)
+			require.NoError(t, err)
+			require.Len(t, all, 6)
+			// when listing the same records one at a time
+			for start := range all {
+				auditLogs, _, err := s.repo.List(context.Background(), auditlog.Filter{
+					UsernamePrefix: prefix,
+				}, start, 1)
+				// then the records are in the same order, sorted by creation date and then by ID
+				require.NoError(t, err)
+				require.Len(t, auditLogs, 1)
+				assert.Equal(t, all[start].ID, auditLogs[0].ID)
+				if start > 0 && all[start].CreatedAt.Equal(all[start-1].CreatedAt) {
+					assert.True(t, all[start-1].ID.String() < all[start].ID.String())
+				}
+			}
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("invalid time range", func(t *testing.T) {
+			// when
+			_, _, err := s.repo.List(context.Background(), auditlog.Filter{
+				CreatedAfter:  &after,
+				CreatedBefore: &before,
+			}, 0, 10)
+			// then
+			require.Error(t, err)
+			require.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("invalid sort order", func(t *testing.T) {
+			// when
+			_, _, err := s.repo.List(context.Background(), auditlog.Filter{
+				Sort: "foo",
+			}, 0, 10)
+			// then
+			require.Error(t, err)
+			require.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("invalid limit", func(t *testing.T) {
+			// when
+			_, _, err := s.repo.List(context.Background(), auditlog.Filter{}, 0, -5)
+			// then
+			require.Error(t, err)
+			require.IsType(t, errors.BadParameterError{}, err)
+		})
+	})
+}
+
+func (s *RepositoryBlackboxTestSuite) TestVerifyChain() {
+	// given 3 records appended to the chain
+	username := fmt.Sprintf("user-%v", uuid.NewV4())
+	records := make([]auditlog.AuditLog, 3)
+	for i := range records {
+		records[i] = auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"idx": i,
+			},
+		}
+		err := s.repo.Create(context.Background(), &records[i])
+		require.NoError(s.T(), err)
+		require.NotEmpty(s.T(), records[i].Hash)
+	}
+
+	s.T().Run("intact", func(t *testing.T) {
+		// when
+		brk, err := s.repo.VerifyChain(context.Background(), records[0].ChainSeq)
+		// then
+		require.NoError(t, err)
+		assert.Nil(t, brk)
+	})
+
+	s.T().Run("record was edited", func(t *testing.T) {
+		// given
+		err := s.DB.Exec(`update audit_log set event_params = '{"idx":42}' where audit_log_id = ?`, records[1].ID).Error
+		require.NoError(t, err)
+		defer func() {
+			// restore the original record
+			err := s.DB.Exec(`update audit_log set event_params = '{"idx":1}' where audit_log_id = ?`, records[1].ID).Error
+			require.NoError(t, err)
+		}()
+		// when
+		brk, err := s.repo.VerifyChain(context.Background(), records[0].ChainSeq)
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, brk)
+		assert.Equal(t, records[1].ChainSeq, brk.Seq)
+		require.NotNil(t, brk.ID)
+		assert.Equal(t, records[1].ID, *brk.ID)
+	})
+
+	s.T().Run("record was deleted", func(t *testing.T) {
+		// given
+		err := s.DB.Exec(`delete from audit_log where audit_log_id = ?`, records[1].ID).Error
+		require.NoError(t, err)
+		defer func() {
+			// restore the original record
+			err := s.DB.Create(&records[1]).Error
+			require.NoError(t, err)
+		}()
+		// when
+		brk, err := s.repo.VerifyChain(context.Background(), records[0].ChainSeq)
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, brk)
+		assert.Equal(t, records[1].ChainSeq, brk.Seq)
+		assert.Nil(t, brk.ID)
+	})
+}
+
+func (s *RepositoryBlackboxTestSuite) TestArchiveExpired() {
+	// given 3 expired records and 1 record that has not expired yet
+	username := fmt.Sprintf("user-%v", uuid.NewV4())
+	records := make([]auditlog.AuditLog, 4)
+	var before time.Time
+	for i := range records {
+		if i == len(records)-1 {
+			before = time.Now()
+		}
+		records[i] = auditlog.AuditLog{
+			EventTypeID: auditlog.ArchiveAuditLogs,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"idx": i,
+			},
+		}
+		err := s.repo.Create(context.Background(), &records[i])
+		require.NoError(s.T(), err)
+	}
+
+	s.T().Run("failure to archive", func(t *testing.T) {
+		// when
+		_, err := s.repo.ArchiveExpired(context.Background(), auditlog.ArchiveAuditLogs, before, 10, func([]auditlog.AuditLog) (string, error) {
+			return "", fmt.Errorf("mock failure")
+		})
+		// then
+		require.Error(t, err)
+		_, err = s.repo.LoadByID(context.Background(), records[0].ID)
+		require.NoError(t, err) // not deleted
+	})
+
+	s.T().Run("ok", func(t *testing.T) {
+		// when archiving by batches of 2 records (including records of previous test runs)
+		archived := map[uuid.UUID]string{}
+		for i := 0; ; i++ {
+			count, err := s.repo.ArchiveExpired(context.Background(), auditlog.ArchiveAuditLogs, before, 2, func(batch []auditlog.AuditLog) (string, error) {
+				require.True(t, len(batch) <= 2)
+				for _, r := range batch {
+					archived[r.ID] = fmt.Sprintf("archive-%d", i)
+				}
+				return fmt.Sprintf("archive-%d", i), nil
+			})
+			require.NoError(t, err)
+			if count == 0 {
+				break
+			}
+		}
+		// then
+		for _, r := range records[:3] {
+			assert.Contains(t, archived, r.ID)
+			_, err := s.repo.LoadByID(context.Background(), r.ID)
+			require.Error(t, err)
+			assert.IsType(t, errors.NotFoundError{}, err)
+		}
+		assert.NotContains(t, archived, records[3].ID)
+		_, err := s.repo.LoadByID(context.Background(), records[3].ID)
+		require.NoError(t, err)
+		// also, the chain can still be verified
+		brk, err := s.repo.VerifyChain(context.Background(), records[0].ChainSeq)
+		require.NoError(t, err)
+		assert.Nil(t, brk)
+	})
+
+	s.T().Run("archived record was edited", func(t *testing.T) {
+		// given
+		err := s.DB.Exec(`update audit_log_archived set prev_hash = 'foo' where audit_log_id = ?`, records[1].ID).Error
+		require.NoError(t, err)
+		defer func() {
+			// restore the original tombstone
+			err := s.DB.Exec(`update audit_log_archived set prev_hash = ? where audit_log_id = ?`, records[1].PrevHash, records[1].ID).Error
+			require.NoError(t, err)
+		}()
+		// when
+		brk, err := s.repo.VerifyChain(context.Background(), records[0].ChainSeq)
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, brk)
+		assert.Equal(t, records[1].ChainSeq, brk.Seq)
+	})
+
+	s.T().Run("invalid limit", func(t *testing.T) {
+		// when
+		_, err := s.repo.ArchiveExpired(context.Background(), auditlog.ArchiveAuditLogs, before, 0, func([]auditlog.AuditLog) (string, error) {
+			return "", nil
+		})
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, err)
+	})
+}
@@ -0,0 +1,259 @@
+package auditlog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/closeable"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// The audit log records are chained together: each record carries the hash of the previous record in the chain
+// and its own hash, which is computed over the canonical JSON representation of the record (including the
+// previous hash). Editing or deleting a record thus breaks the chain, which can be detected with `VerifyChain`.
+// The head of the chain (ie, the sequence number and hash of the last record) is kept in a single-row table,
+// which is locked while a record is appended, so that the chain remains linear.
+
+const (
+	chainTableName = "audit_log_chain"
+)
+
+// ChainBreak the first broken link found while verifying the chain of audit log records
+type ChainBreak struct {
+	// Seq the sequence number at which the chain is broken
+	Seq int64
+	// ID the ID of the record at which the chain is broken, if the record exists
+	ID *uuid.UUID
+	// Reason a description of the problem
+	Reason string
+}
+
+// String returns a description of the broken link
+func (b ChainBreak) String() string {
+	if b.ID != nil {
+		return fmt.Sprintf("audit log chain is broken at #%d (record '%s'): %s", b.Seq, b.ID.String(), b.Reason)
+	}
+	return fmt.Sprintf("audit log chain is broken at #%d: %s", b.Seq, b.Reason)
+}
+
+// canonicalRecord the fields of an audit log record that are covered by its hash, sorted by name
+type canonicalRecord struct {
+	ChainSeq    int64       `json:"chain_seq"`
+	CreatedAt   string      `json:"created_at"`
+	EventParams interface{} `json:"event_params"`
+	EventTypeID string      `json:"event_type_id"`
+	ID          string      `json:"id"`
+	IdentityID  string      `json:"identity_id"`
+	PrevHash    string      `json:"prev_hash"`
+	Username    string      `json:"username"`
+}
+
+// computeHash returns the SHA-256 hash (hex-encoded) of the canonical JSON representation of the given record
+func computeHash(r AuditLog) (string, error) {
+	// convert the params into their JSON counterpart, so that the values are the same once
+	// the record has been persisted and loaded again (eg: integers become floats)
+	var params interface{}
+	if r.EventParams != nil {
+		b, err := json.Marshal(r.EventParams)
+		if err != nil {
+			return "", errs.Wrap(err, "unable to compute hash of audit log record")
+		}
+		if err := json.Unmarshal(b, &params); err != nil {
+			return "", errs.Wrap(err, "unable to compute hash of audit log record")
+		}
+	}
+	c, err := json.Marshal(canonicalRecord{
+		ChainSeq:    r.ChainSeq,
+		CreatedAt:   r.CreatedAt.UTC().Format(time.RFC3339Nano),
+		EventParams: params,
+		EventTypeID: r.EventTypeID.String(),
+		ID:          r.ID.String(),
+		IdentityID:  r.IdentityID.String(),
+		PrevHash:    r.PrevHash,
+		Username:    r.Username,
+	})
+	if err != nil {
+		return "", errs.Wrap(err, "unable to compute hash of audit log record")
+	}
+	h := sha256.Sum256(c)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// lockChainHead locks the head of the chain until the end of the transaction, and returns its sequence number and hash
+func lockChainHead(ctx context.Context, tx *gorm.DB) (int64, string, error) {
+	var seq int64
+	var hash string
+	row := tx.Raw("select seq, hash from " + chainTableName + " where id = 1 for update").Row()
+	if err := row.Scan(&seq, &hash); err != nil {
+		return 0, "", errors.NewInternalError(ctx, errs.Wrap(err, "unable to lock the head of the audit log chain"))
+	}
+	return seq, hash, nil
+}
+
+// appendToChain sets the ID, creation date, sequence number and hashes of the given records and
+// inserts them at the end of the chain. The creation date of the records is the current time, unless `keepCreatedAt`
+// is true: in that case, the creation date which is already set is kept (unless it is in the future). This is only
+// meant for the records whose creation date was set by the admin console itself, never by its clients.
+// Must be called in a transaction.
+func appendToChain(ctx context.Context, tx *gorm.DB, keepCreatedAt bool, records ...*AuditLog) error {
+	seq, hash, err := lockChainHead(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if r.ID == uuid.Nil {
+			r.ID = uuid.NewV4()
+		}
+		// the creation date is set here (rather than by the database) because it is covered by the hash.
+		// Also, the database only keeps microseconds.
+		now := time.Now()
+		if !keepCreatedAt || r.CreatedAt.IsZero() || r.CreatedAt.After(now) {
+			r.CreatedAt = now
+		}
+		r.CreatedAt = r.CreatedAt.UTC().Truncate(time.Microsecond)
+		seq++
+		r.ChainSeq = seq
+		r.PrevHash = hash
+		h, err := computeHash(*r)
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		r.Hash = h
+		hash = h
+	}
+	if err := insertRecords(tx, records); err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	err = tx.Exec("update "+chainTableName+" set seq = ?, hash = ? where id = 1", seq, hash).Error
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// insertBatchSize the maximum number of records inserted with a single statement
+// (each record takes 14 of the 65535 parameters allowed by PostgreSQL)
+const insertBatchSize = 1000
+
+// insertRecords inserts the given records with multi-row INSERT statements
+func insertRecords(tx *gorm.DB, records []*AuditLog) error {
+	for start := 0; start < len(records); start += insertBatchSize {
+		end := start + insertBatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*14)
+		for _, r := range records[start:end] {
+			values = append(values, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+			args = append(args, r.ID, r.CreatedAt, r.IdentityID, r.Username, r.EventTypeID, r.EventParams, r.ChainSeq, r.PrevHash, r.Hash,
+				r.StatusCode, r.Outcome, r.LatencyMs, r.ResponseSize, r.ResultCount)
+		}
+		err := tx.Exec("insert into "+recordTableName+
+			" (audit_log_id, created_at, identity_id, username, event_type_id, event_params, chain_seq, prev_hash, hash,"+
+			" status_code, outcome, latency_ms, response_size, result_count) values "+
+			strings.Join(values, ", "), args...).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyChain walks through the chain of audit log records, starting at the record with the given sequence number (or at the
+// beginning of the chain if it is lower than 1), and verifies that each record's hash matches its content and the hash of the
+// previous record. Records which were archived are verified against their tombstone: only their link to the previous and next
+// records can be verified, since their content was moved to the archive. When starting in the middle of the chain, the hash
+// of the record before the starting point is assumed to be valid, which allows for verifying the chain incrementally.
+// Records which were created before the chaining was introduced are ignored.
+// returns the first broken link, or nil if the chain is intact, or an InternalError if something wrong happened
+// while qyerying or reading the returned rows
+func (r *GormAuditLogRepository) VerifyChain(ctx context.Context, from int64) (*ChainBreak, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "verify_chain"}, time.Now())
+	// read the head of the chain first, so that records appended during the verification are ignored
+	var headSeq int64
+	var headHash string
+	row := r.db.Raw("select seq, hash from " + chainTableName + " where id = 1").Row()
+	if err := row.Scan(&headSeq, &headHash); err != nil {
+		return nil, errors.NewInternalError(ctx, errs.Wrap(err, "unable to read the head of the audit log chain"))
+	}
+	if from < 1 {
+		from = 1
+	}
+	rows, err := r.db.Model(&AuditLog{}).Where("chain_seq >= ? and chain_seq <= ?", from, headSeq).Order("chain_seq").Rows()
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	defer closeable.Close(ctx, rows)
+	expectedSeq := from
+	var prevHash *string
+	if from == 1 {
+		genesis := ""
+		prevHash = &genesis
+	}
+	for rows.Next() {
+		record := AuditLog{}
+		if err := r.db.ScanRows(rows, &record); err != nil {
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		id := record.ID
+		if record.ChainSeq != expectedSeq {
+			// the missing records may have been archived
+			h, brk, err := r.verifyArchived(ctx, expectedSeq, record.ChainSeq-1, prevHash)
+			if err != nil || brk != nil {
+				return brk, err
+			}
+			prevHash = h
+			expectedSeq = record.ChainSeq
+		}
+		if prevHash != nil && record.PrevHash != *prevHash {
+			return &ChainBreak{
+				Seq:    record.ChainSeq,
+				ID:     &id,
+				Reason: "previous hash does not match the hash of the previous record",
+			}, nil
+		}
+		h, err := computeHash(record)
+		if err != nil {
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		if h != record.Hash {
+			return &ChainBreak{
+				Seq:    record.ChainSeq,
+				ID:     &id,
+				Reason: "hash does not match the content of the record",
+			}, nil
+		}
+		prevHash = &record.Hash
+		expectedSeq++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	// also verify that the last records were not deleted (unless they were archived)
+	if expectedSeq <= headSeq {
+		h, brk, err := r.verifyArchived(ctx, expectedSeq, headSeq, prevHash)
+		if err != nil || brk != nil {
+			return brk, err
+		}
+		prevHash = h
+		expectedSeq = headSeq + 1
+	}
+	if expectedSeq-1 == headSeq && prevHash != nil && *prevHash != headHash {
+		return &ChainBreak{
+			Seq:    expectedSeq,
+			Reason: fmt.Sprintf("record is missing (head of the chain is #%d)", headSeq),
+		}, nil
+	}
+	return nil, nil
+}
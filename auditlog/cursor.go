@@ -0,0 +1,57 @@
+package auditlog
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Cursor the position of an audit log record in a list sorted by creation date and ID, used
+// for keyset pagination
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// NewCursor returns the cursor pointing at the given audit log record
+func NewCursor(r AuditLog) Cursor {
+	return Cursor{
+		CreatedAt: r.CreatedAt,
+		ID:        r.ID,
+	}
+}
+
+// String returns the opaque token representing this cursor
+func (c Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s|%s", c.CreatedAt.Format(time.RFC3339Nano), c.ID)))
+}
+
+// ParseCursor parses the given opaque token
+// returns a BadParameterError if the token is invalid
+func ParseCursor(token string) (Cursor, error) {
+	value, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, errors.NewBadParameterError("page[cursor]", token)
+	}
+	parts := strings.SplitN(string(value), "|", 2)
+	if len(parts) != 2 {
+		return Cursor{}, errors.NewBadParameterError("page[cursor]", token)
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return Cursor{}, errors.NewBadParameterError("page[cursor]", token)
+	}
+	id, err := uuid.FromString(parts[1])
+	if err != nil {
+		return Cursor{}, errors.NewBadParameterError("page[cursor]", token)
+	}
+	return Cursor{
+		CreatedAt: createdAt,
+		ID:        id,
+	}, nil
+}
@@ -0,0 +1,279 @@
+package auditlog
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+)
+
+// EventType a type of audited event
+type EventType struct {
+	ID          uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:event_type_id"`
+	Name        string
+	Description string
+	// ParamsSchema the JSON schema of the params of the events of this type, if any
+	ParamsSchema JSONSchema `sql:"type:jsonb"`
+	// Internal whether the events of this type can only be recorded by the admin console itself
+	Internal  bool
+	CreatedAt time.Time
+}
+
+const (
+	eventTypeTableName = "event_type"
+)
+
+// TableName implements gorm.tabler
+func (t EventType) TableName() string {
+	return eventTypeTableName
+}
+
+// JSONSchema a JSON schema document
+type JSONSchema map[string]interface{}
+
+// Ensure JSONSchema implements the sql.Scanner and driver.Valuer interfaces
+var _ sql.Scanner = (*JSONSchema)(nil)
+var _ driver.Valuer = (*JSONSchema)(nil)
+
+// Value implements the driver.Valuer interface
+func (s JSONSchema) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return toBytes(s)
+}
+
+// Scan implements the https://golang.org/pkg/database/sql/#Scanner interface
+func (s *JSONSchema) Scan(src interface{}) error {
+	return fromBytes(src, s)
+}
+
+// EventTypeRepository provides functions to register and lookup event types
+type EventTypeRepository interface {
+	Create(ctx context.Context, eventType *EventType) error
+	List(ctx context.Context) ([]EventType, error)
+	LoadByID(ctx context.Context, id uuid.UUID) (EventType, error)
+	LoadByName(ctx context.Context, name string) (EventType, error)
+	Names(ctx context.Context) (map[uuid.UUID]string, error)
+}
+
+// NewEventTypeRepository creates a GormEventTypeRepository
+func NewEventTypeRepository(db *gorm.DB) EventTypeRepository {
+	return &GormEventTypeRepository{
+		db:    db,
+		cache: eventTypes,
+	}
+}
+
+// GormEventTypeRepository implements EventTypeRepository using gorm. The event types are
+// cached, since they are looked-up every time an audit log record is created or read.
+type GormEventTypeRepository struct {
+	db    *gorm.DB
+	cache *eventTypeCache
+}
+
+// Create registers the given event type
+// returns BadParameterError if the name is missing or if the params schema is invalid,
+// DataConflictError if an event type with the same name already exists, or InternalError
+// if something wrong happened
+func (r *GormEventTypeRepository) Create(ctx context.Context, eventType *EventType) error {
+	defer goa.MeasureSince([]string{"goa", "db", "eventType", "create"}, time.Now())
+	if eventType == nil {
+		return errors.NewBadParameterErrorFromString("missing event type to persist")
+	}
+	if eventType.Name == "" {
+		return errors.NewBadParameterError("name", eventType.Name)
+	}
+	if err := ValidateSchema(eventType.ParamsSchema); err != nil {
+		return err
+	}
+	if _, err := r.LoadByName(ctx, eventType.Name); err == nil {
+		return errors.NewDataConflictError("event type already exists: " + eventType.Name)
+	} else if !isNotFound(err) {
+		return err
+	}
+	if eventType.ID == uuid.Nil {
+		eventType.ID = uuid.NewV4()
+	}
+	if err := r.db.Create(eventType).Error; err != nil {
+		// the event type may have been registered concurrently since it was looked-up
+		if isUniqueViolation(err, "uix_event_type_name") {
+			return errors.NewDataConflictError("event type already exists: " + eventType.Name)
+		}
+		return errors.NewInternalError(ctx, err)
+	}
+	// the new event type will be loaded with the others
+	r.cache.reset()
+	return nil
+}
+
+// List returns all the event types, sorted by name
+// returns InternalError if something wrong happened while loading the event types
+func (r *GormEventTypeRepository) List(ctx context.Context) ([]EventType, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "eventType", "list"}, time.Now())
+	return r.cache.list(ctx, r.db)
+}
+
+// LoadByID returns the event type with the given ID
+// returns NotFoundError or InternalError
+func (r *GormEventTypeRepository) LoadByID(ctx context.Context, id uuid.UUID) (EventType, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "eventType", "loadById"}, time.Now())
+	t, found, err := r.cache.lookup(ctx, r.db, "id:"+id.String(), func(c *eventTypeCache) (EventType, bool) {
+		t, found := c.byID[id]
+		return t, found
+	})
+	if err != nil {
+		return EventType{}, err
+	}
+	if !found {
+		return EventType{}, errors.NewNotFoundError("event_type", id.String())
+	}
+	return t, nil
+}
+
+// LoadByName returns the event type with the given name
+// returns NotFoundError or InternalError
+func (r *GormEventTypeRepository) LoadByName(ctx context.Context, name string) (EventType, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "eventType", "loadByName"}, time.Now())
+	t, found, err := r.cache.lookup(ctx, r.db, "name:"+name, func(c *eventTypeCache) (EventType, bool) {
+		t, found := c.byName[name]
+		return t, found
+	})
+	if err != nil {
+		return EventType{}, err
+	}
+	if !found {
+		return EventType{}, errors.NewNotFoundError("event_type", name)
+	}
+	return t, nil
+}
+
+// Names returns the names of all the event types, indexed by their ID
+// returns InternalError if something wrong happened while loading the event types
+func (r *GormEventTypeRepository) Names(ctx context.Context) (map[uuid.UUID]string, error) {
+	types, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uuid.UUID]string, len(types))
+	for _, t := range types {
+		names[t.ID] = t.Name
+	}
+	return names, nil
+}
+
+func isNotFound(err error) bool {
+	_, ok := err.(errors.NotFoundError)
+	return ok
+}
+
+// isUniqueViolation returns true if the given error is a violation of the unique index with the given name
+func isUniqueViolation(err error, index string) bool {
+	e, ok := err.(*pq.Error)
+	return ok && e.Code == "23505" && e.Constraint == index
+}
+
+// the cache of event types shared by all repositories
+var eventTypes = &eventTypeCache{}
+
+const (
+	// eventTypeCacheTTL the duration after which the cached event types are reloaded, and after which an event type
+	// which was not found is looked-up again, so that the event types registered by other instances of the service
+	// are eventually visible
+	eventTypeCacheTTL = time.Minute
+	// maxCachedMisses the maximum number of event types which were not found kept in the cache
+	maxCachedMisses = 1000
+)
+
+// eventTypeCache the cache of event types, loaded all at once from the database
+type eventTypeCache struct {
+	mux      sync.RWMutex
+	loadedAt time.Time
+	all      []EventType
+	byID     map[uuid.UUID]EventType
+	byName   map[string]EventType
+	// misses the time at which the event types which were not found were looked-up, indexed by lookup key
+	misses map[string]time.Time
+}
+
+func (c *eventTypeCache) reset() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.loadedAt = time.Time{}
+	c.misses = nil
+}
+
+// list returns all the event types, (re)loading them if the cache expired
+func (c *eventTypeCache) list(ctx context.Context, db *gorm.DB) ([]EventType, error) {
+	c.mux.RLock()
+	if time.Since(c.loadedAt) < eventTypeCacheTTL {
+		defer c.mux.RUnlock()
+		return c.all, nil
+	}
+	c.mux.RUnlock()
+	if err := c.load(ctx, db); err != nil {
+		return nil, err
+	}
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.all, nil
+}
+
+// lookup looks-up an event type in the cache, and reloads the cache if the cache expired or if the event type was not
+// found (eg: in case the event type was registered by another instance). An event type which is still not found after
+// the cache was reloaded is reported as not found without reloading the cache until the TTL expires.
+func (c *eventTypeCache) lookup(ctx context.Context, db *gorm.DB, key string, fn func(c *eventTypeCache) (EventType, bool)) (EventType, bool, error) {
+	c.mux.RLock()
+	if time.Since(c.loadedAt) < eventTypeCacheTTL {
+		if t, found := fn(c); found {
+			c.mux.RUnlock()
+			return t, true, nil
+		}
+		if missedAt, missed := c.misses[key]; missed && time.Since(missedAt) < eventTypeCacheTTL {
+			c.mux.RUnlock()
+			return EventType{}, false, nil
+		}
+	}
+	c.mux.RUnlock()
+	if err := c.load(ctx, db); err != nil {
+		return EventType{}, false, err
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	t, found := fn(c)
+	if !found {
+		if c.misses == nil || len(c.misses) >= maxCachedMisses {
+			c.misses = map[string]time.Time{}
+		}
+		c.misses[key] = time.Now()
+	}
+	return t, found, nil
+}
+
+func (c *eventTypeCache) load(ctx context.Context, db *gorm.DB) error {
+	all := []EventType{}
+	if err := db.Order("name").Find(&all).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	byID := make(map[uuid.UUID]EventType, len(all))
+	byName := make(map[string]EventType, len(all))
+	for _, t := range all {
+		byID[t.ID] = t
+		byName[t.Name] = t
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.all = all
+	c.byID = byID
+	c.byName = byName
+	c.loadedAt = time.Now()
+	return nil
+}
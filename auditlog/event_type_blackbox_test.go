@@ -0,0 +1,202 @@
+package auditlog_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type EventTypeRepositoryBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	repo auditlog.EventTypeRepository
+}
+
+func TestEventTypeRepository(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &EventTypeRepositoryBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *EventTypeRepositoryBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.repo = auditlog.NewEventTypeRepository(s.DB)
+}
+
+func (s *EventTypeRepositoryBlackboxTestSuite) TestBuiltinEventTypes() {
+	// verify that the IDs of the built-in event types match the records inserted by the migrations
+	builtins := map[uuid.UUID]string{
+		auditlog.UserSearch:                   auditlog.UserSearchEvent,
+		auditlog.ShowTenantUpdate:             auditlog.ShowTenantUpdateEvent,
+		auditlog.StartTenantUpdate:            auditlog.StartTenantUpdateEvent,
+		auditlog.StopTenantUpdate:             auditlog.StopTenantUpdateEvent,
+		auditlog.UserDeactivationNotification: auditlog.UserDeactivationNotificationEvent,
+		auditlog.UserDeactivation:             auditlog.UserDeactivationEvent,
+		auditlog.ListAuditLogs:                auditlog.ListAuditLogsEvent,
+		auditlog.ExportAuditLogs:              auditlog.ExportAuditLogsEvent,
+		auditlog.ArchiveAuditLogs:             auditlog.ArchiveAuditLogsEvent,
+		auditlog.CreateRole:                   auditlog.CreateRoleEvent,
+		auditlog.AssignRole:                   auditlog.AssignRoleEvent,
+		auditlog.RevokeRole:                   auditlog.RevokeRoleEvent,
+		auditlog.AccessDenied:                 auditlog.AccessDeniedEvent,
+		auditlog.CreateWebhook:                auditlog.CreateWebhookEvent,
+		auditlog.DeleteWebhook:                auditlog.DeleteWebhookEvent,
+		auditlog.StreamAuditLogs:              auditlog.StreamAuditLogsEvent,
+		auditlog.AggregateAuditLogs:           auditlog.AggregateAuditLogsEvent,
+		auditlog.CreateTenantUpdateSchedule:   auditlog.CreateTenantUpdateScheduleEvent,
+		auditlog.ExecuteTenantUpdateSchedule:  auditlog.ExecuteTenantUpdateScheduleEvent,
+		auditlog.CancelTenantUpdateSchedule:   auditlog.CancelTenantUpdateScheduleEvent,
+		auditlog.RequestTenantUpdate:          auditlog.RequestTenantUpdateEvent,
+		auditlog.ApproveTenantUpdate:          auditlog.ApproveTenantUpdateEvent,
+		auditlog.RejectTenantUpdate:           auditlog.RejectTenantUpdateEvent,
+		auditlog.ExpireTenantUpdateRequest:    auditlog.ExpireTenantUpdateRequestEvent,
+		auditlog.CreateTenantUpdateRollout:    auditlog.CreateTenantUpdateRolloutEvent,
+		auditlog.PauseTenantUpdateRollout:     auditlog.PauseTenantUpdateRolloutEvent,
+		auditlog.ResumeTenantUpdateRollout:    auditlog.ResumeTenantUpdateRolloutEvent,
+		auditlog.AbortTenantUpdateRollout:     auditlog.AbortTenantUpdateRolloutEvent,
+		auditlog.AdvanceTenantUpdateRollout:   auditlog.AdvanceTenantUpdateRolloutEvent,
+		auditlog.ShowAuditLog:                 auditlog.ShowAuditLogEvent,
+	}
+	for id, name := range builtins {
+		s.T().Run(name, func(t *testing.T) {
+			// when
+			byID, err := s.repo.LoadByID(context.Background(), id)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, name, byID.Name)
+			// when
+			byName, err := s.repo.LoadByName(context.Background(), name)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, id, byName.ID)
+		})
+	}
+}
+
+func (s *EventTypeRepositoryBlackboxTestSuite) TestCreate() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		eventType := auditlog.EventType{
+			Name:        fmt.Sprintf("event_%s", uuid.NewV4().String()[:8]),
+			Description: "an event",
+			ParamsSchema: auditlog.JSONSchema{
+				"type": "object",
+			},
+		}
+		// when
+		err := s.repo.Create(context.Background(), &eventType)
+		// then
+		require.NoError(t, err)
+		assert.NotEqual(t, uuid.Nil, eventType.ID)
+		result, err := s.repo.LoadByName(context.Background(), eventType.Name)
+		require.NoError(t, err)
+		assert.Equal(t, eventType.ID, result.ID)
+		assert.Equal(t, "an event", result.Description)
+		assert.Equal(t, auditlog.JSONSchema{"type": "object"}, result.ParamsSchema)
+		assert.False(t, result.Internal)
+		names, err := s.repo.Names(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, eventType.Name, names[eventType.ID])
+	})
+
+	s.T().Run("visible after registration by another instance", func(t *testing.T) {
+		// given a cache which is already loaded, and an event type inserted directly in the DB
+		_, err := s.repo.List(context.Background())
+		require.NoError(t, err)
+		name := fmt.Sprintf("event_%s", uuid.NewV4().String()[:8])
+		err = s.DB.Exec("insert into event_type (event_type_id, name) values (?, ?)", uuid.NewV4(), name).Error
+		require.NoError(t, err)
+		// when
+		_, err = s.repo.LoadByName(context.Background(), name)
+		// then
+		require.NoError(t, err)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("missing name", func(t *testing.T) {
+			// when
+			err := s.repo.Create(context.Background(), &auditlog.EventType{})
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("duplicate name", func(t *testing.T) {
+			// when
+			err := s.repo.Create(context.Background(), &auditlog.EventType{
+				Name: auditlog.UserSearchEvent,
+			})
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, err)
+		})
+
+		t.Run("duplicate name registered concurrently", func(t *testing.T) {
+			// given an event type which was not found, and then registered by another instance
+			name := fmt.Sprintf("event_%s", uuid.NewV4().String()[:8])
+			_, err := s.repo.LoadByName(context.Background(), name)
+			require.Error(t, err)
+			err = s.DB.Exec("insert into event_type (event_type_id, name) values (?, ?)", uuid.NewV4(), name).Error
+			require.NoError(t, err)
+			// when
+			err = s.repo.Create(context.Background(), &auditlog.EventType{
+				Name: name,
+			})
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, err)
+		})
+	})
+}
+
+func (s *EventTypeRepositoryBlackboxTestSuite) TestLoadUnknown() {
+
+	s.T().Run("by id", func(t *testing.T) {
+		// when
+		_, err := s.repo.LoadByID(context.Background(), uuid.NewV4())
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	s.T().Run("by name", func(t *testing.T) {
+		// when
+		_, err := s.repo.LoadByName(context.Background(), "unknown")
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	s.T().Run("not looked-up again until the cache expires", func(t *testing.T) {
+		// given an event type which was not found, and then inserted directly in the DB
+		name := fmt.Sprintf("event_%s", uuid.NewV4().String()[:8])
+		_, err := s.repo.LoadByName(context.Background(), name)
+		require.Error(t, err)
+		err = s.DB.Exec("insert into event_type (event_type_id, name) values (?, ?)", uuid.NewV4(), name).Error
+		require.NoError(t, err)
+		// when
+		_, err = s.repo.LoadByName(context.Background(), name)
+		// then the miss was cached
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+		// until another event type is registered by this instance
+		err = s.repo.Create(context.Background(), &auditlog.EventType{
+			Name: fmt.Sprintf("event_%s", uuid.NewV4().String()[:8]),
+		})
+		require.NoError(t, err)
+		_, err = s.repo.LoadByName(context.Background(), name)
+		require.NoError(t, err)
+	})
+}
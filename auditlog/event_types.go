@@ -1,8 +1,6 @@
 package auditlog
 
 import (
-	"fmt"
-
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -21,82 +19,115 @@ const (
 	UserDeactivationEvent = "user_deactivation"
 	// ListAuditLogsEvent the name of the "list audit logs" event
 	ListAuditLogsEvent = "list_audit_logs"
+	// ExportAuditLogsEvent the name of the "export audit logs" event
+	ExportAuditLogsEvent = "export_audit_logs"
+	// ArchiveAuditLogsEvent the name of the "archive audit logs" event
+	ArchiveAuditLogsEvent = "archive_audit_logs"
+	// CreateRoleEvent the name of the "create role" event
+	CreateRoleEvent = "create_role"
+	// AssignRoleEvent the name of the "assign role" event
+	AssignRoleEvent = "assign_role"
+	// RevokeRoleEvent the name of the "revoke role" event
+	RevokeRoleEvent = "revoke_role"
+	// AccessDeniedEvent the name of the "access denied" event
+	AccessDeniedEvent = "access_denied"
+	// CreateWebhookEvent the name of the "create webhook" event
+	CreateWebhookEvent = "create_webhook"
+	// DeleteWebhookEvent the name of the "delete webhook" event
+	DeleteWebhookEvent = "delete_webhook"
+	// StreamAuditLogsEvent the name of the "stream audit logs" event
+	StreamAuditLogsEvent = "stream_audit_logs"
+	// AggregateAuditLogsEvent the name of the "aggregate audit logs" event
+	AggregateAuditLogsEvent = "aggregate_audit_logs"
+	// CreateTenantUpdateScheduleEvent the name of the "create tenant update schedule" event
+	CreateTenantUpdateScheduleEvent = "create_tenant_update_schedule"
+	// ExecuteTenantUpdateScheduleEvent the name of the "execute tenant update schedule" event
+	ExecuteTenantUpdateScheduleEvent = "execute_tenant_update_schedule"
+	// CancelTenantUpdateScheduleEvent the name of the "cancel tenant update schedule" event
+	CancelTenantUpdateScheduleEvent = "cancel_tenant_update_schedule"
+	// RequestTenantUpdateEvent the name of the "request tenant update" event
+	RequestTenantUpdateEvent = "request_tenant_update"
+	// ApproveTenantUpdateEvent the name of the "approve tenant update" event
+	ApproveTenantUpdateEvent = "approve_tenant_update"
+	// RejectTenantUpdateEvent the name of the "reject tenant update" event
+	RejectTenantUpdateEvent = "reject_tenant_update"
+	// ExpireTenantUpdateRequestEvent the name of the "expire tenant update request" event
+	ExpireTenantUpdateRequestEvent = "expire_tenant_update_request"
+	// CreateTenantUpdateRolloutEvent the name of the "create tenant update rollout" event
+	CreateTenantUpdateRolloutEvent = "create_tenant_update_rollout"
+	// PauseTenantUpdateRolloutEvent the name of the "pause tenant update rollout" event
+	PauseTenantUpdateRolloutEvent = "pause_tenant_update_rollout"
+	// ResumeTenantUpdateRolloutEvent the name of the "resume tenant update rollout" event
+	ResumeTenantUpdateRolloutEvent = "resume_tenant_update_rollout"
+	// AbortTenantUpdateRolloutEvent the name of the "abort tenant update rollout" event
+	AbortTenantUpdateRolloutEvent = "abort_tenant_update_rollout"
+	// AdvanceTenantUpdateRolloutEvent the name of the "advance tenant update rollout" event
+	AdvanceTenantUpdateRolloutEvent = "advance_tenant_update_rollout"
+	// ShowAuditLogEvent the name of the "show audit log" event
+	ShowAuditLogEvent = "show_audit_log"
 )
 
-// UserSearch the UUID of the event for the "user search" action
-var UserSearch uuid.UUID
-
-// ShowTenantUpdate the UUID of the event for the "show tenant update" action
-var ShowTenantUpdate uuid.UUID
-
-// StartTenantUpdate the UUID of the event for the "start tenant update" action
-var StartTenantUpdate uuid.UUID
-
-// StopTenantUpdate the UUID of the event for the "stop tenant update" action
-var StopTenantUpdate uuid.UUID
-
-// UserDeactivationNotification the UUID of the event when a user is notified before account deactivation
-var UserDeactivationNotification uuid.UUID
-
-// UserDeactivation the UUID of the event when a user account is deactivated
-var UserDeactivation uuid.UUID
-
-// ListAuditLogs the UUID of the event for the "list audit logs" action
-var ListAuditLogs uuid.UUID
-
-// EventTypesByName the event types indexed by their name. At least, those that can be created from an endpoint
-var EventTypesByName map[string]uuid.UUID
-
-// EventTypesByID the event types indexed by their UUID.
-var EventTypesByID map[uuid.UUID]string
-
-func init() {
-	var err error
-	EventTypesByName = map[string]uuid.UUID{}
-	EventTypesByID = map[uuid.UUID]string{}
-
-	UserSearch, err = uuid.FromString("7aea0277-d6fa-4df9-8224-a27fa4096ec7")
-	if err != nil {
-		panic(fmt.Sprintf("UserSearch event type ID is not an UUID: %v", err))
-	}
-	EventTypesByID[UserSearch] = UserSearchEvent
-
-	ShowTenantUpdate, err = uuid.FromString("a2633717-12f0-4edd-bcd9-bbdf900a8ec5")
-	if err != nil {
-		panic(fmt.Sprintf("ShowTenantUpdate event type ID is not an UUID: %v", err))
-	}
-	EventTypesByID[ShowTenantUpdate] = ShowTenantUpdateEvent
-
-	StartTenantUpdate, err = uuid.FromString("2d51de09-2ab7-4e15-9e0d-030a71756c8d")
-	if err != nil {
-		panic(fmt.Sprintf("StartTenantUpdate event type ID is not an UUID: %v", err))
-	}
-	EventTypesByID[StartTenantUpdate] = StartTenantUpdateEvent
-
-	StopTenantUpdate, err = uuid.FromString("3dd22424-27b6-494a-a550-9611bfe41cac")
-	if err != nil {
-		panic(fmt.Sprintf("StopTenantUpdate event type ID is not an UUID: %v", err))
-	}
-	EventTypesByID[StopTenantUpdate] = StopTenantUpdateEvent
-
-	UserDeactivationNotification, err = uuid.FromString("9f924fc3-403b-4167-b20c-a543adc4ff3c")
-	if err != nil {
-		panic(fmt.Sprintf("UserDeactivationNotification event type ID is not an UUID: %v", err))
-	}
-	EventTypesByName[UserDeactivationNotificationEvent] = UserDeactivationNotification
-	EventTypesByID[UserDeactivationNotification] = UserDeactivationNotificationEvent
-
-	UserDeactivation, err = uuid.FromString("777ede15-4b18-4720-bada-1519d1915f2e")
-	if err != nil {
-		panic(fmt.Sprintf("UserDeactivation event type ID is not an UUID: %v", err))
-	}
-	EventTypesByName[UserDeactivationEvent] = UserDeactivation
-	EventTypesByID[UserDeactivation] = UserDeactivationEvent
-
-	ListAuditLogs, err = uuid.FromString("3a7cc30b-1b7f-4764-9a35-d1bbb5cfe38a")
-	if err != nil {
-		panic(fmt.Sprintf("UserDeactivationNotification event type ID is not an UUID: %v", err))
-	}
-	EventTypesByName[ListAuditLogsEvent] = ListAuditLogs
-
-}
+// The UUIDs of the built-in event types, which are inserted in the `event_type` table by the SQL migrations.
+// Other event types are registered at runtime, and must be looked-up with an `EventTypeRepository`.
+var (
+	// UserSearch the UUID of the event for the "user search" action
+	UserSearch = uuid.Must(uuid.FromString("7aea0277-d6fa-4df9-8224-a27fa4096ec7"))
+	// ShowTenantUpdate the UUID of the event for the "show tenant update" action
+	ShowTenantUpdate = uuid.Must(uuid.FromString("a2633717-12f0-4edd-bcd9-bbdf900a8ec5"))
+	// StartTenantUpdate the UUID of the event for the "start tenant update" action
+	StartTenantUpdate = uuid.Must(uuid.FromString("2d51de09-2ab7-4e15-9e0d-030a71756c8d"))
+	// StopTenantUpdate the UUID of the event for the "stop tenant update" action
+	StopTenantUpdate = uuid.Must(uuid.FromString("3dd22424-27b6-494a-a550-9611bfe41cac"))
+	// UserDeactivationNotification the UUID of the event when a user is notified before account deactivation
+	UserDeactivationNotification = uuid.Must(uuid.FromString("9f924fc3-403b-4167-b20c-a543adc4ff3c"))
+	// UserDeactivation the UUID of the event when a user account is deactivated
+	UserDeactivation = uuid.Must(uuid.FromString("777ede15-4b18-4720-bada-1519d1915f2e"))
+	// ListAuditLogs the UUID of the event for the "list audit logs" action
+	ListAuditLogs = uuid.Must(uuid.FromString("3a7cc30b-1b7f-4764-9a35-d1bbb5cfe38a"))
+	// ExportAuditLogs the UUID of the event for the "export audit logs" action
+	ExportAuditLogs = uuid.Must(uuid.FromString("4c0d50d1-582d-418b-8d07-d96366a532ba"))
+	// ArchiveAuditLogs the UUID of the event when expired audit logs are archived
+	ArchiveAuditLogs = uuid.Must(uuid.FromString("505ee427-ed66-4a25-8d30-f06931ff2c32"))
+	// CreateRole the UUID of the event for the "create role" action
+	CreateRole = uuid.Must(uuid.FromString("366b6ac1-5c03-4162-afbb-4c22ff24d4bf"))
+	// AssignRole the UUID of the event for the "assign role" action
+	AssignRole = uuid.Must(uuid.FromString("513c1be2-f65e-4349-86b6-342dd2c0b726"))
+	// RevokeRole the UUID of the event for the "revoke role" action
+	RevokeRole = uuid.Must(uuid.FromString("b489c6a1-107b-4f8c-a20a-01a28856ad47"))
+	// AccessDenied the UUID of the event when a user is denied an action
+	AccessDenied = uuid.Must(uuid.FromString("9c1f6d0e-7a3b-4e58-b2d4-63f0c8a1e5b7"))
+	// CreateWebhook the UUID of the event for the "create webhook" action
+	CreateWebhook = uuid.Must(uuid.FromString("97e3bac6-eefe-4636-9bf4-20576a3a9aa8"))
+	// DeleteWebhook the UUID of the event for the "delete webhook" action
+	DeleteWebhook = uuid.Must(uuid.FromString("732a6a86-5a6b-425c-a4dc-344ee5107067"))
+	// StreamAuditLogs the UUID of the event for the "stream audit logs" action
+	StreamAuditLogs = uuid.Must(uuid.FromString("e3c1d3a5-0b6f-4f0e-a3b9-2f6f3c8a5d17"))
+	// AggregateAuditLogs the UUID of the event for the "aggregate audit logs" action
+	AggregateAuditLogs = uuid.Must(uuid.FromString("ac4a1eaf-a3ee-45de-9974-559e3cb1e896"))
+	// CreateTenantUpdateSchedule the UUID of the event for the "create tenant update schedule" action
+	CreateTenantUpdateSchedule = uuid.Must(uuid.FromString("8f955651-17ae-4af7-885c-ef551274e61b"))
+	// ExecuteTenantUpdateSchedule the UUID of the event when the scheduler starts a scheduled tenant update
+	ExecuteTenantUpdateSchedule = uuid.Must(uuid.FromString("605e24af-b7cd-43c8-b2de-db9884d080b9"))
+	// CancelTenantUpdateSchedule the UUID of the event for the "cancel tenant update schedule" action
+	CancelTenantUpdateSchedule = uuid.Must(uuid.FromString("b5178f26-3e68-4485-bc95-ffb769fb4de0"))
+	// RequestTenantUpdate the UUID of the event for the "start tenant update" action when the updates must be approved
+	RequestTenantUpdate = uuid.Must(uuid.FromString("29a37212-13e9-4d2d-986a-584bcc20245b"))
+	// ApproveTenantUpdate the UUID of the event for the "approve tenant update" action
+	ApproveTenantUpdate = uuid.Must(uuid.FromString("f59a55cc-4465-4654-9199-c8cc9a29de01"))
+	// RejectTenantUpdate the UUID of the event for the "reject tenant update" action
+	RejectTenantUpdate = uuid.Must(uuid.FromString("4748e7b8-2a7d-496b-b5ea-62e378a205fa"))
+	// ExpireTenantUpdateRequest the UUID of the event when a request to start a tenant update expires before its approval
+	ExpireTenantUpdateRequest = uuid.Must(uuid.FromString("6e6418aa-cd83-49ed-98d7-f7fcea5c1e39"))
+	// CreateTenantUpdateRollout the UUID of the event for the "create tenant update rollout" action
+	CreateTenantUpdateRollout = uuid.Must(uuid.FromString("7854a1f8-2fbd-471b-b8e3-d5d68b1d972c"))
+	// PauseTenantUpdateRollout the UUID of the event for the "pause tenant update rollout" action
+	PauseTenantUpdateRollout = uuid.Must(uuid.FromString("f46a2787-d747-4266-bd3e-0207052b15fd"))
+	// ResumeTenantUpdateRollout the UUID of the event for the "resume tenant update rollout" action
+	ResumeTenantUpdateRollout = uuid.Must(uuid.FromString("0ae8cf3e-aa28-4262-9c3a-fcc736165ce4"))
+	// AbortTenantUpdateRollout the UUID of the event for the "abort tenant update rollout" action
+	AbortTenantUpdateRollout = uuid.Must(uuid.FromString("c86780ad-0165-4101-a94c-e116fd3e7bd4"))
+	// AdvanceTenantUpdateRollout the UUID of the event when the scheduler starts or completes a stage of a rollout
+	AdvanceTenantUpdateRollout = uuid.Must(uuid.FromString("b427a522-e951-428d-ad74-19628635855a"))
+	// ShowAuditLog the UUID of the event for the "show audit log" action
+	ShowAuditLog = uuid.Must(uuid.FromString("f870ea58-a31b-4667-af69-9d275e44c3e3"))
+)
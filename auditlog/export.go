@@ -0,0 +1,132 @@
+package auditlog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"time"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// CSVMediaType the media type of the audit logs exported in the CSV format
+	CSVMediaType = "text/csv"
+	// NDJSONMediaType the media type of the audit logs exported in the newline-delimited JSON format
+	NDJSONMediaType = "application/x-ndjson"
+)
+
+// Exporter writes audit log records to an underlying writer, one at a time
+type Exporter interface {
+	// Write writes the given record
+	Write(r AuditLog) error
+	// Flush writes any buffered data to the underlying writer
+	Flush() error
+}
+
+// NewCSVExporter returns an Exporter which writes the records in the CSV format, with a header line.
+// The event types are written with their name, as given in `eventTypes`.
+// Each event param in the given `paramKeys` is flattened into its own `event_params.<key>` column.
+func NewCSVExporter(w io.Writer, eventTypes map[uuid.UUID]string, paramKeys []string) (Exporter, error) {
+	e := &csvExporter{
+		w:          csv.NewWriter(w),
+		eventTypes: eventTypes,
+		paramKeys:  paramKeys,
+	}
+	header := []string{"id", "created_at", "identity_id", "username", "event_type"}
+	for _, k := range paramKeys {
+		header = append(header, "event_params."+k)
+	}
+	if err := e.w.Write(header); err != nil {
+		return nil, errs.Wrap(err, "unable to write CSV header")
+	}
+	return e, nil
+}
+
+type csvExporter struct {
+	w          *csv.Writer
+	eventTypes map[uuid.UUID]string
+	paramKeys  []string
+}
+
+func (e *csvExporter) Write(r AuditLog) error {
+	line := []string{
+		r.ID.String(),
+		r.CreatedAt.UTC().Format(time.RFC3339Nano),
+		r.IdentityID.String(),
+		r.Username,
+		e.eventTypes[r.EventTypeID],
+	}
+	for _, k := range e.paramKeys {
+		value, err := formatParam(r.EventParams[k])
+		if err != nil {
+			return err
+		}
+		line = append(line, value)
+	}
+	return errs.Wrapf(e.w.Write(line), "unable to write audit log record '%s' in CSV", r.ID)
+}
+
+func (e *csvExporter) Flush() error {
+	e.w.Flush()
+	return errs.Wrap(e.w.Error(), "unable to flush CSV")
+}
+
+// formatParam returns the given event param value as a string. Strings are returned
+// as-is while other types of values are converted to JSON.
+func formatParam(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", errs.Wrapf(err, "unable to convert event param value '%v'", v)
+		}
+		return string(b), nil
+	}
+}
+
+// NewNDJSONExporter returns an Exporter which writes each record as a JSON object on its own line.
+// The event types are written with their name, as given in `eventTypes`.
+func NewNDJSONExporter(w io.Writer, eventTypes map[uuid.UUID]string) Exporter {
+	return &ndjsonExporter{
+		enc:        json.NewEncoder(w),
+		eventTypes: eventTypes,
+	}
+}
+
+type ndjsonExporter struct {
+	enc        *json.Encoder
+	eventTypes map[uuid.UUID]string
+}
+
+type ndjsonRecord struct {
+	ID          string      `json:"id"`
+	CreatedAt   string      `json:"created_at"`
+	IdentityID  string      `json:"identity_id"`
+	Username    string      `json:"username"`
+	EventType   string      `json:"event_type"`
+	EventParams EventParams `json:"event_params"`
+}
+
+func (e *ndjsonExporter) Write(r AuditLog) error {
+	// json.Encoder appends a newline after each value
+	err := e.enc.Encode(ndjsonRecord{
+		ID:          r.ID.String(),
+		CreatedAt:   r.CreatedAt.UTC().Format(time.RFC3339Nano),
+		IdentityID:  r.IdentityID.String(),
+		Username:    r.Username,
+		EventType:   e.eventTypes[r.EventTypeID],
+		EventParams: r.EventParams,
+	})
+	return errs.Wrapf(err, "unable to write audit log record '%s' in JSON", r.ID)
+}
+
+func (e *ndjsonExporter) Flush() error {
+	// nothing buffered
+	return nil
+}
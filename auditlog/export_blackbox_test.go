@@ -0,0 +1,82 @@
+package auditlog_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/auditlog"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	// given
+	id1 := uuid.NewV4()
+	id2 := uuid.NewV4()
+	createdAt := time.Date(2018, 10, 1, 12, 30, 0, 0, time.UTC)
+	records := []auditlog.AuditLog{
+		{
+			ID:          id1,
+			CreatedAt:   createdAt,
+			Username:    "foo",
+			EventTypeID: auditlog.UserDeactivationNotification,
+			EventParams: auditlog.EventParams{
+				"scheduled_deactivation": "2018-10-08",
+			},
+		},
+		{
+			ID:          id2,
+			CreatedAt:   createdAt,
+			Username:    "foo",
+			EventTypeID: auditlog.UserSearch,
+			EventParams: auditlog.EventParams{
+				"query": "bar, baz",
+				"count": 2,
+			},
+		},
+	}
+
+	eventTypes := map[uuid.UUID]string{
+		auditlog.UserDeactivationNotification: auditlog.UserDeactivationNotificationEvent,
+		auditlog.UserSearch:                   auditlog.UserSearchEvent,
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		// given
+		buf := &bytes.Buffer{}
+		exporter, err := auditlog.NewCSVExporter(buf, eventTypes, []string{"count", "query", "scheduled_deactivation"})
+		require.NoError(t, err)
+		// when
+		for _, r := range records {
+			err := exporter.Write(r)
+			require.NoError(t, err)
+		}
+		err = exporter.Flush()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "id,created_at,identity_id,username,event_type,event_params.count,event_params.query,event_params.scheduled_deactivation\n"+
+			id1.String()+",2018-10-01T12:30:00Z,00000000-0000-0000-0000-000000000000,foo,user_deactivation_notification,,,2018-10-08\n"+
+			id2.String()+",2018-10-01T12:30:00Z,00000000-0000-0000-0000-000000000000,foo,user_search,2,\"bar, baz\",\n",
+			buf.String())
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		// given
+		buf := &bytes.Buffer{}
+		exporter := auditlog.NewNDJSONExporter(buf, eventTypes)
+		// when
+		for _, r := range records {
+			err := exporter.Write(r)
+			require.NoError(t, err)
+		}
+		err := exporter.Flush()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, `{"id":"`+id1.String()+`","created_at":"2018-10-01T12:30:00Z","identity_id":"00000000-0000-0000-0000-000000000000","username":"foo","event_type":"user_deactivation_notification","event_params":{"scheduled_deactivation":"2018-10-08"}}`+"\n"+
+			`{"id":"`+id2.String()+`","created_at":"2018-10-01T12:30:00Z","identity_id":"00000000-0000-0000-0000-000000000000","username":"foo","event_type":"user_search","event_params":{"count":2,"query":"bar, baz"}}`+"\n",
+			buf.String())
+	})
+}
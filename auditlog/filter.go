@@ -0,0 +1,130 @@
+package auditlog
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// SortOrder the order in which audit log records are sorted on their creation date
+type SortOrder string
+
+const (
+	// SortAscending sorts the audit log records from the oldest to the newest
+	SortAscending SortOrder = "asc"
+	// SortDescending sorts the audit log records from the newest to the oldest
+	SortDescending SortOrder = "desc"
+)
+
+// Filter the criteria that audit log records must match to be listed. Empty/nil criteria are ignored.
+type Filter struct {
+	// EventTypes the names of the event types to match (any of them)
+	EventTypes []string
+	// UsernamePrefix the prefix of the username to match
+	UsernamePrefix string
+	// IdentityID the identity ID to match
+	IdentityID *uuid.UUID
+	// CreatedAfter the (exclusive) lower bound of the record creation date
+	CreatedAfter *time.Time
+	// CreatedBefore the (exclusive) upper bound of the record creation date
+	CreatedBefore *time.Time
+	// EventParams the key/value pairs that the record event params must contain. String values which are valid
+	// JSON values (eg: `42` or `true`) match both the JSON value and the string
+	EventParams EventParams
+	// Sort the order of the records on their creation date (ascending by default)
+	Sort SortOrder
+}
+
+// apply adds the filter criteria to the given query
+// returns a BadParameterError if the filter is invalid
+func (f Filter) apply(db *gorm.DB) (*gorm.DB, error) {
+	if f.CreatedAfter != nil && f.CreatedBefore != nil && !f.CreatedAfter.Before(*f.CreatedBefore) {
+		return nil, errors.NewBadParameterErrorFromString("'created_after' must be before 'created_before'")
+	}
+	switch f.Sort {
+	case "", SortAscending, SortDescending:
+	default:
+		return nil, errors.NewBadParameterError("sort", f.Sort)
+	}
+	if len(f.EventTypes) > 0 {
+		db = db.Where("event_type_id in (select event_type_id from event_type where name in (?))", f.EventTypes)
+	}
+	if f.UsernamePrefix != "" {
+		db = db.Where("username like ?", escapeLike(f.UsernamePrefix)+"%")
+	}
+	if f.IdentityID != nil {
+		db = db.Where("identity_id = ?", *f.IdentityID)
+	}
+	if f.CreatedAfter != nil {
+		db = db.Where("created_at > ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		db = db.Where("created_at < ?", *f.CreatedBefore)
+	}
+	if len(f.EventParams) > 0 {
+		params := EventParams{}
+		for k, v := range f.EventParams {
+			value, ok := jsonValue(v)
+			if !ok {
+				params[k] = v
+				continue
+			}
+			// the value may have been stored as a JSON value (eg: a number or a boolean) or as a string
+			asValue, err := json.Marshal(EventParams{k: value})
+			if err != nil {
+				return nil, errors.NewBadParameterError("event_params", f.EventParams)
+			}
+			asString, err := json.Marshal(EventParams{k: v})
+			if err != nil {
+				return nil, errors.NewBadParameterError("event_params", f.EventParams)
+			}
+			db = db.Where("(event_params @> ?::jsonb or event_params @> ?::jsonb)", string(asValue), string(asString))
+		}
+		if len(params) > 0 {
+			p, err := json.Marshal(params)
+			if err != nil {
+				return nil, errors.NewBadParameterError("event_params", f.EventParams)
+			}
+			// use the JSONB containment operator, which can benefit from a GIN index on the column
+			db = db.Where("event_params @> ?::jsonb", string(p))
+		}
+	}
+	return db, nil
+}
+
+// jsonValue parses the given string value of an event param as JSON
+// returns the parsed value and true if it is a valid JSON value other than a string (eg: a number or a boolean),
+// false otherwise
+func jsonValue(v interface{}) (interface{}, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, false
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return nil, false
+	}
+	if _, isString := value.(string); isString {
+		return nil, false
+	}
+	return value, true
+}
+
+// orderBy returns the 'ORDER BY' clause matching the sort order of the filter. The records created at the same time
+// are sorted by ID, so that the pages are stable.
+func (f Filter) orderBy() string {
+	if f.Sort == SortDescending {
+		return "created_at desc, audit_log_id desc"
+	}
+	return "created_at, audit_log_id"
+}
+
+// escapeLike escapes the wildcard characters of the given value so it can be used in a 'LIKE' clause
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -0,0 +1,169 @@
+package auditlog
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/closeable"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// The idempotency keys provided by the clients are kept in a separate table, along with the ID and creation date of the
+// record which was created with each key and the hash of its payload, so that a retried request can be answered with
+// the original record, and a request which reuses a key with a different payload can be rejected.
+// The keys are deleted along with their record when it is archived, and are purged once they expired.
+
+const (
+	idempotencyKeyTableName = "audit_log_idempotency_key"
+)
+
+// idempotencyKey the record which was created with an idempotency key, along with the hash of its payload
+type idempotencyKey struct {
+	record *AuditLog
+	// payloadHash the hash of the payload of the record, or an empty string if it is unknown
+	payloadHash string
+}
+
+// payloadHash returns the hash of the payload of the given record, ie, its content which is provided by the client
+func payloadHash(r *AuditLog) (string, error) {
+	params := r.EventParams
+	if len(params) == 0 {
+		params = nil
+	}
+	content, err := json.Marshal(struct {
+		EventTypeID uuid.UUID   `json:"event_type_id"`
+		IdentityID  uuid.UUID   `json:"identity_id"`
+		Username    string      `json:"username"`
+		EventParams EventParams `json:"event_params"`
+	}{
+		EventTypeID: r.EventTypeID,
+		IdentityID:  r.IdentityID,
+		Username:    r.Username,
+		EventParams: params,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// checkReplay verifies that the given record has the same payload as the original record created with the same
+// idempotency key
+// returns a DataConflictError if the payloads differ
+func checkReplay(ctx context.Context, r *AuditLog, original idempotencyKey) error {
+	if original.payloadHash == "" {
+		// the key was stored before the payload hashes were
+		return nil
+	}
+	hash, err := payloadHash(r)
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	if hash != original.payloadHash {
+		return errors.NewDataConflictError(fmt.Sprintf("the idempotency key '%s' was already used with a different payload", r.IdempotencyKey))
+	}
+	return nil
+}
+
+// loadIdempotencyKeys returns the (ID and creation date of the) records which were already created with the
+// idempotency keys of the given records, along with the hash of their payload, indexed by key
+func loadIdempotencyKeys(ctx context.Context, tx *gorm.DB, records []*AuditLog) (map[string]idempotencyKey, error) {
+	originals := map[string]idempotencyKey{}
+	keys := []string{}
+	for _, r := range records {
+		if r.IdempotencyKey != "" {
+			keys = append(keys, r.IdempotencyKey)
+		}
+	}
+	if len(keys) == 0 {
+		return originals, nil
+	}
+	rows, err := tx.Raw("select idempotency_key, audit_log_id, created_at, payload_hash from "+idempotencyKeyTableName+
+		" where idempotency_key in (?)", keys).Rows()
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	defer closeable.Close(ctx, rows)
+	for rows.Next() {
+		var key string
+		var id uuid.UUID
+		var createdAt time.Time
+		var hash sql.NullString
+		if err := rows.Scan(&key, &id, &createdAt, &hash); err != nil {
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		originals[key] = idempotencyKey{
+			record: &AuditLog{
+				ID:             id,
+				CreatedAt:      createdAt,
+				IdempotencyKey: key,
+			},
+			payloadHash: hash.String,
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return originals, nil
+}
+
+// storeIdempotencyKeys stores the idempotency keys of the given (newly created) records, along with the hash of their payload
+func storeIdempotencyKeys(ctx context.Context, tx *gorm.DB, records []*AuditLog) error {
+	values := []string{}
+	args := []interface{}{}
+	for _, r := range records {
+		if r.IdempotencyKey == "" {
+			continue
+		}
+		hash, err := payloadHash(r)
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		values = append(values, "(?, ?, ?, ?)")
+		args = append(args, r.IdempotencyKey, r.ID, r.CreatedAt, hash)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	err := tx.Exec("insert into "+idempotencyKeyTableName+" (idempotency_key, audit_log_id, created_at, payload_hash) values "+
+		strings.Join(values, ", "), args...).Error
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// deleteIdempotencyKeys deletes the idempotency keys of the records with the given IDs
+func deleteIdempotencyKeys(ctx context.Context, tx *gorm.DB, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Exec("delete from "+idempotencyKeyTableName+" where audit_log_id in (?)", ids).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes the idempotency keys which were created before the given date, along with those whose
+// record no longer exists (eg: because its partition was dropped). A request with a purged key records a new audit log.
+// returns the number of deleted keys
+func (r *GormAuditLogRepository) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLog", "purge_idempotency_keys"}, time.Now())
+	result := r.db.Exec("delete from "+idempotencyKeyTableName+" k where k.created_at < ?"+
+		" or not exists (select 1 from "+recordTableName+" r where r.audit_log_id = k.audit_log_id and r.created_at = k.created_at)", before)
+	if result.Error != nil {
+		return 0, errors.NewInternalError(ctx, result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
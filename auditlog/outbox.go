@@ -0,0 +1,241 @@
+package auditlog
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/admin-console/gormutil"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// The audit log records are also written in an outbox table, in the same transaction as the records themselves, so that
+// they can be published as events to the other services (see the `outbox` package) even if the broker is unavailable when
+// they are created. The entries are published in order, and they are deleted once they were published. An entry which
+// could not be published is retried after a delay which doubles with each failed attempt, and the next entries of the
+// same user are held back until it is published. After `MaxPublishAttempts` failed attempts, the entry is marked as dead:
+// it is kept in the outbox for investigation, but it is no longer retried and no longer holds back the next entries.
+
+const (
+	outboxTableName = "audit_log_outbox"
+	// outboxLockID the key of the advisory lock held while the outbox entries are published, so that concurrent
+	// relays do not publish the entries of a user out of order
+	outboxLockID = 0x6f7574626f78 // "outbox"
+	// MaxPublishAttempts the number of failed attempts after which an outbox entry is marked as dead
+	MaxPublishAttempts = 15
+	// minPublishRetryDelay the delay before the first retry of an outbox entry which could not be published
+	minPublishRetryDelay = 5 * time.Second
+	// maxPublishRetryDelay the maximum delay before the retry of an outbox entry which could not be published
+	maxPublishRetryDelay = time.Hour
+)
+
+// OutboxEntry an audit log record waiting to be published
+type OutboxEntry struct {
+	Seq         int64     `gorm:"primary_key"`
+	AuditLogID  uuid.UUID `sql:"type:uuid"`
+	CreatedAt   time.Time
+	IdentityID  uuid.UUID   `sql:"type:uuid"`
+	Username    string      `sql:"type:string"`
+	EventTypeID uuid.UUID   `sql:"type:uuid"`
+	EventParams EventParams `sql:"type:jsonb"`
+	// Attempts the number of failed attempts to publish the entry
+	Attempts int
+	// LastError the error which occurred during the last failed attempt to publish the entry
+	LastError string
+	// ClaimedUntil the end of the lease of the relay which is publishing the entry, if any
+	ClaimedUntil *time.Time
+	// NextAttemptAt the time before which the entry is not retried after a failed attempt, if any
+	NextAttemptAt *time.Time
+	// DeadAt the time at which the entry was marked as dead after too many failed attempts, if it was
+	DeadAt *time.Time
+}
+
+// TableName implements gorm.tabler
+func (e OutboxEntry) TableName() string {
+	return outboxTableName
+}
+
+// PartitionKey returns the key under which the entries must be published in order: the username,
+// or the identity ID for the records which have no username
+func (e OutboxEntry) PartitionKey() string {
+	if e.Username != "" {
+		return e.Username
+	}
+	return e.IdentityID.String()
+}
+
+// PublishFunc publishes the given outbox entry
+type PublishFunc func(entry OutboxEntry) error
+
+// outboxInsertBatchSize the maximum number of outbox entries inserted with a single statement
+const outboxInsertBatchSize = 1000
+
+// storeOutboxEntries inserts the outbox entries of the given (newly created) records
+func storeOutboxEntries(ctx context.Context, tx *gorm.DB, records []*AuditLog) error {
+	for start := 0; start < len(records); start += outboxInsertBatchSize {
+		end := start + outboxInsertBatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*6)
+		for _, r := range records[start:end] {
+			values = append(values, "(?, ?, ?, ?, ?, ?)")
+			args = append(args, r.ID, r.CreatedAt, r.IdentityID, r.Username, r.EventTypeID, r.EventParams)
+		}
+		err := tx.Exec("insert into "+outboxTableName+
+			" (audit_log_id, created_at, identity_id, username, event_type_id, event_params) values "+
+			strings.Join(values, ", "), args...).Error
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+	}
+	return nil
+}
+
+// PublishPending passes at most `limit` outbox entries to the given `publish` function, oldest first, and deletes the
+// entries which were published. When an entry could not be published, its number of attempts and last error are updated,
+// and it is not retried before a delay (see `publishRetryDelay`), during which the next entries with the same partition
+// key are held back, so that they are published in order during a subsequent call. The entry is marked as dead instead
+// once it failed `MaxPublishAttempts` times.
+// The entries are claimed (ie, leased for the given duration) before they are passed to the `publish` function, and no
+// transaction is open while they are published. Nothing is published while the entries claimed by a concurrent call are
+// still leased, and the entries whose lease expired (eg: because the relay stopped while publishing them) are published again.
+// returns the number of published entries, a BadParameterError if the `limit` is invalid (negative), the first error
+// returned by the `publish` function, or an InternalError if something wrong happened while qyerying or updating the database
+func (r *GormAuditLogRepository) PublishPending(ctx context.Context, limit int, lease time.Duration, publish PublishFunc) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "publish_pending"}, time.Now())
+	if limit <= 0 {
+		return 0, errors.NewBadParameterError("limit", limit)
+	}
+	entries, err := r.claimPending(ctx, limit, lease)
+	if err != nil {
+		return 0, err
+	}
+	published := []int64{}
+	failed := map[int64]error{}
+	attempts := map[int64]int{}
+	heldBack := map[string]bool{}
+	var publishErr error
+	for _, entry := range entries {
+		key := entry.PartitionKey()
+		if heldBack[key] {
+			continue
+		}
+		if err := publish(entry); err != nil {
+			heldBack[key] = true
+			failed[entry.Seq] = err
+			attempts[entry.Seq] = entry.Attempts + 1
+			if publishErr == nil {
+				publishErr = err
+			}
+			continue
+		}
+		published = append(published, entry.Seq)
+	}
+	err = gormutil.InTransaction(r.db, func(tx *gorm.DB) error {
+		if len(published) > 0 {
+			if err := tx.Exec("delete from "+outboxTableName+" where seq in (?)", published).Error; err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		for seq, err := range failed {
+			var deadAt *time.Time
+			if attempts[seq] >= MaxPublishAttempts {
+				deadAt = &now
+			}
+			err = tx.Exec("update "+outboxTableName+" set attempts = ?, last_error = ?, next_attempt_at = ?, dead_at = ? where seq = ?",
+				attempts[seq], err.Error(), now.Add(publishRetryDelay(attempts[seq])), deadAt, seq).Error
+			if err != nil {
+				return err
+			}
+		}
+		// release the lease on the entries which were not published
+		claimed := make([]int64, len(entries))
+		for i, entry := range entries {
+			claimed[i] = entry.Seq
+		}
+		if len(claimed) > 0 {
+			return tx.Exec("update "+outboxTableName+" set claimed_until = null where seq in (?)", claimed).Error
+		}
+		return nil
+	})
+	if err != nil {
+		// the entries which were published will be published again once their lease expired
+		return 0, errors.NewInternalError(ctx, err)
+	}
+	return len(published), publishErr
+}
+
+// publishRetryDelay returns the delay before the next attempt to publish an outbox entry which failed the given number
+// of times: it doubles with each failed attempt, up to `maxPublishRetryDelay`
+func publishRetryDelay(attempts int) time.Duration {
+	delay := minPublishRetryDelay
+	for i := 1; i < attempts && delay < maxPublishRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxPublishRetryDelay {
+		return maxPublishRetryDelay
+	}
+	return delay
+}
+
+// claimPending leases at most `limit` outbox entries, oldest first, until the end of the given duration, unless the
+// entries claimed by a concurrent call are still leased, so that the entries of a user are not published out of order.
+// The dead entries are skipped, as well as the entries whose partition key is held back by an entry waiting to be retried
+// (including the entry itself), so that they do not fill the batch at the expense of the entries of the other keys.
+// returns the claimed entries
+func (r *GormAuditLogRepository) claimPending(ctx context.Context, limit int, lease time.Duration) ([]OutboxEntry, error) {
+	entries := []OutboxEntry{}
+	err := gormutil.InTransaction(r.db, func(tx *gorm.DB) error {
+		if err := tx.Exec("select pg_advisory_xact_lock(?)", outboxLockID).Error; err != nil {
+			return err
+		}
+		var leased int
+		if err := tx.Model(&OutboxEntry{}).Where("claimed_until > ?", time.Now()).Count(&leased).Error; err != nil {
+			return err
+		}
+		if leased > 0 {
+			return nil
+		}
+		now := time.Now()
+		err := tx.Raw("select * from "+outboxTableName+" o where o.dead_at is null and not exists ("+
+			"select 1 from "+outboxTableName+" b where b.dead_at is null and b.next_attempt_at > ? and b.seq <= o.seq"+
+			" and "+outboxPartitionKey("b")+" = "+outboxPartitionKey("o")+
+			") order by o.seq limit ?", now, limit).Scan(&entries).Error
+		if err != nil || len(entries) == 0 {
+			return err
+		}
+		seqs := make([]int64, len(entries))
+		for i, entry := range entries {
+			seqs[i] = entry.Seq
+		}
+		return tx.Exec("update "+outboxTableName+" set claimed_until = ? where seq in (?)", now.Add(lease), seqs).Error
+	})
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return entries, nil
+}
+
+// outboxPartitionKey returns the SQL expression of the partition key of the outbox entries with the given alias
+// (see `OutboxEntry.PartitionKey`)
+func outboxPartitionKey(alias string) string {
+	return "coalesce(nullif(" + alias + ".username, ''), " + alias + ".identity_id::text)"
+}
+
+// CountPending returns the number of outbox entries waiting to be published, ie, which are not dead
+// returns an InternalError if something wrong happened while qyerying the database
+func (r *GormAuditLogRepository) CountPending(ctx context.Context) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "count_pending"}, time.Now())
+	var count int
+	if err := r.db.Model(&OutboxEntry{}).Where("dead_at is null").Count(&count).Error; err != nil {
+		return 0, errors.NewInternalError(ctx, err)
+	}
+	return count, nil
+}
@@ -0,0 +1,104 @@
+package auditlog
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
+)
+
+// The outcome of an audited action (ie, the status code of the response, the outcome, the latency, etc.) is usually
+// only known once the action completed, ie, after the record was appended to the chain. For this reason, the outcome
+// fields are not covered by the hash of the record, and they can be set only once.
+
+// Outcome the outcome of an audited action
+type Outcome string
+
+const (
+	// OutcomeSuccess the action succeeded
+	OutcomeSuccess Outcome = "success"
+	// OutcomeDenied the action was denied to the user
+	OutcomeDenied Outcome = "denied"
+	// OutcomeError the action failed
+	OutcomeError Outcome = "error"
+)
+
+// OutcomeOf returns the outcome corresponding to the given HTTP status code
+func OutcomeOf(statusCode int) Outcome {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return OutcomeDenied
+	case statusCode >= http.StatusBadRequest:
+		return OutcomeError
+	default:
+		return OutcomeSuccess
+	}
+}
+
+// Value implements the driver.Valuer interface (an empty outcome is stored as NULL)
+func (o Outcome) Value() (driver.Value, error) {
+	if o == "" {
+		return nil, nil
+	}
+	return string(o), nil
+}
+
+// Scan implements the https://golang.org/pkg/database/sql/#Scanner interface
+func (o *Outcome) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*o = ""
+	case string:
+		*o = Outcome(v)
+	case []byte:
+		*o = Outcome(v)
+	default:
+		return errs.Errorf("unable to scan outcome from %T", src)
+	}
+	return nil
+}
+
+// ActionResult the result of an audited action, once it completed
+type ActionResult struct {
+	// StatusCode the HTTP status code of the response
+	StatusCode int
+	// Latency the time it took to perform the action
+	Latency time.Duration
+	// ResponseSize the size of the response body, in bytes
+	ResponseSize int64
+	// ResultCount the number of results returned by the action, if relevant
+	ResultCount *int
+}
+
+// RecordOutcome sets the status code, outcome, latency, response size and result count of the given record, once the
+// audited action completed. The outcome of a record can only be set once.
+// returns a NotFoundError if the record does not exist or if its outcome was already set, or an InternalError if
+// something wrong happened
+func (r *GormAuditLogRepository) RecordOutcome(ctx context.Context, auditLog *AuditLog, result ActionResult) error {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLog", "record_outcome"}, time.Now())
+	statusCode := result.StatusCode
+	outcome := OutcomeOf(statusCode)
+	latencyMs := int64(result.Latency / time.Millisecond)
+	responseSize := result.ResponseSize
+	// also filter on the creation date, so that only the relevant partition is scanned
+	res := r.db.Exec("update "+recordTableName+" set status_code = ?, outcome = ?, latency_ms = ?, response_size = ?, result_count = ? "+
+		"where audit_log_id = ? and created_at = ? and outcome is null",
+		statusCode, outcome, latencyMs, responseSize, result.ResultCount, auditLog.ID, auditLog.CreatedAt)
+	if res.Error != nil {
+		return errors.NewInternalError(ctx, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return errors.NewNotFoundError("auditlog_record", auditLog.ID.String())
+	}
+	auditLog.StatusCode = &statusCode
+	auditLog.Outcome = outcome
+	auditLog.LatencyMs = &latencyMs
+	auditLog.ResponseSize = &responseSize
+	auditLog.ResultCount = result.ResultCount
+	return nil
+}
@@ -0,0 +1,92 @@
+package auditlog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	errs "github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// ParamsValidationError the error returned when the params of an event do not match the JSON schema of its type
+type ParamsValidationError struct {
+	EventType string
+	Errors    []ParamError
+}
+
+// ParamError a single mismatch between the params of an event and the JSON schema of its type
+type ParamError struct {
+	// Pointer the JSON pointer to the invalid value, relative to the event params (eg: "/query")
+	Pointer string
+	// Detail a description of the problem
+	Detail string
+}
+
+// Error implements the error interface
+func (e ParamsValidationError) Error() string {
+	details := make([]string, len(e.Errors))
+	for i, pe := range e.Errors {
+		details[i] = fmt.Sprintf("%s: %s", pe.Pointer, pe.Detail)
+	}
+	return fmt.Sprintf("invalid params for event type '%s': %s", e.EventType, strings.Join(details, "; "))
+}
+
+// ValidateSchema verifies that the given JSON schema can be used to validate event params
+// returns a BadParameterError if the schema is invalid
+func ValidateSchema(schema JSONSchema) error {
+	if schema == nil {
+		return nil
+	}
+	if _, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(map[string]interface{}(schema))); err != nil {
+		return errors.NewBadParameterErrorFromString(fmt.Sprintf("invalid params schema: %s", err.Error()))
+	}
+	return nil
+}
+
+// ValidateParams validates the given params against the JSON schema of this event type, if any.
+// Missing params are validated as an empty object.
+// returns a ParamsValidationError if the params do not match the schema
+func (t EventType) ValidateParams(params EventParams) error {
+	if t.ParamsSchema == nil {
+		return nil
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(map[string]interface{}(t.ParamsSchema)))
+	if err != nil {
+		return errs.Wrapf(err, "invalid params schema for event type '%s'", t.Name)
+	}
+	if params == nil {
+		params = EventParams{}
+	}
+	result, err := schema.Validate(gojsonschema.NewGoLoader(map[string]interface{}(params)))
+	if err != nil {
+		return errs.Wrapf(err, "unable to validate the params of event type '%s'", t.Name)
+	}
+	if result.Valid() {
+		return nil
+	}
+	verr := ParamsValidationError{
+		EventType: t.Name,
+	}
+	for _, re := range result.Errors() {
+		verr.Errors = append(verr.Errors, ParamError{
+			Pointer: toJSONPointer(re.Field()),
+			Detail:  re.Description(),
+		})
+	}
+	return verr
+}
+
+// toJSONPointer converts the dotted path of a field (as returned by the validator) into a JSON pointer
+func toJSONPointer(field string) string {
+	if field == "" || field == "(root)" {
+		return ""
+	}
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	segments := strings.Split(field, ".")
+	for i, s := range segments {
+		segments[i] = escaper.Replace(s)
+	}
+	return "/" + strings.Join(segments, "/")
+}
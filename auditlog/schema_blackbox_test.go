@@ -0,0 +1,109 @@
+package auditlog_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateParams(t *testing.T) {
+	// given
+	eventType := auditlog.EventType{
+		Name: "user_search",
+		ParamsSchema: auditlog.JSONSchema{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":      "string",
+					"maxLength": 10,
+				},
+				"a/b": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"c": map[string]interface{}{
+							"type": "integer",
+						},
+					},
+				},
+			},
+			"additionalProperties": false,
+		},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		// when
+		err := eventType.ValidateParams(auditlog.EventParams{
+			"query": "foo",
+		})
+		// then
+		assert.NoError(t, err)
+	})
+
+	t.Run("no params", func(t *testing.T) {
+		// when
+		err := eventType.ValidateParams(nil)
+		// then
+		assert.NoError(t, err)
+	})
+
+	t.Run("no schema", func(t *testing.T) {
+		// when
+		err := auditlog.EventType{Name: "foo"}.ValidateParams(auditlog.EventParams{
+			"query": 1,
+		})
+		// then
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		// when
+		err := eventType.ValidateParams(auditlog.EventParams{
+			"query": "a very long query",
+			"a/b": map[string]interface{}{
+				"c": "foo",
+			},
+		})
+		// then
+		require.IsType(t, auditlog.ParamsValidationError{}, err)
+		pointers := []string{}
+		for _, e := range err.(auditlog.ParamsValidationError).Errors {
+			pointers = append(pointers, e.Pointer)
+		}
+		assert.ElementsMatch(t, []string{"/query", "/a~1b/c"}, pointers)
+	})
+
+	t.Run("unexpected params", func(t *testing.T) {
+		// when
+		err := eventType.ValidateParams(auditlog.EventParams{
+			"foo": "bar",
+		})
+		// then
+		require.IsType(t, auditlog.ParamsValidationError{}, err)
+		assert.Equal(t, "", err.(auditlog.ParamsValidationError).Errors[0].Pointer)
+	})
+}
+
+func TestValidateSchema(t *testing.T) {
+
+	t.Run("ok", func(t *testing.T) {
+		// when
+		err := auditlog.ValidateSchema(auditlog.JSONSchema{
+			"type": "object",
+		})
+		// then
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		// when
+		err := auditlog.ValidateSchema(auditlog.JSONSchema{
+			"type": "foo",
+		})
+		// then
+		assert.IsType(t, errors.BadParameterError{}, err)
+	})
+}
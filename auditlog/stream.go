@@ -0,0 +1,141 @@
+package auditlog
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	"github.com/lib/pq"
+	errs "github.com/pkg/errors"
+)
+
+// The new audit log records can be streamed to the clients: a trigger on the `audit_log` table notifies the
+// listeners on the `audit_log` channel when a record is inserted (ie, when the transaction is committed), and the
+// listeners then fetch the records which follow the last one they received, in the order of the chain.
+// Since the records are appended to the chain while its head is locked, their sequence numbers become visible in order,
+// and a client can resume a stream from the sequence number of the last record it received.
+
+const (
+	notifyChannel = "audit_log"
+	// notifierPingInterval the interval after which the connection of the notifier is checked if no notification was received
+	notifierPingInterval = 90 * time.Second
+)
+
+// LastSeq returns the sequence number of the last record appended to the chain (0 if the chain is empty)
+// returns an InternalError if something wrong happened while querying the database
+func (r *GormAuditLogRepository) LastSeq(ctx context.Context) (int64, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "last_seq"}, time.Now())
+	var seq int64
+	row := r.db.Raw("select seq from " + chainTableName + " where id = 1").Row()
+	if err := row.Scan(&seq); err != nil {
+		return 0, errors.NewInternalError(ctx, errs.Wrap(err, "unable to read the head of the audit log chain"))
+	}
+	return seq, nil
+}
+
+// ListAfterSeq returns the (at most `limit`) records matching the given filter which were appended to the chain after
+// the record with the given sequence number, in the order of the chain. The sort order of the filter is ignored.
+// returns a BadParameterError if the filter or the limit is invalid, or an InternalError if something wrong happened
+// while querying the database
+func (r *GormAuditLogRepository) ListAfterSeq(ctx context.Context, filter Filter, after int64, limit int) ([]AuditLog, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "auditLogs", "list_after_seq"}, time.Now())
+	if limit <= 0 {
+		return nil, errors.NewBadParameterError("limit", limit)
+	}
+	db, err := filter.apply(r.db.Model(&AuditLog{}))
+	if err != nil {
+		return nil, err
+	}
+	result := []AuditLog{}
+	err = db.Where("chain_seq > ?", after).Order("chain_seq").Limit(limit).Find(&result).Error
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return result, nil
+}
+
+// Notifier listens to the notifications sent by PostgreSQL when audit log records are inserted, and signals them
+// to its subscribers. A single database connection is used, whatever the number of subscribers.
+type Notifier struct {
+	listener    *pq.Listener
+	mux         sync.Mutex
+	subscribers map[chan struct{}]bool
+}
+
+// NewNotifier creates a notifier which listens to the notifications on a dedicated connection to the database
+// (given its connection string). The connection is re-established if it is lost.
+func NewNotifier(connStr string) (*Notifier, error) {
+	n := &Notifier{
+		subscribers: map[chan struct{}]bool{},
+	}
+	n.listener = pq.NewListener(connStr, time.Second, time.Minute, n.onListenerEvent)
+	if err := n.listener.Listen(notifyChannel); err != nil {
+		n.listener.Close()
+		return nil, errs.Wrapf(err, "unable to listen to the '%s' notifications", notifyChannel)
+	}
+	return n, nil
+}
+
+// Start forwards the notifications to the subscribers until the given context is done, then closes the connection
+func (n *Notifier) Start(ctx context.Context) {
+	defer n.listener.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.listener.Notify:
+			// also signaled with a nil notification after the connection was re-established, in which case
+			// some notifications may have been missed
+			n.broadcast()
+		case <-time.After(notifierPingInterval):
+			go func() {
+				if err := n.listener.Ping(); err != nil {
+					log.Error(nil, map[string]interface{}{
+						"err": err,
+					}, "audit log notifier connection is broken")
+				}
+			}()
+		}
+	}
+}
+
+// Subscribe returns a channel which is signaled when new audit log records may have been stored, along with a function
+// to call to unsubscribe. Signals are coalesced: a subscriber which is busy receives a single signal for all the
+// notifications which occurred since it last read the channel.
+func (n *Notifier) Subscribe() (<-chan struct{}, func()) {
+	c := make(chan struct{}, 1)
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.subscribers[c] = true
+	return c, func() {
+		n.mux.Lock()
+		defer n.mux.Unlock()
+		delete(n.subscribers, c)
+	}
+}
+
+func (n *Notifier) broadcast() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	for c := range n.subscribers {
+		select {
+		case c <- struct{}{}:
+		default: // a signal is already pending
+		}
+	}
+}
+
+func (n *Notifier) onListenerEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventDisconnected, pq.ListenerEventConnectionAttemptFailed:
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "audit log notifier is disconnected")
+	case pq.ListenerEventReconnected:
+		log.Info(nil, map[string]interface{}{}, "audit log notifier is reconnected")
+	}
+}
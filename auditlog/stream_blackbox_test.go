@@ -0,0 +1,126 @@
+package auditlog_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type StreamBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	config *configuration.Configuration
+	repo   auditlog.Repository
+}
+
+func TestStream(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &StreamBlackboxTestSuite{
+		DBTestSuite: testsuite.NewDBTestSuite(config),
+		config:      config,
+	})
+}
+
+func (s *StreamBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.repo = auditlog.NewRepository(s.DB)
+}
+
+func (s *StreamBlackboxTestSuite) TestListAfterSeq() {
+	// given
+	last, err := s.repo.LastSeq(context.Background())
+	require.NoError(s.T(), err)
+	username := fmt.Sprintf("user-%s", uuid.NewV4())
+	records := make([]*auditlog.AuditLog, 3)
+	for i, eventTypeID := range []uuid.UUID{auditlog.UserSearch, auditlog.StartTenantUpdate, auditlog.UserSearch} {
+		records[i] = &auditlog.AuditLog{
+			EventTypeID: eventTypeID,
+			Username:    username,
+			EventParams: auditlog.EventParams{},
+		}
+		err := s.repo.Create(context.Background(), records[i])
+		require.NoError(s.T(), err)
+	}
+
+	s.T().Run("head of the chain", func(t *testing.T) {
+		// when
+		head, err := s.repo.LastSeq(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.True(t, head >= records[2].ChainSeq) // other records may have been created by other tests
+	})
+
+	s.T().Run("all records after seq", func(t *testing.T) {
+		// when
+		result, err := s.repo.ListAfterSeq(context.Background(), auditlog.Filter{UsernamePrefix: username}, last, 10)
+		// then
+		require.NoError(t, err)
+		require.Len(t, result, 3)
+		for i, r := range result {
+			assert.Equal(t, records[i].ID, r.ID)
+		}
+	})
+
+	s.T().Run("filtered by event type", func(t *testing.T) {
+		// when
+		result, err := s.repo.ListAfterSeq(context.Background(), auditlog.Filter{
+			UsernamePrefix: username,
+			EventTypes:     []string{auditlog.UserSearchEvent},
+		}, records[0].ChainSeq, 10)
+		// then
+		require.NoError(t, err)
+		require.Len(t, result, 1)
+		assert.Equal(t, records[2].ID, result[0].ID)
+	})
+
+	s.T().Run("limited", func(t *testing.T) {
+		// when
+		result, err := s.repo.ListAfterSeq(context.Background(), auditlog.Filter{UsernamePrefix: username}, last, 2)
+		// then
+		require.NoError(t, err)
+		require.Len(t, result, 2)
+		assert.Equal(t, records[1].ID, result[1].ID)
+	})
+
+	s.T().Run("invalid limit", func(t *testing.T) {
+		// when
+		_, err := s.repo.ListAfterSeq(context.Background(), auditlog.Filter{}, last, 0)
+		// then
+		require.Error(t, err)
+	})
+}
+
+func (s *StreamBlackboxTestSuite) TestNotifier() {
+	// given
+	notifier, err := auditlog.NewNotifier(s.config.GetPostgresConfigString())
+	require.NoError(s.T(), err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Start(ctx)
+	signals, unsubscribe := notifier.Subscribe()
+	defer unsubscribe()
+	// when
+	err = s.repo.Create(context.Background(), &auditlog.AuditLog{
+		EventTypeID: auditlog.UserSearch,
+		Username:    fmt.Sprintf("user-%s", uuid.NewV4()),
+		EventParams: auditlog.EventParams{},
+	})
+	require.NoError(s.T(), err)
+	// then
+	select {
+	case <-signals:
+	case <-time.After(5 * time.Second):
+		assert.Fail(s.T(), "no notification received after the record was created")
+	}
+}
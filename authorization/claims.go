@@ -0,0 +1,80 @@
+package authorization
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ClaimPermissions the permissions granted to the users whose token contains a given claim value, indexed by claim
+// and by value. The claim may be a path to a nested claim, such as `realm_access.roles`.
+type ClaimPermissions map[string]map[string][]Permission
+
+// NewClaimPermissions converts the given mapping of claim values to permission names (as provided by the configuration)
+// returns an error if a permission is unknown
+func NewClaimPermissions(mapping map[string]map[string][]string) (ClaimPermissions, error) {
+	result := make(ClaimPermissions, len(mapping))
+	for claim, values := range mapping {
+		result[claim] = make(map[string][]Permission, len(values))
+		for value, permissions := range values {
+			for _, name := range permissions {
+				p := Permission(name)
+				if !p.IsValid() {
+					return nil, fmt.Errorf("unknown permission '%s' granted by claim '%s:%s'", name, claim, value)
+				}
+				result[claim][value] = append(result[claim][value], p)
+			}
+		}
+	}
+	return result, nil
+}
+
+// PermissionsOf returns the (sorted) permissions granted by the given token claims
+func (c ClaimPermissions) PermissionsOf(claims jwt.MapClaims) []Permission {
+	granted := map[Permission]bool{}
+	for claim, values := range c {
+		for _, value := range claimValues(claims, claim) {
+			for _, p := range values[value] {
+				granted[p] = true
+			}
+		}
+	}
+	permissions := make([]Permission, 0, len(granted))
+	for p := range granted {
+		permissions = append(permissions, p)
+	}
+	sort.Slice(permissions, func(i, j int) bool {
+		return permissions[i] < permissions[j]
+	})
+	return permissions
+}
+
+// claimValues returns the string value(s) of the claim at the given path (eg: `realm_access.roles`),
+// or nil if the claim is missing or if it is neither a string nor an array of strings
+func claimValues(claims jwt.MapClaims, path string) []string {
+	var current interface{} = map[string]interface{}(claims)
+	for _, segment := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[segment]
+	}
+	switch v := current.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
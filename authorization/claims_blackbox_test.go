@@ -0,0 +1,81 @@
+package authorization_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/admin-console/authorization"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimPermissions(t *testing.T) {
+
+	// given
+	claimPermissions, err := authorization.NewClaimPermissions(map[string]map[string][]string{
+		"groups": {
+			"tenant-admins": {"start_tenant_update", "stop_tenant_update"},
+		},
+		"realm_access.roles": {
+			"auditor": {"read_audit_logs", "export_audit_logs"},
+			"support": {"read_audit_logs", "search_users"},
+		},
+	})
+	require.NoError(t, err)
+
+	t.Run("nested claims", func(t *testing.T) {
+		// given
+		claims := jwt.MapClaims{
+			"realm_access": map[string]interface{}{
+				"roles": []interface{}{"auditor", "support", "offline_access"},
+			},
+		}
+		// when
+		permissions := claimPermissions.PermissionsOf(claims)
+		// then
+		assert.Equal(t, []authorization.Permission{
+			authorization.ExportAuditLogs,
+			authorization.ReadAuditLogs,
+			authorization.SearchUsers,
+		}, permissions)
+	})
+
+	t.Run("single value claim", func(t *testing.T) {
+		// given
+		claims := jwt.MapClaims{
+			"groups": "tenant-admins",
+		}
+		// when
+		permissions := claimPermissions.PermissionsOf(claims)
+		// then
+		assert.Equal(t, []authorization.Permission{
+			authorization.StartTenantUpdate,
+			authorization.StopTenantUpdate,
+		}, permissions)
+	})
+
+	t.Run("no matching claim", func(t *testing.T) {
+		// given
+		claims := jwt.MapClaims{
+			"groups":       []interface{}{"developers"},
+			"realm_access": "auditor",
+		}
+		// when
+		permissions := claimPermissions.PermissionsOf(claims)
+		// then
+		assert.Empty(t, permissions)
+	})
+
+	t.Run("unknown permission", func(t *testing.T) {
+		// when
+		_, err := authorization.NewClaimPermissions(map[string]map[string][]string{
+			"groups": {
+				"tenant-admins": {"start_everything"},
+			},
+		})
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown permission 'start_everything' granted by claim 'groups:tenant-admins'")
+	})
+}
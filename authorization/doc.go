@@ -0,0 +1,3 @@
+// Package authorization contains the roles and permissions which are granted to the users, and the goa middleware
+// which verifies that the user has the permission required by the requested action.
+package authorization
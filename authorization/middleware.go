@@ -0,0 +1,82 @@
+package authorization
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+)
+
+// DenialRecorder records that the user with the given username (or an unidentified user if the username is empty)
+// was denied the requested action because she lacks the given permission, along with the status code of the response.
+// The controller and action are available in the given context.
+type DenialRecorder func(ctx context.Context, username string, permission Permission, statusCode int) error
+
+// Middleware returns a goa middleware which loads the permissions granted to the user identified by the
+// `preferred_username` claim of the request token (via her roles and via the claims of her token), stores them in the
+// request context and verifies that they include the permission required by the requested action, if any.
+// Denied actions are recorded with the given recorder.
+// Must be used after the middleware which stores the request token in the context.
+func Middleware(roles RoleRepository, claims ClaimPermissions, recordDenial DenialRecorder) goa.Middleware {
+	return func(h goa.Handler) goa.Handler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			username := Username(ctx)
+			if username != "" {
+				permissions, err := roles.PermissionsOf(ctx, username)
+				if err != nil {
+					return err
+				}
+				permissions = append(permissions, claims.PermissionsOf(tokenClaims(ctx))...)
+				ctx = WithPermissions(ctx, permissions...)
+			}
+			controller := goa.ContextController(ctx)
+			action := goa.ContextAction(ctx)
+			if required, found := RequiredPermission(controller, action); found && !HasPermission(ctx, required) {
+				log.Error(ctx, map[string]interface{}{
+					"username":   username,
+					"controller": controller,
+					"action":     action,
+					"permission": required,
+				}, "user is not allowed to perform the action")
+				var denial error = errors.NewForbiddenError(fmt.Sprintf("missing permission '%s'", required))
+				statusCode := http.StatusForbidden
+				if username == "" {
+					denial = errors.NewUnauthorizedError("missing or invalid authorization token")
+					statusCode = http.StatusUnauthorized
+				}
+				if err := recordDenial(ctx, username, required, statusCode); err != nil {
+					log.Error(ctx, map[string]interface{}{
+						"err":        err,
+						"username":   username,
+						"permission": required,
+					}, "unable to record the denied action")
+				}
+				return denial
+			}
+			return h(ctx, rw, req)
+		}
+	}
+}
+
+// Username returns the value of the `preferred_username` claim of the request token, or an empty string if
+// there is no token or if the claim is missing
+func Username(ctx context.Context) string {
+	username, _ := tokenClaims(ctx)["preferred_username"].(string)
+	return username
+}
+
+// tokenClaims returns the claims of the request token, or nil if there is no token
+func tokenClaims(ctx context.Context) jwt.MapClaims {
+	token := goajwt.ContextJWT(ctx)
+	if token == nil {
+		return nil
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	return claims
+}
@@ -0,0 +1,155 @@
+package authorization_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fabric8-services/admin-console/authorization"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type MiddlewareBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	repo authorization.RoleRepository
+}
+
+func TestMiddleware(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &MiddlewareBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *MiddlewareBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.repo = authorization.NewRoleRepository(s.DB)
+}
+
+func (s *MiddlewareBlackboxTestSuite) TestMiddleware() {
+	// given
+	svc := goa.New("authorization")
+	claimPermissions, err := authorization.NewClaimPermissions(map[string]map[string][]string{
+		"groups": {
+			"tenant-admins": {"show_tenant_update", "start_tenant_update", "stop_tenant_update"},
+		},
+	})
+	require.NoError(s.T(), err)
+	var denials []string
+	middleware := authorization.Middleware(s.repo, claimPermissions, func(ctx context.Context, username string, permission authorization.Permission, statusCode int) error {
+		denials = append(denials, fmt.Sprintf("%s:%d", permission, statusCode))
+		return nil
+	})
+	// newContext returns a context for the given controller and action, with a token containing the given claims (if any)
+	newContext := func(controller, action string, claims jwt.MapClaims) context.Context {
+		ctx := svc.NewController(controller).Context
+		ctx = goa.WithAction(ctx, action)
+		if claims == nil {
+			return ctx
+		}
+		return goajwt.WithJWT(ctx, &jwt.Token{Claims: claims})
+	}
+	// invoke calls the middleware and returns the context given to the next handler, if it was called
+	invoke := func(ctx context.Context) (bool, context.Context, error) {
+		denials = nil
+		called := false
+		var handlerCtx context.Context
+		h := middleware(func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			called = true
+			handlerCtx = ctx
+			return nil
+		})
+		err := h(ctx, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		return called, handlerCtx, err
+	}
+
+	s.T().Run("allowed", func(t *testing.T) {
+		// given
+		username := fmt.Sprintf("user-%v", uuid.NewV4())
+		err := s.repo.Assign(context.Background(), username, "auditor", "admin")
+		require.NoError(t, err)
+		ctx := newContext("AuditLogsController", "list", jwt.MapClaims{"preferred_username": username})
+		// when
+		called, handlerCtx, err := invoke(ctx)
+		// then
+		require.NoError(t, err)
+		require.True(t, called)
+		assert.True(t, authorization.HasPermission(handlerCtx, authorization.ReadAuditLogs))
+		assert.False(t, authorization.HasPermission(handlerCtx, authorization.ManageRoles))
+	})
+
+	s.T().Run("allowed via token claims", func(t *testing.T) {
+		// given
+		ctx := newContext("TenantUpdateController", "start", jwt.MapClaims{
+			"preferred_username": fmt.Sprintf("user-%v", uuid.NewV4()),
+			"groups":             []interface{}{"developers", "tenant-admins"},
+		})
+		// when
+		called, handlerCtx, err := invoke(ctx)
+		// then
+		require.NoError(t, err)
+		require.True(t, called)
+		assert.True(t, authorization.HasPermission(handlerCtx, authorization.StopTenantUpdate))
+		assert.False(t, authorization.HasPermission(handlerCtx, authorization.ReadAuditLogs))
+	})
+
+	s.T().Run("action without required permission", func(t *testing.T) {
+		// given
+		ctx := newContext("StatusController", "show", nil)
+		// when
+		called, _, err := invoke(ctx)
+		// then
+		require.NoError(t, err)
+		assert.True(t, called)
+	})
+
+	s.T().Run("forbidden - missing permission", func(t *testing.T) {
+		// given
+		username := fmt.Sprintf("user-%v", uuid.NewV4())
+		err := s.repo.Assign(context.Background(), username, "support", "admin")
+		require.NoError(t, err)
+		ctx := newContext("TenantUpdateController", "start", jwt.MapClaims{"preferred_username": username})
+		// when
+		called, _, err := invoke(ctx)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.ForbiddenError{}, err)
+		assert.False(t, called)
+		assert.Equal(t, []string{"start_tenant_update:403"}, denials)
+	})
+
+	s.T().Run("forbidden - no role", func(t *testing.T) {
+		// given
+		ctx := newContext("AuditLogsController", "export", jwt.MapClaims{"preferred_username": fmt.Sprintf("user-%v", uuid.NewV4())})
+		// when
+		called, _, err := invoke(ctx)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.ForbiddenError{}, err)
+		assert.False(t, called)
+	})
+
+	s.T().Run("unauthorized - missing token", func(t *testing.T) {
+		// given
+		ctx := newContext("SearchController", "search_users", nil)
+		// when
+		called, _, err := invoke(ctx)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.UnauthorizedError{}, err)
+		assert.False(t, called)
+		assert.Equal(t, []string{"search_users:401"}, denials)
+	})
+}
@@ -0,0 +1,136 @@
+package authorization
+
+import (
+	"context"
+)
+
+// Permission a permission to perform one or more actions
+type Permission string
+
+const (
+	// SearchUsers the permission to search for users
+	SearchUsers Permission = "search_users"
+	// ShowTenantUpdate the permission to view the status of the tenant update
+	ShowTenantUpdate Permission = "show_tenant_update"
+	// StartTenantUpdate the permission to start a tenant update
+	StartTenantUpdate Permission = "start_tenant_update"
+	// StopTenantUpdate the permission to stop the tenant update
+	StopTenantUpdate Permission = "stop_tenant_update"
+	// ApproveTenantUpdate the permission to approve or reject the requests of the other users to start a tenant update
+	ApproveTenantUpdate Permission = "approve_tenant_update"
+	// ReadAuditLogs the permission to list and view the audit logs (and their event types)
+	ReadAuditLogs Permission = "read_audit_logs"
+	// ExportAuditLogs the permission to export the audit logs of a user
+	ExportAuditLogs Permission = "export_audit_logs"
+	// ManageRoles the permission to create roles and to assign them to users
+	ManageRoles Permission = "manage_roles"
+	// ManageWebhooks the permission to register and delete the webhooks, and to view their deliveries
+	ManageWebhooks Permission = "manage_webhooks"
+)
+
+// Permissions all the known permissions
+var Permissions = []Permission{
+	SearchUsers,
+	ShowTenantUpdate,
+	StartTenantUpdate,
+	StopTenantUpdate,
+	ApproveTenantUpdate,
+	ReadAuditLogs,
+	ExportAuditLogs,
+	ManageRoles,
+	ManageWebhooks,
+}
+
+// IsValid returns true if the permission is known
+func (p Permission) IsValid() bool {
+	for _, known := range Permissions {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// actionPermissions the permission required by each secured action, indexed by controller name and action name.
+// Actions which are not listed here are not subject to the permissions (eg: the actions of the service accounts).
+var actionPermissions = map[string]map[string]Permission{
+	"SearchController": {
+		"search_users": SearchUsers,
+	},
+	"TenantUpdateController": {
+		"show":  ShowTenantUpdate,
+		"start": StartTenantUpdate,
+		"stop":  StopTenantUpdate,
+	},
+	"TenantUpdateJobsController": {
+		"list": ShowTenantUpdate,
+		"show": ShowTenantUpdate,
+	},
+	"TenantUpdateSchedulesController": {
+		"list":   ShowTenantUpdate,
+		"show":   ShowTenantUpdate,
+		"create": StartTenantUpdate,
+		"cancel": StartTenantUpdate,
+	},
+	"TenantUpdateRequestsController": {
+		"list":    ShowTenantUpdate,
+		"show":    ShowTenantUpdate,
+		"approve": ApproveTenantUpdate,
+		"reject":  ApproveTenantUpdate,
+	},
+	"TenantUpdateRolloutsController": {
+		"list":     ShowTenantUpdate,
+		"show":     ShowTenantUpdate,
+		"progress": ShowTenantUpdate,
+		"create":   StartTenantUpdate,
+		"pause":    StartTenantUpdate,
+		"resume":   StartTenantUpdate,
+		"abort":    StartTenantUpdate,
+	},
+	"AuditLogsController": {
+		"list_for_user": ReadAuditLogs,
+		"show":          ReadAuditLogs,
+		"list":          ReadAuditLogs,
+		"export":        ExportAuditLogs,
+		"stream":        ReadAuditLogs,
+		"aggregate":     ReadAuditLogs,
+	},
+	"RolesController": {
+		"list":          ManageRoles,
+		"show":          ManageRoles,
+		"create":        ManageRoles,
+		"list_for_user": ManageRoles,
+		"assign":        ManageRoles,
+		"revoke":        ManageRoles,
+	},
+	"WebhooksController": {
+		"list":            ManageWebhooks,
+		"show":            ManageWebhooks,
+		"create":          ManageWebhooks,
+		"delete":          ManageWebhooks,
+		"list_deliveries": ManageWebhooks,
+	},
+}
+
+// RequiredPermission returns the permission required to perform the given action of the given controller, if any
+func RequiredPermission(controller, action string) (Permission, bool) {
+	p, found := actionPermissions[controller][action]
+	return p, found
+}
+
+type permissionsKey struct{}
+
+// WithPermissions returns a copy of the given context which holds the permissions granted to the current user
+func WithPermissions(ctx context.Context, permissions ...Permission) context.Context {
+	granted := make(map[Permission]bool, len(permissions))
+	for _, p := range permissions {
+		granted[p] = true
+	}
+	return context.WithValue(ctx, permissionsKey{}, granted)
+}
+
+// HasPermission returns true if the given permission was granted to the current user
+func HasPermission(ctx context.Context, permission Permission) bool {
+	granted, ok := ctx.Value(permissionsKey{}).(map[Permission]bool)
+	return ok && granted[permission]
+}
@@ -0,0 +1,235 @@
+package authorization
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/closeable"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Role a named set of permissions, which can be assigned to users
+type Role struct {
+	ID          uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:role_id"`
+	Name        string
+	Description string
+	// Permissions the permissions granted by the role, stored in the `role_permission` table
+	Permissions []Permission `gorm:"-"`
+	CreatedAt   time.Time
+}
+
+const (
+	roleTableName           = "role"
+	rolePermissionTableName = "role_permission"
+	roleAssignmentTableName = "role_assignment"
+)
+
+// TableName implements gorm.tabler
+func (r Role) TableName() string {
+	return roleTableName
+}
+
+// rolePermission a permission granted by a role
+type rolePermission struct {
+	RoleID     uuid.UUID `sql:"type:uuid" gorm:"primary_key"`
+	Permission Permission
+}
+
+// TableName implements gorm.tabler
+func (p rolePermission) TableName() string {
+	return rolePermissionTableName
+}
+
+// RoleRepository provides functions to manage the roles and their assignments
+type RoleRepository interface {
+	Create(ctx context.Context, role *Role) error
+	List(ctx context.Context) ([]Role, error)
+	LoadByName(ctx context.Context, name string) (Role, error)
+	ListByUsername(ctx context.Context, username string) ([]Role, error)
+	Assign(ctx context.Context, username, roleName, assignedBy string) error
+	Revoke(ctx context.Context, username, roleName string) error
+	PermissionsOf(ctx context.Context, username string) ([]Permission, error)
+}
+
+// NewRoleRepository creates a GormRoleRepository
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &GormRoleRepository{
+		db: db,
+	}
+}
+
+// GormRoleRepository implements RoleRepository using gorm
+type GormRoleRepository struct {
+	db *gorm.DB
+}
+
+// Create stores the given role along with its permissions
+// returns BadParameterError if the name is missing or if a permission is unknown, DataConflictError if a role
+// with the same name already exists, or InternalError if something wrong happened
+func (r *GormRoleRepository) Create(ctx context.Context, role *Role) error {
+	defer goa.MeasureSince([]string{"goa", "db", "role", "create"}, time.Now())
+	if role == nil {
+		return errors.NewBadParameterErrorFromString("missing role to persist")
+	}
+	if role.Name == "" {
+		return errors.NewBadParameterError("name", role.Name)
+	}
+	for _, p := range role.Permissions {
+		if !p.IsValid() {
+			return errors.NewBadParameterError("permissions", p)
+		}
+	}
+	if _, err := r.LoadByName(ctx, role.Name); err == nil {
+		return errors.NewDataConflictError("role already exists: " + role.Name)
+	} else if _, ok := err.(errors.NotFoundError); !ok {
+		return err
+	}
+	if role.ID == uuid.Nil {
+		role.ID = uuid.NewV4()
+	}
+	if err := r.db.Create(role).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	for _, p := range role.Permissions {
+		if err := r.db.Create(&rolePermission{RoleID: role.ID, Permission: p}).Error; err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+	}
+	return nil
+}
+
+// List returns all the roles, sorted by name
+// returns InternalError if something wrong happened
+func (r *GormRoleRepository) List(ctx context.Context) ([]Role, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "role", "list"}, time.Now())
+	roles := []Role{}
+	if err := r.db.Order("name").Find(&roles).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return r.loadPermissions(ctx, roles)
+}
+
+// LoadByName returns the role with the given name
+// returns NotFoundError or InternalError
+func (r *GormRoleRepository) LoadByName(ctx context.Context, name string) (Role, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "role", "loadByName"}, time.Now())
+	roles := []Role{}
+	if err := r.db.Where("name = ?", name).Find(&roles).Error; err != nil {
+		return Role{}, errors.NewInternalError(ctx, err)
+	}
+	if len(roles) == 0 {
+		return Role{}, errors.NewNotFoundError("role", name)
+	}
+	roles, err := r.loadPermissions(ctx, roles)
+	if err != nil {
+		return Role{}, err
+	}
+	return roles[0], nil
+}
+
+// ListByUsername returns the roles assigned to the user with the given username, sorted by name
+// returns InternalError if something wrong happened
+func (r *GormRoleRepository) ListByUsername(ctx context.Context, username string) ([]Role, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "role", "listByUsername"}, time.Now())
+	roles := []Role{}
+	err := r.db.Where(fmt.Sprintf("role_id in (select role_id from %s where username = ?)", roleAssignmentTableName), username).
+		Order("name").Find(&roles).Error
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return r.loadPermissions(ctx, roles)
+}
+
+// Assign assigns the role with the given name to the user with the given username.
+// Assigning a role which is already assigned to the user has no effect.
+// returns NotFoundError if the role does not exist, or InternalError if something wrong happened
+func (r *GormRoleRepository) Assign(ctx context.Context, username, roleName, assignedBy string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "role", "assign"}, time.Now())
+	if username == "" {
+		return errors.NewBadParameterError("username", username)
+	}
+	role, err := r.LoadByName(ctx, roleName)
+	if err != nil {
+		return err
+	}
+	err = r.db.Exec(fmt.Sprintf("insert into %s (username, role_id, assigned_by) values (?, ?, ?) on conflict do nothing", roleAssignmentTableName),
+		username, role.ID, assignedBy).Error
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// Revoke revokes the role with the given name from the user with the given username
+// returns NotFoundError if the role does not exist or if it was not assigned to the user,
+// or InternalError if something wrong happened
+func (r *GormRoleRepository) Revoke(ctx context.Context, username, roleName string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "role", "revoke"}, time.Now())
+	role, err := r.LoadByName(ctx, roleName)
+	if err != nil {
+		return err
+	}
+	result := r.db.Exec(fmt.Sprintf("delete from %s where username = ? and role_id = ?", roleAssignmentTableName), username, role.ID)
+	if result.Error != nil {
+		return errors.NewInternalError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("role_assignment", fmt.Sprintf("%s/%s", roleName, username))
+	}
+	return nil
+}
+
+// PermissionsOf returns the (sorted) permissions granted to the user with the given username by all her roles
+// returns InternalError if something wrong happened
+func (r *GormRoleRepository) PermissionsOf(ctx context.Context, username string) ([]Permission, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "role", "permissionsOf"}, time.Now())
+	rows, err := r.db.Raw(fmt.Sprintf(`select distinct p.permission from %s p join %s a on a.role_id = p.role_id
+		where a.username = ? order by p.permission`, rolePermissionTableName, roleAssignmentTableName), username).Rows()
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	defer closeable.Close(ctx, rows)
+	permissions := []Permission{}
+	for rows.Next() {
+		var p Permission
+		if err := rows.Scan(&p); err != nil {
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		permissions = append(permissions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return permissions, nil
+}
+
+// loadPermissions loads the permissions of the given roles
+func (r *GormRoleRepository) loadPermissions(ctx context.Context, roles []Role) ([]Role, error) {
+	if len(roles) == 0 {
+		return roles, nil
+	}
+	ids := make([]uuid.UUID, len(roles))
+	for i, role := range roles {
+		ids[i] = role.ID
+	}
+	permissions := []rolePermission{}
+	if err := r.db.Where("role_id in (?)", ids).Order("permission").Find(&permissions).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	byRole := map[uuid.UUID][]Permission{}
+	for _, p := range permissions {
+		byRole[p.RoleID] = append(byRole[p.RoleID], p.Permission)
+	}
+	for i := range roles {
+		roles[i].Permissions = byRole[roles[i].ID]
+		if roles[i].Permissions == nil {
+			roles[i].Permissions = []Permission{}
+		}
+	}
+	return roles, nil
+}
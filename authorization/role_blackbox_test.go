@@ -0,0 +1,150 @@
+package authorization_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/fabric8-services/admin-console/authorization"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RoleRepositoryBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	repo authorization.RoleRepository
+}
+
+func TestRoleRepository(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &RoleRepositoryBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *RoleRepositoryBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.repo = authorization.NewRoleRepository(s.DB)
+}
+
+func (s *RoleRepositoryBlackboxTestSuite) TestBuiltinRoles() {
+	// when
+	auditor, err := s.repo.LoadByName(context.Background(), "auditor")
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []authorization.Permission{authorization.ExportAuditLogs, authorization.ReadAuditLogs}, auditor.Permissions)
+	// when
+	admin, err := s.repo.LoadByName(context.Background(), "admin")
+	// then
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), admin.Permissions, len(authorization.Permissions))
+}
+
+func (s *RoleRepositoryBlackboxTestSuite) TestCreateRole() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		role := authorization.Role{
+			Name:        fmt.Sprintf("role-%s", uuid.NewV4().String()[:8]),
+			Description: "a role",
+			Permissions: []authorization.Permission{authorization.SearchUsers, authorization.ReadAuditLogs},
+		}
+		// when
+		err := s.repo.Create(context.Background(), &role)
+		// then
+		require.NoError(t, err)
+		assert.NotEqual(t, uuid.Nil, role.ID)
+		result, err := s.repo.LoadByName(context.Background(), role.Name)
+		require.NoError(t, err)
+		assert.Equal(t, role.ID, result.ID)
+		assert.Equal(t, "a role", result.Description)
+		assert.Equal(t, []authorization.Permission{authorization.ReadAuditLogs, authorization.SearchUsers}, result.Permissions)
+	})
+
+	s.T().Run("unknown permission", func(t *testing.T) {
+		// given
+		role := authorization.Role{
+			Name:        fmt.Sprintf("role-%s", uuid.NewV4().String()[:8]),
+			Permissions: []authorization.Permission{"do_anything"},
+		}
+		// when
+		err := s.repo.Create(context.Background(), &role)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, err)
+	})
+
+	s.T().Run("conflict", func(t *testing.T) {
+		// given
+		role := authorization.Role{
+			Name: "auditor",
+		}
+		// when
+		err := s.repo.Create(context.Background(), &role)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.DataConflictError{}, err)
+	})
+}
+
+func (s *RoleRepositoryBlackboxTestSuite) TestAssignAndRevokeRoles() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		username := fmt.Sprintf("user-%v", uuid.NewV4())
+		// when
+		err := s.repo.Assign(context.Background(), username, "auditor", "admin")
+		require.NoError(t, err)
+		err = s.repo.Assign(context.Background(), username, "support", "admin")
+		require.NoError(t, err)
+		// assigning the same role twice has no effect
+		err = s.repo.Assign(context.Background(), username, "support", "admin")
+		require.NoError(t, err)
+		// then
+		roles, err := s.repo.ListByUsername(context.Background(), username)
+		require.NoError(t, err)
+		require.Len(t, roles, 2)
+		assert.Equal(t, "auditor", roles[0].Name)
+		assert.Equal(t, "support", roles[1].Name)
+		permissions, err := s.repo.PermissionsOf(context.Background(), username)
+		require.NoError(t, err)
+		assert.Equal(t, []authorization.Permission{authorization.ExportAuditLogs, authorization.ReadAuditLogs, authorization.SearchUsers}, permissions)
+		// when
+		err = s.repo.Revoke(context.Background(), username, "auditor")
+		// then
+		require.NoError(t, err)
+		permissions, err = s.repo.PermissionsOf(context.Background(), username)
+		require.NoError(t, err)
+		assert.Equal(t, []authorization.Permission{authorization.ReadAuditLogs, authorization.SearchUsers}, permissions)
+	})
+
+	s.T().Run("no permission", func(t *testing.T) {
+		// when
+		permissions, err := s.repo.PermissionsOf(context.Background(), fmt.Sprintf("user-%v", uuid.NewV4()))
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, permissions)
+	})
+
+	s.T().Run("unknown role", func(t *testing.T) {
+		// when
+		err := s.repo.Assign(context.Background(), "user", "unknown", "admin")
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	s.T().Run("revoke role not assigned", func(t *testing.T) {
+		// when
+		err := s.repo.Revoke(context.Background(), fmt.Sprintf("user-%v", uuid.NewV4()), "auditor")
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+}
@@ -2,7 +2,9 @@ package configuration
 
 import (
 	"fmt"
+	"net"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -65,6 +67,66 @@ const (
 	// other services
 	varAuthURL   = "auth.url"
 	varTenantURL = "tenant.url"
+
+	// audit logs retention
+	varAuditLogRetentionPolicies  = "auditlog.retention.policies"
+	varAuditLogRetentionInterval  = "auditlog.retention.interval"
+	varAuditLogRetentionBatchSize = "auditlog.retention.batchsize"
+	varAuditLogArchiveDir         = "auditlog.archive.dir"
+	varAuditLogIdempotencyKeyTTL  = "auditlog.idempotency.ttl"
+
+	// audit logs partitions
+	varAuditLogPartitionsAhead    = "auditlog.partitions.ahead"
+	varAuditLogPartitionsInterval = "auditlog.partitions.interval"
+
+	// audit logs failure policies
+	varAuditLogFailurePolicies = "auditlog.failure.policies"
+	varAuditLogFailureDefault  = "auditlog.failure.default"
+	varAuditLogSpoolDir        = "auditlog.spool.dir"
+	varAuditLogSpoolInterval   = "auditlog.spool.interval"
+
+	// audit logs outbox
+	varAuditLogOutboxSink      = "auditlog.outbox.sink"
+	varAuditLogOutboxFile      = "auditlog.outbox.file"
+	varAuditLogOutboxURL       = "auditlog.outbox.url"
+	varAuditLogOutboxSource    = "auditlog.outbox.source"
+	varAuditLogOutboxInterval  = "auditlog.outbox.interval"
+	varAuditLogOutboxBatchSize = "auditlog.outbox.batchsize"
+	varAuditLogOutboxTimeout   = "auditlog.outbox.timeout"
+
+	// audit logs stream
+	varAuditLogStreamKeepAlive = "auditlog.stream.keepalive"
+
+	// audit logs stats
+	varAuditLogAggregateMaxRange = "auditlog.aggregate.maxrange"
+
+	// webhooks
+	varWebhookDispatchInterval = "webhook.dispatch.interval"
+	varWebhookBatchSize        = "webhook.batchsize"
+	varWebhookMaxAttempts      = "webhook.maxattempts"
+	varWebhookTimeout          = "webhook.timeout"
+
+	// tenant updates
+	varTenantUpdatePollInterval = "tenant.update.pollinterval"
+	varTenantUpdateTimeout      = "tenant.update.timeout"
+
+	// approval of the tenant updates
+	varTenantUpdateApprovalRequired = "tenant.update.approval.required"
+	varTenantUpdateApprovalExpiry   = "tenant.update.approval.expiry"
+
+	// tenant update schedules
+	varTenantUpdateScheduleInterval = "tenant.update.schedule.interval"
+
+	// service account of the admin console, with which the scheduled tenant updates are started
+	varServiceAccountID     = "service.account.id"
+	varServiceAccountSecret = "service.account.secret"
+
+	// authorization
+	varAuthorizationAdmins = "authorization.admins"
+	varAuthorizationClaims = "authorization.claims"
+
+	// proxies in front of the service, whose `X-Forwarded-For` header is trusted
+	varHTTPTrustedProxies = "http.trusted.proxies"
 )
 
 // Configuration encapsulates the Viper configuration object which stores the configuration data in-memory.
@@ -102,6 +164,32 @@ func New() *Configuration {
 	if c.IsDeveloperModeEnabled() {
 		c.appendDefaultConfigErrorMessage("developer mode is enabled")
 	}
+	if _, err := ParseRetentionPolicies(c.v.GetString(varAuditLogRetentionPolicies)); err != nil {
+		c.appendDefaultConfigErrorMessage(err.Error())
+	}
+	if _, err := ParseFailurePolicies(c.v.GetString(varAuditLogFailurePolicies)); err != nil {
+		c.appendDefaultConfigErrorMessage(err.Error())
+	}
+	if !isFailurePolicy(c.v.GetString(varAuditLogFailureDefault)) {
+		c.appendDefaultConfigErrorMessage(fmt.Sprintf("invalid default audit log failure policy: '%s'", c.v.GetString(varAuditLogFailureDefault)))
+	}
+	switch c.v.GetString(varAuditLogOutboxSink) {
+	case "none":
+	case "file":
+		if c.v.GetString(varAuditLogOutboxFile) == "" {
+			c.appendDefaultConfigErrorMessage("audit log outbox file is empty")
+		}
+	case "http":
+		c.validateURL(c.v.GetString(varAuditLogOutboxURL), "audit log outbox")
+	default:
+		c.appendDefaultConfigErrorMessage(fmt.Sprintf("invalid audit log outbox sink: '%s'", c.v.GetString(varAuditLogOutboxSink)))
+	}
+	if _, err := ParseClaimPermissions(c.v.GetString(varAuthorizationClaims)); err != nil {
+		c.appendDefaultConfigErrorMessage(err.Error())
+	}
+	if _, err := ParseTrustedProxies(c.v.GetString(varHTTPTrustedProxies)); err != nil {
+		c.appendDefaultConfigErrorMessage(err.Error())
+	}
 
 	return c
 }
@@ -207,6 +295,96 @@ func (c *Configuration) setConfigDefaults() {
 
 	c.v.SetDefault(varLogLevel, defaultLogLevel)
 
+	//-----
+	// Audit logs retention
+	//-----
+
+	// By default, audit logs are kept forever
+	c.v.SetDefault(varAuditLogRetentionPolicies, "")
+	c.v.SetDefault(varAuditLogRetentionInterval, defaultAuditLogRetentionInterval)
+	c.v.SetDefault(varAuditLogRetentionBatchSize, defaultAuditLogRetentionBatchSize)
+	c.v.SetDefault(varAuditLogArchiveDir, defaultAuditLogArchiveDir)
+	c.v.SetDefault(varAuditLogIdempotencyKeyTTL, defaultAuditLogIdempotencyKeyTTL)
+
+	//-----
+	// Audit logs partitions
+	//-----
+	c.v.SetDefault(varAuditLogPartitionsAhead, defaultAuditLogPartitionsAhead)
+	c.v.SetDefault(varAuditLogPartitionsInterval, defaultAuditLogPartitionsInterval)
+
+	//-----
+	// Audit logs failure policies
+	//-----
+
+	// By default, the admin actions are refused when they cannot be audited
+	c.v.SetDefault(varAuditLogFailurePolicies, "")
+	c.v.SetDefault(varAuditLogFailureDefault, defaultAuditLogFailurePolicy)
+	c.v.SetDefault(varAuditLogSpoolDir, defaultAuditLogSpoolDir)
+	c.v.SetDefault(varAuditLogSpoolInterval, defaultAuditLogSpoolInterval)
+
+	//-----
+	// Audit logs outbox
+	//-----
+
+	// By default, the audit log events are not published to any broker
+	c.v.SetDefault(varAuditLogOutboxSink, defaultAuditLogOutboxSink)
+	c.v.SetDefault(varAuditLogOutboxFile, "")
+	c.v.SetDefault(varAuditLogOutboxURL, "")
+	c.v.SetDefault(varAuditLogOutboxSource, defaultAuditLogOutboxSource)
+	c.v.SetDefault(varAuditLogOutboxInterval, defaultAuditLogOutboxInterval)
+	c.v.SetDefault(varAuditLogOutboxBatchSize, defaultAuditLogOutboxBatchSize)
+	c.v.SetDefault(varAuditLogOutboxTimeout, defaultAuditLogOutboxTimeout)
+
+	//-----
+	// Audit logs stream
+	//-----
+	c.v.SetDefault(varAuditLogStreamKeepAlive, defaultAuditLogStreamKeepAlive)
+
+	//-----
+	// Audit logs stats
+	//-----
+	c.v.SetDefault(varAuditLogAggregateMaxRange, defaultAuditLogAggregateMaxRange)
+
+	//-----
+	// Webhooks
+	//-----
+	c.v.SetDefault(varWebhookDispatchInterval, defaultWebhookDispatchInterval)
+	c.v.SetDefault(varWebhookBatchSize, defaultWebhookBatchSize)
+	c.v.SetDefault(varWebhookMaxAttempts, defaultWebhookMaxAttempts)
+	c.v.SetDefault(varWebhookTimeout, defaultWebhookTimeout)
+
+	//-----
+	// Tenant updates
+	//-----
+	c.v.SetDefault(varTenantUpdatePollInterval, defaultTenantUpdatePollInterval)
+	c.v.SetDefault(varTenantUpdateTimeout, defaultTenantUpdateTimeout)
+	c.v.SetDefault(varTenantUpdateScheduleInterval, defaultTenantUpdateScheduleInterval)
+	// By default, a single user can start a tenant update
+	c.v.SetDefault(varTenantUpdateApprovalRequired, false)
+	c.v.SetDefault(varTenantUpdateApprovalExpiry, defaultTenantUpdateApprovalExpiry)
+
+	//-----
+	// Service account
+	//-----
+
+	// By default, there is no service account, so the scheduled tenant updates are not started
+	c.v.SetDefault(varServiceAccountID, "")
+	c.v.SetDefault(varServiceAccountSecret, "")
+
+	//-----
+	// Authorization
+	//-----
+
+	// By default, the roles are only assigned via the API
+	c.v.SetDefault(varAuthorizationAdmins, "")
+	c.v.SetDefault(varAuthorizationClaims, "")
+
+	//-----
+	// Trusted proxies
+	//-----
+
+	// By default, no proxy is trusted, so the remote address of the clients is the address of the connection
+	c.v.SetDefault(varHTTPTrustedProxies, "")
 }
 
 // GetPostgresHost returns the postgres host as set via default, config file, or environment variable
@@ -371,3 +549,365 @@ func (c *Configuration) GetDiagnoseHTTPAddress() string {
 func (c *Configuration) GetDevModePrivateKey() []byte {
 	return []byte(commonconfig.DevModeRsaPrivateKey)
 }
+
+// GetAuditLogRetentionPolicies returns the maximum age of the audit log records, indexed by event type name
+// (as set via default, config file, or environment variable). Audit log records of other event types are kept forever.
+// The policies are set as a comma-separated list of `<event_type>=<max_age>` items, where the max age is a duration
+// (eg: `2160h`) or a number of days (eg: `90d`). Invalid policies are reported in the `DefaultConfigurationError()`
+// and ignored here.
+func (c *Configuration) GetAuditLogRetentionPolicies() map[string]time.Duration {
+	policies, err := ParseRetentionPolicies(c.v.GetString(varAuditLogRetentionPolicies))
+	if err != nil {
+		return map[string]time.Duration{}
+	}
+	return policies
+}
+
+// GetAuditLogRetentionInterval returns the interval between 2 runs of the audit logs retention worker
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogRetentionInterval() time.Duration {
+	return c.v.GetDuration(varAuditLogRetentionInterval)
+}
+
+// GetAuditLogRetentionBatchSize returns the maximum number of audit log records archived and deleted at once
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogRetentionBatchSize() int {
+	return c.v.GetInt(varAuditLogRetentionBatchSize)
+}
+
+// GetAuditLogArchiveDir returns the directory in which the expired audit log records are archived
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogArchiveDir() string {
+	return c.v.GetString(varAuditLogArchiveDir)
+}
+
+// GetAuditLogIdempotencyKeyTTL returns how long the idempotency keys of the audit log records are kept, after which
+// a request with the same key records a new audit log. The keys are kept forever if the TTL is not positive.
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogIdempotencyKeyTTL() time.Duration {
+	return c.v.GetDuration(varAuditLogIdempotencyKeyTTL)
+}
+
+// GetAuditLogPartitionsAhead returns the number of monthly partitions of the audit logs table to create ahead of the current month
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogPartitionsAhead() int {
+	return c.v.GetInt(varAuditLogPartitionsAhead)
+}
+
+// GetAuditLogPartitionsInterval returns the interval between 2 checks of the upcoming partitions of the audit logs table
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogPartitionsInterval() time.Duration {
+	return c.v.GetDuration(varAuditLogPartitionsInterval)
+}
+
+// GetAuditLogFailurePolicies returns the policy applied when an audit log record cannot be stored, indexed by event type name
+// (as set via default, config file, or environment variable). The policies are set as a comma-separated list of
+// `<event_type>=<policy>` items, where the policy is `fail-closed` (the action is refused) or `fail-open` (the action
+// proceeds and the record is spooled on disk until it can be stored). Invalid policies are reported in the
+// `DefaultConfigurationError()` and ignored here.
+func (c *Configuration) GetAuditLogFailurePolicies() map[string]string {
+	policies, err := ParseFailurePolicies(c.v.GetString(varAuditLogFailurePolicies))
+	if err != nil {
+		return map[string]string{}
+	}
+	return policies
+}
+
+// GetAuditLogDefaultFailurePolicy returns the policy applied when an audit log record cannot be stored, for the event
+// types which have no specific policy (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogDefaultFailurePolicy() string {
+	if policy := c.v.GetString(varAuditLogFailureDefault); isFailurePolicy(policy) {
+		return policy
+	}
+	return defaultAuditLogFailurePolicy
+}
+
+// GetAuditLogSpoolDir returns the directory in which the audit log records which could not be stored are spooled
+// (as set via default, config file, or environment variable). The directory should be on a persistent volume, otherwise
+// the spooled records are lost when the pod is deleted.
+func (c *Configuration) GetAuditLogSpoolDir() string {
+	return c.v.GetString(varAuditLogSpoolDir)
+}
+
+// GetAuditLogSpoolInterval returns the interval between 2 attempts to replay the spooled audit log records
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogSpoolInterval() time.Duration {
+	return c.v.GetDuration(varAuditLogSpoolInterval)
+}
+
+// GetAuditLogOutboxSink returns the kind of sink to which the audit log events are published: `none` (the events are discarded),
+// `file` (the events are appended to a file) or `http` (the events are sent to an HTTP endpoint, as CloudEvents)
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogOutboxSink() string {
+	return c.v.GetString(varAuditLogOutboxSink)
+}
+
+// GetAuditLogOutboxFile returns the path of the file to which the audit log events are appended, when the sink is `file`
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogOutboxFile() string {
+	return c.v.GetString(varAuditLogOutboxFile)
+}
+
+// GetAuditLogOutboxURL returns the URL to which the audit log events are sent, when the sink is `http`
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogOutboxURL() string {
+	return c.v.GetString(varAuditLogOutboxURL)
+}
+
+// GetAuditLogOutboxSource returns the source of the published audit log events
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogOutboxSource() string {
+	return c.v.GetString(varAuditLogOutboxSource)
+}
+
+// GetAuditLogOutboxInterval returns the interval between 2 runs of the audit log outbox relay
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogOutboxInterval() time.Duration {
+	return c.v.GetDuration(varAuditLogOutboxInterval)
+}
+
+// GetAuditLogOutboxBatchSize returns the maximum number of audit log events published at once
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogOutboxBatchSize() int {
+	return c.v.GetInt(varAuditLogOutboxBatchSize)
+}
+
+// GetAuditLogOutboxTimeout returns the timeout of the requests which send the audit log events, when the sink is `http`
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogOutboxTimeout() time.Duration {
+	return c.v.GetDuration(varAuditLogOutboxTimeout)
+}
+
+// GetAuditLogStreamKeepAlive returns the interval after which a comment is sent to the clients streaming the audit logs
+// if no record was sent, so that the idle connections are not closed by the proxies
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogStreamKeepAlive() time.Duration {
+	return c.v.GetDuration(varAuditLogStreamKeepAlive)
+}
+
+// GetAuditLogAggregateMaxRange returns the maximum range of dates within which the audit logs are counted at once
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetAuditLogAggregateMaxRange() time.Duration {
+	return c.v.GetDuration(varAuditLogAggregateMaxRange)
+}
+
+// GetWebhookDispatchInterval returns the interval between 2 runs of the webhook dispatcher
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetWebhookDispatchInterval() time.Duration {
+	return c.v.GetDuration(varWebhookDispatchInterval)
+}
+
+// GetWebhookBatchSize returns the maximum number of events delivered to the webhooks at once
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetWebhookBatchSize() int {
+	return c.v.GetInt(varWebhookBatchSize)
+}
+
+// GetWebhookMaxAttempts returns the maximum number of attempts to deliver an event to a webhook, after which the delivery is dead
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetWebhookMaxAttempts() int {
+	return c.v.GetInt(varWebhookMaxAttempts)
+}
+
+// GetWebhookTimeout returns the timeout of the requests which deliver the events to the webhooks
+// (as set via default, config file, or environment variable)
+func (c *Configuration) GetWebhookTimeout() time.Duration {
+	return c.v.GetDuration(varWebhookTimeout)
+}
+
+// GetTenantUpdatePollInterval returns the interval between 2 requests to the tenant service to refresh the status of the
+// ongoing tenant updates (as set via default, config file, or environment variable)
+func (c *Configuration) GetTenantUpdatePollInterval() time.Duration {
+	return c.v.GetDuration(varTenantUpdatePollInterval)
+}
+
+// GetTenantUpdateTimeout returns the timeout of the requests to the tenant service which refresh the status of the
+// ongoing tenant updates (as set via default, config file, or environment variable)
+func (c *Configuration) GetTenantUpdateTimeout() time.Duration {
+	return c.v.GetDuration(varTenantUpdateTimeout)
+}
+
+// GetTenantUpdateScheduleInterval returns the interval between 2 runs of the scheduler which starts the scheduled tenant
+// updates (as set via default, config file, or environment variable)
+func (c *Configuration) GetTenantUpdateScheduleInterval() time.Duration {
+	return c.v.GetDuration(varTenantUpdateScheduleInterval)
+}
+
+// IsTenantUpdateApprovalRequired returns true if a request to start a tenant update which is not limited to a cluster
+// must be approved by another user before the update is started (as set via default, config file, or environment
+// variable)
+func (c *Configuration) IsTenantUpdateApprovalRequired() bool {
+	return c.v.GetBool(varTenantUpdateApprovalRequired)
+}
+
+// GetTenantUpdateApprovalExpiry returns the delay after which a request to start a tenant update expires if it was not
+// approved or rejected (as set via default, config file, or environment variable)
+func (c *Configuration) GetTenantUpdateApprovalExpiry() time.Duration {
+	return c.v.GetDuration(varTenantUpdateApprovalExpiry)
+}
+
+// GetServiceAccountID returns the ID of the service account with which the admin console obtains a token from the auth
+// service to start the scheduled tenant updates (as set via default, config file, or environment variable)
+func (c *Configuration) GetServiceAccountID() string {
+	return c.v.GetString(varServiceAccountID)
+}
+
+// GetServiceAccountSecret returns the secret of the service account with which the admin console obtains a token from the
+// auth service to start the scheduled tenant updates (as set via default, config file, or environment variable)
+func (c *Configuration) GetServiceAccountSecret() string {
+	return c.v.GetString(varServiceAccountSecret)
+}
+
+// GetAuthorizationAdmins returns the usernames of the users to whom the 'admin' role is assigned at startup, so that they can
+// assign the roles to the other users (as set via default, config file, or environment variable, as a comma-separated list)
+func (c *Configuration) GetAuthorizationAdmins() []string {
+	admins := []string{}
+	for _, username := range strings.Split(c.v.GetString(varAuthorizationAdmins), ",") {
+		if username = strings.TrimSpace(username); username != "" {
+			admins = append(admins, username)
+		}
+	}
+	return admins
+}
+
+// GetAuthorizationClaims returns the permissions granted to the users whose token contains a given claim value,
+// indexed by claim and by value (as set via default, config file, or environment variable).
+// The mapping is set as a comma-separated list of `<claim>:<value>=<permission>[|<permission>...]` items, where the
+// claim may be a path to a nested claim (eg: `groups:tenant-admins=start_tenant_update|stop_tenant_update` or
+// `realm_access.roles:auditor=read_audit_logs`). An invalid mapping is reported in the `DefaultConfigurationError()`
+// and ignored here.
+func (c *Configuration) GetAuthorizationClaims() map[string]map[string][]string {
+	claims, err := ParseClaimPermissions(c.v.GetString(varAuthorizationClaims))
+	if err != nil {
+		return map[string]map[string][]string{}
+	}
+	return claims
+}
+
+// GetHTTPTrustedProxies returns the networks of the proxies in front of the service, from which the client address in
+// the `X-Forwarded-For` header is trusted (as set via default, config file, or environment variable, as a
+// comma-separated list of IP addresses or CIDR blocks, eg: `10.0.0.0/8,192.168.1.1`). An invalid list is reported in
+// the `DefaultConfigurationError()` and ignored here.
+func (c *Configuration) GetHTTPTrustedProxies() []*net.IPNet {
+	proxies, err := ParseTrustedProxies(c.v.GetString(varHTTPTrustedProxies))
+	if err != nil {
+		return []*net.IPNet{}
+	}
+	return proxies
+}
+
+// ParseTrustedProxies parses the given comma-separated list of IP addresses or CIDR blocks
+func ParseTrustedProxies(value string) ([]*net.IPNet, error) {
+	proxies := []*net.IPNet{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, errors.Errorf("invalid trusted proxy: '%s'", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, errors.Errorf("invalid trusted proxy: '%s'", item)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// ParseClaimPermissions parses the given comma-separated list of `<claim>:<value>=<permission>[|<permission>...]` items
+func ParseClaimPermissions(value string) (map[string]map[string][]string, error) {
+	claims := map[string]map[string][]string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid authorization claim mapping: '%s'", item)
+		}
+		claimAndValue := strings.SplitN(parts[0], ":", 2)
+		if len(claimAndValue) != 2 || strings.TrimSpace(claimAndValue[0]) == "" || strings.TrimSpace(claimAndValue[1]) == "" {
+			return nil, errors.Errorf("invalid authorization claim mapping: '%s'", item)
+		}
+		claim := strings.TrimSpace(claimAndValue[0])
+		claimValue := strings.TrimSpace(claimAndValue[1])
+		if _, found := claims[claim]; !found {
+			claims[claim] = map[string][]string{}
+		}
+		for _, permission := range strings.Split(parts[1], "|") {
+			if permission = strings.TrimSpace(permission); permission == "" {
+				return nil, errors.Errorf("invalid authorization claim mapping: '%s'", item)
+			}
+			claims[claim][claimValue] = append(claims[claim][claimValue], permission)
+		}
+	}
+	return claims, nil
+}
+
+// ParseFailurePolicies parses the given comma-separated list of `<event_type>=<policy>` items, where the policy
+// is `fail-closed` or `fail-open`
+func ParseFailurePolicies(value string) (map[string]string, error) {
+	policies := map[string]string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || !isFailurePolicy(strings.TrimSpace(parts[1])) {
+			return nil, errors.Errorf("invalid audit log failure policy: '%s'", item)
+		}
+		policies[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return policies, nil
+}
+
+func isFailurePolicy(value string) bool {
+	return value == "fail-closed" || value == "fail-open"
+}
+
+// ParseRetentionPolicies parses the given comma-separated list of `<event_type>=<max_age>` items, where the max age is
+// a duration (eg: `2160h`) or a number of days (eg: `90d`)
+func ParseRetentionPolicies(value string) (map[string]time.Duration, error) {
+	policies := map[string]time.Duration{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.Errorf("invalid audit log retention policy: '%s'", item)
+		}
+		maxAge, err := parseMaxAge(strings.TrimSpace(parts[1]))
+		if err != nil || maxAge <= 0 {
+			return nil, errors.Errorf("invalid audit log retention policy: '%s'", item)
+		}
+		policies[strings.TrimSpace(parts[0])] = maxAge
+	}
+	return policies, nil
+}
+
+func parseMaxAge(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -3,6 +3,7 @@ package configuration_test
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -125,6 +126,338 @@ func TestConfiguration(t *testing.T) {
 		})
 	})
 
+	t.Run("audit log retention policies", func(t *testing.T) {
+
+		t.Run("none by default", func(t *testing.T) {
+			// when
+			config := configuration.New()
+			// then
+			assert.Empty(t, config.GetAuditLogRetentionPolicies())
+		})
+
+		t.Run("valid", func(t *testing.T) {
+			// given
+			unsetenvs := setenvs(envvars{
+				"ADMIN_AUDITLOG_RETENTION_POLICIES": "list_audit_logs=90d, user_deactivation=61320h",
+			})
+			defer unsetenvs()
+			// when
+			config := configuration.New()
+			// then
+			assert.Equal(t, map[string]time.Duration{
+				"list_audit_logs":   90 * 24 * time.Hour,
+				"user_deactivation": 61320 * time.Hour,
+			}, config.GetAuditLogRetentionPolicies())
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			// given
+			unsetenvs := setenvs(envvars{
+				"ADMIN_AUDITLOG_RETENTION_POLICIES": "list_audit_logs=90d,user_deactivation=forever",
+			})
+			defer unsetenvs()
+			// when
+			config := configuration.New()
+			// then
+			assert.Empty(t, config.GetAuditLogRetentionPolicies())
+			err := config.DefaultConfigurationError()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid audit log retention policy: 'user_deactivation=forever'")
+		})
+	})
+
+	t.Run("audit log failure policies", func(t *testing.T) {
+
+		t.Run("fail-closed by default", func(t *testing.T) {
+			// when
+			config := configuration.New()
+			// then
+			assert.Empty(t, config.GetAuditLogFailurePolicies())
+			assert.Equal(t, "fail-closed", config.GetAuditLogDefaultFailurePolicy())
+		})
+
+		t.Run("valid", func(t *testing.T) {
+			// given
+			unsetenvs := setenvs(envvars{
+				"ADMIN_AUDITLOG_FAILURE_POLICIES": "user_search=fail-open, start_tenant_update=fail-closed",
+				"ADMIN_AUDITLOG_FAILURE_DEFAULT":  "fail-open",
+			})
+			defer unsetenvs()
+			// when
+			config := configuration.New()
+			// then
+			assert.Equal(t, map[string]string{
+				"user_search":         "fail-open",
+				"start_tenant_update": "fail-closed",
+			}, config.GetAuditLogFailurePolicies())
+			assert.Equal(t, "fail-open", config.GetAuditLogDefaultFailurePolicy())
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			// given
+			unsetenvs := setenvs(envvars{
+				"ADMIN_AUDITLOG_FAILURE_POLICIES": "user_search=fail-open,start_tenant_update=ignore",
+				"ADMIN_AUDITLOG_FAILURE_DEFAULT":  "ignore",
+			})
+			defer unsetenvs()
+			// when
+			config := configuration.New()
+			// then
+			assert.Empty(t, config.GetAuditLogFailurePolicies())
+			assert.Equal(t, "fail-closed", config.GetAuditLogDefaultFailurePolicy())
+			err := config.DefaultConfigurationError()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid audit log failure policy: 'start_tenant_update=ignore'")
+			assert.Contains(t, err.Error(), "invalid default audit log failure policy: 'ignore'")
+		})
+	})
+
+	t.Run("audit log outbox", func(t *testing.T) {
+
+		t.Run("default", func(t *testing.T) {
+			// when
+			config := configuration.New()
+			// then
+			assert.Equal(t, "none", config.GetAuditLogOutboxSink())
+			assert.Equal(t, "/admin-console", config.GetAuditLogOutboxSource())
+			assert.Equal(t, 5*time.Second, config.GetAuditLogOutboxInterval())
+			assert.Equal(t, 100, config.GetAuditLogOutboxBatchSize())
+		})
+
+		t.Run("http", func(t *testing.T) {
+			// given
+			unsetenvs := setenvs(envvars{
+				"ADMIN_AUDITLOG_OUTBOX_SINK": "http",
+				"ADMIN_AUDITLOG_OUTBOX_URL":  "http://broker/default",
+			})
+			defer unsetenvs()
+			// when
+			config := configuration.New()
+			// then
+			assert.Equal(t, "http", config.GetAuditLogOutboxSink())
+			assert.Equal(t, "http://broker/default", config.GetAuditLogOutboxURL())
+			err := config.DefaultConfigurationError()
+			if err != nil {
+				assert.NotContains(t, err.Error(), "audit log outbox")
+			}
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			// given
+			unsetenvs := setenvs(envvars{
+				"ADMIN_AUDITLOG_OUTBOX_SINK": "kafka",
+			})
+			defer unsetenvs()
+			// when
+			config := configuration.New()
+			// then
+			err := config.DefaultConfigurationError()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid audit log outbox sink: 'kafka'")
+		})
+
+		t.Run("missing file", func(t *testing.T) {
+			// given
+			unsetenvs := setenvs(envvars{
+				"ADMIN_AUDITLOG_OUTBOX_SINK": "file",
+			})
+			defer unsetenvs()
+			// when
+			config := configuration.New()
+			// then
+			err := config.DefaultConfigurationError()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "audit log outbox file is empty")
+		})
+	})
+
+	t.Run("audit log stream", func(t *testing.T) {
+		// given
+		unsetenvs := setenvs(envvars{
+			"ADMIN_AUDITLOG_STREAM_KEEPALIVE": "30s",
+		})
+		defer unsetenvs()
+		// when
+		config := configuration.New()
+		// then
+		assert.Equal(t, 30*time.Second, config.GetAuditLogStreamKeepAlive())
+	})
+
+	t.Run("audit log stats", func(t *testing.T) {
+
+		t.Run("default", func(t *testing.T) {
+			// when
+			config := configuration.New()
+			// then
+			assert.Equal(t, 92*24*time.Hour, config.GetAuditLogAggregateMaxRange())
+		})
+
+		t.Run("custom", func(t *testing.T) {
+			// given
+			unsetenvs := setenvs(envvars{
+				"ADMIN_AUDITLOG_AGGREGATE_MAXRANGE": "720h",
+			})
+			defer unsetenvs()
+			// when
+			config := configuration.New()
+			// then
+			assert.Equal(t, 30*24*time.Hour, config.GetAuditLogAggregateMaxRange())
+		})
+	})
+
+	t.Run("webhooks", func(t *testing.T) {
+		// given
+		unsetenvs := setenvs(envvars{
+			"ADMIN_WEBHOOK_MAXATTEMPTS": "3",
+		})
+		defer unsetenvs()
+		// when
+		config := configuration.New()
+		// then
+		assert.Equal(t, 3, config.GetWebhookMaxAttempts())
+		assert.Equal(t, 5*time.Second, config.GetWebhookDispatchInterval())
+		assert.Equal(t, 100, config.GetWebhookBatchSize())
+		assert.Equal(t, 10*time.Second, config.GetWebhookTimeout())
+	})
+
+	t.Run("tenant updates", func(t *testing.T) {
+		// given
+		unsetenvs := setenvs(envvars{
+			"ADMIN_TENANT_UPDATE_POLLINTERVAL": "1m",
+		})
+		defer unsetenvs()
+		// when
+		config := configuration.New()
+		// then
+		assert.Equal(t, time.Minute, config.GetTenantUpdatePollInterval())
+		assert.Equal(t, 10*time.Second, config.GetTenantUpdateTimeout())
+	})
+
+	t.Run("tenant update schedules", func(t *testing.T) {
+		// given
+		unsetenvs := setenvs(envvars{
+			"ADMIN_SERVICE_ACCOUNT_ID":     "sa-id",
+			"ADMIN_SERVICE_ACCOUNT_SECRET": "sa-secret",
+		})
+		defer unsetenvs()
+		// when
+		config := configuration.New()
+		// then
+		assert.Equal(t, "sa-id", config.GetServiceAccountID())
+		assert.Equal(t, "sa-secret", config.GetServiceAccountSecret())
+		assert.Equal(t, time.Minute, config.GetTenantUpdateScheduleInterval())
+	})
+
+	t.Run("tenant update approval", func(t *testing.T) {
+		// given
+		unsetenvs := setenvs(envvars{
+			"ADMIN_TENANT_UPDATE_APPROVAL_REQUIRED": "true",
+			"ADMIN_TENANT_UPDATE_APPROVAL_EXPIRY":   "30m",
+		})
+		defer unsetenvs()
+		// when
+		config := configuration.New()
+		// then
+		assert.True(t, config.IsTenantUpdateApprovalRequired())
+		assert.Equal(t, 30*time.Minute, config.GetTenantUpdateApprovalExpiry())
+	})
+
+	t.Run("authorization admins", func(t *testing.T) {
+		// given
+		unsetenvs := setenvs(envvars{
+			"ADMIN_AUTHORIZATION_ADMINS": "foo, bar,,",
+		})
+		defer unsetenvs()
+		// when
+		config := configuration.New()
+		// then
+		assert.Equal(t, []string{"foo", "bar"}, config.GetAuthorizationAdmins())
+	})
+
+	t.Run("authorization claims", func(t *testing.T) {
+
+		t.Run("none by default", func(t *testing.T) {
+			// when
+			config := configuration.New()
+			// then
+			assert.Empty(t, config.GetAuthorizationClaims())
+		})
+
+		t.Run("valid", func(t *testing.T) {
+			// given
+			unsetenvs := setenvs(envvars{
+				"ADMIN_AUTHORIZATION_CLAIMS": "groups:tenant-admins=start_tenant_update|stop_tenant_update, realm_access.roles:auditor=read_audit_logs,groups:tenant-admins=show_tenant_update",
+			})
+			defer unsetenvs()
+			// when
+			config := configuration.New()
+			// then
+			assert.Equal(t, map[string]map[string][]string{
+				"groups": {
+					"tenant-admins": {"start_tenant_update", "stop_tenant_update", "show_tenant_update"},
+				},
+				"realm_access.roles": {
+					"auditor": {"read_audit_logs"},
+				},
+			}, config.GetAuthorizationClaims())
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			// given
+			unsetenvs := setenvs(envvars{
+				"ADMIN_AUTHORIZATION_CLAIMS": "groups:tenant-admins=start_tenant_update,tenant-admins=stop_tenant_update",
+			})
+			defer unsetenvs()
+			// when
+			config := configuration.New()
+			// then
+			assert.Empty(t, config.GetAuthorizationClaims())
+			err := config.DefaultConfigurationError()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid authorization claim mapping: 'tenant-admins=stop_tenant_update'")
+		})
+	})
+
+	t.Run("trusted proxies", func(t *testing.T) {
+
+		t.Run("none by default", func(t *testing.T) {
+			// when
+			config := configuration.New()
+			// then
+			assert.Empty(t, config.GetHTTPTrustedProxies())
+		})
+
+		t.Run("valid", func(t *testing.T) {
+			// given
+			unsetenvs := setenvs(envvars{
+				"ADMIN_HTTP_TRUSTED_PROXIES": "10.0.0.0/8, 192.168.1.1,,",
+			})
+			defer unsetenvs()
+			// when
+			config := configuration.New()
+			// then
+			proxies := config.GetHTTPTrustedProxies()
+			require.Len(t, proxies, 2)
+			assert.Equal(t, "10.0.0.0/8", proxies[0].String())
+			assert.Equal(t, "192.168.1.1/32", proxies[1].String())
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			// given
+			unsetenvs := setenvs(envvars{
+				"ADMIN_HTTP_TRUSTED_PROXIES": "10.0.0.0/8,proxy",
+			})
+			defer unsetenvs()
+			// when
+			config := configuration.New()
+			// then
+			assert.Empty(t, config.GetHTTPTrustedProxies())
+			err := config.DefaultConfigurationError()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid trusted proxy: 'proxy'")
+		})
+	})
+
 }
 
 type envvars map[string]string
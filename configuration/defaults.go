@@ -1,5 +1,7 @@
 package configuration
 
+import "time"
+
 const (
 	defaultHeaderMaxLength = 5000 // bytes
 
@@ -8,4 +10,38 @@ const (
 	defaultAuthURL = "http://auth"
 
 	defaultLogLevel = "info"
+
+	defaultAuditLogRetentionInterval  = 24 * time.Hour
+	defaultAuditLogRetentionBatchSize = 1000
+	defaultAuditLogArchiveDir         = "/var/lib/admin-console/archive"
+	defaultAuditLogIdempotencyKeyTTL  = 7 * 24 * time.Hour
+
+	defaultAuditLogPartitionsAhead    = 3
+	defaultAuditLogPartitionsInterval = 24 * time.Hour
+
+	defaultAuditLogFailurePolicy = "fail-closed"
+	defaultAuditLogSpoolDir      = "/var/lib/admin-console/spool"
+	defaultAuditLogSpoolInterval = 30 * time.Second
+
+	defaultAuditLogOutboxSink      = "none"
+	defaultAuditLogOutboxSource    = "/admin-console"
+	defaultAuditLogOutboxInterval  = 5 * time.Second
+	defaultAuditLogOutboxBatchSize = 100
+	defaultAuditLogOutboxTimeout   = 10 * time.Second
+
+	defaultAuditLogStreamKeepAlive = 15 * time.Second
+
+	defaultAuditLogAggregateMaxRange = 92 * 24 * time.Hour
+
+	defaultWebhookDispatchInterval = 5 * time.Second
+	defaultWebhookBatchSize        = 100
+	defaultWebhookMaxAttempts      = 8
+	defaultWebhookTimeout          = 10 * time.Second
+
+	defaultTenantUpdatePollInterval = 30 * time.Second
+	defaultTenantUpdateTimeout      = 10 * time.Second
+
+	defaultTenantUpdateScheduleInterval = time.Minute
+
+	defaultTenantUpdateApprovalExpiry = time.Hour
 )
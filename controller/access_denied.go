@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/authorization"
+
+	"github.com/goadesign/goa"
+)
+
+// unknownUsername the username recorded in the audit logs when the user could not be identified
+// (the angle brackets make sure it does not match any actual username)
+const unknownUsername = "<unknown>"
+
+// AccessDeniedRecorderConfiguration the configuration for the recorder of the denied accesses
+type AccessDeniedRecorderConfiguration interface {
+	GetHTTPTrustedProxies() []*net.IPNet
+}
+
+// RecordAccessDenied returns an authorization.DenialRecorder which records an `access_denied` audit log
+// with the requested controller, action and path, the missing permission and the status code of the response
+func RecordAccessDenied(config AccessDeniedRecorderConfiguration, db application.DB) authorization.DenialRecorder {
+	trustedProxies := config.GetHTTPTrustedProxies()
+	return func(ctx context.Context, username string, permission authorization.Permission, statusCode int) error {
+		if username == "" {
+			username = unknownUsername
+		}
+		params := auditlog.EventParams{
+			"controller": goa.ContextController(ctx),
+			"action":     goa.ContextAction(ctx),
+			"permission": string(permission),
+		}
+		if req := goa.ContextRequest(ctx); req != nil {
+			params["method"] = req.Method
+			params["path"] = req.URL.Path
+			params["remote_addr"] = remoteAddr(req.Request, trustedProxies)
+		}
+		_, err := createAuditLog(ctx, db, &auditlog.AuditLog{
+			EventTypeID: auditlog.AccessDenied,
+			Username:    username,
+			EventParams: params,
+			StatusCode:  &statusCode,
+			Outcome:     auditlog.OutcomeDenied,
+		})
+		return err
+	}
+}
+
+// remoteAddr returns the address of the client which sent the given request. The `X-Forwarded-For` header is only
+// taken into account when the request comes from one of the given trusted proxies, since any client can set it: in
+// that case, the address is the last one in the header which is not a trusted proxy itself.
+func remoteAddr(req *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	if !isTrustedProxy(host, trustedProxies) {
+		return req.RemoteAddr
+	}
+	forwarded := strings.Split(strings.Join(req.Header["X-Forwarded-For"], ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if addr == "" {
+			continue
+		}
+		if !isTrustedProxy(addr, trustedProxies) || i == 0 {
+			return addr
+		}
+	}
+	return req.RemoteAddr
+}
+
+// isTrustedProxy returns true if the given address belongs to one of the given trusted proxies
+func isTrustedProxy(addr string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/spool"
+)
+
+// createAuditLog stores the given audit log record for an admin action. If the record cannot be stored, the
+// failure policy of its event type applies: the returned error is nil if the record was spooled and the action
+// can proceed, in which case `spooled` is true.
+func createAuditLog(ctx context.Context, db application.DB, record *auditlog.AuditLog) (spooled bool, err error) {
+	err = application.Transactional(db, func(appl application.Application) error {
+		return appl.AuditLogs().Create(ctx, record)
+	})
+	if err != nil {
+		if err := spool.HandleFailure(ctx, record, err); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
@@ -1,36 +1,45 @@
 package controller
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fabric8-services/admin-console/app"
 	"github.com/fabric8-services/admin-console/application"
 	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/authorization"
 	"github.com/fabric8-services/admin-console/configuration"
 	"github.com/fabric8-services/fabric8-common/auth"
 	"github.com/fabric8-services/fabric8-common/errors"
 	"github.com/fabric8-services/fabric8-common/httpsupport"
 	"github.com/fabric8-services/fabric8-common/log"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/goadesign/goa"
-	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
 )
 
 // AuditLogsController implements the auditlogs resource.
 type AuditLogsController struct {
 	*goa.Controller
-	db     application.DB
-	config *configuration.Configuration
+	db       application.DB
+	config   *configuration.Configuration
+	notifier *auditlog.Notifier
 }
 
 // NewAuditLogsController creates a auditlogs controller.
-func NewAuditLogsController(service *goa.Service, config *configuration.Configuration, db application.DB) *AuditLogsController {
+func NewAuditLogsController(service *goa.Service, config *configuration.Configuration, db application.DB, notifier *auditlog.Notifier) *AuditLogsController {
 	return &AuditLogsController{
 		Controller: service.NewController("AuditLogsController"),
 		config:     config,
 		db:         db,
+		notifier:   notifier,
 	}
 }
 
@@ -40,50 +49,153 @@ func (c *AuditLogsController) Create(ctx *app.CreateAuditLogContext) error {
 	if !auth.IsSpecificServiceAccount(ctx, auth.Auth) {
 		return app.JSONErrorResponse(ctx, errors.NewUnauthorizedError("invalid or missing authorization token"))
 	}
-	// lookup the event type ID
-	eventTypeID, found := auditlog.EventTypesByName[ctx.Payload.Data.Attributes.EventType]
-	if !found {
-		return app.JSONErrorResponse(ctx, errors.NewBadParameterError("event_type", ctx.Payload.Data.Attributes.EventType))
-	}
 	log.Info(ctx, map[string]interface{}{
 		"username": ctx.Username,
 	}, "creating audit log for user")
+	var record *auditlog.AuditLog
 	err := application.Transactional(c.db, func(appl application.Application) error {
-		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
-			Username:    ctx.Username,
-			EventTypeID: eventTypeID,
-			EventParams: ctx.Payload.Data.Attributes.EventParams,
-		})
+		var err error
+		record, err = newAuditLog(ctx, appl, ctx.Username, ctx.Payload.Data.Attributes)
+		if err != nil {
+			return err
+		}
+		// the idempotency key in the header takes precedence over the one in the payload
+		if ctx.IdempotencyKey != nil {
+			record.IdempotencyKey = *ctx.IdempotencyKey
+		} else if ctx.Payload.Data.Attributes.IdempotencyKey != nil {
+			record.IdempotencyKey = *ctx.Payload.Data.Attributes.IdempotencyKey
+		}
+		return appl.AuditLogs().Create(ctx, record)
 	})
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"err":      err,
 			"username": ctx.Username,
 		}, "unable to record the auditlog for user")
+		// the error returned by the transaction is wrapped
+		if verr, ok := errs.Cause(err).(auditlog.ParamsValidationError); ok {
+			return ctx.BadRequest(&app.JSONAPIErrors{
+				Errors: convertParamsValidationError(verr, "/data/attributes/event_params"),
+			})
+		}
 		return app.JSONErrorResponse(ctx, err)
 	}
-	return ctx.NoContent()
+	ctx.ResponseData.Header().Set("Location", httpsupport.AbsoluteURL(ctx.RequestData, app.AuditLogHref(record.ID), c.config))
+	return ctx.Created()
 }
 
-// ListForUser lists the audit logs for a given user
-func (c *AuditLogsController) ListForUser(ctx *app.ListForUserAuditLogContext) error {
-	// check the token and make sure it belongs to a Red Hat employee
-	// retrieve the user's identity ID from the token
-	username, emailAddress, emailVerified, err := parseToken(ctx)
+// CreateBatch records the auditlogs of several users at once
+func (c *AuditLogsController) CreateBatch(ctx *app.CreateBatchAuditLogContext) error {
+	// check the token and make sure it belongs to `auth`
+	if !auth.IsSpecificServiceAccount(ctx, auth.Auth) {
+		return app.JSONErrorResponse(ctx, errors.NewUnauthorizedError("invalid or missing authorization token"))
+	}
+	results := make([]*app.AuditLogBatchItemResult, len(ctx.Payload.Data))
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		// validate all the entries first, and only record the valid ones
+		records := []*auditlog.AuditLog{}
+		indexes := []int{}
+		for i, data := range ctx.Payload.Data {
+			pointer := fmt.Sprintf("/data/%d/attributes", i)
+			if data.Attributes.Username == nil || *data.Attributes.Username == "" {
+				results[i] = rejectedBatchItem(newBadParameterJSONAPIError("missing username", pointer+"/username"))
+				continue
+			}
+			record, err := newAuditLog(ctx, appl, *data.Attributes.Username, data.Attributes)
+			if verr, ok := err.(auditlog.ParamsValidationError); ok {
+				results[i] = rejectedBatchItem(convertParamsValidationError(verr, pointer+"/event_params")...)
+				continue
+			} else if _, ok := err.(errors.BadParameterError); ok {
+				results[i] = rejectedBatchItem(newBadParameterJSONAPIError(err.Error(), pointer+"/event_type"))
+				continue
+			} else if err != nil {
+				return err
+			}
+			if data.Attributes.IdempotencyKey != nil {
+				record.IdempotencyKey = *data.Attributes.IdempotencyKey
+			}
+			records = append(records, record)
+			indexes = append(indexes, i)
+		}
+		outcomes, err := appl.AuditLogs().CreateBatch(ctx, records)
+		if err != nil {
+			return err
+		}
+		for j, record := range records {
+			if outcomes[j].Conflict != nil {
+				pointer := fmt.Sprintf("/data/%d/attributes/idempotency_key", indexes[j])
+				results[indexes[j]] = rejectedBatchItem(newDataConflictJSONAPIError(outcomes[j].Conflict.Error(), pointer))
+				continue
+			}
+			id := record.ID.String()
+			status := "created"
+			if !outcomes[j].Created {
+				status = "duplicate"
+			}
+			results[indexes[j]] = &app.AuditLogBatchItemResult{
+				Status: status,
+				ID:     &id,
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
-			"err": err,
-		}, "unable to parse request token")
+			"err":     err,
+			"entries": len(ctx.Payload.Data),
+		}, "unable to record the batch of auditlogs")
 		return app.JSONErrorResponse(ctx, err)
 	}
-	if !(strings.HasSuffix(emailAddress, "@redhat.com") && emailVerified) {
+	log.Info(ctx, map[string]interface{}{
+		"entries": len(ctx.Payload.Data),
+	}, "recorded batch of auditlogs")
+	return ctx.OK(&app.AuditLogBatchResult{
+		Data: results,
+	})
+}
+
+// newAuditLog returns a new audit log record for the given user, after verifying that the event type exists,
+// that it is not reserved to the admin console and that the event params match its schema
+// returns a BadParameterError if the event type is invalid, or a ParamsValidationError if the event params are invalid
+func newAuditLog(ctx context.Context, appl application.Application, username string, attrs *app.CreateAuditLogDataAttributes) (*auditlog.AuditLog, error) {
+	eventType, err := appl.EventTypes().LoadByName(ctx, attrs.EventType)
+	if err != nil {
+		if _, ok := err.(errors.NotFoundError); ok {
+			return nil, errors.NewBadParameterError("event_type", attrs.EventType)
+		}
+		return nil, err
+	}
+	if eventType.Internal {
+		return nil, errors.NewBadParameterError("event_type", attrs.EventType)
+	}
+	if err := eventType.ValidateParams(attrs.EventParams); err != nil {
+		return nil, err
+	}
+	return &auditlog.AuditLog{
+		Username:    username,
+		EventTypeID: eventType.ID,
+		EventParams: attrs.EventParams,
+	}, nil
+}
+
+// rejectedBatchItem returns the result of an entry of a batch which was rejected because of the given errors
+func rejectedBatchItem(errs ...*app.JSONAPIError) *app.AuditLogBatchItemResult {
+	return &app.AuditLogBatchItemResult{
+		Status: "rejected",
+		Errors: errs,
+	}
+}
+
+// ListForUser lists the audit logs for a given user
+func (c *AuditLogsController) ListForUser(ctx *app.ListForUserAuditLogContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
 		log.Error(ctx, map[string]interface{}{
-			"target_username":     ctx.Username,
-			"requesting_username": username,
-			"email_address":       emailAddress,
-			"email_verified":      emailVerified,
-		}, "user is not allowed to list audit logs")
-		return app.JSONErrorResponse(ctx, errors.NewForbiddenError("forbidden"))
+			"err":             err,
+			"target_username": ctx.Username,
+		}, "unable to identify the user listing audit logs")
+		return app.JSONErrorResponse(ctx, err)
 	}
 	// log an audit log for the current user for her action
 	record := auditlog.AuditLog{
@@ -93,16 +205,74 @@ func (c *AuditLogsController) ListForUser(ctx *app.ListForUserAuditLogContext) e
 			"user": ctx.Username,
 		},
 	}
-	err = application.Transactional(c.db, func(appl application.Application) error {
-		return appl.AuditLogs().Create(ctx, &record)
+	spooled, err := createAuditLog(ctx, c.db, &record)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to record the auditlog while listing audit logs for user")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return routeAndRecordOutcome(ctx, c.db, &record, spooled, ctx.ResponseData, countJSONAPIData, func() error {
+		// search for audit logs for the request (target) user
+		if ctx.PageCursor != nil {
+			return c.listForUserWithCursor(ctx)
+		}
+		var logs []auditlog.AuditLog
+		var total int
+		var eventTypes map[uuid.UUID]string
+		err = application.Transactional(c.db, func(appl application.Application) error {
+			var err error
+			logs, total, err = appl.AuditLogs().ListByUsername(ctx, ctx.Username, ctx.PageNumber, ctx.PageSize)
+			if err != nil {
+				return err
+			}
+			eventTypes, err = appl.EventTypes().Names(ctx)
+			return err
+		})
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":      err,
+				"username": ctx.Username,
+			}, "unable to list auditlogs for user")
+			return app.JSONErrorResponse(ctx, err)
+		}
+		return ctx.OK(convertAuditLogs(ctx.RequestData, logs, eventTypes, total, ctx.PageNumber, ctx.PageSize, c.config))
 	})
-	// search for audit logs for the request (target) user
+}
+
+// listForUserWithCursor lists the audit logs for a given user, using keyset pagination
+func (c *AuditLogsController) listForUserWithCursor(ctx *app.ListForUserAuditLogContext) error {
+	var after *auditlog.Cursor
+	if *ctx.PageCursor != "" {
+		cursor, err := auditlog.ParseCursor(*ctx.PageCursor)
+		if err != nil {
+			return app.JSONErrorResponse(ctx, err)
+		}
+		after = &cursor
+	}
+	_, pageSize := computePagingLimits(0, ctx.PageSize)
 	var logs []auditlog.AuditLog
-	var total int
-	err = application.Transactional(c.db, func(appl application.Application) error {
+	var next *auditlog.Cursor
+	var total *int
+	var eventTypes map[uuid.UUID]string
+	err := application.Transactional(c.db, func(appl application.Application) error {
 		var err error
-		logs, total, err = appl.AuditLogs().ListByUsername(ctx, ctx.Username, ctx.PageNumber, ctx.PageSize)
-		return err
+		logs, next, err = appl.AuditLogs().ListByUsernameAfter(ctx, ctx.Username, after, pageSize)
+		if err != nil {
+			return err
+		}
+		eventTypes, err = appl.EventTypes().Names(ctx)
+		if err != nil {
+			return err
+		}
+		if ctx.Count {
+			count, err := appl.AuditLogs().CountByUsername(ctx, ctx.Username)
+			if err != nil {
+				return err
+			}
+			total = &count
+		}
+		return nil
 	})
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
@@ -111,57 +281,644 @@ func (c *AuditLogsController) ListForUser(ctx *app.ListForUserAuditLogContext) e
 		}, "unable to list auditlogs for user")
 		return app.JSONErrorResponse(ctx, err)
 	}
-	return ctx.OK(convertAuditLogs(ctx, logs, total, c.config))
+	response := &app.AuditLogList{
+		Data:  convertAuditLogsData(ctx.RequestData, logs, eventTypes, c.config),
+		Links: &app.PagingLinks{},
+	}
+	if total != nil {
+		response.Meta = &app.UserListMeta{
+			TotalCount: total,
+		}
+	}
+	path := httpsupport.AbsoluteURL(ctx.RequestData, ctx.Request.URL.Path, c.config)
+	var nextCursor *string
+	if next != nil {
+		n := next.String()
+		nextCursor = &n
+	}
+	var additionalQuery []string
+	if ctx.Count {
+		additionalQuery = append(additionalQuery, "count=true")
+	}
+	setCursorPagingLinks(response.Links, path, pageSize, nextCursor, additionalQuery...)
+	return ctx.OK(response)
+}
+
+// Export streams all the audit logs for a given user, in the CSV or NDJSON format
+func (c *AuditLogsController) Export(ctx *app.ExportAuditLogContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"target_username": ctx.Username,
+		}, "unable to identify the user exporting audit logs")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	mediaType, err := exportMediaType(ctx.Accept)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	// log an audit log for the current user for her action
+	record := auditlog.AuditLog{
+		EventTypeID: auditlog.ExportAuditLogs,
+		Username:    username,
+		EventParams: auditlog.EventParams{
+			"user":   ctx.Username,
+			"format": mediaType,
+		},
+	}
+	spooled, err := createAuditLog(ctx, c.db, &record)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to record the auditlog while exporting audit logs")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return routeAndRecordOutcome(ctx, c.db, &record, spooled, ctx.ResponseData, nil, func() error {
+		// records are streamed outside of a transaction, which would otherwise timeout on large histories
+		repo := c.db.AuditLogs()
+		eventTypes, err := c.db.EventTypes().Names(ctx)
+		if err != nil {
+			return app.JSONErrorResponse(ctx, err)
+		}
+		var exporter auditlog.Exporter
+		var extension string
+		switch mediaType {
+		case auditlog.NDJSONMediaType:
+			exporter = auditlog.NewNDJSONExporter(ctx.ResponseData, eventTypes)
+			extension = "ndjson"
+		default:
+			keys, err := repo.EventParamKeysByUsername(ctx, ctx.Username)
+			if err != nil {
+				return app.JSONErrorResponse(ctx, err)
+			}
+			exporter, err = auditlog.NewCSVExporter(ctx.ResponseData, eventTypes, keys)
+			if err != nil {
+				return app.JSONErrorResponse(ctx, err)
+			}
+			extension = "csv"
+		}
+		ctx.ResponseData.Header().Set("Content-Type", mediaType)
+		ctx.ResponseData.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="auditlogs-%s.%s"`, ctx.Username, extension))
+		ctx.ResponseData.WriteHeader(http.StatusOK)
+		count := 0
+		err = repo.ForEachByUsername(ctx, ctx.Username, func(r auditlog.AuditLog) error {
+			if err := exporter.Write(r); err != nil {
+				return err
+			}
+			count++
+			// periodically send the data to the client
+			if count%exportFlushInterval == 0 {
+				return flush(ctx.ResponseData.ResponseWriter, exporter)
+			}
+			return nil
+		})
+		if err == nil {
+			err = flush(ctx.ResponseData.ResponseWriter, exporter)
+		}
+		if err != nil {
+			// too late to return an error response since the headers were already sent
+			log.Error(ctx, map[string]interface{}{
+				"err":      err,
+				"username": ctx.Username,
+				"count":    count,
+			}, "unable to export all auditlogs for user")
+		}
+		return nil
+	})
 }
 
-func parseToken(ctx *app.ListForUserAuditLogContext) (string, string, bool, error) {
-	token := goajwt.ContextJWT(ctx)
-	if token == nil {
-		return "", "", false, errors.NewUnauthorizedError("bad or missing token")
+// number of records after which the exported data is flushed to the client
+const exportFlushInterval = 100
+
+// flush flushes the exporter and sends the data written so far to the client (see `flushResponse`)
+func flush(w http.ResponseWriter, exporter auditlog.Exporter) error {
+	if err := exporter.Flush(); err != nil {
+		return err
 	}
-	claims := token.Claims.(jwt.MapClaims)
-	username, ok := claims["preferred_username"].(string)
-	if !ok {
-		log.Error(ctx, map[string]interface{}{}, "token is missing 'preferred_username' claim")
-		return "", "", false, errors.NewUnauthorizedError("bad or missing token")
+	flushResponse(w)
+	return nil
+}
+
+// flushResponse sends the data written so far to the client. The given writer must be the one wrapped by the goa
+// response data, since `*goa.ResponseData` does not implement `http.Flusher`
+func flushResponse(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
 	}
-	emailAddress, ok := claims["email"].(string)
-	if !ok {
-		log.Error(ctx, map[string]interface{}{}, "token is missing 'email' claim")
-		return "", "", false, errors.NewUnauthorizedError("bad or missing token")
+}
+
+// exportMediaType returns the media type of the export, given the request 'Accept' header.
+// Defaults to CSV if the header is missing or accepts any media type.
+// returns a BadParameterError if none of the requested media types is supported
+func exportMediaType(accept *string) (string, error) {
+	if accept == nil || strings.TrimSpace(*accept) == "" {
+		return auditlog.CSVMediaType, nil
 	}
-	emailVerified, ok := claims["email_verified"].(bool)
-	if !ok {
-		log.Error(ctx, map[string]interface{}{}, "token is missing 'email_verified' claim")
-		return "", "", false, errors.NewUnauthorizedError("bad or missing token")
+	for _, mt := range strings.Split(*accept, ",") {
+		// ignore the params such as the 'q' weight
+		mt = strings.TrimSpace(strings.SplitN(mt, ";", 2)[0])
+		switch mt {
+		case auditlog.CSVMediaType, "text/*", "*/*":
+			return auditlog.CSVMediaType, nil
+		case auditlog.NDJSONMediaType:
+			return auditlog.NDJSONMediaType, nil
+		}
 	}
-	return username, emailAddress, emailVerified, nil
+	return "", errors.NewBadParameterError("Accept", *accept)
 }
 
-// convertAuditLogs converts the audit logs to their resource-API counterpart
-func convertAuditLogs(ctx *app.ListForUserAuditLogContext, logs []auditlog.AuditLog, total int, config httpsupport.Configuration) *app.AuditLogList {
-	data := []*app.AuditLogData{}
-	for _, log := range logs {
-		fmt.Printf("event type: %v -> %s\n", log.EventTypeID, auditlog.EventTypesByID[log.EventTypeID])
-		fmt.Printf("event params: %v\n", log.EventParams)
-		data = append(data, &app.AuditLogData{
-			Type: "audit_logs",
-			Attributes: &app.AuditLogDataAttributes{
-				Date:        log.CreatedAt.Format("2006-01-02:15:03:04"),
-				EventType:   auditlog.EventTypesByID[log.EventTypeID],
-				EventParams: log.EventParams,
-			},
+// Stream streams the new audit logs matching the criteria given in the request as Server-Sent Events, until the client disconnects
+func (c *AuditLogsController) Stream(ctx *app.StreamAuditLogContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to identify the user streaming audit logs")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	filter := auditlog.Filter{
+		EventTypes: ctx.EventType,
+	}
+	if ctx.Username != nil {
+		filter.UsernamePrefix = *ctx.Username
+	}
+	var last int64
+	if ctx.LastEventID != nil {
+		last, err = strconv.ParseInt(*ctx.LastEventID, 10, 64)
+		if err != nil || last < 0 {
+			return app.JSONErrorResponse(ctx, errors.NewBadParameterError("Last-Event-ID", *ctx.LastEventID))
+		}
+	}
+	// log an audit log for the current user for her action
+	record := auditlog.AuditLog{
+		EventTypeID: auditlog.StreamAuditLogs,
+		Username:    username,
+		EventParams: auditlog.EventParams{
+			"query": ctx.Request.URL.RawQuery,
+		},
+	}
+	spooled, err := createAuditLog(ctx, c.db, &record)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to record the auditlog while streaming audit logs")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return routeAndRecordOutcome(ctx, c.db, &record, spooled, ctx.ResponseData, nil, func() error {
+		// subscribe before looking up the records, so that no notification is missed in-between
+		signals, unsubscribe := c.notifier.Subscribe()
+		defer unsubscribe()
+		// records are streamed outside of a transaction, which would otherwise timeout
+		repo := c.db.AuditLogs()
+		if ctx.LastEventID == nil {
+			// only stream the records stored from now on
+			if last, err = repo.LastSeq(ctx); err != nil {
+				return app.JSONErrorResponse(ctx, err)
+			}
+		}
+		eventTypes, err := c.db.EventTypes().Names(ctx)
+		if err != nil {
+			return app.JSONErrorResponse(ctx, err)
+		}
+		ctx.ResponseData.Header().Set("Content-Type", "text/event-stream")
+		ctx.ResponseData.Header().Set("Cache-Control", "no-cache")
+		ctx.ResponseData.Header().Set("X-Accel-Buffering", "no") // disables the buffering by the proxies
+		ctx.ResponseData.WriteHeader(http.StatusOK)
+		flushResponse(ctx.ResponseData.ResponseWriter)
+		keepAlive := time.NewTicker(c.config.GetAuditLogStreamKeepAlive())
+		defer keepAlive.Stop()
+		count := 0
+		for {
+			// send the records which were stored since the last lookup, if they match the filter
+			for {
+				// the records up to the head of the chain are all visible, so the next lookup can start from there
+				head, err := repo.LastSeq(ctx)
+				if err != nil {
+					return stopStream(ctx, err, count)
+				}
+				records, err := repo.ListAfterSeq(ctx, filter, last, streamBatchSize)
+				if err != nil {
+					return stopStream(ctx, err, count)
+				}
+				for _, r := range records {
+					if _, found := eventTypes[r.EventTypeID]; !found {
+						// the event type was registered after the stream started
+						if eventTypes, err = c.db.EventTypes().Names(ctx); err != nil {
+							return stopStream(ctx, err, count)
+						}
+					}
+					if err := writeServerSentEvent(ctx.ResponseData, r.ChainSeq, convertAuditLogData(ctx.RequestData, r, eventTypes, c.config)); err != nil {
+						return stopStream(ctx, err, count)
+					}
+					last = r.ChainSeq
+					count++
+				}
+				if len(records) < streamBatchSize {
+					if head > last {
+						last = head
+					}
+					break
+				}
+			}
+			flushResponse(ctx.ResponseData.ResponseWriter)
+			select {
+			case <-ctx.Done():
+				return stopStream(ctx, nil, count)
+			case <-ctx.Request.Context().Done():
+				// the client disconnected
+				return stopStream(ctx, nil, count)
+			case <-signals:
+			case <-keepAlive.C:
+				// send a comment, which is ignored by the clients
+				if _, err := io.WriteString(ctx.ResponseData, ": keep-alive\n\n"); err != nil {
+					return stopStream(ctx, err, count)
+				}
+			}
+		}
+	})
+}
+
+// number of records looked-up at once while streaming the audit logs
+const streamBatchSize = 100
+
+// stopStream logs the end of a stream of audit logs, which is caused by the given error if it is not nil.
+// Always returns nil, since it is too late to return an error response once the headers were sent
+func stopStream(ctx *app.StreamAuditLogContext, err error, count int) error {
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":   err,
+			"count": count,
+		}, "unable to stream all audit logs")
+		return nil
+	}
+	log.Info(ctx, map[string]interface{}{
+		"count": count,
+	}, "stopped streaming audit logs")
+	return nil
+}
+
+// writeServerSentEvent writes the given data as a Server-Sent Event with the given ID
+// see https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+func writeServerSentEvent(w io.Writer, id int64, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	// the JSON representation does not contain any line break, so the data fits on a single line
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", id, b)
+	return err
+}
+
+// Show shows an audit log record
+func (c *AuditLogsController) Show(ctx *app.ShowAuditLogContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":          err,
+			"audit_log_id": ctx.ID,
+		}, "unable to identify the user showing an audit log")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	id := uuid.UUID(ctx.ID)
+	// log an audit log for the current user for her action
+	record := auditlog.AuditLog{
+		EventTypeID: auditlog.ShowAuditLog,
+		Username:    username,
+		EventParams: auditlog.EventParams{
+			"audit_log_id": id.String(),
+		},
+	}
+	spooled, err := createAuditLog(ctx, c.db, &record)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to record the auditlog while showing an audit log")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return routeAndRecordOutcome(ctx, c.db, &record, spooled, ctx.ResponseData, nil, func() error {
+		var record auditlog.AuditLog
+		var eventTypes map[uuid.UUID]string
+		err = application.Transactional(c.db, func(appl application.Application) error {
+			var err error
+			record, err = appl.AuditLogs().LoadByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			eventTypes, err = appl.EventTypes().Names(ctx)
+			return err
+		})
+		if err != nil {
+			return app.JSONErrorResponse(ctx, err)
+		}
+		return ctx.OK(&app.AuditLogSingle{
+			Data: convertAuditLogData(ctx.RequestData, record, eventTypes, c.config),
+		})
+	})
+}
+
+// List lists the audit logs of all users, matching the criteria given in the request
+func (c *AuditLogsController) List(ctx *app.ListAuditLogContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to identify the user listing audit logs")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	filter, err := newAuditLogFilter(ctx)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	// log an audit log for the current user for her action
+	record := auditlog.AuditLog{
+		EventTypeID: auditlog.ListAuditLogs,
+		Username:    username,
+		EventParams: auditlog.EventParams{
+			"query": ctx.Request.URL.RawQuery,
+		},
+	}
+	spooled, err := createAuditLog(ctx, c.db, &record)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to record the auditlog while listing audit logs")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return routeAndRecordOutcome(ctx, c.db, &record, spooled, ctx.ResponseData, countJSONAPIData, func() error {
+		// search for audit logs matching the filter
+		var logs []auditlog.AuditLog
+		var total int
+		var eventTypes map[uuid.UUID]string
+		err = application.Transactional(c.db, func(appl application.Application) error {
+			var err error
+			logs, total, err = appl.AuditLogs().List(ctx, filter, ctx.PageNumber, ctx.PageSize)
+			if err != nil {
+				return err
+			}
+			eventTypes, err = appl.EventTypes().Names(ctx)
+			return err
+		})
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err": err,
+			}, "unable to list auditlogs")
+			return app.JSONErrorResponse(ctx, err)
+		}
+		return ctx.OK(convertAuditLogs(ctx.RequestData, logs, eventTypes, total, ctx.PageNumber, ctx.PageSize, c.config, filterQuery(ctx.Request.URL.Query())...))
+	})
+}
+
+// Aggregate counts the audit logs matching the criteria given in the request, by group
+func (c *AuditLogsController) Aggregate(ctx *app.AggregateAuditLogContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to identify the user aggregating audit logs")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	// the range of dates is bounded, so that the whole table is never scanned
+	createdBefore := time.Now()
+	if ctx.CreatedBefore != nil {
+		createdBefore = *ctx.CreatedBefore
+	}
+	if maxRange := c.config.GetAuditLogAggregateMaxRange(); createdBefore.Sub(ctx.CreatedAfter) > maxRange {
+		return app.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString(
+			fmt.Sprintf("the range of dates must not exceed %s", maxRange)))
+	}
+	filter := auditlog.Filter{
+		EventTypes:    ctx.EventType,
+		CreatedAfter:  &ctx.CreatedAfter,
+		CreatedBefore: &createdBefore,
+	}
+	if ctx.Username != nil {
+		filter.UsernamePrefix = *ctx.Username
+	}
+	grouping := auditlog.Grouping{}
+	for _, g := range ctx.GroupBy {
+		switch g {
+		case "event_type":
+			grouping.EventType = true
+		case "username":
+			grouping.Username = true
+		}
+	}
+	if ctx.Interval != nil {
+		grouping.Interval = auditlog.Interval(*ctx.Interval)
+	}
+	// log an audit log for the current user for her action
+	record := auditlog.AuditLog{
+		EventTypeID: auditlog.AggregateAuditLogs,
+		Username:    username,
+		EventParams: auditlog.EventParams{
+			"query": ctx.Request.URL.RawQuery,
+		},
+	}
+	spooled, err := createAuditLog(ctx, c.db, &record)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to record the auditlog while aggregating audit logs")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return routeAndRecordOutcome(ctx, c.db, &record, spooled, ctx.ResponseData, countJSONAPIData, func() error {
+		var stats []auditlog.Stat
+		var eventTypes map[uuid.UUID]string
+		err = application.Transactional(c.db, func(appl application.Application) error {
+			var err error
+			stats, err = appl.AuditLogs().Aggregate(ctx, filter, grouping, ctx.Limit)
+			if err != nil {
+				return err
+			}
+			eventTypes, err = appl.EventTypes().Names(ctx)
+			return err
 		})
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err": err,
+			}, "unable to aggregate auditlogs")
+			return app.JSONErrorResponse(ctx, err)
+		}
+		return ctx.OK(&app.AuditLogStatList{
+			Data: convertAuditLogStatsData(stats, eventTypes),
+		})
+	})
+}
+
+// newAuditLogFilter converts the query params of the request into an audit log filter
+func newAuditLogFilter(ctx *app.ListAuditLogContext) (auditlog.Filter, error) {
+	filter := auditlog.Filter{
+		EventTypes:    ctx.EventType,
+		CreatedAfter:  ctx.CreatedAfter,
+		CreatedBefore: ctx.CreatedBefore,
+		Sort:          auditlog.SortAscending,
+	}
+	if ctx.Username != nil {
+		filter.UsernamePrefix = *ctx.Username
+	}
+	if ctx.IdentityID != nil {
+		identityID := uuid.UUID(*ctx.IdentityID)
+		filter.IdentityID = &identityID
+	}
+	if strings.HasPrefix(ctx.Sort, "-") {
+		filter.Sort = auditlog.SortDescending
+	}
+	if len(ctx.EventParams) > 0 {
+		filter.EventParams = auditlog.EventParams{}
+		for _, p := range ctx.EventParams {
+			kv := strings.SplitN(p, ":", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return auditlog.Filter{}, errors.NewBadParameterError("event_params", p)
+			}
+			filter.EventParams[kv[0]] = kv[1]
+		}
+	}
+	return filter, nil
+}
+
+// currentUsername returns the username of the user, as found in the token in the given context
+// returns an UnauthorizedError if the token is missing or if it has no 'preferred_username' claim
+func currentUsername(ctx context.Context) (string, error) {
+	username := authorization.Username(ctx)
+	if username == "" {
+		return "", errors.NewUnauthorizedError("bad or missing token")
 	}
+	return username, nil
+}
+
+// convertAuditLogs converts the audit logs to their resource-API counterpart
+func convertAuditLogs(req *goa.RequestData, logs []auditlog.AuditLog, eventTypes map[uuid.UUID]string, total, pageNumber, pageSize int, config httpsupport.Configuration, additionalQuery ...string) *app.AuditLogList {
 	response := &app.AuditLogList{
-		Data:  data,
+		Data:  convertAuditLogsData(req, logs, eventTypes, config),
 		Links: &app.PagingLinks{},
 		Meta: &app.UserListMeta{
-			TotalCount: total,
+			TotalCount: &total,
 		},
 	}
-	pageNumber, pageSize := computePagingLimits(ctx.PageNumber, ctx.PageSize)
-	path := httpsupport.AbsoluteURL(ctx.RequestData, ctx.Request.URL.Path, config)
-	setPagingLinks(response.Links, path, len(logs), pageNumber, pageSize, total)
+	pageNumber, pageSize = computePagingLimits(pageNumber, pageSize)
+	path := httpsupport.AbsoluteURL(req, req.Request.URL.Path, config)
+	setPagingLinks(response.Links, path, len(logs), pageNumber, pageSize, total, additionalQuery...)
 	return response
 }
+
+// convertAuditLogsData converts the audit logs to their resource-API data counterpart, using the given event type names
+func convertAuditLogsData(req *goa.RequestData, logs []auditlog.AuditLog, eventTypes map[uuid.UUID]string, config httpsupport.Configuration) []*app.AuditLogData {
+	data := []*app.AuditLogData{}
+	for _, log := range logs {
+		data = append(data, convertAuditLogData(req, log, eventTypes, config))
+	}
+	return data
+}
+
+// convertAuditLogData converts the audit log to its resource-API data counterpart, using the given event type names
+func convertAuditLogData(req *goa.RequestData, record auditlog.AuditLog, eventTypes map[uuid.UUID]string, config httpsupport.Configuration) *app.AuditLogData {
+	username := record.Username
+	self := httpsupport.AbsoluteURL(req, app.AuditLogHref(record.ID), config)
+	data := &app.AuditLogData{
+		Type: "audit_logs",
+		ID:   record.ID.String(),
+		Attributes: &app.AuditLogDataAttributes{
+			Date:        record.CreatedAt.Format("2006-01-02:15:03:04"),
+			Username:    &username,
+			EventType:   eventTypes[record.EventTypeID],
+			EventParams: record.EventParams,
+		},
+		Links: &app.GenericLinks{
+			Self: &self,
+		},
+	}
+	if record.IdentityID != uuid.Nil {
+		identityID := record.IdentityID.String()
+		data.Attributes.IdentityID = &identityID
+	}
+	if record.StatusCode != nil {
+		statusCode := *record.StatusCode
+		data.Attributes.StatusCode = &statusCode
+	}
+	if record.Outcome != "" {
+		outcome := string(record.Outcome)
+		data.Attributes.Outcome = &outcome
+	}
+	if record.LatencyMs != nil {
+		latency := int(*record.LatencyMs)
+		data.Attributes.LatencyMs = &latency
+	}
+	if record.ResponseSize != nil {
+		responseSize := int(*record.ResponseSize)
+		data.Attributes.ResponseSize = &responseSize
+	}
+	if record.ResultCount != nil {
+		resultCount := *record.ResultCount
+		data.Attributes.ResultCount = &resultCount
+	}
+	return data
+}
+
+// convertAuditLogStatsData converts the audit log stats to their resource-API data counterpart, using the given event type names
+func convertAuditLogStatsData(stats []auditlog.Stat, eventTypes map[uuid.UUID]string) []*app.AuditLogStatData {
+	data := make([]*app.AuditLogStatData, len(stats))
+	for i, s := range stats {
+		attrs := &app.AuditLogStatDataAttributes{
+			Username: s.Username,
+			Bucket:   s.Bucket,
+			Count:    s.Count,
+		}
+		if s.EventTypeID != nil {
+			eventType := eventTypes[*s.EventTypeID]
+			attrs.EventType = &eventType
+		}
+		data[i] = &app.AuditLogStatData{
+			Type:       "audit_log_stats",
+			Attributes: attrs,
+		}
+	}
+	return data
+}
+
+// convertParamsValidationError converts the errors found while validating the params of an event into JSON-API errors,
+// whose source pointer locates the invalid value in the request payload, given the pointer to the event params
+func convertParamsValidationError(err auditlog.ParamsValidationError, pointer string) []*app.JSONAPIError {
+	errs := make([]*app.JSONAPIError, len(err.Errors))
+	for i, e := range err.Errors {
+		errs[i] = newBadParameterJSONAPIError(e.Detail, pointer+e.Pointer)
+	}
+	return errs
+}
+
+// newDataConflictJSONAPIError returns a JSON-API error about the conflicting value at the given pointer in the request payload
+func newDataConflictJSONAPIError(detail, pointer string) *app.JSONAPIError {
+	status := "409"
+	code := "data_conflict"
+	title := "Data Conflict"
+	return &app.JSONAPIError{
+		Status: &status,
+		Code:   &code,
+		Title:  &title,
+		Detail: detail,
+		Source: map[string]interface{}{
+			"pointer": pointer,
+		},
+	}
+}
+
+// newBadParameterJSONAPIError returns a JSON-API error about the invalid value at the given pointer in the request payload
+func newBadParameterJSONAPIError(detail, pointer string) *app.JSONAPIError {
+	status := "400"
+	code := "bad_parameter"
+	title := "Bad Parameter"
+	return &app.JSONAPIError{
+		Status: &status,
+		Code:   &code,
+		Title:  &title,
+		Detail: detail,
+		Source: map[string]interface{}{
+			"pointer": pointer,
+		},
+	}
+}
@@ -1,9 +1,16 @@
 package controller_test
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +18,7 @@ import (
 	apptest "github.com/fabric8-services/admin-console/app/test"
 	"github.com/fabric8-services/admin-console/application"
 	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/authorization"
 	"github.com/fabric8-services/admin-console/configuration"
 	"github.com/fabric8-services/admin-console/controller"
 	"github.com/fabric8-services/fabric8-common/auth"
@@ -18,7 +26,9 @@ import (
 	testauth "github.com/fabric8-services/fabric8-common/test/auth"
 	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
 	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
 
+	goauuid "github.com/goadesign/goa/uuid"
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -27,8 +37,10 @@ import (
 
 type AuditLogsControllerBlackboxTestSuite struct {
 	testsuite.DBTestSuite
-	app    *application.GormApplication
-	config *configuration.Configuration
+	app          *application.GormApplication
+	config       *configuration.Configuration
+	notifier     *auditlog.Notifier
+	stopNotifier context.CancelFunc
 }
 
 func TestAuditLogs(t *testing.T) {
@@ -43,13 +55,24 @@ func TestAuditLogs(t *testing.T) {
 func (s *AuditLogsControllerBlackboxTestSuite) SetupSuite() {
 	s.DBTestSuite.SetupSuite()
 	s.app = application.NewGormApplication(s.DB)
+	notifier, err := auditlog.NewNotifier(s.config.GetPostgresConfigString())
+	require.NoError(s.T(), err)
+	var ctx context.Context
+	ctx, s.stopNotifier = context.WithCancel(context.Background())
+	go notifier.Start(ctx)
+	s.notifier = notifier
+}
+
+func (s *AuditLogsControllerBlackboxTestSuite) TearDownSuite() {
+	s.stopNotifier()
+	s.DBTestSuite.TearDownSuite()
 }
 
 func (s *AuditLogsControllerBlackboxTestSuite) TestCreateAuditLog() {
 
 	// given
 	svc := goa.New("auditlogs")
-	ctrl := controller.NewAuditLogsController(svc, s.config, s.app)
+	ctrl := controller.NewAuditLogsController(svc, s.config, s.app, s.notifier)
 	ctx, err := testauth.EmbedServiceAccountTokenInContext(context.Background(), &testauth.Identity{
 		Username: auth.Auth,
 		ID:       uuid.NewV4(),
@@ -64,7 +87,7 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestCreateAuditLog() {
 				"notification_deactivation": time.Now().Format("2006-01-02:15:04:05"),
 				"scheduled_deactivation":    time.Now().Add(time.Hour * 24 * 7).Format("2006-01-02:15:04:05"),
 			}
-			apptest.CreateAuditLogNoContent(s.T(), ctx, svc, ctrl, username, &app.CreateAuditLogPayload{
+			apptest.CreateAuditLogCreated(s.T(), ctx, svc, ctrl, username, nil, &app.CreateAuditLogPayload{
 				Data: &app.CreateAuditLogData{
 					Type: "audit_logs",
 					Attributes: &app.CreateAuditLogDataAttributes{
@@ -85,7 +108,7 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestCreateAuditLog() {
 		s.Run("without event params", func() {
 			// when
 			username := fmt.Sprintf("user-%v", uuid.NewV4())
-			apptest.CreateAuditLogNoContent(s.T(), ctx, svc, ctrl, username, &app.CreateAuditLogPayload{
+			apptest.CreateAuditLogCreated(s.T(), ctx, svc, ctrl, username, nil, &app.CreateAuditLogPayload{
 				Data: &app.CreateAuditLogData{
 					Type: "audit_logs",
 					Attributes: &app.CreateAuditLogDataAttributes{
@@ -101,13 +124,65 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestCreateAuditLog() {
 			assert.Equal(s.T(), auditlog.UserDeactivation, record.EventTypeID)
 			assert.Nil(s.T(), record.EventParams)
 		})
+
+		s.Run("with idempotency key", func() {
+			// given
+			username := fmt.Sprintf("user-%v", uuid.NewV4())
+			key := uuid.NewV4().String()
+			payload := &app.CreateAuditLogPayload{
+				Data: &app.CreateAuditLogData{
+					Type: "audit_logs",
+					Attributes: &app.CreateAuditLogDataAttributes{
+						EventType: auditlog.UserDeactivationNotificationEvent,
+						EventParams: map[string]interface{}{
+							"scheduled_deactivation": time.Now().Add(time.Hour * 24 * 7).Format("2006-01-02:15:04:05"),
+						},
+					},
+				},
+			}
+			rw := apptest.CreateAuditLogCreated(s.T(), ctx, svc, ctrl, username, &key, payload)
+			// when the request is retried
+			retry := apptest.CreateAuditLogCreated(s.T(), ctx, svc, ctrl, username, &key, payload)
+			// then
+			records, total, err := auditlog.NewRepository(s.DB).ListByUsername(context.Background(), username, 0, 5)
+			require.NoError(s.T(), err)
+			require.Equal(s.T(), 1, total)
+			location := rw.Header().Get("Location")
+			assert.True(s.T(), strings.HasSuffix(location, "/api/auditlogs/"+records[0].ID.String()), location)
+			assert.Equal(s.T(), location, retry.Header().Get("Location"))
+		})
 	})
 
 	s.Run("failures", func() {
+		s.Run("idempotency key reused with a different payload", func() {
+			// given
+			username := fmt.Sprintf("user-%v", uuid.NewV4())
+			key := uuid.NewV4().String()
+			newPayload := func(scheduledDeactivation time.Time) *app.CreateAuditLogPayload {
+				return &app.CreateAuditLogPayload{
+					Data: &app.CreateAuditLogData{
+						Type: "audit_logs",
+						Attributes: &app.CreateAuditLogDataAttributes{
+							EventType: auditlog.UserDeactivationNotificationEvent,
+							EventParams: map[string]interface{}{
+								"scheduled_deactivation": scheduledDeactivation.Format("2006-01-02:15:04:05"),
+							},
+						},
+					},
+				}
+			}
+			apptest.CreateAuditLogCreated(s.T(), ctx, svc, ctrl, username, &key, newPayload(time.Now().Add(time.Hour*24*7)))
+			// when/then
+			apptest.CreateAuditLogConflict(s.T(), ctx, svc, ctrl, username, &key, newPayload(time.Now().Add(time.Hour*24*14)))
+			_, total, err := auditlog.NewRepository(s.DB).ListByUsername(context.Background(), username, 0, 5)
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), 1, total)
+		})
+
 		s.Run("bad request", func() {
 			s.Run("invalid event type", func() {
 				// when/then
-				apptest.CreateAuditLogBadRequest(s.T(), ctx, svc, ctrl, "username", &app.CreateAuditLogPayload{
+				apptest.CreateAuditLogBadRequest(s.T(), ctx, svc, ctrl, "username", nil, &app.CreateAuditLogPayload{
 					Data: &app.CreateAuditLogData{
 						Type: "audit_logs",
 						Attributes: &app.CreateAuditLogDataAttributes{
@@ -117,12 +192,61 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestCreateAuditLog() {
 					},
 				})
 			})
+
+			s.Run("internal event type", func() {
+				// when/then
+				apptest.CreateAuditLogBadRequest(s.T(), ctx, svc, ctrl, "username", nil, &app.CreateAuditLogPayload{
+					Data: &app.CreateAuditLogData{
+						Type: "audit_logs",
+						Attributes: &app.CreateAuditLogDataAttributes{
+							EventType:   auditlog.ListAuditLogsEvent,
+							EventParams: map[string]interface{}{},
+						},
+					},
+				})
+			})
+
+			s.Run("invalid event params", func() {
+				// when
+				_, result := apptest.CreateAuditLogBadRequest(s.T(), ctx, svc, ctrl, "username", nil, &app.CreateAuditLogPayload{
+					Data: &app.CreateAuditLogData{
+						Type: "audit_logs",
+						Attributes: &app.CreateAuditLogDataAttributes{
+							EventType: "user_deactivation_notification",
+							EventParams: map[string]interface{}{
+								"notification_deactivation": 12345,
+							},
+						},
+					},
+				})
+				// then
+				require.Len(s.T(), result.Errors, 1)
+				assert.Equal(s.T(), "/data/attributes/event_params/notification_deactivation", result.Errors[0].Source["pointer"])
+			})
+
+			s.Run("invalid user deactivation params", func() {
+				// when
+				_, result := apptest.CreateAuditLogBadRequest(s.T(), ctx, svc, ctrl, "username", nil, &app.CreateAuditLogPayload{
+					Data: &app.CreateAuditLogData{
+						Type: "audit_logs",
+						Attributes: &app.CreateAuditLogDataAttributes{
+							EventType: "user_deactivation",
+							EventParams: map[string]interface{}{
+								"scheduled_deactivation": true,
+							},
+						},
+					},
+				})
+				// then the schema of the 'user_deactivation' event type applies
+				require.Len(s.T(), result.Errors, 1)
+				assert.Equal(s.T(), "/data/attributes/event_params/scheduled_deactivation", result.Errors[0].Source["pointer"])
+			})
 		})
 
 		s.Run("unauthorized", func() {
 			s.Run("missing token", func() {
 				// when/then
-				apptest.CreateAuditLogUnauthorized(s.T(), context.Background(), svc, ctrl, "username", &app.CreateAuditLogPayload{
+				apptest.CreateAuditLogUnauthorized(s.T(), context.Background(), svc, ctrl, "username", nil, &app.CreateAuditLogPayload{
 					Data: &app.CreateAuditLogData{
 						Type: "audit_logs",
 						Attributes: &app.CreateAuditLogDataAttributes{
@@ -141,7 +265,7 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestCreateAuditLog() {
 				ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
 				require.NoError(s.T(), err)
 				// when/then
-				apptest.CreateAuditLogUnauthorized(s.T(), ctx, svc, ctrl, "username", &app.CreateAuditLogPayload{
+				apptest.CreateAuditLogUnauthorized(s.T(), ctx, svc, ctrl, "username", nil, &app.CreateAuditLogPayload{
 					Data: &app.CreateAuditLogData{
 						Type: "audit_logs",
 						Attributes: &app.CreateAuditLogDataAttributes{
@@ -158,11 +282,132 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestCreateAuditLog() {
 	})
 }
 
+func (s *AuditLogsControllerBlackboxTestSuite) TestCreateAuditLogBatch() {
+
+	// given
+	svc := goa.New("auditlogs")
+	ctrl := controller.NewAuditLogsController(svc, s.config, s.app, s.notifier)
+	ctx, err := testauth.EmbedServiceAccountTokenInContext(context.Background(), &testauth.Identity{
+		Username: auth.Auth,
+		ID:       uuid.NewV4(),
+	})
+	require.NoError(s.T(), err)
+	newData := func(username, eventType, key string, eventParams map[string]interface{}) *app.CreateAuditLogData {
+		data := &app.CreateAuditLogData{
+			Type: "audit_logs",
+			Attributes: &app.CreateAuditLogDataAttributes{
+				EventType:   eventType,
+				EventParams: eventParams,
+			},
+		}
+		if username != "" {
+			data.Attributes.Username = &username
+		}
+		if key != "" {
+			data.Attributes.IdempotencyKey = &key
+		}
+		return data
+	}
+
+	s.Run("success", func() {
+		// given
+		username1 := fmt.Sprintf("user-%v", uuid.NewV4())
+		username2 := fmt.Sprintf("user-%v", uuid.NewV4())
+		key := uuid.NewV4().String()
+		payload := &app.CreateAuditLogBatchPayload{
+			Data: []*app.CreateAuditLogData{
+				newData(username1, auditlog.UserDeactivationNotificationEvent, key, map[string]interface{}{
+					"scheduled_deactivation": time.Now().Add(time.Hour * 24 * 7).Format("2006-01-02:15:04:05"),
+				}),
+				newData(username2, auditlog.UserDeactivationEvent, "", nil),
+				newData(username2, "unknown", "", nil),
+				newData("", auditlog.UserDeactivationEvent, "", nil),
+				newData(username2, auditlog.UserDeactivationNotificationEvent, "", map[string]interface{}{
+					"scheduled_deactivation": 1,
+				}),
+			},
+		}
+		// when
+		_, result := apptest.CreateBatchAuditLogOK(s.T(), ctx, svc, ctrl, payload)
+		// then
+		require.Len(s.T(), result.Data, 5)
+		assert.Equal(s.T(), "created", result.Data[0].Status)
+		assert.Equal(s.T(), "created", result.Data[1].Status)
+		assert.Equal(s.T(), "rejected", result.Data[2].Status)
+		require.Len(s.T(), result.Data[2].Errors, 1)
+		assert.Equal(s.T(), "/data/2/attributes/event_type", result.Data[2].Errors[0].Source["pointer"])
+		assert.Equal(s.T(), "rejected", result.Data[3].Status)
+		require.Len(s.T(), result.Data[3].Errors, 1)
+		assert.Equal(s.T(), "/data/3/attributes/username", result.Data[3].Errors[0].Source["pointer"])
+		assert.Equal(s.T(), "rejected", result.Data[4].Status)
+		require.Len(s.T(), result.Data[4].Errors, 1)
+		assert.Equal(s.T(), "/data/4/attributes/event_params/scheduled_deactivation", result.Data[4].Errors[0].Source["pointer"])
+		// check that the data was collected
+		_, total, err := auditlog.NewRepository(s.DB).ListByUsername(context.Background(), username1, 0, 5)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), 1, total)
+		_, total, err = auditlog.NewRepository(s.DB).ListByUsername(context.Background(), username2, 0, 5)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), 1, total)
+
+		s.Run("retry", func() {
+			// when
+			_, retry := apptest.CreateBatchAuditLogOK(s.T(), ctx, svc, ctrl, &app.CreateAuditLogBatchPayload{
+				Data: payload.Data[:1],
+			})
+			// then
+			require.Len(s.T(), retry.Data, 1)
+			assert.Equal(s.T(), "duplicate", retry.Data[0].Status)
+			assert.Equal(s.T(), *result.Data[0].ID, *retry.Data[0].ID)
+			_, total, err := auditlog.NewRepository(s.DB).ListByUsername(context.Background(), username1, 0, 5)
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), 1, total)
+		})
+
+		s.Run("retry with a different payload", func() {
+			// when
+			_, retry := apptest.CreateBatchAuditLogOK(s.T(), ctx, svc, ctrl, &app.CreateAuditLogBatchPayload{
+				Data: []*app.CreateAuditLogData{
+					newData(username2, auditlog.UserDeactivationEvent, "", nil),
+					newData(username1, auditlog.UserDeactivationEvent, key, nil),
+				},
+			})
+			// then
+			require.Len(s.T(), retry.Data, 2)
+			assert.Equal(s.T(), "created", retry.Data[0].Status)
+			assert.Equal(s.T(), "rejected", retry.Data[1].Status)
+			assert.Nil(s.T(), retry.Data[1].ID)
+			require.Len(s.T(), retry.Data[1].Errors, 1)
+			assert.Equal(s.T(), "409", *retry.Data[1].Errors[0].Status)
+			assert.Equal(s.T(), "/data/1/attributes/idempotency_key", retry.Data[1].Errors[0].Source["pointer"])
+			// and only the other entry was recorded
+			_, total, err := auditlog.NewRepository(s.DB).ListByUsername(context.Background(), username2, 0, 5)
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), 2, total)
+			_, total, err = auditlog.NewRepository(s.DB).ListByUsername(context.Background(), username1, 0, 5)
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), 1, total)
+		})
+	})
+
+	s.Run("unauthorized", func() {
+		// given
+		ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(s.T(), err)
+		// when/then
+		apptest.CreateBatchAuditLogUnauthorized(s.T(), ctx, svc, ctrl, &app.CreateAuditLogBatchPayload{
+			Data: []*app.CreateAuditLogData{
+				newData("foo", auditlog.UserDeactivationEvent, "", nil),
+			},
+		})
+	})
+}
+
 func (s *AuditLogsControllerBlackboxTestSuite) TestListAuditLogs() {
 
 	// given
 	svc := goa.New("auditlogs")
-	ctrl := controller.NewAuditLogsController(svc, s.config, s.app)
+	ctrl := controller.NewAuditLogsController(svc, s.config, s.app, s.notifier)
 
 	s.Run("success", func() {
 
@@ -194,7 +439,7 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestListAuditLogs() {
 
 		s.Run("first page of results", func() {
 			// when
-			_, result := apptest.ListForUserAuditLogOK(s.T(), ctx, svc, ctrl, targetUser, 0, 1)
+			_, result := apptest.ListForUserAuditLogOK(s.T(), ctx, svc, ctrl, targetUser, false, nil, 0, 1)
 			// then
 			require.NotNil(s.T(), result)
 			json, _ := json.MarshalIndent(result, "", "  ")
@@ -214,14 +459,14 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestListAuditLogs() {
 			assert.Equal(s.T(), fmt.Sprintf("http:///api/auditlogs/users/%s?page[start]=1&page[size]=1", targetUser), *result.Links.Last)
 			// verify meta
 			require.NotNil(s.T(), result.Meta)
-			assert.Equal(s.T(), result.Meta.TotalCount, 2)
+			assert.Equal(s.T(), *result.Meta.TotalCount, 2)
 			// also, verify that an event was logged on behalf of the requesting user
 			s.assertRequesterLogs(requestingUser, targetUser)
 		})
 
 		s.Run("last page of results", func() {
 			// when
-			_, result := apptest.ListForUserAuditLogOK(s.T(), ctx, svc, ctrl, targetUser, 1, 1)
+			_, result := apptest.ListForUserAuditLogOK(s.T(), ctx, svc, ctrl, targetUser, false, nil, 1, 1)
 			// then
 			require.NotNil(s.T(), result)
 			json, _ := json.MarshalIndent(result, "", "  ")
@@ -230,7 +475,7 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestListAuditLogs() {
 			require.Len(s.T(), result.Data, 1)
 			require.NotNil(s.T(), result.Links)
 			require.NotNil(s.T(), result.Meta)
-			assert.Equal(s.T(), result.Meta.TotalCount, 2)
+			assert.Equal(s.T(), *result.Meta.TotalCount, 2)
 			// verify links
 			require.NotNil(s.T(), result.Links.First)
 			assert.Equal(s.T(), fmt.Sprintf("http:///api/auditlogs/users/%s?page[start]=0&page[size]=1", targetUser), *result.Links.First)
@@ -245,16 +490,20 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestListAuditLogs() {
 
 		s.Run("all results", func() {
 			// when
-			_, result := apptest.ListForUserAuditLogOK(s.T(), ctx, svc, ctrl, targetUser, 0, 10)
+			_, result := apptest.ListForUserAuditLogOK(s.T(), ctx, svc, ctrl, targetUser, false, nil, 0, 10)
 			// then
 			require.NotNil(s.T(), result)
 			json, _ := json.MarshalIndent(result, "", "  ")
 			s.T().Logf("result:\n%v\n", string(json))
 			require.NotNil(s.T(), result.Data)
 			require.Len(s.T(), result.Data, 2)
+			for _, d := range result.Data {
+				require.NotNil(s.T(), d.Links)
+				assert.Equal(s.T(), fmt.Sprintf("http:///api/auditlogs/%s", d.ID), *d.Links.Self)
+			}
 			require.NotNil(s.T(), result.Links)
 			require.NotNil(s.T(), result.Meta)
-			assert.Equal(s.T(), result.Meta.TotalCount, 2)
+			assert.Equal(s.T(), *result.Meta.TotalCount, 2)
 			// verify links
 			require.NotNil(s.T(), result.Links.First)
 			assert.Equal(s.T(), fmt.Sprintf("http:///api/auditlogs/users/%s?page[start]=0&page[size]=10", targetUser), *result.Links.First)
@@ -268,7 +517,7 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestListAuditLogs() {
 
 		s.Run("out of range", func() {
 			// when
-			_, result := apptest.ListForUserAuditLogOK(s.T(), ctx, svc, ctrl, targetUser, 100, 100)
+			_, result := apptest.ListForUserAuditLogOK(s.T(), ctx, svc, ctrl, targetUser, false, nil, 100, 100)
 			// then
 			require.NotNil(s.T(), result)
 			json, _ := json.MarshalIndent(result, "", "  ")
@@ -276,7 +525,7 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestListAuditLogs() {
 			require.NotNil(s.T(), result.Data)
 			require.Empty(s.T(), result.Data)
 			require.NotNil(s.T(), result.Meta)
-			assert.Equal(s.T(), result.Meta.TotalCount, 2)
+			assert.Equal(s.T(), *result.Meta.TotalCount, 2)
 			// verify links
 			require.NotNil(s.T(), result.Links.First)
 			assert.Equal(s.T(), fmt.Sprintf("http:///api/auditlogs/users/%s?page[start]=0&page[size]=10", targetUser), *result.Links.First)
@@ -288,14 +537,44 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestListAuditLogs() {
 			s.assertRequesterLogs(requestingUser, targetUser)
 		})
 
+		s.Run("first page of results with cursor and count", func() {
+			// given
+			cursor := ""
+			// when
+			_, result := apptest.ListForUserAuditLogOK(s.T(), ctx, svc, ctrl, targetUser, true, &cursor, 0, 1)
+			// then
+			require.NotNil(s.T(), result)
+			require.Len(s.T(), result.Data, 1)
+			assert.Equal(s.T(), auditlog.UserDeactivationNotificationEvent, result.Data[0].Attributes.EventType)
+			require.NotNil(s.T(), result.Meta)
+			require.NotNil(s.T(), result.Meta.TotalCount)
+			assert.Equal(s.T(), 2, *result.Meta.TotalCount)
+			// verify links
+			require.NotNil(s.T(), result.Links.First)
+			assert.Equal(s.T(), fmt.Sprintf("http:///api/auditlogs/users/%s?page[cursor]=&page[size]=1&count=true", targetUser), *result.Links.First)
+			require.NotNil(s.T(), result.Links.Next)
+			assert.Nil(s.T(), result.Links.Prev)
+			assert.Nil(s.T(), result.Links.Last)
+			// when fetching the next page
+			next, err := url.Parse(*result.Links.Next)
+			require.NoError(s.T(), err)
+			cursor = next.Query().Get("page[cursor]")
+			_, result = apptest.ListForUserAuditLogOK(s.T(), ctx, svc, ctrl, targetUser, false, &cursor, 0, 1)
+			// then
+			require.Len(s.T(), result.Data, 1)
+			assert.Equal(s.T(), auditlog.UserDeactivationEvent, result.Data[0].Attributes.EventType)
+			assert.Nil(s.T(), result.Meta) // count was not requested
+			assert.Nil(s.T(), result.Links.Next)
+		})
+
 		s.Run("user has no audit log", func() {
 			// when
-			_, result := apptest.ListForUserAuditLogOK(s.T(), ctx, svc, ctrl, "user-bar", 1, 100)
+			_, result := apptest.ListForUserAuditLogOK(s.T(), ctx, svc, ctrl, "user-bar", false, nil, 1, 100)
 			// then
 			require.NotNil(s.T(), result.Data)
 			require.Empty(s.T(), result.Data)
 			require.NotNil(s.T(), result.Meta)
-			assert.Equal(s.T(), result.Meta.TotalCount, 0)
+			assert.Equal(s.T(), *result.Meta.TotalCount, 0)
 			// also, verify that an event was logged on behalf of the requesting user
 			s.assertRequesterLogs(requestingUser, "user-bar")
 		})
@@ -310,23 +589,368 @@ func (s *AuditLogsControllerBlackboxTestSuite) TestListAuditLogs() {
 			// given
 			ctx := context.Background()
 			// when/then
-			apptest.ListForUserAuditLogUnauthorized(s.T(), ctx, svc, ctrl, targetUser, 1, 0)
+			apptest.ListForUserAuditLogUnauthorized(s.T(), ctx, svc, ctrl, targetUser, false, nil, 1, 0)
+		})
+
+		s.Run("bad request - invalid cursor", func() {
+			// given
+			ctx, _, err := testauth.EmbedTokenInContext("identity", requestingUser, testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(true))
+			require.NoError(s.T(), err)
+			cursor := "foo"
+			// when/then
+			apptest.ListForUserAuditLogBadRequest(s.T(), ctx, svc, ctrl, targetUser, false, &cursor, 0, 10)
+		})
+
+		s.Run("forbidden - missing permission", func() {
+			// given a user who was not assigned any role
+			requestingUser := fmt.Sprintf("requesting_user-%v", uuid.NewV4())
+			ctx, _, err := testauth.EmbedTokenInContext("identity", requestingUser, testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(true))
+			require.NoError(s.T(), err)
+			server := s.newAuthorizedService(ctx, s.config)
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/auditlogs/users/"+targetUser, nil)
+			req.Header.Set("X-Forwarded-For", "10.1.2.3")
+			// when
+			server.Mux.ServeHTTP(rw, req)
+			// then
+			assert.Equal(s.T(), http.StatusForbidden, rw.Code)
+			// also, verify that the denied access was logged with the address of the connection, since the
+			// `X-Forwarded-For` header was not set by a trusted proxy
+			logs, _, err := auditlog.NewRepository(s.DB).ListByUsername(context.Background(), requestingUser, 0, 100)
+			require.NoError(s.T(), err)
+			require.Len(s.T(), logs, 1)
+			assert.Equal(s.T(), auditlog.AccessDenied, logs[0].EventTypeID)
+			assert.Equal(s.T(), req.RemoteAddr, logs[0].EventParams["remote_addr"])
+		})
+
+		s.Run("forbidden - missing permission behind a trusted proxy", func() {
+			// given a user who was not assigned any role, and whose request comes through a trusted proxy
+			requestingUser := fmt.Sprintf("requesting_user-%v", uuid.NewV4())
+			ctx, _, err := testauth.EmbedTokenInContext("identity", requestingUser, testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(true))
+			require.NoError(s.T(), err)
+			_, proxy, err := net.ParseCIDR("192.0.2.0/24")
+			require.NoError(s.T(), err)
+			server := s.newAuthorizedService(ctx, trustedProxies{proxy})
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/auditlogs/users/"+targetUser, nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-Forwarded-For", "10.1.2.3, 10.4.5.6, 192.0.2.2")
+			// when
+			server.Mux.ServeHTTP(rw, req)
+			// then
+			assert.Equal(s.T(), http.StatusForbidden, rw.Code)
+			// also, verify that the denied access was logged with the address of the client which connected to the
+			// trusted proxies, since the addresses before it may have been set by the client itself
+			logs, _, err := auditlog.NewRepository(s.DB).ListByUsername(context.Background(), requestingUser, 0, 100)
+			require.NoError(s.T(), err)
+			require.Len(s.T(), logs, 1)
+			assert.Equal(s.T(), "10.4.5.6", logs[0].EventParams["remote_addr"])
+		})
+
+	})
+
+	s.Run("allowed - with permission", func() {
+		// given a user who was assigned a role with the permission to read the audit logs
+		requestingUser := fmt.Sprintf("requesting_user-%v", uuid.NewV4())
+		err := s.app.Roles().Assign(context.Background(), requestingUser, "auditor", "admin")
+		require.NoError(s.T(), err)
+		ctx, _, err := testauth.EmbedTokenInContext("identity", requestingUser, testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(true))
+		require.NoError(s.T(), err)
+		server := s.newAuthorizedService(ctx, s.config)
+		rw := httptest.NewRecorder()
+		// when
+		server.Mux.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/api/auditlogs/users/user-bar", nil))
+		// then
+		assert.Equal(s.T(), http.StatusOK, rw.Code)
+		s.assertRequesterLogs(requestingUser, "user-bar")
+	})
+}
+
+// trustedProxies a configuration of the recorder of the denied accesses with the given trusted proxies
+type trustedProxies []*net.IPNet
+
+func (p trustedProxies) GetHTTPTrustedProxies() []*net.IPNet {
+	return p
+}
+
+// newAuthorizedService returns a service on which the audit logs controller is mounted behind the authorization
+// middleware (as in main.go), and whose requests carry the token of the given context
+func (s *AuditLogsControllerBlackboxTestSuite) newAuthorizedService(ctx context.Context, config controller.AccessDeniedRecorderConfiguration) *goa.Service {
+	tk := goajwt.ContextJWT(ctx)
+	require.NotNil(s.T(), tk)
+	claimPermissions, err := authorization.NewClaimPermissions(s.config.GetAuthorizationClaims())
+	require.NoError(s.T(), err)
+	server := goa.New("auditlogs")
+	server.Use(app.ErrorHandler(server, true))
+	server.Use(func(h goa.Handler) goa.Handler {
+		return func(c context.Context, rw http.ResponseWriter, req *http.Request) error {
+			return h(goajwt.WithJWT(c, tk), rw, req)
+		}
+	})
+	server.Use(authorization.Middleware(s.app.Roles(), claimPermissions, controller.RecordAccessDenied(config, s.app)))
+	app.MountAuditLogsController(server, controller.NewAuditLogsController(server, s.config, s.app, s.notifier))
+	return server
+}
+
+func (s *AuditLogsControllerBlackboxTestSuite) TestShowAuditLog() {
+	// given
+	svc := goa.New("auditlogs")
+	ctrl := controller.NewAuditLogsController(svc, s.config, s.app, s.notifier)
+	record := auditlog.AuditLog{
+		EventTypeID: auditlog.UserDeactivation,
+		IdentityID:  uuid.NewV4(),
+		Username:    fmt.Sprintf("user-%v", uuid.NewV4()),
+		EventParams: auditlog.EventParams{
+			"scheduled_deactivation": "2018-10-08",
+		},
+	}
+	err := auditlog.NewRepository(s.DB).Create(context.Background(), &record)
+	require.NoError(s.T(), err)
+	requestingUser := fmt.Sprintf("user-%v", uuid.NewV4())
+
+	s.Run("ok", func() {
+		// given
+		ctx, _, err := testauth.EmbedTokenInContext("identity", requestingUser, testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(true))
+		require.NoError(s.T(), err)
+		// when
+		_, result := apptest.ShowAuditLogOK(s.T(), ctx, svc, ctrl, goauuid.UUID(record.ID))
+		// then
+		require.NotNil(s.T(), result.Data)
+		assert.Equal(s.T(), record.ID.String(), result.Data.ID)
+		require.NotNil(s.T(), result.Data.Attributes.IdentityID)
+		assert.Equal(s.T(), record.IdentityID.String(), *result.Data.Attributes.IdentityID)
+		assert.Equal(s.T(), record.Username, *result.Data.Attributes.Username)
+		assert.Equal(s.T(), auditlog.UserDeactivationEvent, result.Data.Attributes.EventType)
+		assert.Equal(s.T(), "2018-10-08", result.Data.Attributes.EventParams["scheduled_deactivation"])
+		require.NotNil(s.T(), result.Data.Links)
+		assert.Equal(s.T(), fmt.Sprintf("http:///api/auditlogs/%s", record.ID), *result.Data.Links.Self)
+		// also, verify that an event was logged on behalf of the requesting user
+		logs, total, err := auditlog.NewRepository(s.DB).ListByUsername(context.Background(), requestingUser, 0, 100)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), 1, total)
+		require.Len(s.T(), logs, 1)
+		assert.Equal(s.T(), auditlog.ShowAuditLog, logs[0].EventTypeID)
+		assert.Equal(s.T(), record.ID.String(), logs[0].EventParams["audit_log_id"])
+		// and the outcome of the action was recorded
+		require.NotNil(s.T(), logs[0].StatusCode)
+		assert.Equal(s.T(), http.StatusOK, *logs[0].StatusCode)
+		assert.Equal(s.T(), auditlog.OutcomeSuccess, logs[0].Outcome)
+		require.NotNil(s.T(), logs[0].LatencyMs)
+	})
+
+	s.Run("not found", func() {
+		// given
+		ctx, _, err := testauth.EmbedTokenInContext("identity", requestingUser, testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(true))
+		require.NoError(s.T(), err)
+		// when/then
+		apptest.ShowAuditLogNotFound(s.T(), ctx, svc, ctrl, goauuid.NewV4())
+	})
+
+}
+
+func (s *AuditLogsControllerBlackboxTestSuite) TestListAllAuditLogs() {
+
+	// given
+	svc := goa.New("auditlogs")
+	ctrl := controller.NewAuditLogsController(svc, s.config, s.app, s.notifier)
+
+	s.Run("success", func() {
+
+		var ctx context.Context
+		var requestingUser, targetUserPrefix string
+
+		s.SetupSubtest = func() {
+			targetUserPrefix = fmt.Sprintf("user-foo-%v", uuid.NewV4())
+			r := auditlog.NewRepository(s.DB)
+			for i := 0; i < 2; i++ {
+				err := r.Create(context.Background(), &auditlog.AuditLog{
+					Username:    fmt.Sprintf("%s-%d", targetUserPrefix, i),
+					EventTypeID: auditlog.UserDeactivationNotification,
+					EventParams: auditlog.EventParams{
+						"idx": fmt.Sprintf("%d", i),
+					},
+				})
+				require.NoError(s.T(), err)
+				err = r.Create(context.Background(), &auditlog.AuditLog{
+					Username:    fmt.Sprintf("%s-%d", targetUserPrefix, i),
+					EventTypeID: auditlog.UserDeactivation,
+					EventParams: auditlog.EventParams{
+						"idx":      fmt.Sprintf("%d", i),
+						"count":    i,
+						"archived": i == 1,
+					},
+				})
+				require.NoError(s.T(), err)
+			}
+			// requesting user context with token
+			requestingUser = fmt.Sprintf("requesting_user-%v", uuid.NewV4())
+			var err error
+			ctx, _, err = testauth.EmbedTokenInContext("identity", requestingUser, testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(true))
+			require.NoError(s.T(), err)
+		}
+		s.TearDownSubtest = func() {
+			s.CleanTest()
+		}
+
+		s.Run("by username prefix", func() {
+			// when
+			_, result := apptest.ListAuditLogOK(s.T(), ctx, svc, ctrl, nil, nil, nil, nil, nil, 0, 10, "created_at", &targetUserPrefix)
+			// then
+			require.NotNil(s.T(), result)
+			require.Len(s.T(), result.Data, 4)
+			require.NotNil(s.T(), result.Meta)
+			assert.Equal(s.T(), 4, *result.Meta.TotalCount)
+			require.NotNil(s.T(), result.Data[0].Attributes.Username)
+			assert.Equal(s.T(), targetUserPrefix+"-0", *result.Data[0].Attributes.Username)
+			// verify that the filter is retained in the paging links
+			require.NotNil(s.T(), result.Links.First)
+			assert.Equal(s.T(), fmt.Sprintf("http:///api/auditlogs?page[start]=0&page[size]=10&sort=created_at&username=%s", targetUserPrefix), *result.Links.First)
+			// also, verify that an event was logged on behalf of the requesting user
+			logs, total, err := auditlog.NewRepository(s.DB).ListByUsername(context.Background(), requestingUser, 0, 100)
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), 1, total)
+			require.Len(s.T(), logs, 1)
+			assert.Equal(s.T(), auditlog.ListAuditLogs, logs[0].EventTypeID)
+			assert.Contains(s.T(), logs[0].EventParams["query"], targetUserPrefix)
+			// and the outcome of the action was recorded, along with the number of results
+			assert.Equal(s.T(), auditlog.OutcomeSuccess, logs[0].Outcome)
+			require.NotNil(s.T(), logs[0].StatusCode)
+			assert.Equal(s.T(), http.StatusOK, *logs[0].StatusCode)
+			require.NotNil(s.T(), logs[0].ResultCount)
+			assert.Equal(s.T(), 4, *logs[0].ResultCount)
+		})
+
+		s.Run("by username prefix, event type and params in descending order", func() {
+			// when
+			_, result := apptest.ListAuditLogOK(s.T(), ctx, svc, ctrl, nil, nil, []string{"idx:1"}, []string{auditlog.UserDeactivationEvent}, nil, 0, 10, "-created_at", &targetUserPrefix)
+			// then
+			require.NotNil(s.T(), result)
+			require.Len(s.T(), result.Data, 1)
+			assert.Equal(s.T(), 1, *result.Meta.TotalCount)
+			assert.Equal(s.T(), auditlog.UserDeactivationEvent, result.Data[0].Attributes.EventType)
+			assert.Equal(s.T(), "1", result.Data[0].Attributes.EventParams["idx"])
 		})
 
-		s.Run("forbidden - external user", func() {
+		s.Run("by numeric and boolean params", func() {
+			// when
+			_, result := apptest.ListAuditLogOK(s.T(), ctx, svc, ctrl, nil, nil, []string{"count:1", "archived:true"}, nil, nil, 0, 10, "created_at", &targetUserPrefix)
+			// then
+			require.NotNil(s.T(), result)
+			require.Len(s.T(), result.Data, 1)
+			assert.Equal(s.T(), auditlog.UserDeactivationEvent, result.Data[0].Attributes.EventType)
+			assert.Equal(s.T(), float64(1), result.Data[0].Attributes.EventParams["count"])
+			assert.Equal(s.T(), true, result.Data[0].Attributes.EventParams["archived"])
+		})
+
+		s.Run("by time range", func() {
 			// given
-			ctx, _, err := testauth.EmbedTokenInContext("identity", requestingUser, testauth.WithEmailClaim("user@foo.com"), testauth.WithEmailVerifiedClaim(true))
+			now := time.Now()
+			// when
+			_, result := apptest.ListAuditLogOK(s.T(), ctx, svc, ctrl, &now, nil, nil, nil, nil, 0, 10, "created_at", &targetUserPrefix)
+			// then
+			require.NotNil(s.T(), result)
+			assert.Empty(s.T(), result.Data)
+			assert.Equal(s.T(), 0, *result.Meta.TotalCount)
+		})
+	})
+
+	s.Run("failure", func() {
+
+		s.Run("bad request - invalid event params", func() {
+			// given
+			ctx, _, err := testauth.EmbedTokenInContext("identity", "user-foo", testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(true))
 			require.NoError(s.T(), err)
 			// when/then
-			apptest.ListForUserAuditLogForbidden(s.T(), ctx, svc, ctrl, targetUser, 1, 0)
+			apptest.ListAuditLogBadRequest(s.T(), ctx, svc, ctrl, nil, nil, []string{"idx"}, nil, nil, 0, 10, "created_at", nil)
+		})
+
+		s.Run("unauthorized - missing token", func() {
+			// when/then
+			apptest.ListAuditLogUnauthorized(s.T(), context.Background(), svc, ctrl, nil, nil, nil, nil, nil, 0, 10, "created_at", nil)
+		})
+
+	})
+}
+
+func (s *AuditLogsControllerBlackboxTestSuite) TestExportAuditLogs() {
+
+	// given
+	svc := goa.New("auditlogs")
+	ctrl := controller.NewAuditLogsController(svc, s.config, s.app, s.notifier)
+	targetUser := fmt.Sprintf("user-foo-%v", uuid.NewV4())
+	r := auditlog.NewRepository(s.DB)
+	err := r.Create(context.Background(), &auditlog.AuditLog{
+		Username:    targetUser,
+		EventTypeID: auditlog.UserDeactivationNotification,
+		EventParams: auditlog.EventParams{
+			"scheduled_deactivation": "2018-10-08",
+		},
+	})
+	require.NoError(s.T(), err)
+	err = r.Create(context.Background(), &auditlog.AuditLog{
+		Username:    targetUser,
+		EventTypeID: auditlog.UserDeactivation,
+	})
+	require.NoError(s.T(), err)
+	requestingUser := fmt.Sprintf("requesting_user-%v", uuid.NewV4())
+	ctx, _, err := testauth.EmbedTokenInContext("identity", requestingUser, testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(true))
+	require.NoError(s.T(), err)
+
+	s.Run("success", func() {
+
+		s.Run("csv by default", func() {
+			// when
+			rw := apptest.ExportAuditLogOK(s.T(), ctx, svc, ctrl, targetUser, nil)
+			// then
+			assert.Equal(s.T(), "text/csv", rw.Header().Get("Content-Type"))
+			assert.Equal(s.T(), fmt.Sprintf(`attachment; filename="auditlogs-%s.csv"`, targetUser), rw.Header().Get("Content-Disposition"))
+			lines := strings.Split(strings.TrimSpace(rw.(*httptest.ResponseRecorder).Body.String()), "\n")
+			require.Len(s.T(), lines, 3)
+			assert.Equal(s.T(), "id,created_at,identity_id,username,event_type,event_params.scheduled_deactivation", lines[0])
+			assert.Contains(s.T(), lines[1], ",user_deactivation_notification,2018-10-08")
+			assert.Contains(s.T(), lines[2], ",user_deactivation,")
 		})
 
-		s.Run("forbidden - internal user with email not verified", func() {
+		s.Run("ndjson", func() {
 			// given
-			ctx, _, err := testauth.EmbedTokenInContext("identity", requestingUser, testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(false))
+			accept := "application/x-ndjson"
+			// when
+			rw := apptest.ExportAuditLogOK(s.T(), ctx, svc, ctrl, targetUser, &accept)
+			// then
+			assert.Equal(s.T(), "application/x-ndjson", rw.Header().Get("Content-Type"))
+			lines := strings.Split(strings.TrimSpace(rw.(*httptest.ResponseRecorder).Body.String()), "\n")
+			require.Len(s.T(), lines, 2)
+			record := map[string]interface{}{}
+			err := json.Unmarshal([]byte(lines[0]), &record)
 			require.NoError(s.T(), err)
+			assert.Equal(s.T(), auditlog.UserDeactivationNotificationEvent, record["event_type"])
+			assert.Equal(s.T(), targetUser, record["username"])
+		})
+
+		s.Run("export is recorded", func() {
+			// when
+			logs, _, err := r.ListByUsername(context.Background(), requestingUser, 0, 100)
+			// then
+			require.NoError(s.T(), err)
+			require.NotEmpty(s.T(), logs)
+			assert.Equal(s.T(), auditlog.ExportAuditLogs, logs[0].EventTypeID)
+			assert.Equal(s.T(), targetUser, logs[0].EventParams["user"])
+			assert.Equal(s.T(), "text/csv", logs[0].EventParams["format"])
+		})
+	})
+
+	s.Run("failure", func() {
+
+		s.Run("bad request - unsupported media type", func() {
+			// given
+			accept := "application/xml"
+			// when/then
+			apptest.ExportAuditLogBadRequest(s.T(), ctx, svc, ctrl, targetUser, &accept)
+		})
+
+		s.Run("unauthorized - missing token", func() {
 			// when/then
-			apptest.ListForUserAuditLogForbidden(s.T(), ctx, svc, ctrl, targetUser, 1, 0)
+			apptest.ExportAuditLogUnauthorized(s.T(), context.Background(), svc, ctrl, targetUser, nil)
 		})
 
 	})
@@ -341,3 +965,212 @@ func (s *AuditLogsControllerBlackboxTestSuite) assertRequesterLogs(requestingUse
 	assert.Equal(s.T(), auditlog.ListAuditLogs, logs[0].EventTypeID)
 	assert.Equal(s.T(), eventUser, logs[0].EventParams["user"])
 }
+
+func (s *AuditLogsControllerBlackboxTestSuite) TestStreamAuditLogs() {
+	// given
+	svc := goa.New("auditlogs")
+	ctrl := controller.NewAuditLogsController(svc, s.config, s.app, s.notifier)
+	targetUser := fmt.Sprintf("user-stream-%v", uuid.NewV4())
+	r := auditlog.NewRepository(s.DB)
+	newRecord := func(t *testing.T, eventTypeID uuid.UUID) *auditlog.AuditLog {
+		record := &auditlog.AuditLog{
+			Username:    targetUser,
+			EventTypeID: eventTypeID,
+			EventParams: auditlog.EventParams{},
+		}
+		err := r.Create(context.Background(), record)
+		require.NoError(t, err)
+		return record
+	}
+	// streamedIDs returns the IDs of the events in the streamed response, and the IDs of the records in their data
+	streamedIDs := func(t *testing.T, rw http.ResponseWriter) ([]string, []string) {
+		eventIDs := []string{}
+		recordIDs := []string{}
+		for _, line := range strings.Split(rw.(*httptest.ResponseRecorder).Body.String(), "\n") {
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				eventIDs = append(eventIDs, strings.TrimPrefix(line, "id: "))
+			case strings.HasPrefix(line, "data: "):
+				data := app.AuditLogData{}
+				err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data)
+				require.NoError(t, err)
+				recordIDs = append(recordIDs, data.ID)
+			}
+		}
+		return eventIDs, recordIDs
+	}
+	requestingUser := fmt.Sprintf("requesting_user-%v", uuid.NewV4())
+	ctx, _, err := testauth.EmbedTokenInContext("identity", requestingUser, testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(true))
+	require.NoError(s.T(), err)
+
+	s.T().Run("resumed after last event ID", func(t *testing.T) {
+		// given
+		first := newRecord(t, auditlog.UserSearch)
+		newRecord(t, auditlog.StartTenantUpdate)
+		third := newRecord(t, auditlog.UserSearch)
+		lastEventID := strconv.FormatInt(first.ChainSeq, 10)
+		// the stream ends when the context is done
+		streamCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		// when
+		rw := apptest.StreamAuditLogOK(t, streamCtx, svc, ctrl, []string{auditlog.UserSearchEvent}, &targetUser, &lastEventID)
+		// then
+		assert.Equal(t, "text/event-stream", rw.Header().Get("Content-Type"))
+		eventIDs, recordIDs := streamedIDs(t, rw)
+		assert.Equal(t, []string{strconv.FormatInt(third.ChainSeq, 10)}, eventIDs)
+		assert.Equal(t, []string{third.ID.String()}, recordIDs)
+	})
+
+	s.T().Run("new records", func(t *testing.T) {
+		// given
+		streamCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		record := &auditlog.AuditLog{
+			Username:    targetUser,
+			EventTypeID: auditlog.UserDeactivation,
+			EventParams: auditlog.EventParams{},
+		}
+		created := make(chan error, 1)
+		go func() {
+			// leave some time for the stream to start
+			time.Sleep(500 * time.Millisecond)
+			created <- r.Create(context.Background(), record)
+		}()
+		// when
+		rw := apptest.StreamAuditLogOK(t, streamCtx, svc, ctrl, nil, &targetUser, nil)
+		// then
+		require.NoError(t, <-created)
+		_, recordIDs := streamedIDs(t, rw)
+		assert.Equal(t, []string{record.ID.String()}, recordIDs)
+	})
+
+	s.T().Run("events flushed before the end of the stream", func(t *testing.T) {
+		// given a real server, whose response writer is flushed to the client
+		record := newRecord(t, auditlog.UserSearch)
+		tk := goajwt.ContextJWT(ctx)
+		require.NotNil(t, tk)
+		server := goa.New("auditlogs")
+		server.Use(func(h goa.Handler) goa.Handler {
+			return func(c context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return h(goajwt.WithJWT(c, tk), rw, req)
+			}
+		})
+		app.MountAuditLogsController(server, controller.NewAuditLogsController(server, s.config, s.app, s.notifier))
+		ts := httptest.NewServer(server.Mux)
+		defer ts.Close()
+		reqCtx, cancel := context.WithCancel(context.Background())
+		defer cancel() // closes the stream
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/auditlogs/stream?username="+url.QueryEscape(targetUser), nil)
+		require.NoError(t, err)
+		req.Header.Set("Last-Event-ID", strconv.FormatInt(record.ChainSeq-1, 10))
+		// when
+		resp, err := http.DefaultClient.Do(req.WithContext(reqCtx))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		// then the first event is received while the stream is still open
+		received := make(chan string, 1)
+		go func() {
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if strings.HasPrefix(scanner.Text(), "id: ") {
+					received <- strings.TrimPrefix(scanner.Text(), "id: ")
+					return
+				}
+			}
+		}()
+		select {
+		case id := <-received:
+			assert.Equal(t, strconv.FormatInt(record.ChainSeq, 10), id)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event was received before the end of the stream")
+		}
+	})
+
+	s.T().Run("invalid last event ID", func(t *testing.T) {
+		// given
+		lastEventID := "foo"
+		// when/then
+		apptest.StreamAuditLogBadRequest(t, ctx, svc, ctrl, nil, nil, &lastEventID)
+	})
+}
+
+func (s *AuditLogsControllerBlackboxTestSuite) TestAggregateAuditLogs() {
+	// given
+	svc := goa.New("auditlogs")
+	ctrl := controller.NewAuditLogsController(svc, s.config, s.app, s.notifier)
+	before := time.Now().Add(-time.Second)
+	prefix := fmt.Sprintf("user-agg-%v-", uuid.NewV4())
+	r := auditlog.NewRepository(s.DB)
+	for _, username := range []string{prefix + "foo", prefix + "bar", prefix + "foo"} {
+		err := r.Create(context.Background(), &auditlog.AuditLog{
+			Username:    username,
+			EventTypeID: auditlog.UserSearch,
+			EventParams: auditlog.EventParams{},
+		})
+		require.NoError(s.T(), err)
+	}
+	requestingUser := fmt.Sprintf("requesting_user-%v", uuid.NewV4())
+	ctx, _, err := testauth.EmbedTokenInContext("identity", requestingUser, testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(true))
+	require.NoError(s.T(), err)
+
+	s.T().Run("ok", func(t *testing.T) {
+		// when
+		_, result := apptest.AggregateAuditLogOK(t, ctx, svc, ctrl, before, nil, nil, []string{"event_type", "username"}, nil, 100, &prefix)
+		// then
+		require.Len(t, result.Data, 2)
+		assert.Equal(t, "audit_log_stats", result.Data[0].Type)
+		require.NotNil(t, result.Data[0].Attributes.EventType)
+		assert.Equal(t, auditlog.UserSearchEvent, *result.Data[0].Attributes.EventType)
+		require.NotNil(t, result.Data[0].Attributes.Username)
+		assert.Equal(t, prefix+"foo", *result.Data[0].Attributes.Username)
+		assert.Equal(t, 2, result.Data[0].Attributes.Count)
+		assert.Nil(t, result.Data[0].Attributes.Bucket)
+		assert.Equal(t, 1, result.Data[1].Attributes.Count)
+		// check that an audit record was created for the requesting user
+		logs, _, err := auditlog.NewRepository(s.DB).ListByUsername(context.Background(), requestingUser, 0, 5)
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, auditlog.AggregateAuditLogs, logs[0].EventTypeID)
+		assert.Contains(t, logs[0].EventParams["query"], "group_by=username")
+	})
+
+	s.T().Run("by hour", func(t *testing.T) {
+		// given
+		interval := "hour"
+		// when
+		_, result := apptest.AggregateAuditLogOK(t, ctx, svc, ctrl, before, nil, nil, nil, &interval, 100, &prefix)
+		// then
+		require.NotEmpty(t, result.Data)
+		total := 0
+		for _, stat := range result.Data {
+			require.NotNil(t, stat.Attributes.Bucket)
+			assert.Equal(t, 0, stat.Attributes.Bucket.Minute())
+			total += stat.Attributes.Count
+		}
+		assert.Equal(t, 3, total)
+	})
+
+	s.T().Run("invalid range", func(t *testing.T) {
+		// given
+		createdBefore := before.Add(-time.Hour)
+		// when/then
+		apptest.AggregateAuditLogBadRequest(t, ctx, svc, ctrl, before, &createdBefore, nil, nil, nil, 100, &prefix)
+	})
+
+	s.T().Run("range too large", func(t *testing.T) {
+
+		t.Run("until now", func(t *testing.T) {
+			// given
+			createdAfter := time.Now().Add(-s.config.GetAuditLogAggregateMaxRange() - time.Hour)
+			// when/then
+			apptest.AggregateAuditLogBadRequest(t, ctx, svc, ctrl, createdAfter, nil, nil, nil, nil, 100, &prefix)
+		})
+
+		t.Run("until the given date", func(t *testing.T) {
+			// given
+			createdBefore := before.Add(s.config.GetAuditLogAggregateMaxRange() + time.Hour)
+			// when/then
+			apptest.AggregateAuditLogBadRequest(t, ctx, svc, ctrl, before, &createdBefore, nil, nil, nil, 100, &prefix)
+		})
+	})
+}
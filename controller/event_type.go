@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fabric8-services/admin-console/app"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/authorization"
+	"github.com/fabric8-services/fabric8-common/auth"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/httpsupport"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+// EventTypesController implements the event_type resource.
+type EventTypesController struct {
+	*goa.Controller
+	db     application.DB
+	config httpsupport.Configuration
+}
+
+// NewEventTypesController creates an event_type controller.
+func NewEventTypesController(service *goa.Service, config httpsupport.Configuration, db application.DB) *EventTypesController {
+	return &EventTypesController{
+		Controller: service.NewController("EventTypesController"),
+		config:     config,
+		db:         db,
+	}
+}
+
+// List lists all the event types
+func (c *EventTypesController) List(ctx *app.ListEventTypeContext) error {
+	if err := authorizeEventTypesReader(ctx); err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	eventTypes, err := c.db.EventTypes().List(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the event types")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.EventTypeData, len(eventTypes))
+	for i, t := range eventTypes {
+		data[i] = convertEventTypeData(ctx.RequestData, t, c.config)
+	}
+	return ctx.OK(&app.EventTypeList{
+		Data: data,
+	})
+}
+
+// Show shows an event type
+func (c *EventTypesController) Show(ctx *app.ShowEventTypeContext) error {
+	if err := authorizeEventTypesReader(ctx); err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	eventType, err := c.db.EventTypes().LoadByID(ctx, uuid.UUID(ctx.ID))
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.EventTypeSingle{
+		Data: convertEventTypeData(ctx.RequestData, eventType, c.config),
+	})
+}
+
+// Create registers a new event type
+func (c *EventTypesController) Create(ctx *app.CreateEventTypeContext) error {
+	// only the services which are allowed to record audit logs can register event types
+	if !auth.IsSpecificServiceAccount(ctx, auth.Auth) {
+		return app.JSONErrorResponse(ctx, errors.NewUnauthorizedError("invalid or missing authorization token"))
+	}
+	attrs := ctx.Payload.Data.Attributes
+	eventType := auditlog.EventType{
+		Name:         attrs.Name,
+		ParamsSchema: attrs.ParamsSchema,
+	}
+	if attrs.Description != nil {
+		eventType.Description = *attrs.Description
+	}
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		return appl.EventTypes().Create(ctx, &eventType)
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":        err,
+			"event_type": attrs.Name,
+		}, "unable to register the event type")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"event_type_id": eventType.ID,
+		"event_type":    eventType.Name,
+	}, "registered new event type")
+	data := convertEventTypeData(ctx.RequestData, eventType, c.config)
+	ctx.ResponseData.Header().Set("Location", *data.Links.Self)
+	return ctx.Created(&app.EventTypeSingle{
+		Data: data,
+	})
+}
+
+// authorizeEventTypesReader checks that the token in the given context belongs to a user who is allowed to read
+// the audit logs or to a service which is allowed to record audit logs
+func authorizeEventTypesReader(ctx context.Context) error {
+	if auth.IsSpecificServiceAccount(ctx, auth.Auth) {
+		return nil
+	}
+	if _, err := currentUsername(ctx); err != nil {
+		return err
+	}
+	if !authorization.HasPermission(ctx, authorization.ReadAuditLogs) {
+		return errors.NewForbiddenError(fmt.Sprintf("missing permission '%s'", authorization.ReadAuditLogs))
+	}
+	return nil
+}
+
+// convertEventTypeData converts the event type to its resource-API data counterpart
+func convertEventTypeData(req *goa.RequestData, t auditlog.EventType, config httpsupport.Configuration) *app.EventTypeData {
+	self := httpsupport.AbsoluteURL(req, app.EventTypeHref(t.ID), config)
+	data := &app.EventTypeData{
+		Type: "event_types",
+		ID:   t.ID.String(),
+		Attributes: &app.EventTypeDataAttributes{
+			Name:         t.Name,
+			ParamsSchema: t.ParamsSchema,
+			Internal:     t.Internal,
+			CreatedAt:    t.CreatedAt,
+		},
+		Links: &app.GenericLinks{
+			Self: &self,
+		},
+	}
+	if t.Description != "" {
+		description := t.Description
+		data.Attributes.Description = &description
+	}
+	return data
+}
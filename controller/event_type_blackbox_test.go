@@ -0,0 +1,147 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/fabric8-services/admin-console/app"
+	apptest "github.com/fabric8-services/admin-console/app/test"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/authorization"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/controller"
+	"github.com/fabric8-services/fabric8-common/auth"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testauth "github.com/fabric8-services/fabric8-common/test/auth"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	"github.com/goadesign/goa"
+	goauuid "github.com/goadesign/goa/uuid"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type EventTypesControllerBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	app    *application.GormApplication
+	config *configuration.Configuration
+}
+
+func TestEventTypes(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &EventTypesControllerBlackboxTestSuite{
+		DBTestSuite: testsuite.NewDBTestSuite(config),
+		config:      config,
+	})
+}
+
+func (s *EventTypesControllerBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.app = application.NewGormApplication(s.DB)
+}
+
+func (s *EventTypesControllerBlackboxTestSuite) TestCreateEventType() {
+	// given
+	svc := goa.New("eventtypes")
+	ctrl := controller.NewEventTypesController(svc, s.config, s.app)
+	saCtx, err := testauth.EmbedServiceAccountTokenInContext(context.Background(), &testauth.Identity{
+		Username: auth.Auth,
+		ID:       uuid.NewV4(),
+	})
+	require.NoError(s.T(), err)
+	newPayload := func(name string) *app.CreateEventTypePayload {
+		description := "an event"
+		return &app.CreateEventTypePayload{
+			Data: &app.CreateEventTypeData{
+				Type: "event_types",
+				Attributes: &app.CreateEventTypeDataAttributes{
+					Name:        name,
+					Description: &description,
+					ParamsSchema: map[string]interface{}{
+						"type": "object",
+					},
+				},
+			},
+		}
+	}
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		name := fmt.Sprintf("event_%s", uuid.NewV4().String()[:8])
+		// when
+		rw, result := apptest.CreateEventTypeCreated(t, saCtx, svc, ctrl, newPayload(name))
+		// then
+		require.NotNil(t, result.Data)
+		assert.Equal(t, name, result.Data.Attributes.Name)
+		assert.False(t, result.Data.Attributes.Internal)
+		assert.Equal(t, *result.Data.Links.Self, rw.Header().Get("Location"))
+		// verify that the new event type can be retrieved
+		id, err := goauuid.FromString(result.Data.ID)
+		require.NoError(t, err)
+		_, show := apptest.ShowEventTypeOK(t, saCtx, svc, ctrl, id)
+		assert.Equal(t, name, show.Data.Attributes.Name)
+		assert.Equal(t, "an event", *show.Data.Attributes.Description)
+	})
+
+	s.T().Run("conflict", func(t *testing.T) {
+		// given
+		name := fmt.Sprintf("event_%s", uuid.NewV4().String()[:8])
+		apptest.CreateEventTypeCreated(t, saCtx, svc, ctrl, newPayload(name))
+		// when/then
+		apptest.CreateEventTypeConflict(t, saCtx, svc, ctrl, newPayload(name))
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		// given
+		ctx, _, err := testauth.EmbedTokenInContext("identity", "user", testauth.WithEmailClaim("user@redhat.com"), testauth.WithEmailVerifiedClaim(true))
+		require.NoError(t, err)
+		// when/then
+		apptest.CreateEventTypeUnauthorized(t, ctx, svc, ctrl, newPayload("foo"))
+	})
+}
+
+func (s *EventTypesControllerBlackboxTestSuite) TestListEventTypes() {
+	// given
+	svc := goa.New("eventtypes")
+	ctrl := controller.NewEventTypesController(svc, s.config, s.app)
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		ctx, _, err := testauth.EmbedTokenInContext("identity", "user")
+		require.NoError(t, err)
+		ctx = authorization.WithPermissions(ctx, authorization.ReadAuditLogs)
+		// when
+		_, result := apptest.ListEventTypeOK(t, ctx, svc, ctrl)
+		// then
+		names := map[string]bool{}
+		for _, et := range result.Data {
+			names[et.Attributes.Name] = et.Attributes.Internal
+		}
+		require.Contains(t, names, "list_audit_logs")
+		assert.True(t, names["list_audit_logs"])
+		require.Contains(t, names, "user_deactivation")
+		assert.False(t, names["user_deactivation"])
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		// given
+		// no permission granted
+		ctx, _, err := testauth.EmbedTokenInContext("identity", "user")
+		require.NoError(t, err)
+		// when/then
+		apptest.ListEventTypeForbidden(t, ctx, svc, ctrl)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// given
+		ctx, _, err := testauth.EmbedTokenInContext("identity", "user")
+		require.NoError(t, err)
+		ctx = authorization.WithPermissions(ctx, authorization.ReadAuditLogs)
+		// when/then
+		apptest.ShowEventTypeNotFound(t, ctx, svc, ctrl, goauuid.NewV4())
+	})
+}
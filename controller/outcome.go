@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+)
+
+// maxRecordedBodySize the maximum size of a response body kept by a responseRecorder
+const maxRecordedBodySize = 1024 * 1024
+
+// responseRecorder an http.ResponseWriter which records the status code and the size of the response written
+// through it, and optionally keeps a copy of the response body (up to `maxRecordedBodySize` bytes)
+type responseRecorder struct {
+	http.ResponseWriter
+	status    int
+	size      int64
+	body      *bytes.Buffer
+	truncated bool
+}
+
+// newResponseRecorder returns a new responseRecorder which writes into the given writer
+func newResponseRecorder(w http.ResponseWriter, keepBody bool) *responseRecorder {
+	r := &responseRecorder{
+		ResponseWriter: w,
+	}
+	if keepBody {
+		r.body = &bytes.Buffer{}
+	}
+	return r
+}
+
+// WriteHeader implements http.ResponseWriter
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.size += int64(n)
+	if r.body != nil && !r.truncated {
+		if r.body.Len()+n > maxRecordedBodySize {
+			r.truncated = true
+		} else {
+			r.body.Write(b[:n])
+		}
+	}
+	return n, err
+}
+
+// Flush implements http.Flusher, so that streamed responses are still flushed to the client
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// resultCounter returns the number of results in the given recorded response, or nil if it cannot be determined
+type resultCounter func(r *responseRecorder) *int
+
+// routeAndRecordOutcome calls the given function which handles the audited action (eg: by proxying the request), with a
+// responseRecorder in place of the writer of the given response data. Once the response was written, the status code,
+// the latency, the size of the response and the number of results (if a counter is given) are recorded in the given
+// audit log record.
+// The error returned by the route function (if any) takes precedence over the status of the response, since it is
+// converted into an error response afterwards.
+// Failures to record the outcome are only logged, since the response was already written, and the outcome is not recorded
+// if the record was spooled instead of being stored in the database (see `createAuditLog`).
+func routeAndRecordOutcome(ctx context.Context, db application.DB, record *auditlog.AuditLog, spooled bool, rd *goa.ResponseData, count resultCounter, route func() error) error {
+	if spooled {
+		return route()
+	}
+	recorder := newResponseRecorder(rd.ResponseWriter, count != nil)
+	rd.ResponseWriter = recorder
+	defer func() {
+		rd.ResponseWriter = recorder.ResponseWriter
+	}()
+	start := time.Now()
+	routeErr := route()
+	result := auditlog.ActionResult{
+		StatusCode:   recorder.status,
+		Latency:      time.Since(start),
+		ResponseSize: recorder.size,
+	}
+	switch {
+	case routeErr != nil:
+		result.StatusCode = http.StatusInternalServerError
+	case result.StatusCode == 0:
+		// nothing was written
+		result.StatusCode = http.StatusOK
+	}
+	if count != nil && routeErr == nil && result.StatusCode == http.StatusOK {
+		result.ResultCount = count(recorder)
+	}
+	err := application.Transactional(db, func(appl application.Application) error {
+		return appl.AuditLogs().RecordOutcome(ctx, record, result)
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":          err,
+			"audit_log_id": record.ID,
+			"status_code":  result.StatusCode,
+		}, "unable to record the outcome of the audited action")
+	}
+	return routeErr
+}
+
+// countJSONAPIData returns the number of elements in the `data` array of the recorded JSON-API response,
+// or nil if the body was truncated or if it is not a JSON-API document with a `data` array
+func countJSONAPIData(r *responseRecorder) *int {
+	if r.body == nil || r.truncated {
+		return nil
+	}
+	var body io.Reader = bytes.NewReader(r.body.Bytes())
+	if r.Header().Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil
+		}
+		defer gz.Close()
+		body = gz
+	}
+	content, err := ioutil.ReadAll(io.LimitReader(body, maxRecordedBodySize))
+	if err != nil {
+		return nil
+	}
+	var doc struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil || doc.Data == nil {
+		return nil
+	}
+	count := len(doc.Data)
+	return &count
+}
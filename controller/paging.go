@@ -4,6 +4,8 @@ package controller
 
 import (
 	"fmt"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -82,6 +84,44 @@ func setPagingLinks(links *app.PagingLinks, path string, currentCount, pageNumbe
 	links.Last = &last
 }
 
+// setCursorPagingLinks sets the links of a list paged with a cursor. The `next` link is only set if there is a cursor
+// to the next records.
+func setCursorPagingLinks(links *app.PagingLinks, path string, pageSize int, nextCursor *string, additionalQuery ...string) {
+	format := func(additional []string) string {
+		if len(additional) > 0 {
+			return "&" + strings.Join(additional, "&")
+		}
+		return ""
+	}
+
+	// first link
+	first := fmt.Sprintf("%s?page[cursor]=&page[size]=%d%s", path, pageSize, format(additionalQuery))
+	links.First = &first
+
+	// next link
+	if nextCursor != nil {
+		next := fmt.Sprintf("%s?page[cursor]=%s&page[size]=%d%s", path, url.QueryEscape(*nextCursor), pageSize, format(additionalQuery))
+		links.Next = &next
+	}
+}
+
+// filterQuery returns the (escaped) query params other than the paging ones, so they can be
+// appended to the paging links
+func filterQuery(query url.Values) []string {
+	result := []string{}
+	for name, values := range query {
+		if strings.HasPrefix(name, "page[") {
+			continue
+		}
+		for _, value := range values {
+			result = append(result, fmt.Sprintf("%s=%s", url.QueryEscape(name), url.QueryEscape(value)))
+		}
+	}
+	// make sure the links are always the same for a given query
+	sort.Strings(result)
+	return result
+}
+
 func parseInts(s *string) ([]int, error) {
 	if s == nil || len(*s) == 0 {
 		return []int{}, nil
@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"github.com/fabric8-services/admin-console/app"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/authorization"
+	"github.com/fabric8-services/fabric8-common/httpsupport"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+)
+
+// RolesController implements the role resource.
+type RolesController struct {
+	*goa.Controller
+	db     application.DB
+	config httpsupport.Configuration
+}
+
+// NewRolesController creates a role controller.
+func NewRolesController(service *goa.Service, config httpsupport.Configuration, db application.DB) *RolesController {
+	return &RolesController{
+		Controller: service.NewController("RolesController"),
+		config:     config,
+		db:         db,
+	}
+}
+
+// List lists all the roles
+func (c *RolesController) List(ctx *app.ListRoleContext) error {
+	roles, err := c.db.Roles().List(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the roles")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.RoleList{
+		Data: convertRolesData(ctx.RequestData, roles, c.config),
+	})
+}
+
+// Show shows a role
+func (c *RolesController) Show(ctx *app.ShowRoleContext) error {
+	role, err := c.db.Roles().LoadByName(ctx, ctx.Name)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.RoleSingle{
+		Data: convertRoleData(ctx.RequestData, role, c.config),
+	})
+}
+
+// Create creates a new role
+func (c *RolesController) Create(ctx *app.CreateRoleContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to identify the user creating a role")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	attrs := ctx.Payload.Data.Attributes
+	role := authorization.Role{
+		Name:        attrs.Name,
+		Permissions: make([]authorization.Permission, len(attrs.Permissions)),
+	}
+	if attrs.Description != nil {
+		role.Description = *attrs.Description
+	}
+	for i, p := range attrs.Permissions {
+		role.Permissions[i] = authorization.Permission(p)
+	}
+	// create the role and log an audit log for the current user for her action in the same transaction
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		if err := appl.Roles().Create(ctx, &role); err != nil {
+			return err
+		}
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: auditlog.CreateRole,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"role":        role.Name,
+				"permissions": attrs.Permissions,
+			},
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":  err,
+			"role": attrs.Name,
+		}, "unable to create the role")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"role":       role.Name,
+		"created_by": username,
+	}, "created new role")
+	data := convertRoleData(ctx.RequestData, role, c.config)
+	ctx.ResponseData.Header().Set("Location", *data.Links.Self)
+	return ctx.Created(&app.RoleSingle{
+		Data: data,
+	})
+}
+
+// ListForUser lists the roles assigned to a user
+func (c *RolesController) ListForUser(ctx *app.ListForUserRoleContext) error {
+	roles, err := c.db.Roles().ListByUsername(ctx, ctx.Username)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"username": ctx.Username,
+		}, "unable to list the roles of the user")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.RoleList{
+		Data: convertRolesData(ctx.RequestData, roles, c.config),
+	})
+}
+
+// Assign assigns a role to a user
+func (c *RolesController) Assign(ctx *app.AssignRoleContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to identify the user assigning a role")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	// assign the role and log an audit log for the current user for her action in the same transaction
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		if err := appl.Roles().Assign(ctx, ctx.Username, ctx.Name, username); err != nil {
+			return err
+		}
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: auditlog.AssignRole,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"role": ctx.Name,
+				"user": ctx.Username,
+			},
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"role":     ctx.Name,
+			"username": ctx.Username,
+		}, "unable to assign the role")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// Revoke revokes a role from a user
+func (c *RolesController) Revoke(ctx *app.RevokeRoleContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to identify the user revoking a role")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	// revoke the role and log an audit log for the current user for her action in the same transaction
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		if err := appl.Roles().Revoke(ctx, ctx.Username, ctx.Name); err != nil {
+			return err
+		}
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: auditlog.RevokeRole,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"role": ctx.Name,
+				"user": ctx.Username,
+			},
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"role":     ctx.Name,
+			"username": ctx.Username,
+		}, "unable to revoke the role")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// convertRolesData converts the roles to their resource-API data counterpart
+func convertRolesData(req *goa.RequestData, roles []authorization.Role, config httpsupport.Configuration) []*app.RoleData {
+	data := make([]*app.RoleData, len(roles))
+	for i, r := range roles {
+		data[i] = convertRoleData(req, r, config)
+	}
+	return data
+}
+
+// convertRoleData converts the role to its resource-API data counterpart
+func convertRoleData(req *goa.RequestData, r authorization.Role, config httpsupport.Configuration) *app.RoleData {
+	self := httpsupport.AbsoluteURL(req, app.RoleHref(r.Name), config)
+	permissions := make([]string, len(r.Permissions))
+	for i, p := range r.Permissions {
+		permissions[i] = string(p)
+	}
+	data := &app.RoleData{
+		Type: "roles",
+		ID:   r.ID.String(),
+		Attributes: &app.RoleDataAttributes{
+			Name:        r.Name,
+			Permissions: permissions,
+			CreatedAt:   r.CreatedAt,
+		},
+		Links: &app.GenericLinks{
+			Self: &self,
+		},
+	}
+	if r.Description != "" {
+		description := r.Description
+		data.Attributes.Description = &description
+	}
+	return data
+}
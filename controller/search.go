@@ -52,9 +52,7 @@ func (c *SearchController) SearchUsers(ctx *app.SearchUsersSearchContext) error
 			"query": ctx.Q,
 		},
 	}
-	err = application.Transactional(c.db, func(appl application.Application) error {
-		return appl.AuditLogs().Create(ctx, &record)
-	})
+	spooled, err := createAuditLog(ctx, c.db, &record)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"err": err,
@@ -62,5 +60,7 @@ func (c *SearchController) SearchUsers(ctx *app.SearchUsersSearchContext) error
 		return app.JSONErrorResponse(ctx, err)
 	}
 
-	return httpsupport.RouteHTTP(ctx, c.config.GetAuthServiceURL(), c.options...)
+	return routeAndRecordOutcome(ctx, c.db, &record, spooled, ctx.ResponseData, countJSONAPIData, func() error {
+		return httpsupport.RouteHTTP(ctx, c.config.GetAuthServiceURL(), c.options...)
+	})
 }
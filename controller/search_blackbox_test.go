@@ -3,7 +3,9 @@ package controller_test
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"testing"
 
 	apptest "github.com/fabric8-services/admin-console/app/test"
@@ -11,6 +13,7 @@ import (
 	"github.com/fabric8-services/admin-console/auditlog"
 	"github.com/fabric8-services/admin-console/configuration"
 	"github.com/fabric8-services/admin-console/controller"
+	"github.com/fabric8-services/admin-console/spool"
 	testconfig "github.com/fabric8-services/admin-console/test/generated/configuration"
 	commonconfig "github.com/fabric8-services/fabric8-common/configuration"
 	"github.com/fabric8-services/fabric8-common/httpsupport"
@@ -20,6 +23,9 @@ import (
 
 	"github.com/goadesign/goa"
 	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	gock "gopkg.in/h2non/gock.v1"
@@ -35,6 +41,15 @@ func newSearchController(config controller.SearchControllerConfiguration, db app
 	return svc, ctrl
 }
 
+// unavailableDB a database in which no transaction can begin, as if the database was unreachable
+type unavailableDB struct {
+	*application.GormApplication
+}
+
+func (db unavailableDB) BeginTransaction() (application.Transaction, error) {
+	return nil, errs.New("dial tcp: connection refused")
+}
+
 type SearchControllerBlackboxTestSuite struct {
 	testsuite.DBTestSuite
 	app *application.GormApplication
@@ -85,6 +100,64 @@ func (s *SearchControllerBlackboxTestSuite) TestSearchUsers() {
 		assertAuditLog(t, s.DB, *identity, auditlog.UserSearch, auditlog.EventParams{"query": "foo"})
 	})
 
+	s.T().Run("ok with result count", func(t *testing.T) {
+		// given
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		tk := goajwt.ContextJWT(ctx)
+		require.NotNil(t, tk)
+		authzHeader := fmt.Sprintf("Bearer %s", tk.Raw)
+		body := `{"data":[{"id":"1","type":"identities"},{"id":"2","type":"identities"}]}`
+		gock.New("https://test-auth").
+			Get("/api/search/users").
+			MatchHeader("Authorization", authzHeader).
+			MatchParam("q", "bar").
+			Reply(http.StatusOK).
+			BodyString(body)
+		// when
+		apptest.SearchUsersSearchOK(t, ctx, svc, ctrl, nil, nil, "bar", &authzHeader)
+		// then check that the outcome of the search was recorded
+		assertAuditLogOutcome(t, s.DB, *identity, http.StatusOK, auditlog.OutcomeSuccess)
+		records, _, err := auditlog.NewRepository(s.DB).ListByIdentityID(context.Background(), identity.ID, 0, 5)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		require.NotNil(t, records[0].ResponseSize)
+		assert.Equal(t, int64(len(body)), *records[0].ResponseSize)
+		require.NotNil(t, records[0].ResultCount)
+		assert.Equal(t, 2, *records[0].ResultCount)
+	})
+
+	s.T().Run("record spooled when the database is unavailable", func(t *testing.T) {
+		// given a `fail-open` policy for the user searches
+		dir, err := ioutil.TempDir("", "spool")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		sp, err := spool.New(dir)
+		require.NoError(t, err)
+		eventTypes := map[uuid.UUID]string{auditlog.UserSearch: auditlog.UserSearchEvent}
+		policies, err := spool.ParsePolicies(map[string]string{auditlog.UserSearchEvent: "fail-open"}, eventTypes)
+		require.NoError(t, err)
+		ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		ctx = spool.WithRecorder(ctx, spool.NewRecorder(sp, policies, spool.FailClosed, eventTypes))
+		tk := goajwt.ContextJWT(ctx)
+		require.NotNil(t, tk)
+		authzHeader := fmt.Sprintf("Bearer %s", tk.Raw)
+		gock.New("https://test-auth").
+			Get("/api/search/users").
+			MatchParam("q", "unavailable").
+			Reply(http.StatusOK).
+			BodyString(`{"data":[]}`)
+		svc, ctrl := newSearchController(config, unavailableDB{GormApplication: s.app})
+		// when
+		apptest.SearchUsersSearchOK(t, ctx, svc, ctrl, nil, nil, "unavailable", &authzHeader)
+		// then the request was proxied and the record was spooled
+		assert.True(t, gock.IsDone())
+		pending, err := sp.Len()
+		require.NoError(t, err)
+		assert.Equal(t, 1, pending)
+	})
+
 	s.T().Run("failures", func(t *testing.T) {
 
 		t.Run("missing JWT", func(t *testing.T) {
@@ -1,14 +1,20 @@
 package controller
 
 import (
+	"context"
+	"time"
+
 	"github.com/fabric8-services/admin-console/app"
 	"github.com/fabric8-services/admin-console/application"
 	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/admin-console/tenantupdate"
 	authsupport "github.com/fabric8-services/fabric8-common/auth"
 	"github.com/fabric8-services/fabric8-common/errors"
 	"github.com/fabric8-services/fabric8-common/httpsupport"
 	"github.com/fabric8-services/fabric8-common/log"
 	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
 )
 
 // TenantUpdateController implements the TenantUpdate resource.
@@ -16,19 +22,26 @@ type TenantUpdateController struct {
 	*goa.Controller
 	config TenantUpdateControllerConfiguration
 	db     application.DB
+	tenant tenant.Client
 }
 
 // TenantUpdateControllerConfiguration the configuration for the SearchController
 type TenantUpdateControllerConfiguration interface {
-	GetTenantServiceURL() string
+	httpsupport.Configuration
+	IsTenantUpdateApprovalRequired() bool
+	GetTenantUpdateApprovalExpiry() time.Duration
 }
 
-// NewTenantUpdateController creates a TenantUpdate controller.
-func NewTenantUpdateController(service *goa.Service, config TenantUpdateControllerConfiguration, db application.DB) *TenantUpdateController {
+// NewTenantUpdateController creates a TenantUpdate controller, which calls the tenant service with the given client and
+// records the started updates in the history of the tenant update jobs.
+// When the approval of the tenant updates is required, the updates which are not limited to a cluster are not started,
+// but requested until another user approves them.
+func NewTenantUpdateController(service *goa.Service, config TenantUpdateControllerConfiguration, db application.DB, client tenant.Client) *TenantUpdateController {
 	return &TenantUpdateController{
 		Controller: service.NewController("TenantUpdateController"),
 		config:     config,
 		db:         db,
+		tenant:     client,
 	}
 }
 
@@ -41,32 +54,88 @@ func (c *TenantUpdateController) Show(ctx *app.ShowTenantUpdateContext) error {
 		}, "invalid or missing authorization token")
 		return app.JSONErrorResponse(ctx, errors.NewUnauthorizedError("invalid or missing authorization token"))
 	}
-	err = application.Transactional(c.db, func(appl application.Application) error {
-		eventParams := auditlog.EventParams{}
-		if ctx.ClusterURL != nil {
-			eventParams["clusterURL"] = *ctx.ClusterURL
-		}
-		if ctx.EnvType != nil {
-			eventParams["envType"] = *ctx.EnvType
+	eventParams := auditlog.EventParams{}
+	if ctx.ClusterURL != nil {
+		eventParams["clusterURL"] = *ctx.ClusterURL
+	}
+	if ctx.EnvType != nil {
+		eventParams["envType"] = *ctx.EnvType
+	}
+	record := auditlog.AuditLog{
+		EventTypeID: auditlog.ShowTenantUpdate,
+		IdentityID:  identityID,
+		Username:    username,
+		EventParams: eventParams,
+	}
+	spooled, err := createAuditLog(ctx, c.db, &record)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to record the auditlog while calling the tenant service")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return routeAndRecordOutcome(ctx, c.db, &record, spooled, ctx.ResponseData, nil, func() error {
+		update, err := c.tenant.ShowUpdate(ctx, authorizationHeader(ctx.Authorization), ctx.ClusterURL, ctx.EnvType)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err": err,
+			}, "unable to retrieve the status of the tenant update")
+			return app.JSONErrorResponse(ctx, err)
 		}
-		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
-			EventTypeID: auditlog.ShowTenantUpdate,
-			IdentityID:  identityID,
-			Username:    username,
-			EventParams: eventParams,
+		return ctx.OK(&app.TenantUpdateSingle{
+			Data: convertTenantUpdateData(update, ctx.ClusterURL, ctx.EnvType),
 		})
 	})
+}
+
+// Start starts a tenant update, or requests to start it if it is not limited to a cluster and the updates must be
+// approved
+func (c *TenantUpdateController) Start(ctx *app.StartTenantUpdateContext) error {
+	identityID, username, err := authsupport.LocateIdentity(ctx)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"err": err,
-		}, "unable to record the auditlog while proxying request to tenant")
+		}, "invalid or missing authorization token")
+		return app.JSONErrorResponse(ctx, errors.NewUnauthorizedError("invalid or missing authorization token"))
+	}
+	if ctx.ClusterURL == nil && c.config.IsTenantUpdateApprovalRequired() {
+		return c.requestApproval(ctx, identityID, username)
+	}
+	eventParams := auditlog.EventParams{}
+	if ctx.ClusterURL != nil {
+		eventParams["clusterURL"] = *ctx.ClusterURL
+	}
+	if ctx.EnvType != nil {
+		eventParams["envType"] = *ctx.EnvType
+	}
+	record := auditlog.AuditLog{
+		EventTypeID: auditlog.StartTenantUpdate,
+		IdentityID:  identityID,
+		Username:    username,
+		EventParams: eventParams,
+	}
+	spooled, err := createAuditLog(ctx, c.db, &record)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to record the auditlog while calling the tenant service")
 		return app.JSONErrorResponse(ctx, err)
 	}
-	return httpsupport.RouteHTTPToPath(ctx, c.config.GetTenantServiceURL(), "/api/update")
+	return routeAndRecordOutcome(ctx, c.db, &record, spooled, ctx.ResponseData, nil, func() error {
+		_, err := c.tenant.StartUpdate(ctx, authorizationHeader(ctx.Authorization), ctx.ClusterURL, ctx.EnvType)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err": err,
+			}, "unable to start the tenant update")
+			return app.JSONErrorResponse(ctx, err)
+		}
+		recordStartedJob(ctx, c.db, username, ctx.ClusterURL, ctx.EnvType)
+		return ctx.Accepted()
+	})
 }
 
-// Start starts a tenant update
-func (c *TenantUpdateController) Start(ctx *app.StartTenantUpdateContext) error {
+// Stop stops the ongoing tenant update
+func (c *TenantUpdateController) Stop(ctx *app.StopTenantUpdateContext) error {
 	identityID, username, err := authsupport.LocateIdentity(ctx)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
@@ -74,8 +143,49 @@ func (c *TenantUpdateController) Start(ctx *app.StartTenantUpdateContext) error
 		}, "invalid or missing authorization token")
 		return app.JSONErrorResponse(ctx, errors.NewUnauthorizedError("invalid or missing authorization token"))
 	}
-	err = application.Transactional(c.db, func(appl application.Application) error {
-		eventParams := auditlog.EventParams{}
+	record := auditlog.AuditLog{
+		EventTypeID: auditlog.StopTenantUpdate,
+		IdentityID:  identityID,
+		Username:    username,
+		EventParams: auditlog.EventParams{},
+	}
+	spooled, err := createAuditLog(ctx, c.db, &record)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to record the auditlog while calling the tenant service")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return routeAndRecordOutcome(ctx, c.db, &record, spooled, ctx.ResponseData, nil, func() error {
+		if err := c.tenant.StopUpdate(ctx, authorizationHeader(ctx.Authorization)); err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err": err,
+			}, "unable to stop the tenant update")
+			return app.JSONErrorResponse(ctx, err)
+		}
+		c.recordStopRequest(ctx, username)
+		return ctx.Accepted()
+	})
+}
+
+// requestApproval stores a request to start the tenant update on behalf of the user with the given identity, which is
+// pending until another user approves or rejects it, and returns its location
+func (c *TenantUpdateController) requestApproval(ctx *app.StartTenantUpdateContext, identityID uuid.UUID, username string) error {
+	request := tenantupdate.Request{
+		ClusterURL:  ctx.ClusterURL,
+		EnvType:     ctx.EnvType,
+		RequestedBy: username,
+		ExpiresAt:   time.Now().Add(c.config.GetTenantUpdateApprovalExpiry()),
+	}
+	// store the request and log an audit log for the current user for her action in the same transaction
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		if err := appl.TenantUpdateRequests().Create(ctx, &request); err != nil {
+			return err
+		}
+		eventParams := auditlog.EventParams{
+			"request_id": request.ID.String(),
+			"expires_at": request.ExpiresAt.UTC().Format(time.RFC3339),
+		}
 		if ctx.ClusterURL != nil {
 			eventParams["clusterURL"] = *ctx.ClusterURL
 		}
@@ -83,7 +193,7 @@ func (c *TenantUpdateController) Start(ctx *app.StartTenantUpdateContext) error
 			eventParams["envType"] = *ctx.EnvType
 		}
 		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
-			EventTypeID: auditlog.StartTenantUpdate,
+			EventTypeID: auditlog.RequestTenantUpdate,
 			IdentityID:  identityID,
 			Username:    username,
 			EventParams: eventParams,
@@ -92,34 +202,79 @@ func (c *TenantUpdateController) Start(ctx *app.StartTenantUpdateContext) error
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"err": err,
-		}, "unable to record the auditlog while proxying request to tenant")
+		}, "unable to request the approval of the tenant update")
 		return app.JSONErrorResponse(ctx, err)
 	}
-	return httpsupport.RouteHTTPToPath(ctx, c.config.GetTenantServiceURL(), "/api/update")
+	log.Info(ctx, map[string]interface{}{
+		"request_id":   request.ID,
+		"requested_by": username,
+	}, "tenant update pending approval")
+	ctx.ResponseData.Header().Set("Location", httpsupport.AbsoluteURL(ctx.RequestData, app.TenantUpdateRequestHref(request.ID.String()), c.config))
+	return ctx.Accepted()
 }
 
-// Stop stops the ongoing tenant update
-func (c *TenantUpdateController) Stop(ctx *app.StopTenantUpdateContext) error {
-	identityID, username, err := authsupport.LocateIdentity(ctx)
+// checkStartableWithoutApproval returns ForbiddenError if the approval of the tenant updates is required and the update
+// with the given scope is not limited to a cluster, since the scheduler would start it without any approval
+func checkStartableWithoutApproval(approvalRequired bool, clusterURL *string) error {
+	if approvalRequired && clusterURL == nil {
+		return errors.NewForbiddenError("a tenant update which is not limited to a cluster must be requested and approved by another user")
+	}
+	return nil
+}
+
+// recordStartedJob stores the tenant update job which was just started by the user with the given username.
+// Failures are only logged, since the update was started anyway.
+func recordStartedJob(ctx context.Context, db application.DB, username string, clusterURL, envType *string) {
+	job := tenantupdate.Job{
+		ClusterURL: clusterURL,
+		EnvType:    envType,
+		Status:     tenant.StatusUpdating,
+		StartedBy:  username,
+	}
+	err := application.Transactional(db, func(appl application.Application) error {
+		return appl.TenantUpdateJobs().Create(ctx, &job)
+	})
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
-			"err": err,
-		}, "invalid or missing authorization token")
-		return app.JSONErrorResponse(ctx, errors.NewUnauthorizedError("invalid or missing authorization token"))
+			"err":        err,
+			"started_by": username,
+		}, "unable to record the started tenant update job")
 	}
-	err = application.Transactional(c.db, func(appl application.Application) error {
-		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
-			EventTypeID: auditlog.StopTenantUpdate,
-			IdentityID:  identityID,
-			Username:    username,
-			EventParams: auditlog.EventParams{},
-		})
+}
+
+// recordStopRequest records that the user with the given username requested to stop the running tenant update jobs.
+// Failures are only logged, since the update was stopped anyway.
+func (c *TenantUpdateController) recordStopRequest(ctx context.Context, username string) {
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		_, err := appl.TenantUpdateJobs().RequestStop(ctx, username)
+		return err
 	})
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
-			"err": err,
-		}, "unable to record the auditlog while proxying request to tenant")
-		return app.JSONErrorResponse(ctx, err)
+			"err":               err,
+			"stop_requested_by": username,
+		}, "unable to record the stop request of the tenant update jobs")
+	}
+}
+
+// authorizationHeader returns the value of the given authorization header, or an empty string if there is none
+func authorizationHeader(authorization *string) string {
+	if authorization == nil {
+		return ""
+	}
+	return *authorization
+}
+
+// convertTenantUpdateData converts the status of the tenant update in the given scope to its resource-API data counterpart
+func convertTenantUpdateData(update tenant.Update, clusterURL, envType *string) *app.TenantUpdateData {
+	return &app.TenantUpdateData{
+		Type: "tenant_update_status",
+		Attributes: &app.TenantUpdateDataAttributes{
+			ClusterURL:      clusterURL,
+			EnvType:         envType,
+			Status:          string(update.Status),
+			FailedCount:     update.FailedCount,
+			LastTimeUpdated: update.LastTimeUpdated,
+		},
 	}
-	return httpsupport.RouteHTTPToPath(ctx, c.config.GetTenantServiceURL(), "/api/update")
 }
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	uuid "github.com/satori/go.uuid"
@@ -14,7 +17,10 @@ import (
 	"github.com/fabric8-services/admin-console/auditlog"
 	"github.com/fabric8-services/admin-console/configuration"
 	"github.com/fabric8-services/admin-console/controller"
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/admin-console/tenantupdate"
 	testconfig "github.com/fabric8-services/admin-console/test/generated/controller"
+	testtenant "github.com/fabric8-services/admin-console/test/generated/tenant"
 	"github.com/fabric8-services/fabric8-common/resource"
 	testauth "github.com/fabric8-services/fabric8-common/test/auth"
 	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
@@ -27,11 +33,23 @@ import (
 	gock "gopkg.in/h2non/gock.v1"
 )
 
+// tenantClientConfig the configuration of the tenant client, with a fake tenant service
+type tenantClientConfig struct{}
+
+func (c tenantClientConfig) GetTenantServiceURL() string {
+	return "http://test-tenant"
+}
+
 func newTenantUpdateController(config controller.TenantUpdateControllerConfiguration, db application.DB) (*goa.Service, *controller.TenantUpdateController) {
+	return newTenantUpdateControllerWithClient(config, db, tenant.NewClient(tenantClientConfig{}, http.DefaultClient))
+}
+
+func newTenantUpdateControllerWithClient(config controller.TenantUpdateControllerConfiguration, db application.DB, client tenant.Client) (*goa.Service, *controller.TenantUpdateController) {
 	svc := goa.New("search")
 	ctrl := controller.NewTenantUpdateController(svc,
 		config,
 		db,
+		client,
 	)
 	return svc, ctrl
 }
@@ -55,9 +73,6 @@ func TestTenantUpdateController(t *testing.T) {
 func (s *TenantUpdateControllerBlackboxTestSuite) TestShowTenantUpdate() {
 	// given
 	config := testconfig.NewTenantUpdateControllerConfigurationMock(s.T())
-	config.GetTenantServiceURLFunc = func() string {
-		return "http://test-tenant"
-	}
 	svc, ctrl := newTenantUpdateController(config, s.app)
 	defer gock.OffAll()
 
@@ -72,10 +87,19 @@ func (s *TenantUpdateControllerBlackboxTestSuite) TestShowTenantUpdate() {
 			gock.New("http://test-tenant").
 				Get("/api/update").
 				MatchHeader("Authorization", authzHeader).
-				Reply(http.StatusOK).BodyString(`{"data":"whatever"}`)
+				Reply(http.StatusOK).BodyString(`{"data":{"attributes":{"status":"failed","failed-count":3,"last-time-updated":"2018-06-01T10:00:00Z","file-versions":[]}}}`)
 			// when
-			apptest.ShowTenantUpdateOK(t, ctx, svc, ctrl, nil, nil, &authzHeader)
-			// then check that an audit record was created
+			_, result := apptest.ShowTenantUpdateOK(t, ctx, svc, ctrl, nil, nil, &authzHeader)
+			// then the status reported by the tenant service is returned
+			require.NotNil(t, result.Data)
+			assert.Equal(t, "tenant_update_status", result.Data.Type)
+			assert.Equal(t, "failed", result.Data.Attributes.Status)
+			assert.Equal(t, 3, result.Data.Attributes.FailedCount)
+			require.NotNil(t, result.Data.Attributes.LastTimeUpdated)
+			assert.Equal(t, time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC), result.Data.Attributes.LastTimeUpdated.UTC())
+			assert.Nil(t, result.Data.Attributes.ClusterURL)
+			assert.Nil(t, result.Data.Attributes.EnvType)
+			// and check that an audit record was created
 			assertAuditLog(t, s.DB, *identity, auditlog.ShowTenantUpdate, auditlog.EventParams{})
 		})
 		t.Run("single clusters single env", func(t *testing.T) {
@@ -93,15 +117,48 @@ func (s *TenantUpdateControllerBlackboxTestSuite) TestShowTenantUpdate() {
 				MatchHeader("Authorization", authzHeader).
 				MatchParam("cluster_url", cluster).
 				MatchParam("env_type", envType).
-				Reply(http.StatusOK).BodyString(`{"data":"whatever"}`)
+				Reply(http.StatusOK).BodyString(`{"data":{"attributes":{"status":"updating","failed-count":0}}}`)
 			// when
-			apptest.ShowTenantUpdateOK(t, ctx, svc, ctrl, &cluster, &envType, &authzHeader)
-			// then check that an audit record was created
+			_, result := apptest.ShowTenantUpdateOK(t, ctx, svc, ctrl, &cluster, &envType, &authzHeader)
+			// then the status is returned along with its scope
+			assert.Equal(t, "updating", result.Data.Attributes.Status)
+			assert.Equal(t, 0, result.Data.Attributes.FailedCount)
+			assert.Nil(t, result.Data.Attributes.LastTimeUpdated)
+			require.NotNil(t, result.Data.Attributes.ClusterURL)
+			assert.Equal(t, cluster, *result.Data.Attributes.ClusterURL)
+			require.NotNil(t, result.Data.Attributes.EnvType)
+			assert.Equal(t, envType, *result.Data.Attributes.EnvType)
+			// and check that an audit record was created
 			assertAuditLog(t, s.DB, *identity, auditlog.ShowTenantUpdate, auditlog.EventParams{
 				"clusterURL": cluster,
 				"envType":    envType,
 			})
 		})
+
+		t.Run("fake tenant client", func(t *testing.T) {
+			// given
+			ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			tk := goajwt.ContextJWT(ctx)
+			require.NotNil(t, tk)
+			authzHeader := fmt.Sprintf("Bearer %s", tk.Raw)
+			client := testtenant.NewClientMock(t)
+			client.ShowUpdateFunc = func(ctx context.Context, authorization string, clusterURL, envType *string) (tenant.Update, error) {
+				assert.Equal(t, authzHeader, authorization)
+				return tenant.Update{
+					Status:      tenant.StatusKilled,
+					FailedCount: 1,
+				}, nil
+			}
+			svc, ctrl := newTenantUpdateControllerWithClient(config, s.app, client)
+			// when
+			_, result := apptest.ShowTenantUpdateOK(t, ctx, svc, ctrl, nil, nil, &authzHeader)
+			// then
+			assert.Equal(t, "killed", result.Data.Attributes.Status)
+			assert.Equal(t, 1, result.Data.Attributes.FailedCount)
+			assertAuditLog(t, s.DB, *identity, auditlog.ShowTenantUpdate, auditlog.EventParams{})
+			assertAuditLogOutcome(t, s.DB, *identity, http.StatusOK, auditlog.OutcomeSuccess)
+		})
 	})
 
 	s.T().Run("failures", func(t *testing.T) {
@@ -149,13 +206,31 @@ func (s *TenantUpdateControllerBlackboxTestSuite) TestShowTenantUpdate() {
 			// then check that an audit record was created
 			assertAuditLog(t, s.DB, *identity, auditlog.ShowTenantUpdate, auditlog.EventParams{})
 		})
+
+		t.Run("unexpected payload", func(t *testing.T) {
+			// given
+			ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			tk := goajwt.ContextJWT(ctx)
+			require.NotNil(t, tk)
+			authzHeader := fmt.Sprintf("Bearer %s", tk.Raw)
+			gock.New("http://test-tenant").
+				Get("/api/update").
+				MatchHeader("Authorization", authzHeader).
+				Reply(http.StatusOK).BodyString(`{"data":{"attributes":{"status":"whatever","failed-count":0}}}`)
+			// when
+			apptest.ShowTenantUpdateInternalServerError(t, ctx, svc, ctrl, nil, nil, &authzHeader)
+			// then check that an audit record was created with the outcome of the request
+			assertAuditLog(t, s.DB, *identity, auditlog.ShowTenantUpdate, auditlog.EventParams{})
+			assertAuditLogOutcome(t, s.DB, *identity, http.StatusInternalServerError, auditlog.OutcomeError)
+		})
 	})
 }
 func (s *TenantUpdateControllerBlackboxTestSuite) TestStartTenantUpdate() {
 	// given
 	config := testconfig.NewTenantUpdateControllerConfigurationMock(s.T())
-	config.GetTenantServiceURLFunc = func() string {
-		return "http://test-tenant"
+	config.IsTenantUpdateApprovalRequiredFunc = func() bool {
+		return false
 	}
 	svc, ctrl := newTenantUpdateController(config, s.app)
 	defer gock.OffAll()
@@ -177,6 +252,13 @@ func (s *TenantUpdateControllerBlackboxTestSuite) TestStartTenantUpdate() {
 			apptest.StartTenantUpdateAccepted(t, ctx, svc, ctrl, nil, nil, &authzHeader)
 			// then check that an audit record was created
 			assertAuditLog(t, s.DB, *identity, auditlog.StartTenantUpdate, auditlog.EventParams{})
+			assertAuditLogOutcome(t, s.DB, *identity, http.StatusAccepted, auditlog.OutcomeSuccess)
+			// and that the job was recorded
+			job := lastTenantUpdateJob(t, s.app)
+			assert.Equal(t, identity.Username, job.StartedBy)
+			assert.Equal(t, tenant.StatusUpdating, job.Status)
+			assert.Nil(t, job.ClusterURL)
+			assert.Nil(t, job.EnvType)
 		})
 
 		t.Run("single clusters single env", func(t *testing.T) {
@@ -201,6 +283,13 @@ func (s *TenantUpdateControllerBlackboxTestSuite) TestStartTenantUpdate() {
 				"clusterURL": cluster,
 				"envType":    envType,
 			})
+			// and that the job was recorded with its scope
+			job := lastTenantUpdateJob(t, s.app)
+			assert.Equal(t, identity.Username, job.StartedBy)
+			require.NotNil(t, job.ClusterURL)
+			assert.Equal(t, cluster, *job.ClusterURL)
+			require.NotNil(t, job.EnvType)
+			assert.Equal(t, envType, *job.EnvType)
 		})
 	})
 
@@ -231,6 +320,7 @@ func (s *TenantUpdateControllerBlackboxTestSuite) TestStartTenantUpdate() {
 			apptest.StartTenantUpdateUnauthorized(t, ctx, svc, ctrl, nil, nil, &authzHeader)
 			// then check that an audit record was created
 			assertAuditLog(t, s.DB, *identity, auditlog.StartTenantUpdate, auditlog.EventParams{})
+			assertAuditLogOutcome(t, s.DB, *identity, http.StatusUnauthorized, auditlog.OutcomeDenied)
 		})
 
 		t.Run("conflict", func(t *testing.T) {
@@ -248,6 +338,13 @@ func (s *TenantUpdateControllerBlackboxTestSuite) TestStartTenantUpdate() {
 			apptest.StartTenantUpdateConflict(t, ctx, svc, ctrl, nil, nil, &authzHeader)
 			// then check that an audit record was created
 			assertAuditLog(t, s.DB, *identity, auditlog.StartTenantUpdate, auditlog.EventParams{})
+			assertAuditLogOutcome(t, s.DB, *identity, http.StatusConflict, auditlog.OutcomeError)
+			// but no job was recorded
+			jobs, _, err := s.app.TenantUpdateJobs().List(context.Background(), 0, 1)
+			require.NoError(t, err)
+			for _, job := range jobs {
+				assert.NotEqual(t, identity.Username, job.StartedBy)
+			}
 		})
 		t.Run("bad request", func(t *testing.T) {
 			// given
@@ -284,12 +381,83 @@ func (s *TenantUpdateControllerBlackboxTestSuite) TestStartTenantUpdate() {
 		})
 	})
 }
-func (s *TenantUpdateControllerBlackboxTestSuite) TestStopTenantUpdate() {
+func (s *TenantUpdateControllerBlackboxTestSuite) TestRequestTenantUpdate() {
 	// given
 	config := testconfig.NewTenantUpdateControllerConfigurationMock(s.T())
-	config.GetTenantServiceURLFunc = func() string {
-		return "http://test-tenant"
+	config.IsTenantUpdateApprovalRequiredFunc = func() bool {
+		return true
+	}
+	config.GetTenantUpdateApprovalExpiryFunc = func() time.Duration {
+		return time.Hour
 	}
+	config.IsPostgresDeveloperModeEnabledFunc = func() bool {
+		return false
+	}
+	svc, ctrl := newTenantUpdateController(config, s.app)
+	defer gock.OffAll()
+
+	s.T().Run("all clusters", func(t *testing.T) {
+		// given
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		tk := goajwt.ContextJWT(ctx)
+		require.NotNil(t, tk)
+		authzHeader := fmt.Sprintf("Bearer %s", tk.Raw)
+		envType := "che"
+		// when
+		rw := apptest.StartTenantUpdateAccepted(t, ctx, svc, ctrl, nil, &envType, &authzHeader)
+		// then the update is pending approval (and the tenant service was not called)
+		location := rw.Header().Get("Location")
+		require.True(t, strings.Contains(location, "/api/tenants/update/requests/"), location)
+		id, err := uuid.FromString(path.Base(location))
+		require.NoError(t, err)
+		request, err := s.app.TenantUpdateRequests().Load(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RequestPending, request.Status)
+		assert.Equal(t, identity.Username, request.RequestedBy)
+		assert.Nil(t, request.ClusterURL)
+		require.NotNil(t, request.EnvType)
+		assert.Equal(t, envType, *request.EnvType)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), request.ExpiresAt, time.Minute)
+		// and an audit record was created
+		assertAuditLog(t, s.DB, *identity, auditlog.RequestTenantUpdate, auditlog.EventParams{
+			"request_id": id.String(),
+			"envType":    envType,
+			"expires_at": request.ExpiresAt.UTC().Format(time.RFC3339),
+		})
+	})
+
+	s.T().Run("single cluster", func(t *testing.T) {
+		// given
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		tk := goajwt.ContextJWT(ctx)
+		require.NotNil(t, tk)
+		authzHeader := fmt.Sprintf("Bearer %s", tk.Raw)
+		cluster := "cluster1"
+		gock.New("http://test-tenant").
+			Post("/api/update").
+			MatchHeader("Authorization", authzHeader).
+			MatchParam("cluster_url", cluster).
+			Reply(http.StatusAccepted).BodyString(`{"data":"whatever"}`)
+		// when
+		rw := apptest.StartTenantUpdateAccepted(t, ctx, svc, ctrl, &cluster, nil, &authzHeader)
+		// then the update was started without approval
+		assert.Empty(t, rw.Header().Get("Location"))
+		assert.True(t, gock.IsDone())
+		assertAuditLog(t, s.DB, *identity, auditlog.StartTenantUpdate, auditlog.EventParams{
+			"clusterURL": cluster,
+		})
+		job := lastTenantUpdateJob(t, s.app)
+		assert.Equal(t, identity.Username, job.StartedBy)
+		require.NotNil(t, job.ClusterURL)
+		assert.Equal(t, cluster, *job.ClusterURL)
+	})
+}
+
+func (s *TenantUpdateControllerBlackboxTestSuite) TestStopTenantUpdate() {
+	// given
+	config := testconfig.NewTenantUpdateControllerConfigurationMock(s.T())
 	svc, ctrl := newTenantUpdateController(config, s.app)
 	defer gock.OffAll()
 
@@ -300,6 +468,12 @@ func (s *TenantUpdateControllerBlackboxTestSuite) TestStopTenantUpdate() {
 		tk := goajwt.ContextJWT(ctx)
 		require.NotNil(t, tk)
 		authzHeader := fmt.Sprintf("Bearer %s", tk.Raw)
+		job := tenantupdate.Job{
+			Status:    tenant.StatusUpdating,
+			StartedBy: "starter",
+		}
+		err = s.app.TenantUpdateJobs().Create(context.Background(), &job)
+		require.NoError(t, err)
 		gock.New("http://test-tenant").
 			Delete("/api/update").
 			MatchHeader("Authorization", authzHeader).
@@ -308,6 +482,11 @@ func (s *TenantUpdateControllerBlackboxTestSuite) TestStopTenantUpdate() {
 		apptest.StopTenantUpdateAccepted(t, ctx, svc, ctrl, &authzHeader)
 		// then check that an audit record was created
 		assertAuditLog(t, s.DB, *identity, auditlog.StopTenantUpdate, auditlog.EventParams{})
+		// and that the stop request was recorded
+		result, err := s.app.TenantUpdateJobs().Load(context.Background(), job.ID)
+		require.NoError(t, err)
+		require.NotNil(t, result.StopRequestedBy)
+		assert.Equal(t, identity.Username, *result.StopRequestedBy)
 	})
 
 	s.T().Run("failures", func(t *testing.T) {
@@ -358,6 +537,14 @@ func (s *TenantUpdateControllerBlackboxTestSuite) TestStopTenantUpdate() {
 	})
 }
 
+// lastTenantUpdateJob returns the most recent tenant update job
+func lastTenantUpdateJob(t *testing.T, db application.DB) tenantupdate.Job {
+	jobs, _, err := db.TenantUpdateJobs().List(context.Background(), 0, 1)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	return jobs[0]
+}
+
 func assertAuditLog(t *testing.T, db *gorm.DB, identity testauth.Identity, expectedEventType uuid.UUID, expectedQueryParams auditlog.EventParams) {
 	recordRepo := auditlog.NewRepository(db)
 	// check events by identity ID
@@ -375,3 +562,16 @@ func assertAuditLog(t *testing.T, db *gorm.DB, identity testauth.Identity, expec
 	assert.Equal(t, expectedEventType, record.EventTypeID)
 	assert.Equal(t, expectedQueryParams, record.EventParams)
 }
+
+// assertAuditLogOutcome verifies the outcome of the (single) audit log record of the given identity
+func assertAuditLogOutcome(t *testing.T, db *gorm.DB, identity testauth.Identity, expectedStatusCode int, expectedOutcome auditlog.Outcome) {
+	records, total, err := auditlog.NewRepository(db).ListByIdentityID(context.Background(), identity.ID, 0, 5)
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	record := records[0]
+	require.NotNil(t, record.StatusCode)
+	assert.Equal(t, expectedStatusCode, *record.StatusCode)
+	assert.Equal(t, expectedOutcome, record.Outcome)
+	require.NotNil(t, record.LatencyMs)
+	assert.True(t, *record.LatencyMs >= 0)
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"github.com/fabric8-services/admin-console/app"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/fabric8-common/httpsupport"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+// TenantUpdateJobsController implements the tenant_update_job resource.
+type TenantUpdateJobsController struct {
+	*goa.Controller
+	db     application.DB
+	config httpsupport.Configuration
+}
+
+// NewTenantUpdateJobsController creates a tenant_update_job controller.
+func NewTenantUpdateJobsController(service *goa.Service, config httpsupport.Configuration, db application.DB) *TenantUpdateJobsController {
+	return &TenantUpdateJobsController{
+		Controller: service.NewController("TenantUpdateJobsController"),
+		config:     config,
+		db:         db,
+	}
+}
+
+// List lists the tenant update jobs, most recent first
+func (c *TenantUpdateJobsController) List(ctx *app.ListTenantUpdateJobContext) error {
+	pageNumber, pageSize := computePagingLimits(ctx.PageNumber, ctx.PageSize)
+	jobs, total, err := c.db.TenantUpdateJobs().List(ctx, pageNumber*pageSize, pageSize)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the tenant update jobs")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	response := &app.TenantUpdateJobList{
+		Data:  convertTenantUpdateJobsData(ctx.RequestData, jobs, c.config),
+		Links: &app.PagingLinks{},
+		Meta: &app.UserListMeta{
+			TotalCount: &total,
+		},
+	}
+	path := httpsupport.AbsoluteURL(ctx.RequestData, ctx.Request.URL.Path, c.config)
+	setPagingLinks(response.Links, path, len(jobs), pageNumber, pageSize, total)
+	return ctx.OK(response)
+}
+
+// Show shows a tenant update job
+func (c *TenantUpdateJobsController) Show(ctx *app.ShowTenantUpdateJobContext) error {
+	job, err := c.db.TenantUpdateJobs().Load(ctx, uuid.UUID(ctx.ID))
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TenantUpdateJobSingle{
+		Data: convertTenantUpdateJobData(ctx.RequestData, job, c.config),
+	})
+}
+
+// convertTenantUpdateJobsData converts the tenant update jobs to their resource-API data counterpart
+func convertTenantUpdateJobsData(req *goa.RequestData, jobs []tenantupdate.Job, config httpsupport.Configuration) []*app.TenantUpdateJobData {
+	data := make([]*app.TenantUpdateJobData, len(jobs))
+	for i, j := range jobs {
+		data[i] = convertTenantUpdateJobData(req, j, config)
+	}
+	return data
+}
+
+// convertTenantUpdateJobData converts the tenant update job to its resource-API data counterpart
+func convertTenantUpdateJobData(req *goa.RequestData, j tenantupdate.Job, config httpsupport.Configuration) *app.TenantUpdateJobData {
+	self := httpsupport.AbsoluteURL(req, app.TenantUpdateJobHref(j.ID.String()), config)
+	return &app.TenantUpdateJobData{
+		Type: "tenant_updates",
+		ID:   j.ID.String(),
+		Attributes: &app.TenantUpdateJobDataAttributes{
+			ClusterURL:      j.ClusterURL,
+			EnvType:         j.EnvType,
+			Status:          string(j.Status),
+			StartedBy:       j.StartedBy,
+			StartedAt:       j.StartedAt,
+			StopRequestedBy: j.StopRequestedBy,
+			StopRequestedAt: j.StopRequestedAt,
+			FinishedAt:      j.FinishedAt,
+			Duration:        int(j.Duration().Seconds()),
+			FailedCount:     j.FailedCount,
+			RefreshedAt:     j.RefreshedAt,
+		},
+		Links: &app.GenericLinks{
+			Self: &self,
+		},
+	}
+}
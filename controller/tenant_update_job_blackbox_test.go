@@ -0,0 +1,119 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	apptest "github.com/fabric8-services/admin-console/app/test"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/controller"
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testauth "github.com/fabric8-services/fabric8-common/test/auth"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	"github.com/goadesign/goa"
+	goauuid "github.com/goadesign/goa/uuid"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TenantUpdateJobsControllerBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	app    *application.GormApplication
+	config *configuration.Configuration
+}
+
+func TestTenantUpdateJobs(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &TenantUpdateJobsControllerBlackboxTestSuite{
+		DBTestSuite: testsuite.NewDBTestSuite(config),
+		config:      config,
+	})
+}
+
+func (s *TenantUpdateJobsControllerBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.app = application.NewGormApplication(s.DB)
+}
+
+// newTenantUpdateJob records a tenant update job which is the most recent one, and which failed after 10 minutes
+func (s *TenantUpdateJobsControllerBlackboxTestSuite) newTenantUpdateJob(t *testing.T) tenantupdate.Job {
+	envType := "jenkins"
+	job := tenantupdate.Job{
+		EnvType:   &envType,
+		Status:    tenant.StatusUpdating,
+		StartedBy: fmt.Sprintf("user-%s", uuid.NewV4()),
+		StartedAt: time.Now(),
+	}
+	err := s.app.TenantUpdateJobs().Create(context.Background(), &job)
+	require.NoError(t, err)
+	finishedAt := job.StartedAt.Add(10 * time.Minute)
+	err = s.app.TenantUpdateJobs().Refresh(context.Background(), job.ID, tenant.Update{
+		Status:          tenant.StatusFailed,
+		FailedCount:     2,
+		LastTimeUpdated: &finishedAt,
+	})
+	require.NoError(t, err)
+	return job
+}
+
+func (s *TenantUpdateJobsControllerBlackboxTestSuite) TestListTenantUpdateJobs() {
+	// given
+	svc := goa.New("tenant_update_jobs")
+	ctrl := controller.NewTenantUpdateJobsController(svc, s.config, s.app)
+	job := s.newTenantUpdateJob(s.T())
+	ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+	require.NoError(s.T(), err)
+	pageNumber := 0
+	pageSize := 5
+	// when
+	_, result := apptest.ListTenantUpdateJobOK(s.T(), ctx, svc, ctrl, &pageNumber, &pageSize)
+	// then
+	require.NotEmpty(s.T(), result.Data)
+	assert.Equal(s.T(), job.ID.String(), result.Data[0].ID)
+	attrs := result.Data[0].Attributes
+	assert.Equal(s.T(), job.StartedBy, attrs.StartedBy)
+	assert.Equal(s.T(), "failed", attrs.Status)
+	assert.Equal(s.T(), 2, attrs.FailedCount)
+	assert.Equal(s.T(), 600, attrs.Duration)
+	require.NotNil(s.T(), attrs.EnvType)
+	assert.Equal(s.T(), "jenkins", *attrs.EnvType)
+	require.NotNil(s.T(), result.Meta.TotalCount)
+	assert.True(s.T(), *result.Meta.TotalCount >= 1)
+	assert.NotNil(s.T(), result.Links.First)
+}
+
+func (s *TenantUpdateJobsControllerBlackboxTestSuite) TestShowTenantUpdateJob() {
+	// given
+	svc := goa.New("tenant_update_jobs")
+	ctrl := controller.NewTenantUpdateJobsController(svc, s.config, s.app)
+	ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+	require.NoError(s.T(), err)
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		job := s.newTenantUpdateJob(t)
+		// when
+		_, result := apptest.ShowTenantUpdateJobOK(t, ctx, svc, ctrl, goauuid.UUID(job.ID))
+		// then
+		require.NotNil(t, result.Data)
+		assert.Equal(t, job.ID.String(), result.Data.ID)
+		assert.Equal(t, job.StartedBy, result.Data.Attributes.StartedBy)
+		assert.NotNil(t, result.Data.Attributes.FinishedAt)
+		assert.Nil(t, result.Data.Attributes.StopRequestedBy)
+		assert.NotNil(t, result.Data.Links.Self)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// when/then
+		apptest.ShowTenantUpdateJobNotFound(t, ctx, svc, ctrl, goauuid.UUID(uuid.NewV4()))
+	})
+}
@@ -0,0 +1,283 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/admin-console/app"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	authsupport "github.com/fabric8-services/fabric8-common/auth"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/httpsupport"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+// expiryUsername the username of the audit log records of the requests which expired, since no user expires them
+const expiryUsername = "admin-console"
+
+// TenantUpdateRequestsController implements the tenant_update_request resource.
+type TenantUpdateRequestsController struct {
+	*goa.Controller
+	config TenantUpdateRequestsControllerConfiguration
+	db     application.DB
+	tenant tenant.Client
+}
+
+// TenantUpdateRequestsControllerConfiguration the configuration for the TenantUpdateRequestsController
+type TenantUpdateRequestsControllerConfiguration interface {
+	httpsupport.Configuration
+}
+
+// NewTenantUpdateRequestsController creates a tenant_update_request controller, which starts the approved updates by
+// calling the tenant service with the given client, and records them in the history of the tenant update jobs.
+func NewTenantUpdateRequestsController(service *goa.Service, config TenantUpdateRequestsControllerConfiguration, db application.DB, client tenant.Client) *TenantUpdateRequestsController {
+	return &TenantUpdateRequestsController{
+		Controller: service.NewController("TenantUpdateRequestsController"),
+		config:     config,
+		db:         db,
+		tenant:     client,
+	}
+}
+
+// List lists the requests to start a tenant update
+func (c *TenantUpdateRequestsController) List(ctx *app.ListTenantUpdateRequestContext) error {
+	c.expire(ctx)
+	var status *tenantupdate.RequestStatus
+	if ctx.Status != nil {
+		s := tenantupdate.RequestStatus(*ctx.Status)
+		status = &s
+	}
+	requests, err := c.db.TenantUpdateRequests().List(ctx, status)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the tenant update requests")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TenantUpdateRequestList{
+		Data: convertTenantUpdateRequestsData(ctx.RequestData, requests, c.config),
+	})
+}
+
+// Show shows a request to start a tenant update
+func (c *TenantUpdateRequestsController) Show(ctx *app.ShowTenantUpdateRequestContext) error {
+	c.expire(ctx)
+	request, err := c.db.TenantUpdateRequests().Load(ctx, uuid.UUID(ctx.ID))
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TenantUpdateRequestSingle{
+		Data: convertTenantUpdateRequestData(ctx.RequestData, request, c.config),
+	})
+}
+
+// Approve approves the pending request of another user, and starts the requested tenant update on behalf of the
+// current user. The approval is stored before the tenant service is called, so that the request cannot be approved
+// twice, and the response of the tenant service is recorded afterwards.
+func (c *TenantUpdateRequestsController) Approve(ctx *app.ApproveTenantUpdateRequestContext) error {
+	identityID, username, err := authsupport.LocateIdentity(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "invalid or missing authorization token")
+		return app.JSONErrorResponse(ctx, errors.NewUnauthorizedError("invalid or missing authorization token"))
+	}
+	c.expire(ctx)
+	request, err := c.review(ctx, uuid.UUID(ctx.ID), identityID, username, auditlog.ApproveTenantUpdate, tenantupdate.RequestApproved)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	status := tenantupdate.RequestApproved
+	statusCode, err := c.tenant.StartUpdate(ctx, authorizationHeader(ctx.Authorization), request.ClusterURL, request.EnvType)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":        err,
+			"request_id": request.ID,
+		}, "unable to start the approved tenant update")
+		status = tenantupdate.RequestFailed
+	}
+	request, err = c.recordStart(ctx, request.ID, identityID, username, status, statusCode)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	if request.Status == tenantupdate.RequestApproved {
+		// the update is recorded on behalf of the user who requested it
+		recordStartedJob(ctx, c.db, request.RequestedBy, request.ClusterURL, request.EnvType)
+	}
+	return ctx.OK(&app.TenantUpdateRequestSingle{
+		Data: convertTenantUpdateRequestData(ctx.RequestData, request, c.config),
+	})
+}
+
+// Reject rejects the pending request of another user
+func (c *TenantUpdateRequestsController) Reject(ctx *app.RejectTenantUpdateRequestContext) error {
+	identityID, username, err := authsupport.LocateIdentity(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "invalid or missing authorization token")
+		return app.JSONErrorResponse(ctx, errors.NewUnauthorizedError("invalid or missing authorization token"))
+	}
+	c.expire(ctx)
+	request, err := c.review(ctx, uuid.UUID(ctx.ID), identityID, username, auditlog.RejectTenantUpdate, tenantupdate.RequestRejected)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TenantUpdateRequestSingle{
+		Data: convertTenantUpdateRequestData(ctx.RequestData, request, c.config),
+	})
+}
+
+// review stores the given outcome of the review of the request with the given ID on behalf of the current user, and
+// logs an audit log with the given event type for her action in the same transaction
+func (c *TenantUpdateRequestsController) review(ctx context.Context, id uuid.UUID, identityID uuid.UUID, username string, eventTypeID uuid.UUID, status tenantupdate.RequestStatus) (tenantupdate.Request, error) {
+	var request tenantupdate.Request
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		request, err = appl.TenantUpdateRequests().Review(ctx, id, username, status)
+		if err != nil {
+			return err
+		}
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: eventTypeID,
+			IdentityID:  identityID,
+			Username:    username,
+			EventParams: requestEventParams(request),
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":         err,
+			"request_id":  id,
+			"reviewed_by": username,
+		}, "unable to review the tenant update request")
+		return tenantupdate.Request{}, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"request_id":  id,
+		"reviewed_by": username,
+		"status":      request.Status,
+	}, "reviewed tenant update request")
+	return request, nil
+}
+
+// recordStart stores the response of the tenant service when the update of the approved request with the given ID was
+// started on behalf of the current user, and logs an audit log for her action in the same transaction
+func (c *TenantUpdateRequestsController) recordStart(ctx context.Context, id uuid.UUID, identityID uuid.UUID, username string, status tenantupdate.RequestStatus, statusCode int) (tenantupdate.Request, error) {
+	var request tenantupdate.Request
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		request, err = appl.TenantUpdateRequests().RecordStart(ctx, id, status, statusCode)
+		if err != nil {
+			return err
+		}
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: auditlog.StartTenantUpdate,
+			IdentityID:  identityID,
+			Username:    username,
+			EventParams: requestEventParams(request),
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":         err,
+			"request_id":  id,
+			"status_code": statusCode,
+		}, "unable to record the start of the approved tenant update")
+		return tenantupdate.Request{}, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"request_id":  id,
+		"started_by":  username,
+		"status":      request.Status,
+		"status_code": statusCode,
+	}, "started approved tenant update")
+	return request, nil
+}
+
+// requestEventParams returns the parameters of the audit logs of the actions on the given request
+func requestEventParams(request tenantupdate.Request) auditlog.EventParams {
+	eventParams := auditlog.EventParams{
+		"request_id":   request.ID.String(),
+		"requested_by": request.RequestedBy,
+		"status":       string(request.Status),
+	}
+	if request.ClusterURL != nil {
+		eventParams["clusterURL"] = *request.ClusterURL
+	}
+	if request.EnvType != nil {
+		eventParams["envType"] = *request.EnvType
+	}
+	if request.StatusCode != nil {
+		eventParams["status_code"] = *request.StatusCode
+	}
+	return eventParams
+}
+
+// expire marks the pending requests which expired as such, and logs an audit log for each of them in the same
+// transaction. Failures are only logged, since the expired requests cannot be approved anyway.
+func (c *TenantUpdateRequestsController) expire(ctx context.Context) {
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		requests, err := appl.TenantUpdateRequests().Expire(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		for _, request := range requests {
+			err := appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+				EventTypeID: auditlog.ExpireTenantUpdateRequest,
+				Username:    expiryUsername,
+				EventParams: auditlog.EventParams{
+					"request_id":   request.ID.String(),
+					"requested_by": request.RequestedBy,
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to expire the tenant update requests")
+	}
+}
+
+// convertTenantUpdateRequestsData converts the requests to their resource-API data counterpart
+func convertTenantUpdateRequestsData(req *goa.RequestData, requests []tenantupdate.Request, config httpsupport.Configuration) []*app.TenantUpdateRequestData {
+	data := make([]*app.TenantUpdateRequestData, len(requests))
+	for i, r := range requests {
+		data[i] = convertTenantUpdateRequestData(req, r, config)
+	}
+	return data
+}
+
+// convertTenantUpdateRequestData converts the request to its resource-API data counterpart
+func convertTenantUpdateRequestData(req *goa.RequestData, r tenantupdate.Request, config httpsupport.Configuration) *app.TenantUpdateRequestData {
+	self := httpsupport.AbsoluteURL(req, app.TenantUpdateRequestHref(r.ID.String()), config)
+	return &app.TenantUpdateRequestData{
+		Type: "tenant_update_requests",
+		ID:   r.ID.String(),
+		Attributes: &app.TenantUpdateRequestDataAttributes{
+			ClusterURL:  r.ClusterURL,
+			EnvType:     r.EnvType,
+			Status:      string(r.Status),
+			RequestedBy: r.RequestedBy,
+			RequestedAt: r.RequestedAt,
+			ExpiresAt:   r.ExpiresAt,
+			ReviewedBy:  r.ReviewedBy,
+			ReviewedAt:  r.ReviewedAt,
+			StatusCode:  r.StatusCode,
+		},
+		Links: &app.GenericLinks{
+			Self: &self,
+		},
+	}
+}
@@ -0,0 +1,275 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	apptest "github.com/fabric8-services/admin-console/app/test"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/controller"
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testauth "github.com/fabric8-services/fabric8-common/test/auth"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	goauuid "github.com/goadesign/goa/uuid"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	gock "gopkg.in/h2non/gock.v1"
+)
+
+type TenantUpdateRequestsControllerBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	app    *application.GormApplication
+	config *configuration.Configuration
+}
+
+func TestTenantUpdateRequests(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &TenantUpdateRequestsControllerBlackboxTestSuite{
+		DBTestSuite: testsuite.NewDBTestSuite(config),
+		config:      config,
+	})
+}
+
+func (s *TenantUpdateRequestsControllerBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.app = application.NewGormApplication(s.DB)
+}
+
+func (s *TenantUpdateRequestsControllerBlackboxTestSuite) newTenantUpdateRequestsController() (*goa.Service, *controller.TenantUpdateRequestsController) {
+	svc := goa.New("tenant_update_requests")
+	ctrl := controller.NewTenantUpdateRequestsController(svc, s.config, s.app, tenant.NewClient(tenantClientConfig{}, http.DefaultClient))
+	return svc, ctrl
+}
+
+// newTenantUpdateRequest stores a request of another user to start a tenant update on a new cluster, which expires
+// after the given delay
+func (s *TenantUpdateRequestsControllerBlackboxTestSuite) newTenantUpdateRequest(t *testing.T, expiry time.Duration) tenantupdate.Request {
+	clusterURL := fmt.Sprintf("https://cluster-%s.example.com", uuid.NewV4())
+	request := tenantupdate.Request{
+		ClusterURL:  &clusterURL,
+		RequestedBy: fmt.Sprintf("user-%s", uuid.NewV4()),
+		ExpiresAt:   time.Now().Add(expiry),
+	}
+	err := s.app.TenantUpdateRequests().Create(context.Background(), &request)
+	require.NoError(t, err)
+	return request
+}
+
+// assertApprovalAuditLogs verifies the audit log records of the user who approved the given request: the approval,
+// followed by the start of the update, with the given status of the request and of the response of the tenant service
+func assertApprovalAuditLogs(t *testing.T, db *gorm.DB, identity testauth.Identity, request tenantupdate.Request, expectedStatus string, expectedStatusCode int) {
+	records, total, err := auditlog.NewRepository(db).ListByIdentityID(context.Background(), identity.ID, 0, 5)
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	assert.Equal(t, auditlog.ApproveTenantUpdate, records[0].EventTypeID)
+	assert.Equal(t, auditlog.EventParams{
+		"request_id":   request.ID.String(),
+		"requested_by": request.RequestedBy,
+		"status":       "approved",
+		"clusterURL":   *request.ClusterURL,
+	}, records[0].EventParams)
+	assert.Equal(t, auditlog.StartTenantUpdate, records[1].EventTypeID)
+	assert.Equal(t, auditlog.EventParams{
+		"request_id":   request.ID.String(),
+		"requested_by": request.RequestedBy,
+		"status":       expectedStatus,
+		"clusterURL":   *request.ClusterURL,
+		"status_code":  float64(expectedStatusCode),
+	}, records[1].EventParams)
+}
+
+func (s *TenantUpdateRequestsControllerBlackboxTestSuite) TestApproveTenantUpdateRequest() {
+	// given
+	svc, ctrl := s.newTenantUpdateRequestsController()
+	defer gock.OffAll()
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		request := s.newTenantUpdateRequest(t, time.Hour)
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		tk := goajwt.ContextJWT(ctx)
+		require.NotNil(t, tk)
+		authzHeader := fmt.Sprintf("Bearer %s", tk.Raw)
+		gock.New("http://test-tenant").
+			Post("/api/update").
+			MatchHeader("Authorization", regexp.QuoteMeta(authzHeader)).
+			MatchParam("cluster_url", regexp.QuoteMeta(*request.ClusterURL)).
+			Reply(http.StatusAccepted)
+		// when
+		_, result := apptest.ApproveTenantUpdateRequestOK(t, ctx, svc, ctrl, goauuid.UUID(request.ID), &authzHeader)
+		// then
+		assert.True(t, gock.IsDone())
+		attrs := result.Data.Attributes
+		assert.Equal(t, "approved", attrs.Status)
+		require.NotNil(t, attrs.ReviewedBy)
+		assert.Equal(t, identity.Username, *attrs.ReviewedBy)
+		require.NotNil(t, attrs.StatusCode)
+		assert.Equal(t, http.StatusAccepted, *attrs.StatusCode)
+		assertApprovalAuditLogs(t, s.DB, *identity, request, "approved", http.StatusAccepted)
+		// and the job was recorded on behalf of the user who requested the update
+		job := lastTenantUpdateJob(t, s.app)
+		assert.Equal(t, request.RequestedBy, job.StartedBy)
+		require.NotNil(t, job.ClusterURL)
+		assert.Equal(t, *request.ClusterURL, *job.ClusterURL)
+	})
+
+	s.T().Run("update not accepted", func(t *testing.T) {
+		// given
+		request := s.newTenantUpdateRequest(t, time.Hour)
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		tk := goajwt.ContextJWT(ctx)
+		require.NotNil(t, tk)
+		authzHeader := fmt.Sprintf("Bearer %s", tk.Raw)
+		gock.New("http://test-tenant").
+			Post("/api/update").
+			MatchParam("cluster_url", regexp.QuoteMeta(*request.ClusterURL)).
+			Reply(http.StatusConflict)
+		// when
+		_, result := apptest.ApproveTenantUpdateRequestOK(t, ctx, svc, ctrl, goauuid.UUID(request.ID), &authzHeader)
+		// then
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, "failed", result.Data.Attributes.Status)
+		require.NotNil(t, result.Data.Attributes.StatusCode)
+		assert.Equal(t, http.StatusConflict, *result.Data.Attributes.StatusCode)
+		assertApprovalAuditLogs(t, s.DB, *identity, request, "failed", http.StatusConflict)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("approved by the initiator", func(t *testing.T) {
+			// given
+			ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			clusterURL := "https://cluster.example.com"
+			request := tenantupdate.Request{
+				ClusterURL:  &clusterURL,
+				RequestedBy: identity.Username,
+				ExpiresAt:   time.Now().Add(time.Hour),
+			}
+			err = s.app.TenantUpdateRequests().Create(context.Background(), &request)
+			require.NoError(t, err)
+			// when/then
+			apptest.ApproveTenantUpdateRequestForbidden(t, ctx, svc, ctrl, goauuid.UUID(request.ID), nil)
+		})
+
+		t.Run("already approved", func(t *testing.T) {
+			// given
+			request := s.newTenantUpdateRequest(t, time.Hour)
+			gock.New("http://test-tenant").
+				Post("/api/update").
+				MatchParam("cluster_url", regexp.QuoteMeta(*request.ClusterURL)).
+				Reply(http.StatusAccepted)
+			ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			apptest.ApproveTenantUpdateRequestOK(t, ctx, svc, ctrl, goauuid.UUID(request.ID), nil)
+			// when/then
+			apptest.ApproveTenantUpdateRequestConflict(t, ctx, svc, ctrl, goauuid.UUID(request.ID), nil)
+		})
+
+		t.Run("expired", func(t *testing.T) {
+			// given
+			request := s.newTenantUpdateRequest(t, -time.Minute)
+			ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			// when
+			apptest.ApproveTenantUpdateRequestConflict(t, ctx, svc, ctrl, goauuid.UUID(request.ID), nil)
+			// then the request was marked as expired
+			result, err := s.app.TenantUpdateRequests().Load(context.Background(), request.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tenantupdate.RequestExpired, result.Status)
+			records := []auditlog.AuditLog{}
+			err = s.DB.Where("event_type_id = ? and event_params->>'request_id' = ?", auditlog.ExpireTenantUpdateRequest, request.ID.String()).
+				Find(&records).Error
+			require.NoError(t, err)
+			assert.Len(t, records, 1)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			// given
+			ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			// when/then
+			apptest.ApproveTenantUpdateRequestNotFound(t, ctx, svc, ctrl, goauuid.NewV4(), nil)
+		})
+
+		t.Run("missing JWT", func(t *testing.T) {
+			// given
+			request := s.newTenantUpdateRequest(t, time.Hour)
+			// when/then
+			apptest.ApproveTenantUpdateRequestUnauthorized(t, context.Background(), svc, ctrl, goauuid.UUID(request.ID), nil)
+		})
+	})
+}
+
+func (s *TenantUpdateRequestsControllerBlackboxTestSuite) TestRejectTenantUpdateRequest() {
+	// given
+	svc, ctrl := s.newTenantUpdateRequestsController()
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		request := s.newTenantUpdateRequest(t, time.Hour)
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		// when
+		_, result := apptest.RejectTenantUpdateRequestOK(t, ctx, svc, ctrl, goauuid.UUID(request.ID))
+		// then
+		assert.Equal(t, "rejected", result.Data.Attributes.Status)
+		assert.Nil(t, result.Data.Attributes.StatusCode)
+		assertAuditLog(t, s.DB, *identity, auditlog.RejectTenantUpdate, auditlog.EventParams{
+			"request_id":   request.ID.String(),
+			"requested_by": request.RequestedBy,
+			"status":       "rejected",
+			"clusterURL":   *request.ClusterURL,
+		})
+		// and the request cannot be approved anymore
+		apptest.ApproveTenantUpdateRequestConflict(t, ctx, svc, ctrl, goauuid.UUID(request.ID), nil)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// given
+		ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		// when/then
+		apptest.RejectTenantUpdateRequestNotFound(t, ctx, svc, ctrl, goauuid.NewV4())
+	})
+}
+
+func (s *TenantUpdateRequestsControllerBlackboxTestSuite) TestListTenantUpdateRequests() {
+	// given
+	svc, ctrl := s.newTenantUpdateRequestsController()
+	pending := s.newTenantUpdateRequest(s.T(), time.Hour)
+	expired := s.newTenantUpdateRequest(s.T(), -time.Minute)
+	ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+	require.NoError(s.T(), err)
+	status := "pending"
+	// when
+	_, result := apptest.ListTenantUpdateRequestOK(s.T(), ctx, svc, ctrl, &status)
+	// then
+	ids := make([]string, len(result.Data))
+	for i, d := range result.Data {
+		ids[i] = d.ID
+		assert.Equal(s.T(), "pending", d.Attributes.Status)
+	}
+	assert.Contains(s.T(), ids, pending.ID.String())
+	assert.NotContains(s.T(), ids, expired.ID.String())
+	// and the expired request can be shown
+	_, show := apptest.ShowTenantUpdateRequestOK(s.T(), ctx, svc, ctrl, goauuid.UUID(expired.ID))
+	assert.Equal(s.T(), "expired", show.Data.Attributes.Status)
+}
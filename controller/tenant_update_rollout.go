@@ -0,0 +1,299 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/fabric8-services/admin-console/app"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/fabric8-common/httpsupport"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+// TenantUpdateRolloutsController implements the tenant_update_rollout resource.
+type TenantUpdateRolloutsController struct {
+	*goa.Controller
+	db     application.DB
+	config TenantUpdateRolloutsControllerConfiguration
+}
+
+// TenantUpdateRolloutsControllerConfiguration the configuration for the TenantUpdateRolloutsController
+type TenantUpdateRolloutsControllerConfiguration interface {
+	httpsupport.Configuration
+	IsTenantUpdateApprovalRequired() bool
+}
+
+// NewTenantUpdateRolloutsController creates a tenant_update_rollout controller.
+// When the approval of the tenant updates is required, the rollouts cannot have stages which are not limited to a
+// cluster, since the scheduler would start them without approval.
+func NewTenantUpdateRolloutsController(service *goa.Service, config TenantUpdateRolloutsControllerConfiguration, db application.DB) *TenantUpdateRolloutsController {
+	return &TenantUpdateRolloutsController{
+		Controller: service.NewController("TenantUpdateRolloutsController"),
+		config:     config,
+		db:         db,
+	}
+}
+
+// List lists the staged rollouts of the tenant updates
+func (c *TenantUpdateRolloutsController) List(ctx *app.ListTenantUpdateRolloutContext) error {
+	rollouts, err := c.db.TenantUpdateRollouts().List(ctx, ctx.Active)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the tenant update rollouts")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TenantUpdateRolloutList{
+		Data: convertTenantUpdateRolloutsData(ctx.RequestData, rollouts, c.config),
+	})
+}
+
+// Show shows a staged rollout of a tenant update
+func (c *TenantUpdateRolloutsController) Show(ctx *app.ShowTenantUpdateRolloutContext) error {
+	rollout, err := c.db.TenantUpdateRollouts().Load(ctx, uuid.UUID(ctx.ID))
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TenantUpdateRolloutSingle{
+		Data: convertTenantUpdateRolloutData(ctx.RequestData, rollout, c.config),
+	})
+}
+
+// Progress shows the progress of a staged rollout of a tenant update
+func (c *TenantUpdateRolloutsController) Progress(ctx *app.ProgressTenantUpdateRolloutContext) error {
+	progress, err := c.db.TenantUpdateRollouts().Progress(ctx, uuid.UUID(ctx.ID))
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TenantUpdateRolloutProgressSingle{
+		Data: convertTenantUpdateRolloutProgressData(ctx.RequestData, progress, c.config),
+	})
+}
+
+// Create creates a staged rollout of a tenant update, whose stages are then started by the scheduler
+func (c *TenantUpdateRolloutsController) Create(ctx *app.CreateTenantUpdateRolloutContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to identify the user creating a tenant update rollout")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	rollout := tenantupdate.Rollout{
+		CreatedBy: username,
+	}
+	for _, s := range ctx.Payload.Data.Attributes.Stages {
+		if err := checkStartableWithoutApproval(c.config.IsTenantUpdateApprovalRequired(), s.ClusterURL); err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":      err,
+				"username": username,
+			}, "unable to create a tenant update rollout whose stages must be approved")
+			return app.JSONErrorResponse(ctx, err)
+		}
+		stage := tenantupdate.Stage{
+			ClusterURL: s.ClusterURL,
+			EnvType:    s.EnvType,
+		}
+		if s.MaxFailedCount != nil {
+			stage.MaxFailedCount = *s.MaxFailedCount
+		}
+		if s.Wait != nil {
+			stage.WaitSeconds = *s.Wait
+		}
+		rollout.Stages = append(rollout.Stages, stage)
+	}
+	// store the rollout and log an audit log for the current user for her action in the same transaction
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		if err := appl.TenantUpdateRollouts().Create(ctx, &rollout); err != nil {
+			return err
+		}
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: auditlog.CreateTenantUpdateRollout,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"rollout_id": rollout.ID.String(),
+				"stages":     len(rollout.Stages),
+			},
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to create the tenant update rollout")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"rollout_id": rollout.ID,
+		"created_by": username,
+		"stages":     len(rollout.Stages),
+	}, "created new tenant update rollout")
+	data := convertTenantUpdateRolloutData(ctx.RequestData, rollout, c.config)
+	ctx.ResponseData.Header().Set("Location", *data.Links.Self)
+	return ctx.Created(&app.TenantUpdateRolloutSingle{
+		Data: data,
+	})
+}
+
+// Pause pauses a running rollout
+func (c *TenantUpdateRolloutsController) Pause(ctx *app.PauseTenantUpdateRolloutContext) error {
+	rollout, err := c.transition(ctx, uuid.UUID(ctx.ID), auditlog.PauseTenantUpdateRollout, tenantupdate.RolloutRepository.Pause)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TenantUpdateRolloutSingle{
+		Data: convertTenantUpdateRolloutData(ctx.RequestData, rollout, c.config),
+	})
+}
+
+// Resume resumes a paused rollout
+func (c *TenantUpdateRolloutsController) Resume(ctx *app.ResumeTenantUpdateRolloutContext) error {
+	rollout, err := c.transition(ctx, uuid.UUID(ctx.ID), auditlog.ResumeTenantUpdateRollout, tenantupdate.RolloutRepository.Resume)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TenantUpdateRolloutSingle{
+		Data: convertTenantUpdateRolloutData(ctx.RequestData, rollout, c.config),
+	})
+}
+
+// Abort aborts a running or paused rollout
+func (c *TenantUpdateRolloutsController) Abort(ctx *app.AbortTenantUpdateRolloutContext) error {
+	rollout, err := c.transition(ctx, uuid.UUID(ctx.ID), auditlog.AbortTenantUpdateRollout, tenantupdate.RolloutRepository.Abort)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TenantUpdateRolloutSingle{
+		Data: convertTenantUpdateRolloutData(ctx.RequestData, rollout, c.config),
+	})
+}
+
+// transitionFunc changes the status of the rollout with the given ID on behalf of the user with the given username
+type transitionFunc func(repo tenantupdate.RolloutRepository, ctx context.Context, id uuid.UUID, username string) (tenantupdate.Rollout, error)
+
+// transition changes the status of the rollout with the given ID on behalf of the current user with the given function,
+// and logs an audit log with the given event type for her action in the same transaction
+func (c *TenantUpdateRolloutsController) transition(ctx context.Context, id uuid.UUID, eventTypeID uuid.UUID, change transitionFunc) (tenantupdate.Rollout, error) {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to identify the user changing a tenant update rollout")
+		return tenantupdate.Rollout{}, err
+	}
+	var rollout tenantupdate.Rollout
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		var err error
+		rollout, err = change(appl.TenantUpdateRollouts(), ctx, id, username)
+		if err != nil {
+			return err
+		}
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: eventTypeID,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"rollout_id": id.String(),
+				"stage":      rollout.CurrentStage,
+			},
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":        err,
+			"rollout_id": id,
+		}, "unable to change the tenant update rollout")
+		return tenantupdate.Rollout{}, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"rollout_id": id,
+		"username":   username,
+		"status":     rollout.Status,
+	}, "changed tenant update rollout")
+	return rollout, nil
+}
+
+// convertTenantUpdateRolloutsData converts the rollouts to their resource-API data counterpart
+func convertTenantUpdateRolloutsData(req *goa.RequestData, rollouts []tenantupdate.Rollout, config httpsupport.Configuration) []*app.TenantUpdateRolloutData {
+	data := make([]*app.TenantUpdateRolloutData, len(rollouts))
+	for i, r := range rollouts {
+		data[i] = convertTenantUpdateRolloutData(req, r, config)
+	}
+	return data
+}
+
+// convertTenantUpdateRolloutData converts the rollout to its resource-API data counterpart
+func convertTenantUpdateRolloutData(req *goa.RequestData, r tenantupdate.Rollout, config httpsupport.Configuration) *app.TenantUpdateRolloutData {
+	self := httpsupport.AbsoluteURL(req, app.TenantUpdateRolloutHref(r.ID.String()), config)
+	stages := make([]*app.TenantUpdateRolloutStage, len(r.Stages))
+	for i, s := range r.Stages {
+		stages[i] = &app.TenantUpdateRolloutStage{
+			Position:       s.Position,
+			ClusterURL:     s.ClusterURL,
+			EnvType:        s.EnvType,
+			MaxFailedCount: s.MaxFailedCount,
+			Wait:           s.WaitSeconds,
+			Status:         string(s.Status),
+			StatusCode:     s.StatusCode,
+			StartedAt:      s.StartedAt,
+			FinishedAt:     s.FinishedAt,
+			FailedCount:    s.FailedCount,
+		}
+		if s.JobID != nil {
+			jobID := s.JobID.String()
+			stages[i].JobID = &jobID
+		}
+	}
+	return &app.TenantUpdateRolloutData{
+		Type: "tenant_update_rollouts",
+		ID:   r.ID.String(),
+		Attributes: &app.TenantUpdateRolloutDataAttributes{
+			Status:       string(r.Status),
+			CurrentStage: r.CurrentStage,
+			Reason:       r.Reason,
+			CreatedBy:    r.CreatedBy,
+			CreatedAt:    r.CreatedAt,
+			PausedBy:     r.PausedBy,
+			PausedAt:     r.PausedAt,
+			AbortedBy:    r.AbortedBy,
+			AbortedAt:    r.AbortedAt,
+			FinishedAt:   r.FinishedAt,
+			Stages:       stages,
+		},
+		Links: &app.GenericLinks{
+			Self: &self,
+		},
+	}
+}
+
+// convertTenantUpdateRolloutProgressData converts the progress of a rollout to its resource-API data counterpart
+func convertTenantUpdateRolloutProgressData(req *goa.RequestData, p tenantupdate.Progress, config httpsupport.Configuration) *app.TenantUpdateRolloutProgressData {
+	self := httpsupport.AbsoluteURL(req, app.TenantUpdateRolloutHref(p.Rollout.ID.String())+"/progress", config)
+	attrs := &app.TenantUpdateRolloutProgressDataAttributes{
+		Status:             string(p.Rollout.Status),
+		TotalStages:        len(p.Rollout.Stages),
+		SucceededStages:    p.SucceededStages,
+		CurrentStage:       p.Rollout.CurrentStage,
+		CurrentStageStatus: string(p.Rollout.Stages[p.Rollout.CurrentStage].Status),
+		FailedCount:        p.FailedCount,
+		Reason:             p.Rollout.Reason,
+	}
+	if p.CurrentJob != nil {
+		jobID := p.CurrentJob.ID.String()
+		jobStatus := string(p.CurrentJob.Status)
+		attrs.CurrentJobID = &jobID
+		attrs.CurrentJobStatus = &jobStatus
+	}
+	return &app.TenantUpdateRolloutProgressData{
+		Type:       "tenant_update_rollout_progress",
+		ID:         p.Rollout.ID.String(),
+		Attributes: attrs,
+		Links: &app.GenericLinks{
+			Self: &self,
+		},
+	}
+}
@@ -0,0 +1,334 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/app"
+	apptest "github.com/fabric8-services/admin-console/app/test"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/controller"
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testauth "github.com/fabric8-services/fabric8-common/test/auth"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	"github.com/goadesign/goa"
+	goauuid "github.com/goadesign/goa/uuid"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TenantUpdateRolloutsControllerBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	app    *application.GormApplication
+	config *configuration.Configuration
+}
+
+func TestTenantUpdateRollouts(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &TenantUpdateRolloutsControllerBlackboxTestSuite{
+		DBTestSuite: testsuite.NewDBTestSuite(config),
+		config:      config,
+	})
+}
+
+func (s *TenantUpdateRolloutsControllerBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.app = application.NewGormApplication(s.DB)
+}
+
+// newCreateTenantUpdateRolloutPayload returns a payload with a first stage on a new cluster, which allows 2 failed
+// tenants, followed by a stage on all the clusters
+func newCreateTenantUpdateRolloutPayload() *app.CreateTenantUpdateRollout {
+	clusterURL := fmt.Sprintf("https://cluster-%s.example.com", uuid.NewV4())
+	maxFailedCount := 2
+	wait := 60
+	return &app.CreateTenantUpdateRollout{
+		Data: &app.CreateTenantUpdateRolloutData{
+			Type: "tenant_update_rollouts",
+			Attributes: &app.CreateTenantUpdateRolloutDataAttributes{
+				Stages: []*app.CreateTenantUpdateRolloutStage{
+					{
+						ClusterURL:     &clusterURL,
+						MaxFailedCount: &maxFailedCount,
+						Wait:           &wait,
+					},
+					{},
+				},
+			},
+		},
+	}
+}
+
+func (s *TenantUpdateRolloutsControllerBlackboxTestSuite) TestCreateTenantUpdateRollout() {
+	// given
+	svc := goa.New("tenant_update_rollouts")
+	ctrl := controller.NewTenantUpdateRolloutsController(svc, s.config, s.app)
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		payload := newCreateTenantUpdateRolloutPayload()
+		// when
+		rw, result := apptest.CreateTenantUpdateRolloutCreated(t, ctx, svc, ctrl, payload)
+		// then
+		require.NotNil(t, result.Data)
+		attrs := result.Data.Attributes
+		assert.Equal(t, "running", attrs.Status)
+		assert.Equal(t, 0, attrs.CurrentStage)
+		assert.Equal(t, identity.Username, attrs.CreatedBy)
+		require.Len(t, attrs.Stages, 2)
+		assert.Equal(t, payload.Data.Attributes.Stages[0].ClusterURL, attrs.Stages[0].ClusterURL)
+		assert.Equal(t, 2, attrs.Stages[0].MaxFailedCount)
+		assert.Equal(t, 60, attrs.Stages[0].Wait)
+		assert.Equal(t, "pending", attrs.Stages[0].Status)
+		assert.Nil(t, attrs.Stages[1].ClusterURL)
+		assert.Equal(t, 0, attrs.Stages[1].MaxFailedCount)
+		assert.Equal(t, *result.Data.Links.Self, rw.Header().Get("Location"))
+		assertAuditLog(t, s.DB, *identity, auditlog.CreateTenantUpdateRollout, auditlog.EventParams{
+			"rollout_id": result.Data.ID,
+			"stages":     float64(2),
+		})
+		// verify that the new rollout can be retrieved
+		id, err := goauuid.FromString(result.Data.ID)
+		require.NoError(t, err)
+		_, show := apptest.ShowTenantUpdateRolloutOK(t, ctx, svc, ctrl, id)
+		assert.Len(t, show.Data.Attributes.Stages, 2)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("negative wait", func(t *testing.T) {
+			// given
+			ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			payload := newCreateTenantUpdateRolloutPayload()
+			wait := -1
+			payload.Data.Attributes.Stages[1].Wait = &wait
+			// when/then
+			apptest.CreateTenantUpdateRolloutBadRequest(t, ctx, svc, ctrl, payload)
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// when/then
+			apptest.CreateTenantUpdateRolloutUnauthorized(t, context.Background(), svc, ctrl, newCreateTenantUpdateRolloutPayload())
+		})
+	})
+
+	s.T().Run("approval required", func(t *testing.T) {
+		// given
+		ctrl := controller.NewTenantUpdateRolloutsController(svc, approvalRequiredConfig{s.config}, s.app)
+
+		t.Run("stages limited to a cluster", func(t *testing.T) {
+			// given
+			ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			payload := newCreateTenantUpdateRolloutPayload()
+			clusterURL := fmt.Sprintf("https://cluster-%s.example.com", uuid.NewV4())
+			payload.Data.Attributes.Stages[1].ClusterURL = &clusterURL
+			// when
+			_, result := apptest.CreateTenantUpdateRolloutCreated(t, ctx, svc, ctrl, payload)
+			// then
+			assert.Len(t, result.Data.Attributes.Stages, 2)
+		})
+
+		t.Run("stage on all the clusters", func(t *testing.T) {
+			// given
+			ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			// when
+			apptest.CreateTenantUpdateRolloutForbidden(t, ctx, svc, ctrl, newCreateTenantUpdateRolloutPayload())
+			// then the rollout was not created, so the scheduler cannot start its stages without approval
+			rollouts, err := s.app.TenantUpdateRollouts().List(context.Background(), true)
+			require.NoError(t, err)
+			for _, rollout := range rollouts {
+				assert.NotEqual(t, identity.Username, rollout.CreatedBy)
+			}
+		})
+	})
+}
+
+func (s *TenantUpdateRolloutsControllerBlackboxTestSuite) TestPauseResumeAbortTenantUpdateRollout() {
+	// given
+	svc := goa.New("tenant_update_rollouts")
+	ctrl := controller.NewTenantUpdateRolloutsController(svc, s.config, s.app)
+	ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+	require.NoError(s.T(), err)
+	_, created := apptest.CreateTenantUpdateRolloutCreated(s.T(), ctx, svc, ctrl, newCreateTenantUpdateRolloutPayload())
+	id, err := goauuid.FromString(created.Data.ID)
+	require.NoError(s.T(), err)
+
+	s.T().Run("pause", func(t *testing.T) {
+		// given
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		// when
+		_, result := apptest.PauseTenantUpdateRolloutOK(t, ctx, svc, ctrl, id)
+		// then
+		assert.Equal(t, "paused", result.Data.Attributes.Status)
+		require.NotNil(t, result.Data.Attributes.PausedBy)
+		assert.Equal(t, identity.Username, *result.Data.Attributes.PausedBy)
+		assertAuditLog(t, s.DB, *identity, auditlog.PauseTenantUpdateRollout, auditlog.EventParams{
+			"rollout_id": created.Data.ID,
+			"stage":      float64(0),
+		})
+		// and the rollout cannot be paused again
+		apptest.PauseTenantUpdateRolloutConflict(t, ctx, svc, ctrl, id)
+	})
+
+	s.T().Run("resume", func(t *testing.T) {
+		// given
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		// when
+		_, result := apptest.ResumeTenantUpdateRolloutOK(t, ctx, svc, ctrl, id)
+		// then
+		assert.Equal(t, "running", result.Data.Attributes.Status)
+		assert.Nil(t, result.Data.Attributes.PausedBy)
+		assertAuditLog(t, s.DB, *identity, auditlog.ResumeTenantUpdateRollout, auditlog.EventParams{
+			"rollout_id": created.Data.ID,
+			"stage":      float64(0),
+		})
+		// and the rollout cannot be resumed again
+		apptest.ResumeTenantUpdateRolloutConflict(t, ctx, svc, ctrl, id)
+	})
+
+	s.T().Run("abort", func(t *testing.T) {
+		// given
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		// when
+		_, result := apptest.AbortTenantUpdateRolloutOK(t, ctx, svc, ctrl, id)
+		// then
+		assert.Equal(t, "aborted", result.Data.Attributes.Status)
+		require.NotNil(t, result.Data.Attributes.AbortedBy)
+		assert.Equal(t, identity.Username, *result.Data.Attributes.AbortedBy)
+		assert.NotNil(t, result.Data.Attributes.FinishedAt)
+		assertAuditLog(t, s.DB, *identity, auditlog.AbortTenantUpdateRollout, auditlog.EventParams{
+			"rollout_id": created.Data.ID,
+			"stage":      float64(0),
+		})
+		// and the rollout cannot be paused, resumed nor aborted anymore
+		apptest.PauseTenantUpdateRolloutConflict(t, ctx, svc, ctrl, id)
+		apptest.ResumeTenantUpdateRolloutConflict(t, ctx, svc, ctrl, id)
+		apptest.AbortTenantUpdateRolloutConflict(t, ctx, svc, ctrl, id)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// when/then
+		apptest.PauseTenantUpdateRolloutNotFound(t, ctx, svc, ctrl, goauuid.NewV4())
+		apptest.AbortTenantUpdateRolloutNotFound(t, ctx, svc, ctrl, goauuid.NewV4())
+	})
+}
+
+func (s *TenantUpdateRolloutsControllerBlackboxTestSuite) TestListTenantUpdateRollouts() {
+	// given
+	svc := goa.New("tenant_update_rollouts")
+	ctrl := controller.NewTenantUpdateRolloutsController(svc, s.config, s.app)
+	ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+	require.NoError(s.T(), err)
+	_, active := apptest.CreateTenantUpdateRolloutCreated(s.T(), ctx, svc, ctrl, newCreateTenantUpdateRolloutPayload())
+	_, aborted := apptest.CreateTenantUpdateRolloutCreated(s.T(), ctx, svc, ctrl, newCreateTenantUpdateRolloutPayload())
+	abortedID, err := goauuid.FromString(aborted.Data.ID)
+	require.NoError(s.T(), err)
+	apptest.AbortTenantUpdateRolloutOK(s.T(), ctx, svc, ctrl, abortedID)
+
+	s.T().Run("active only", func(t *testing.T) {
+		// when
+		_, result := apptest.ListTenantUpdateRolloutOK(t, ctx, svc, ctrl, nil)
+		// then
+		ids := tenantUpdateRolloutIDs(result)
+		assert.Contains(t, ids, active.Data.ID)
+		assert.NotContains(t, ids, aborted.Data.ID)
+	})
+
+	s.T().Run("all", func(t *testing.T) {
+		// given
+		activeOnly := false
+		// when
+		_, result := apptest.ListTenantUpdateRolloutOK(t, ctx, svc, ctrl, &activeOnly)
+		// then
+		ids := tenantUpdateRolloutIDs(result)
+		assert.Contains(t, ids, active.Data.ID)
+		assert.Contains(t, ids, aborted.Data.ID)
+	})
+}
+
+func tenantUpdateRolloutIDs(list *app.TenantUpdateRolloutList) []string {
+	ids := make([]string, len(list.Data))
+	for i, d := range list.Data {
+		ids[i] = d.ID
+	}
+	return ids
+}
+
+func (s *TenantUpdateRolloutsControllerBlackboxTestSuite) TestShowTenantUpdateRolloutProgress() {
+	// given
+	svc := goa.New("tenant_update_rollouts")
+	ctrl := controller.NewTenantUpdateRolloutsController(svc, s.config, s.app)
+	ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+	require.NoError(s.T(), err)
+
+	s.T().Run("stage running", func(t *testing.T) {
+		// given
+		_, created := apptest.CreateTenantUpdateRolloutCreated(t, ctx, svc, ctrl, newCreateTenantUpdateRolloutPayload())
+		rolloutID, err := uuid.FromString(created.Data.ID)
+		require.NoError(t, err)
+		// start the first stage, whose update has 1 failed tenant so far
+		var jobID uuid.UUID
+		err = application.Transactional(s.app, func(appl application.Application) error {
+			_, err := appl.TenantUpdateRollouts().Advance(context.Background(), time.Now(), func(r tenantupdate.Rollout, stage tenantupdate.Stage) (*uuid.UUID, int) {
+				if r.ID != rolloutID {
+					return nil, http.StatusConflict
+				}
+				job := tenantupdate.Job{
+					ClusterURL: stage.ClusterURL,
+					Status:     tenant.StatusUpdating,
+					StartedBy:  r.CreatedBy,
+				}
+				require.NoError(t, appl.TenantUpdateJobs().Create(context.Background(), &job))
+				jobID = job.ID
+				return &job.ID, http.StatusAccepted
+			})
+			return err
+		})
+		require.NoError(t, err)
+		err = s.app.TenantUpdateJobs().Refresh(context.Background(), jobID, tenant.Update{
+			Status:      tenant.StatusUpdating,
+			FailedCount: 1,
+		})
+		require.NoError(t, err)
+		id, err := goauuid.FromString(created.Data.ID)
+		require.NoError(t, err)
+		// when
+		_, result := apptest.ProgressTenantUpdateRolloutOK(t, ctx, svc, ctrl, id)
+		// then
+		attrs := result.Data.Attributes
+		assert.Equal(t, "running", attrs.Status)
+		assert.Equal(t, 2, attrs.TotalStages)
+		assert.Equal(t, 0, attrs.SucceededStages)
+		assert.Equal(t, 0, attrs.CurrentStage)
+		assert.Equal(t, "running", attrs.CurrentStageStatus)
+		require.NotNil(t, attrs.CurrentJobID)
+		assert.Equal(t, jobID.String(), *attrs.CurrentJobID)
+		require.NotNil(t, attrs.CurrentJobStatus)
+		assert.Equal(t, "updating", *attrs.CurrentJobStatus)
+		assert.Equal(t, 1, attrs.FailedCount)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// when/then
+		apptest.ProgressTenantUpdateRolloutNotFound(t, ctx, svc, ctrl, goauuid.NewV4())
+	})
+}
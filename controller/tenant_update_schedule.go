@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/fabric8-services/admin-console/app"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/fabric8-common/httpsupport"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+// TenantUpdateSchedulesController implements the tenant_update_schedule resource.
+type TenantUpdateSchedulesController struct {
+	*goa.Controller
+	db     application.DB
+	config TenantUpdateSchedulesControllerConfiguration
+}
+
+// TenantUpdateSchedulesControllerConfiguration the configuration for the TenantUpdateSchedulesController
+type TenantUpdateSchedulesControllerConfiguration interface {
+	httpsupport.Configuration
+	IsTenantUpdateApprovalRequired() bool
+}
+
+// NewTenantUpdateSchedulesController creates a tenant_update_schedule controller.
+// When the approval of the tenant updates is required, the updates which are not limited to a cluster cannot be
+// scheduled, since the scheduler would start them without approval.
+func NewTenantUpdateSchedulesController(service *goa.Service, config TenantUpdateSchedulesControllerConfiguration, db application.DB) *TenantUpdateSchedulesController {
+	return &TenantUpdateSchedulesController{
+		Controller: service.NewController("TenantUpdateSchedulesController"),
+		config:     config,
+		db:         db,
+	}
+}
+
+// List lists the scheduled tenant updates
+func (c *TenantUpdateSchedulesController) List(ctx *app.ListTenantUpdateScheduleContext) error {
+	schedules, err := c.db.TenantUpdateSchedules().List(ctx, ctx.Active)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the tenant update schedules")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TenantUpdateScheduleList{
+		Data: convertTenantUpdateSchedulesData(ctx.RequestData, schedules, c.config),
+	})
+}
+
+// Show shows a scheduled tenant update
+func (c *TenantUpdateSchedulesController) Show(ctx *app.ShowTenantUpdateScheduleContext) error {
+	schedule, err := c.db.TenantUpdateSchedules().Load(ctx, uuid.UUID(ctx.ID))
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.TenantUpdateScheduleSingle{
+		Data: convertTenantUpdateScheduleData(ctx.RequestData, schedule, c.config),
+	})
+}
+
+// Create schedules a tenant update
+func (c *TenantUpdateSchedulesController) Create(ctx *app.CreateTenantUpdateScheduleContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to identify the user scheduling a tenant update")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	attrs := ctx.Payload.Data.Attributes
+	if err := checkStartableWithoutApproval(c.config.IsTenantUpdateApprovalRequired(), attrs.ClusterURL); err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"username": username,
+		}, "unable to schedule a tenant update which must be approved")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	schedule := tenantupdate.Schedule{
+		ClusterURL:     attrs.ClusterURL,
+		EnvType:        attrs.EnvType,
+		CronExpression: attrs.Cron,
+		CreatedBy:      username,
+	}
+	if attrs.Cron == nil {
+		schedule.NextRunAt = attrs.RunAt
+	}
+	// store the schedule and log an audit log for the current user for her action in the same transaction
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		if err := appl.TenantUpdateSchedules().Create(ctx, &schedule); err != nil {
+			return err
+		}
+		params := auditlog.EventParams{
+			"schedule_id": schedule.ID.String(),
+			"run_at":      schedule.NextRunAt.UTC().Format(time.RFC3339),
+		}
+		if schedule.ClusterURL != nil {
+			params["clusterURL"] = *schedule.ClusterURL
+		}
+		if schedule.EnvType != nil {
+			params["envType"] = *schedule.EnvType
+		}
+		if schedule.CronExpression != nil {
+			params["cron"] = *schedule.CronExpression
+		}
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: auditlog.CreateTenantUpdateSchedule,
+			Username:    username,
+			EventParams: params,
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to schedule the tenant update")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"schedule_id": schedule.ID,
+		"created_by":  username,
+		"next_run_at": schedule.NextRunAt,
+	}, "scheduled new tenant update")
+	data := convertTenantUpdateScheduleData(ctx.RequestData, schedule, c.config)
+	ctx.ResponseData.Header().Set("Location", *data.Links.Self)
+	return ctx.Created(&app.TenantUpdateScheduleSingle{
+		Data: data,
+	})
+}
+
+// Cancel cancels a scheduled tenant update
+func (c *TenantUpdateSchedulesController) Cancel(ctx *app.CancelTenantUpdateScheduleContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to identify the user cancelling a scheduled tenant update")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	id := uuid.UUID(ctx.ID)
+	// cancel the schedule and log an audit log for the current user for her action in the same transaction
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		if _, err := appl.TenantUpdateSchedules().Cancel(ctx, id, username); err != nil {
+			return err
+		}
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: auditlog.CancelTenantUpdateSchedule,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"schedule_id": id.String(),
+			},
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":         err,
+			"schedule_id": id,
+		}, "unable to cancel the scheduled tenant update")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// convertTenantUpdateSchedulesData converts the schedules to their resource-API data counterpart
+func convertTenantUpdateSchedulesData(req *goa.RequestData, schedules []tenantupdate.Schedule, config httpsupport.Configuration) []*app.TenantUpdateScheduleData {
+	data := make([]*app.TenantUpdateScheduleData, len(schedules))
+	for i, s := range schedules {
+		data[i] = convertTenantUpdateScheduleData(req, s, config)
+	}
+	return data
+}
+
+// convertTenantUpdateScheduleData converts the schedule to its resource-API data counterpart
+func convertTenantUpdateScheduleData(req *goa.RequestData, s tenantupdate.Schedule, config httpsupport.Configuration) *app.TenantUpdateScheduleData {
+	self := httpsupport.AbsoluteURL(req, app.TenantUpdateScheduleHref(s.ID.String()), config)
+	return &app.TenantUpdateScheduleData{
+		Type: "tenant_update_schedules",
+		ID:   s.ID.String(),
+		Attributes: &app.TenantUpdateScheduleDataAttributes{
+			ClusterURL:    s.ClusterURL,
+			EnvType:       s.EnvType,
+			Cron:          s.CronExpression,
+			NextRunAt:     s.NextRunAt,
+			LastRunAt:     s.LastRunAt,
+			LastRunStatus: s.LastRunStatus,
+			CreatedBy:     s.CreatedBy,
+			CreatedAt:     s.CreatedAt,
+			CancelledBy:   s.CancelledBy,
+			CancelledAt:   s.CancelledAt,
+		},
+		Links: &app.GenericLinks{
+			Self: &self,
+		},
+	}
+}
@@ -0,0 +1,279 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/app"
+	apptest "github.com/fabric8-services/admin-console/app/test"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/controller"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testauth "github.com/fabric8-services/fabric8-common/test/auth"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	"github.com/goadesign/goa"
+	goauuid "github.com/goadesign/goa/uuid"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TenantUpdateSchedulesControllerBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	app    *application.GormApplication
+	config *configuration.Configuration
+}
+
+func TestTenantUpdateSchedules(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &TenantUpdateSchedulesControllerBlackboxTestSuite{
+		DBTestSuite: testsuite.NewDBTestSuite(config),
+		config:      config,
+	})
+}
+
+func (s *TenantUpdateSchedulesControllerBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.app = application.NewGormApplication(s.DB)
+}
+
+// approvalRequiredConfig a configuration which requires the approval of the tenant updates
+type approvalRequiredConfig struct {
+	*configuration.Configuration
+}
+
+func (c approvalRequiredConfig) IsTenantUpdateApprovalRequired() bool {
+	return true
+}
+
+func newCreateTenantUpdateSchedulePayload(cron *string, runAt *time.Time) *app.CreateTenantUpdateSchedule {
+	clusterURL := fmt.Sprintf("https://cluster-%s.example.com", uuid.NewV4())
+	envType := "che"
+	return &app.CreateTenantUpdateSchedule{
+		Data: &app.CreateTenantUpdateScheduleData{
+			Type: "tenant_update_schedules",
+			Attributes: &app.CreateTenantUpdateScheduleDataAttributes{
+				ClusterURL: &clusterURL,
+				EnvType:    &envType,
+				Cron:       cron,
+				RunAt:      runAt,
+			},
+		},
+	}
+}
+
+func (s *TenantUpdateSchedulesControllerBlackboxTestSuite) TestCreateTenantUpdateSchedule() {
+	// given
+	svc := goa.New("tenant_update_schedules")
+	ctrl := controller.NewTenantUpdateSchedulesController(svc, s.config, s.app)
+
+	s.T().Run("one-off", func(t *testing.T) {
+		// given
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		runAt := time.Now().Add(time.Hour)
+		payload := newCreateTenantUpdateSchedulePayload(nil, &runAt)
+		// when
+		rw, result := apptest.CreateTenantUpdateScheduleCreated(t, ctx, svc, ctrl, payload)
+		// then
+		require.NotNil(t, result.Data)
+		attrs := result.Data.Attributes
+		assert.Equal(t, identity.Username, attrs.CreatedBy)
+		assert.Equal(t, payload.Data.Attributes.ClusterURL, attrs.ClusterURL)
+		assert.Nil(t, attrs.Cron)
+		require.NotNil(t, attrs.NextRunAt)
+		assert.WithinDuration(t, runAt, *attrs.NextRunAt, time.Millisecond)
+		assert.Equal(t, *result.Data.Links.Self, rw.Header().Get("Location"))
+		assertAuditLog(t, s.DB, *identity, auditlog.CreateTenantUpdateSchedule, auditlog.EventParams{
+			"schedule_id": result.Data.ID,
+			"clusterURL":  *payload.Data.Attributes.ClusterURL,
+			"envType":     "che",
+			"run_at":      attrs.NextRunAt.UTC().Format(time.RFC3339),
+		})
+		// verify that the new schedule can be retrieved
+		id, err := goauuid.FromString(result.Data.ID)
+		require.NoError(t, err)
+		_, show := apptest.ShowTenantUpdateScheduleOK(t, ctx, svc, ctrl, id)
+		assert.Equal(t, identity.Username, show.Data.Attributes.CreatedBy)
+	})
+
+	s.T().Run("recurring", func(t *testing.T) {
+		// given
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		cron := "0 2 * * 6"
+		payload := newCreateTenantUpdateSchedulePayload(&cron, nil)
+		// when
+		_, result := apptest.CreateTenantUpdateScheduleCreated(t, ctx, svc, ctrl, payload)
+		// then
+		attrs := result.Data.Attributes
+		require.NotNil(t, attrs.Cron)
+		assert.Equal(t, cron, *attrs.Cron)
+		require.NotNil(t, attrs.NextRunAt)
+		assert.Equal(t, time.Saturday, attrs.NextRunAt.UTC().Weekday())
+		assertAuditLog(t, s.DB, *identity, auditlog.CreateTenantUpdateSchedule, auditlog.EventParams{
+			"schedule_id": result.Data.ID,
+			"clusterURL":  *payload.Data.Attributes.ClusterURL,
+			"envType":     "che",
+			"cron":        cron,
+			"run_at":      attrs.NextRunAt.UTC().Format(time.RFC3339),
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("past time", func(t *testing.T) {
+			// given
+			ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			runAt := time.Now().Add(-time.Hour)
+			// when/then
+			apptest.CreateTenantUpdateScheduleBadRequest(t, ctx, svc, ctrl, newCreateTenantUpdateSchedulePayload(nil, &runAt))
+		})
+
+		t.Run("invalid cron expression", func(t *testing.T) {
+			// given
+			ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			cron := "every saturday"
+			// when/then
+			apptest.CreateTenantUpdateScheduleBadRequest(t, ctx, svc, ctrl, newCreateTenantUpdateSchedulePayload(&cron, nil))
+		})
+
+		t.Run("missing time and cron expression", func(t *testing.T) {
+			// given
+			ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			// when/then
+			apptest.CreateTenantUpdateScheduleBadRequest(t, ctx, svc, ctrl, newCreateTenantUpdateSchedulePayload(nil, nil))
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			runAt := time.Now().Add(time.Hour)
+			// when/then
+			apptest.CreateTenantUpdateScheduleUnauthorized(t, context.Background(), svc, ctrl, newCreateTenantUpdateSchedulePayload(nil, &runAt))
+		})
+	})
+
+	s.T().Run("approval required", func(t *testing.T) {
+		// given
+		ctrl := controller.NewTenantUpdateSchedulesController(svc, approvalRequiredConfig{s.config}, s.app)
+
+		t.Run("single cluster", func(t *testing.T) {
+			// given
+			ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			runAt := time.Now().Add(time.Hour)
+			// when
+			_, result := apptest.CreateTenantUpdateScheduleCreated(t, ctx, svc, ctrl, newCreateTenantUpdateSchedulePayload(nil, &runAt))
+			// then
+			assert.NotNil(t, result.Data.Attributes.ClusterURL)
+		})
+
+		t.Run("all clusters", func(t *testing.T) {
+			// given
+			ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+			require.NoError(t, err)
+			runAt := time.Now().Add(time.Hour)
+			payload := newCreateTenantUpdateSchedulePayload(nil, &runAt)
+			payload.Data.Attributes.ClusterURL = nil
+			// when
+			apptest.CreateTenantUpdateScheduleForbidden(t, ctx, svc, ctrl, payload)
+			// then the update was not scheduled, so the scheduler cannot start it without approval
+			schedules, err := s.app.TenantUpdateSchedules().List(context.Background(), true)
+			require.NoError(t, err)
+			for _, schedule := range schedules {
+				assert.NotEqual(t, identity.Username, schedule.CreatedBy)
+			}
+		})
+	})
+}
+
+func (s *TenantUpdateSchedulesControllerBlackboxTestSuite) TestListTenantUpdateSchedules() {
+	// given
+	svc := goa.New("tenant_update_schedules")
+	ctrl := controller.NewTenantUpdateSchedulesController(svc, s.config, s.app)
+	ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+	require.NoError(s.T(), err)
+	runAt := time.Now().Add(time.Hour)
+	_, active := apptest.CreateTenantUpdateScheduleCreated(s.T(), ctx, svc, ctrl, newCreateTenantUpdateSchedulePayload(nil, &runAt))
+	_, cancelled := apptest.CreateTenantUpdateScheduleCreated(s.T(), ctx, svc, ctrl, newCreateTenantUpdateSchedulePayload(nil, &runAt))
+	cancelledID, err := goauuid.FromString(cancelled.Data.ID)
+	require.NoError(s.T(), err)
+	apptest.CancelTenantUpdateScheduleNoContent(s.T(), ctx, svc, ctrl, cancelledID)
+
+	s.T().Run("active only", func(t *testing.T) {
+		// when
+		_, result := apptest.ListTenantUpdateScheduleOK(t, ctx, svc, ctrl, nil)
+		// then
+		ids := tenantUpdateScheduleIDs(result)
+		assert.Contains(t, ids, active.Data.ID)
+		assert.NotContains(t, ids, cancelled.Data.ID)
+	})
+
+	s.T().Run("all", func(t *testing.T) {
+		// given
+		activeOnly := false
+		// when
+		_, result := apptest.ListTenantUpdateScheduleOK(t, ctx, svc, ctrl, &activeOnly)
+		// then
+		ids := tenantUpdateScheduleIDs(result)
+		assert.Contains(t, ids, active.Data.ID)
+		assert.Contains(t, ids, cancelled.Data.ID)
+	})
+}
+
+func tenantUpdateScheduleIDs(list *app.TenantUpdateScheduleList) []string {
+	ids := make([]string, len(list.Data))
+	for i, d := range list.Data {
+		ids[i] = d.ID
+	}
+	return ids
+}
+
+func (s *TenantUpdateSchedulesControllerBlackboxTestSuite) TestCancelTenantUpdateSchedule() {
+	// given
+	svc := goa.New("tenant_update_schedules")
+	ctrl := controller.NewTenantUpdateSchedulesController(svc, s.config, s.app)
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		runAt := time.Now().Add(time.Hour)
+		_, created := apptest.CreateTenantUpdateScheduleCreated(t, ctx, svc, ctrl, newCreateTenantUpdateSchedulePayload(nil, &runAt))
+		id, err := goauuid.FromString(created.Data.ID)
+		require.NoError(t, err)
+		// use another identity to cancel the schedule
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		// when
+		apptest.CancelTenantUpdateScheduleNoContent(t, ctx, svc, ctrl, id)
+		// then
+		_, result := apptest.ShowTenantUpdateScheduleOK(t, ctx, svc, ctrl, id)
+		assert.Nil(t, result.Data.Attributes.NextRunAt)
+		require.NotNil(t, result.Data.Attributes.CancelledBy)
+		assert.Equal(t, identity.Username, *result.Data.Attributes.CancelledBy)
+		assertAuditLog(t, s.DB, *identity, auditlog.CancelTenantUpdateSchedule, auditlog.EventParams{
+			"schedule_id": created.Data.ID,
+		})
+		// and the schedule cannot be cancelled again
+		apptest.CancelTenantUpdateScheduleConflict(t, ctx, svc, ctrl, id)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// given
+		ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		// when/then
+		apptest.CancelTenantUpdateScheduleNotFound(t, ctx, svc, ctrl, goauuid.NewV4())
+	})
+}
@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"github.com/fabric8-services/admin-console/app"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/webhook"
+	"github.com/fabric8-services/fabric8-common/httpsupport"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+// WebhooksController implements the webhook resource.
+type WebhooksController struct {
+	*goa.Controller
+	db     application.DB
+	config httpsupport.Configuration
+}
+
+// NewWebhooksController creates a webhook controller.
+func NewWebhooksController(service *goa.Service, config httpsupport.Configuration, db application.DB) *WebhooksController {
+	return &WebhooksController{
+		Controller: service.NewController("WebhooksController"),
+		config:     config,
+		db:         db,
+	}
+}
+
+// List lists all the webhooks
+func (c *WebhooksController) List(ctx *app.ListWebhookContext) error {
+	subscriptions, err := c.db.Webhooks().List(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the webhooks")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.WebhookList{
+		Data: convertWebhooksData(ctx.RequestData, subscriptions, c.config),
+	})
+}
+
+// Show shows a webhook
+func (c *WebhooksController) Show(ctx *app.ShowWebhookContext) error {
+	subscription, err := c.db.Webhooks().Load(ctx, uuid.UUID(ctx.ID))
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.WebhookSingle{
+		Data: convertWebhookData(ctx.RequestData, subscription, c.config),
+	})
+}
+
+// Create registers a new webhook
+func (c *WebhooksController) Create(ctx *app.CreateWebhookContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to identify the user registering a webhook")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	attrs := ctx.Payload.Data.Attributes
+	subscription := webhook.Subscription{
+		URL:        attrs.URL,
+		Secret:     attrs.Secret,
+		EventTypes: attrs.EventTypes,
+		CreatedBy:  username,
+	}
+	if subscription.EventTypes == nil {
+		subscription.EventTypes = []string{}
+	}
+	// register the webhook and log an audit log for the current user for her action in the same transaction
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		if err := appl.Webhooks().Create(ctx, &subscription); err != nil {
+			return err
+		}
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: auditlog.CreateWebhook,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"webhook_id":  subscription.ID.String(),
+				"url":         subscription.URL,
+				"event_types": subscription.EventTypes,
+			},
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+			"url": attrs.URL,
+		}, "unable to register the webhook")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"webhook_id": subscription.ID,
+		"created_by": username,
+	}, "registered new webhook")
+	data := convertWebhookData(ctx.RequestData, subscription, c.config)
+	ctx.ResponseData.Header().Set("Location", *data.Links.Self)
+	return ctx.Created(&app.WebhookSingle{
+		Data: data,
+	})
+}
+
+// Delete deletes a webhook
+func (c *WebhooksController) Delete(ctx *app.DeleteWebhookContext) error {
+	// retrieve the username from the token (the permission was verified by the authorization middleware)
+	username, err := currentUsername(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to identify the user deleting a webhook")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	id := uuid.UUID(ctx.ID)
+	// delete the webhook and log an audit log for the current user for her action in the same transaction
+	err = application.Transactional(c.db, func(appl application.Application) error {
+		subscription, err := appl.Webhooks().Load(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := appl.Webhooks().Delete(ctx, id); err != nil {
+			return err
+		}
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: auditlog.DeleteWebhook,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"webhook_id": id.String(),
+				"url":        subscription.URL,
+			},
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":        err,
+			"webhook_id": id,
+		}, "unable to delete the webhook")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// ListDeliveries lists the last deliveries of the events to a webhook
+func (c *WebhooksController) ListDeliveries(ctx *app.ListDeliveriesWebhookContext) error {
+	id := uuid.UUID(ctx.ID)
+	var status *webhook.DeliveryStatus
+	if ctx.Status != nil {
+		s := webhook.DeliveryStatus(*ctx.Status)
+		status = &s
+	}
+	var deliveries []webhook.Delivery
+	err := application.Transactional(c.db, func(appl application.Application) error {
+		// make sure that the webhook exists
+		if _, err := appl.Webhooks().Load(ctx, id); err != nil {
+			return err
+		}
+		var err error
+		deliveries, err = appl.Webhooks().ListDeliveries(ctx, id, status, ctx.Limit)
+		return err
+	})
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.WebhookDeliveryList{
+		Data: convertWebhookDeliveriesData(deliveries),
+	})
+}
+
+// convertWebhooksData converts the webhooks to their resource-API data counterpart
+func convertWebhooksData(req *goa.RequestData, subscriptions []webhook.Subscription, config httpsupport.Configuration) []*app.WebhookData {
+	data := make([]*app.WebhookData, len(subscriptions))
+	for i, s := range subscriptions {
+		data[i] = convertWebhookData(req, s, config)
+	}
+	return data
+}
+
+// convertWebhookData converts the webhook to its resource-API data counterpart (without its secret)
+func convertWebhookData(req *goa.RequestData, s webhook.Subscription, config httpsupport.Configuration) *app.WebhookData {
+	self := httpsupport.AbsoluteURL(req, app.WebhookHref(s.ID.String()), config)
+	return &app.WebhookData{
+		Type: "webhooks",
+		ID:   s.ID.String(),
+		Attributes: &app.WebhookDataAttributes{
+			URL:        s.URL,
+			EventTypes: s.EventTypes,
+			CreatedBy:  s.CreatedBy,
+			CreatedAt:  s.CreatedAt,
+		},
+		Links: &app.GenericLinks{
+			Self: &self,
+		},
+	}
+}
+
+// convertWebhookDeliveriesData converts the deliveries to their resource-API data counterpart
+func convertWebhookDeliveriesData(deliveries []webhook.Delivery) []*app.WebhookDeliveryData {
+	data := make([]*app.WebhookDeliveryData, len(deliveries))
+	for i, d := range deliveries {
+		attrs := &app.WebhookDeliveryDataAttributes{
+			EventID:        d.EventID.String(),
+			EventType:      d.EventType,
+			Status:         string(d.Status),
+			Attempts:       d.Attempts,
+			LastStatusCode: d.LastStatusCode,
+			DeliveredAt:    d.DeliveredAt,
+			CreatedAt:      d.CreatedAt,
+		}
+		if d.Status == webhook.DeliveryPending || d.Status == webhook.DeliveryInProgress {
+			nextAttemptAt := d.NextAttemptAt
+			attrs.NextAttemptAt = &nextAttemptAt
+		}
+		if d.LastError != "" {
+			lastError := d.LastError
+			attrs.LastError = &lastError
+		}
+		data[i] = &app.WebhookDeliveryData{
+			Type:       "webhook_deliveries",
+			ID:         d.ID.String(),
+			Attributes: attrs,
+		}
+	}
+	return data
+}
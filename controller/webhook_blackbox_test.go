@@ -0,0 +1,132 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/fabric8-services/admin-console/app"
+	apptest "github.com/fabric8-services/admin-console/app/test"
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/controller"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testauth "github.com/fabric8-services/fabric8-common/test/auth"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	"github.com/goadesign/goa"
+	goauuid "github.com/goadesign/goa/uuid"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type WebhooksControllerBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	app    *application.GormApplication
+	config *configuration.Configuration
+}
+
+func TestWebhooks(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &WebhooksControllerBlackboxTestSuite{
+		DBTestSuite: testsuite.NewDBTestSuite(config),
+		config:      config,
+	})
+}
+
+func (s *WebhooksControllerBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.app = application.NewGormApplication(s.DB)
+}
+
+func newCreateWebhookPayload(eventTypes ...string) *app.CreateWebhook {
+	return &app.CreateWebhook{
+		Data: &app.CreateWebhookData{
+			Type: "webhooks",
+			Attributes: &app.CreateWebhookDataAttributes{
+				URL:        fmt.Sprintf("https://hooks/%s", uuid.NewV4()),
+				Secret:     "a-very-secret-secret",
+				EventTypes: eventTypes,
+			},
+		},
+	}
+}
+
+func (s *WebhooksControllerBlackboxTestSuite) TestCreateWebhook() {
+	// given
+	svc := goa.New("webhooks")
+	ctrl := controller.NewWebhooksController(svc, s.config, s.app)
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		ctx, identity, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		payload := newCreateWebhookPayload(auditlog.StartTenantUpdateEvent)
+		// when
+		rw, result := apptest.CreateWebhookCreated(t, ctx, svc, ctrl, payload)
+		// then
+		require.NotNil(t, result.Data)
+		assert.Equal(t, payload.Data.Attributes.URL, result.Data.Attributes.URL)
+		assert.Equal(t, []string{auditlog.StartTenantUpdateEvent}, result.Data.Attributes.EventTypes)
+		assert.Equal(t, identity.Username, result.Data.Attributes.CreatedBy)
+		assert.Equal(t, *result.Data.Links.Self, rw.Header().Get("Location"))
+		assertAuditLog(t, s.DB, *identity, auditlog.CreateWebhook, auditlog.EventParams{
+			"webhook_id":  result.Data.ID,
+			"url":         payload.Data.Attributes.URL,
+			"event_types": []interface{}{auditlog.StartTenantUpdateEvent},
+		})
+		// verify that the new webhook can be retrieved
+		id, err := goauuid.FromString(result.Data.ID)
+		require.NoError(t, err)
+		_, show := apptest.ShowWebhookOK(t, ctx, svc, ctrl, id)
+		assert.Equal(t, payload.Data.Attributes.URL, show.Data.Attributes.URL)
+		// and that it has no delivery yet
+		_, deliveries := apptest.ListDeliveriesWebhookOK(t, ctx, svc, ctrl, id, nil, nil)
+		assert.Empty(t, deliveries.Data)
+	})
+
+	s.T().Run("unknown event type", func(t *testing.T) {
+		// given
+		ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		// when/then
+		apptest.CreateWebhookBadRequest(t, ctx, svc, ctrl, newCreateWebhookPayload("do_anything"))
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		// when/then
+		apptest.CreateWebhookUnauthorized(t, context.Background(), svc, ctrl, newCreateWebhookPayload())
+	})
+}
+
+func (s *WebhooksControllerBlackboxTestSuite) TestDeleteWebhook() {
+	// given
+	svc := goa.New("webhooks")
+	ctrl := controller.NewWebhooksController(svc, s.config, s.app)
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		_, result := apptest.CreateWebhookCreated(t, ctx, svc, ctrl, newCreateWebhookPayload())
+		id, err := goauuid.FromString(result.Data.ID)
+		require.NoError(t, err)
+		// when
+		apptest.DeleteWebhookNoContent(t, ctx, svc, ctrl, id)
+		// then
+		apptest.ShowWebhookNotFound(t, ctx, svc, ctrl, id)
+		apptest.ListDeliveriesWebhookNotFound(t, ctx, svc, ctrl, id, nil, nil)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// given
+		ctx, _, err := testauth.EmbedUserTokenInContext(context.Background(), testauth.NewIdentity())
+		require.NoError(t, err)
+		// when/then
+		apptest.DeleteWebhookNotFound(t, ctx, svc, ctrl, goauuid.NewV4())
+	})
+}
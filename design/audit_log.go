@@ -14,13 +14,37 @@ var _ = a.Resource("audit_log", func() {
 		a.Routing(
 			a.POST("users/:username"),
 		)
-		a.Description("Add an auditlog for a user")
+		a.Description(`Add an auditlog for a user. When the request is retried with the same idempotency key
+(in the 'Idempotency-Key' header or in the payload), the auditlog is not recorded twice and the original record is returned,
+unless the payload differs from the original one, in which case the request is rejected with a conflict.`)
+		a.Headers(func() {
+			a.Header("Idempotency-Key", d.String, "a unique key, so that the event is not recorded twice when the request is retried", func() {
+				a.MaxLength(255)
+			})
+		})
 		a.Params(func() {
 			a.Param("username", d.String)
 			a.Required("username")
 		})
 		a.Payload(createAuditLog)
-		a.Response(d.NoContent)
+		a.Response(d.Created, "/auditlogs/.*")
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("create_batch", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("batch"),
+		)
+		a.Description(`Add auditlogs for several users at once. The valid entries are recorded in a single transaction,
+and the result of each entry is reported in the response, in the same order as in the request.
+An entry whose idempotency key was already used is not recorded again, and an entry which reuses an idempotency key
+with a different payload is rejected with a conflict, while the other entries are recorded.`)
+		a.Payload(createAuditLogBatch)
+		a.Response(d.OK, auditLogBatchResult)
 		a.Response(d.BadRequest, JSONAPIErrors)
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
@@ -40,6 +64,12 @@ var _ = a.Resource("audit_log", func() {
 			a.Param("page[size]", d.Integer, "Paging size", func() {
 				a.Default(10)
 			})
+			a.Param("page[cursor]", d.String, `Opaque paging cursor, as found in the 'next' link of a previous response. When set (even empty),
+the records are listed after the cursor position (or from the first record if empty) and 'page[number]' is ignored.`)
+			a.Param("count", d.Boolean, `Whether to include the total number of records in the response metadata when paging with a cursor.
+The total number of records is always included when paging by number.`, func() {
+				a.Default(false)
+			})
 			a.Required("username")
 		})
 		a.Response(d.OK, auditlogList)
@@ -48,6 +78,129 @@ var _ = a.Resource("audit_log", func() {
 		a.Response(d.Forbidden, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Show an audit log record")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the audit log record")
+			a.Required("id")
+		})
+		a.Response(d.OK, auditLogSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("export", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("users/:username/export"),
+		)
+		a.Description(`Export all audit logs for a given user, in the CSV (default) or newline-delimited JSON format
+depending on the 'Accept' request header`)
+		a.Headers(func() {
+			a.Header("Accept", d.String, "the media type of the export: 'text/csv' or 'application/x-ndjson'")
+		})
+		a.Params(func() {
+			a.Param("username", d.String)
+			a.Required("username")
+		})
+		a.Response(d.OK) // here we don't specify a media type, because the response body is streamed in CSV or NDJSON
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("stream", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("stream"),
+		)
+		a.Description(`Stream the new audit logs matching the given criteria as Server-Sent Events, until the client disconnects.
+The ID of each event is the position of the record in the chain, so that the stream can be resumed with the 'Last-Event-ID' header`)
+		a.Headers(func() {
+			a.Header("Last-Event-ID", d.String, "the ID of the last event received by the client, after which the stream resumes")
+		})
+		a.Params(func() {
+			a.Param("event_type", a.ArrayOf(d.String), "the name(s) of the type of events to match")
+			a.Param("username", d.String, "the prefix of the username to match")
+		})
+		a.Response(d.OK) // here we don't specify a media type, because the response body is streamed as 'text/event-stream'
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("aggregate", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("stats"),
+		)
+		a.Description(`Count the audit logs created within a range of dates and matching the given criteria, grouped by event type,
+username and/or time bucket. The groups are sorted by time bucket (if any), then from the largest to the smallest`)
+		a.Params(func() {
+			a.Param("group_by", a.ArrayOf(d.String, func() {
+				a.Enum("event_type", "username")
+			}), "the attribute(s) by which the records are grouped")
+			a.Param("interval", d.String, "the size of the time buckets (in UTC) by which the records are grouped", func() {
+				a.Enum("hour", "day", "week", "month")
+			})
+			a.Param("created_after", d.DateTime, "the date and time after which the events were created")
+			a.Param("created_before", d.DateTime, `the date and time before which the events were created (now by default).
+The range of dates must not exceed the configured maximum (92 days by default)`)
+			a.Param("event_type", a.ArrayOf(d.String), "the name(s) of the type of events to match")
+			a.Param("username", d.String, "the prefix of the username to match")
+			a.Param("limit", d.Integer, "the maximum number of groups", func() {
+				a.Minimum(1)
+				a.Maximum(1000)
+				a.Default(100)
+			})
+			a.Required("created_after")
+		})
+		a.Response(d.OK, auditLogStatList)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List audit logs of all users matching the given criteria")
+		a.Params(func() {
+			a.Param("event_type", a.ArrayOf(d.String), "the name(s) of the type of events to match")
+			a.Param("username", d.String, "the prefix of the username to match")
+			a.Param("identity_id", d.UUID, "the identity ID to match")
+			a.Param("created_after", d.DateTime, "the date and time after which the events were created")
+			a.Param("created_before", d.DateTime, "the date and time before which the events were created")
+			a.Param("event_params", a.ArrayOf(d.String), "the event params to match, in the 'key:value' form")
+			a.Param("sort", d.String, "the sort order on the date of the events", func() {
+				a.Enum("created_at", "-created_at")
+				a.Default("created_at")
+			})
+			a.Param("page[number]", d.Integer, "Paging number", func() {
+				a.Default(0)
+			})
+			a.Param("page[size]", d.Integer, "Paging size", func() {
+				a.Default(10)
+			})
+		})
+		a.Response(d.OK, auditlogList)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
 })
 
 var createAuditLog = a.MediaType("application/vnd.createauditlog+json", func() {
@@ -77,9 +230,54 @@ var createAuditLogData = a.Type("CreateAuditLogData", func() {
 var createAuditLogDataAttributes = a.Type("CreateAuditLogDataAttributes", func() {
 	a.Attribute("event_type", d.String, "the type of event")
 	a.Attribute("event_params", a.HashOf(d.String, d.Any), "a generic map holding the params of the event to log")
+	a.Attribute("username", d.String, "the username of the user who triggered or was the subject of the event (required in a batch)")
+	a.Attribute("idempotency_key", d.String, "a unique key, so that the event is not recorded twice when the request is retried", func() {
+		a.MaxLength(255)
+	})
 	a.Required("event_type")
 })
 
+var createAuditLogBatch = a.MediaType("application/vnd.createauditlogbatch+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("CreateAuditLogBatch")
+	a.Description("Create auditlogs for several users")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(createAuditLogData), func() {
+			a.MinLength(1)
+			a.MaxLength(1000)
+		})
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var auditLogBatchResult = a.MediaType("application/vnd.auditlogbatchresult+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("AuditLogBatchResult")
+	a.Description("The result of each entry of a batch of auditlogs, in the same order as in the request")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(auditLogBatchItemResult))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+// auditLogBatchItemResult the result of an entry of a batch of auditlogs
+var auditLogBatchItemResult = a.Type("AuditLogBatchItemResult", func() {
+	a.Attribute("status", d.String, "whether the entry was recorded, was already recorded with the same idempotency key, or was rejected", func() {
+		a.Enum("created", "duplicate", "rejected")
+	})
+	a.Attribute("id", d.String, "the ID of the audit log record, unless the entry was rejected")
+	a.Attribute("errors", a.ArrayOf(JSONAPIError), "the reasons why the entry was rejected")
+	a.Required("status")
+})
+
 // clusterList represents an array of cluster objects
 var auditlogList = JSONList(
 	"AuditLog",
@@ -88,23 +286,68 @@ var auditlogList = JSONList(
 	pagingLinks,
 	auditLogMetadata)
 
+var auditLogSingle = JSONSingle(
+	"AuditLog",
+	"Holds a single audit log record",
+	auditLogData,
+	nil)
+
 // auditLogData represents the data of an audit log associated with a given user
 var auditLogData = a.Type("AuditLogData", func() {
 	a.Attribute("type", d.String, "type of the audit log", func() {
 		a.Enum("audit_logs")
 	})
+	a.Attribute("id", d.String, "ID of the audit log record", func() {
+		a.Example("40bbdd3d-8b5d-4fd6-ac90-7236b669af04")
+	})
 	a.Attribute("attributes", auditLogDataAttributes, "Attributes of the audit log. ")
 	a.Attribute("links", genericLinks)
-	a.Required("type", "attributes")
+	a.Required("type", "id", "attributes")
 })
 
 var auditLogDataAttributes = a.Type("AuditLogDataAttributes", func() {
 	a.Attribute("date", d.String, "the date and time of event")
+	a.Attribute("identity_id", d.String, "the identity ID of the user who triggered or was the subject of the event, if known")
+	a.Attribute("username", d.String, "the username of the user who triggered or was the subject of the event")
 	a.Attribute("event_type", d.String, "the type of event")
 	a.Attribute("event_params", a.HashOf(d.String, d.Any), "a generic map holding the params of the event to log")
+	a.Attribute("status_code", d.Integer, "the HTTP status code of the response to the audited action, once it completed")
+	a.Attribute("outcome", d.String, "the outcome of the audited action, once it completed", func() {
+		a.Enum("success", "denied", "error")
+	})
+	a.Attribute("latency_ms", d.Integer, "the time it took to perform the audited action (in milliseconds), once it completed")
+	a.Attribute("response_size", d.Integer, "the size of the response body to the audited action (in bytes), once it completed")
+	a.Attribute("result_count", d.Integer, "the number of results returned by the audited action (if relevant), once it completed")
 	a.Required("date", "event_type")
 })
 
+var auditLogStatList = JSONList(
+	"AuditLogStat",
+	"Holds the number of audit logs by group",
+	auditLogStatData,
+	nil,
+	nil)
+
+// auditLogStatData represents the number of audit logs in a group
+var auditLogStatData = a.Type("AuditLogStatData", func() {
+	a.Attribute("type", d.String, "type of the audit log stat", func() {
+		a.Enum("audit_log_stats")
+	})
+	a.Attribute("attributes", auditLogStatDataAttributes, "Attributes of the audit log stat")
+	a.Required("type", "attributes")
+})
+
+// auditLogStatDataAttributes the attributes of a group of audit logs: only those by which the records are grouped are set
+var auditLogStatDataAttributes = a.Type("AuditLogStatDataAttributes", func() {
+	a.Attribute("event_type", d.String, "the type of event of the records in the group")
+	a.Attribute("username", d.String, "the username of the records in the group")
+	a.Attribute("bucket", d.DateTime, "the start of the time bucket of the records in the group")
+	a.Attribute("count", d.Integer, "the number of records in the group")
+	a.Required("count")
+})
+
+// pagingLinks the links to the other pages of a list. When paging with a cursor, the
+// 'next' link contains the opaque 'page[cursor]' token and there is no 'prev' or 'last' link
 var pagingLinks = a.Type("pagingLinks", func() {
 	a.Attribute("prev", d.String)
 	a.Attribute("next", d.String)
@@ -113,6 +356,5 @@ var pagingLinks = a.Type("pagingLinks", func() {
 })
 
 var auditLogMetadata = a.Type("UserListMeta", func() {
-	a.Attribute("totalCount", d.Integer)
-	a.Required("totalCount")
+	a.Attribute("totalCount", d.Integer, "the total number of records, if it was computed")
 })
@@ -0,0 +1,124 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("event_type", func() {
+
+	a.BasePath("/eventtypes")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List all the types of audited events")
+		a.Response(d.OK, eventTypeList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Show a type of audited events")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the event type")
+			a.Required("id")
+		})
+		a.Response(d.OK, eventTypeSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description("Register a new type of audited events")
+		a.Payload(createEventType)
+		a.Response(d.Created, "/eventtypes/.*", func() {
+			a.Media(eventTypeSingle)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var createEventType = a.MediaType("application/vnd.createeventtype+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("CreateEventType")
+	a.Description("Register an event type")
+	a.Attributes(func() {
+		a.Attribute("data", createEventTypeData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+// createEventTypeData represents the data of an event type to register
+var createEventTypeData = a.Type("CreateEventTypeData", func() {
+	a.Attribute("type", d.String, "type of the event type", func() {
+		a.Enum("event_types")
+	})
+	a.Attribute("attributes", createEventTypeDataAttributes, "Attributes of the event type")
+	a.Required("type", "attributes")
+})
+
+var createEventTypeDataAttributes = a.Type("CreateEventTypeDataAttributes", func() {
+	a.Attribute("name", d.String, "the name of the event type", func() {
+		a.Pattern("^[a-z][a-z0-9_]*$")
+		a.MaxLength(64)
+	})
+	a.Attribute("description", d.String, "the description of the event type")
+	a.Attribute("params_schema", a.HashOf(d.String, d.Any), "the JSON schema of the params of the events of this type")
+	a.Required("name")
+})
+
+var eventTypeList = JSONList(
+	"EventType",
+	"Holds the list of event types",
+	eventTypeData,
+	nil,
+	nil)
+
+var eventTypeSingle = JSONSingle(
+	"EventType",
+	"Holds a single event type",
+	eventTypeData,
+	nil)
+
+// eventTypeData represents the data of a type of audited events
+var eventTypeData = a.Type("EventTypeData", func() {
+	a.Attribute("type", d.String, "type of the event type", func() {
+		a.Enum("event_types")
+	})
+	a.Attribute("id", d.String, "ID of the event type", func() {
+		a.Example("7aea0277-d6fa-4df9-8224-a27fa4096ec7")
+	})
+	a.Attribute("attributes", eventTypeDataAttributes, "Attributes of the event type")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "id", "attributes")
+})
+
+var eventTypeDataAttributes = a.Type("EventTypeDataAttributes", func() {
+	a.Attribute("name", d.String, "the name of the event type")
+	a.Attribute("description", d.String, "the description of the event type")
+	a.Attribute("params_schema", a.HashOf(d.String, d.Any), "the JSON schema of the params of the events of this type")
+	a.Attribute("internal", d.Boolean, "whether the events of this type can only be recorded by the admin console itself")
+	a.Attribute("created_at", d.DateTime, "the date and time when the event type was registered")
+	a.Required("name", "internal", "created_at")
+})
@@ -0,0 +1,176 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("role", func() {
+
+	a.BasePath("/roles")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List all the roles")
+		a.Response(d.OK, roleList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:name"),
+		)
+		a.Description("Show a role")
+		a.Params(func() {
+			a.Param("name", d.String, "the name of the role")
+			a.Required("name")
+		})
+		a.Response(d.OK, roleSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description("Create a new role")
+		a.Payload(createRole)
+		a.Response(d.Created, "/roles/.*", func() {
+			a.Media(roleSingle)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("list_for_user", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/users/:username"),
+		)
+		a.Description("List the roles assigned to a user")
+		a.Params(func() {
+			a.Param("username", d.String, "the username of the user")
+			a.Required("username")
+		})
+		a.Response(d.OK, roleList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("assign", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:name/users/:username"),
+		)
+		a.Description("Assign a role to a user")
+		a.Params(func() {
+			a.Param("name", d.String, "the name of the role")
+			a.Param("username", d.String, "the username of the user")
+			a.Required("name", "username")
+		})
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("revoke", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:name/users/:username"),
+		)
+		a.Description("Revoke a role from a user")
+		a.Params(func() {
+			a.Param("name", d.String, "the name of the role")
+			a.Param("username", d.String, "the username of the user")
+			a.Required("name", "username")
+		})
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var createRole = a.MediaType("application/vnd.createrole+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("CreateRole")
+	a.Description("Create a role")
+	a.Attributes(func() {
+		a.Attribute("data", createRoleData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+// createRoleData represents the data of a role to create
+var createRoleData = a.Type("CreateRoleData", func() {
+	a.Attribute("type", d.String, "type of the role", func() {
+		a.Enum("roles")
+	})
+	a.Attribute("attributes", createRoleDataAttributes, "Attributes of the role")
+	a.Required("type", "attributes")
+})
+
+var createRoleDataAttributes = a.Type("CreateRoleDataAttributes", func() {
+	a.Attribute("name", d.String, "the name of the role", func() {
+		a.Pattern("^[a-z][a-z0-9_-]*$")
+		a.MaxLength(64)
+	})
+	a.Attribute("description", d.String, "the description of the role")
+	a.Attribute("permissions", a.ArrayOf(d.String), "the permissions granted by the role")
+	a.Required("name", "permissions")
+})
+
+var roleList = JSONList(
+	"Role",
+	"Holds the list of roles",
+	roleData,
+	nil,
+	nil)
+
+var roleSingle = JSONSingle(
+	"Role",
+	"Holds a single role",
+	roleData,
+	nil)
+
+// roleData represents the data of a role
+var roleData = a.Type("RoleData", func() {
+	a.Attribute("type", d.String, "type of the role", func() {
+		a.Enum("roles")
+	})
+	a.Attribute("id", d.String, "ID of the role", func() {
+		a.Example("5ad8f6ea-3c0f-4a55-8e0c-1c4c2a0f5a6e")
+	})
+	a.Attribute("attributes", roleDataAttributes, "Attributes of the role")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "id", "attributes")
+})
+
+var roleDataAttributes = a.Type("RoleDataAttributes", func() {
+	a.Attribute("name", d.String, "the name of the role")
+	a.Attribute("description", d.String, "the description of the role")
+	a.Attribute("permissions", a.ArrayOf(d.String), "the permissions granted by the role")
+	a.Attribute("created_at", d.DateTime, "the date and time when the role was created")
+	a.Required("name", "permissions", "created_at")
+})
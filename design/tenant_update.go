@@ -24,9 +24,10 @@ var _ = a.Resource("tenant_update", func() {
 		})
 
 		a.Description("Get information about last/ongoing update.")
-		a.Response(d.OK) // here we don't specify a media type, because we're just proxying to `tenant`
+		a.Response(d.OK, tenantUpdateSingle)
 		a.Response(d.BadRequest, JSONAPIErrors)
 		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 	})
 
@@ -44,12 +45,13 @@ var _ = a.Resource("tenant_update", func() {
 				a.Enum("user", "che", "jenkins", "stage", "run")
 			})
 		})
-		a.Description("Start new cluster-wide update.")
+		a.Description("Start new cluster-wide update, or request to start it if it is not limited to a cluster and the updates must be approved by another user (in which case the location of the pending request is returned).")
 		a.Response(d.Accepted)
-		a.Response(d.Conflict) // here we don't specify a media type, because we're just proxying to `tenant`
+		a.Response(d.Conflict, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
 	})
 
 	a.Action("stop", func() {
@@ -64,5 +66,32 @@ var _ = a.Resource("tenant_update", func() {
 		a.Response(d.Accepted)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
 	})
 })
+
+var tenantUpdateSingle = JSONSingle(
+	"TenantUpdate",
+	"Holds the status of the ongoing or last cluster-wide tenant update",
+	tenantUpdateData,
+	nil)
+
+// tenantUpdateData represents the status of the ongoing or last cluster-wide tenant update, as reported by the tenant service
+var tenantUpdateData = a.Type("TenantUpdateData", func() {
+	a.Attribute("type", d.String, "type of the tenant update status", func() {
+		a.Enum("tenant_update_status")
+	})
+	a.Attribute("attributes", tenantUpdateDataAttributes, "Attributes of the tenant update status")
+	a.Required("type", "attributes")
+})
+
+var tenantUpdateDataAttributes = a.Type("TenantUpdateDataAttributes", func() {
+	a.Attribute("cluster_url", d.String, "the URL of the OSO cluster to which the status is limited, if any")
+	a.Attribute("env_type", d.String, "the environment type to which the status is limited, if any")
+	a.Attribute("status", d.String, "the status of the update", func() {
+		a.Enum("updating", "finished", "failed", "killed", "incomplete")
+	})
+	a.Attribute("failed_count", d.Integer, "the number of tenants whose update failed")
+	a.Attribute("last_time_updated", d.DateTime, "the last time the tenants were updated, if any")
+	a.Required("status", "failed_count")
+})
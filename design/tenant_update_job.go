@@ -0,0 +1,92 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("tenant_update_job", func() {
+
+	a.BasePath("/tenants/updates")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List the cluster-wide tenant updates started via the admin console, most recent first")
+		a.Params(func() {
+			a.Param("page[number]", d.Integer, "Paging number", func() {
+				a.Default(0)
+			})
+			a.Param("page[size]", d.Integer, "Paging size", func() {
+				a.Default(10)
+			})
+		})
+		a.Response(d.OK, tenantUpdateJobList)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Show a cluster-wide tenant update started via the admin console")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the tenant update")
+			a.Required("id")
+		})
+		a.Response(d.OK, tenantUpdateJobSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var tenantUpdateJobList = JSONList(
+	"TenantUpdateJob",
+	"Holds the list of tenant updates",
+	tenantUpdateJobData,
+	pagingLinks,
+	auditLogMetadata)
+
+var tenantUpdateJobSingle = JSONSingle(
+	"TenantUpdateJob",
+	"Holds a single tenant update",
+	tenantUpdateJobData,
+	nil)
+
+// tenantUpdateJobData represents the data of a cluster-wide tenant update
+var tenantUpdateJobData = a.Type("TenantUpdateJobData", func() {
+	a.Attribute("type", d.String, "type of the tenant update", func() {
+		a.Enum("tenant_updates")
+	})
+	a.Attribute("id", d.String, "ID of the tenant update", func() {
+		a.Example("5c4b7b8e-7e0a-4bd5-a4b4-b8a4e1ec9d44")
+	})
+	a.Attribute("attributes", tenantUpdateJobDataAttributes, "Attributes of the tenant update")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "id", "attributes")
+})
+
+var tenantUpdateJobDataAttributes = a.Type("TenantUpdateJobDataAttributes", func() {
+	a.Attribute("cluster_url", d.String, "the URL of the OSO cluster to which the update was limited, if any")
+	a.Attribute("env_type", d.String, "the environment type to which the update was limited, if any")
+	a.Attribute("status", d.String, "the status of the update, as last reported by the tenant service", func() {
+		a.Enum("updating", "finished", "failed", "killed", "incomplete")
+	})
+	a.Attribute("started_by", d.String, "the username of the user who started the update")
+	a.Attribute("started_at", d.DateTime, "the date and time when the update was started")
+	a.Attribute("stop_requested_by", d.String, "the username of the user who requested to stop the update, if any")
+	a.Attribute("stop_requested_at", d.DateTime, "the date and time when the stop of the update was requested, if any")
+	a.Attribute("finished_at", d.DateTime, "the date and time when the update finished, if it is over")
+	a.Attribute("duration", d.Integer, "how long the update ran (or has been running so far), in seconds")
+	a.Attribute("failed_count", d.Integer, "the number of tenants whose update failed")
+	a.Attribute("refreshed_at", d.DateTime, "the last time the status of the update was refreshed from the tenant service, if any")
+	a.Required("status", "started_by", "started_at", "duration", "failed_count")
+})
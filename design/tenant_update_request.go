@@ -0,0 +1,126 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("tenant_update_request", func() {
+
+	a.BasePath("/tenants/update/requests")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List the requests to start a cluster-wide tenant update, most recent first")
+		a.Params(func() {
+			a.Param("status", d.String, "the status of the requests to list", func() {
+				a.Enum("pending", "approved", "failed", "rejected", "expired")
+			})
+		})
+		a.Response(d.OK, tenantUpdateRequestList)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Show a request to start a cluster-wide tenant update")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the request")
+			a.Required("id")
+		})
+		a.Response(d.OK, tenantUpdateRequestSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("approve", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/approve"),
+		)
+		a.Headers(func() {
+			a.Header("Authorization", d.String, "the authorization header")
+		})
+		a.Description("Approve the pending request of another user to start a cluster-wide tenant update, which is then started")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the request")
+			a.Required("id")
+		})
+		a.Response(d.OK, tenantUpdateRequestSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("reject", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/reject"),
+		)
+		a.Description("Reject the pending request of another user to start a cluster-wide tenant update")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the request")
+			a.Required("id")
+		})
+		a.Response(d.OK, tenantUpdateRequestSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var tenantUpdateRequestList = JSONList(
+	"TenantUpdateRequest",
+	"Holds the list of requests to start a tenant update",
+	tenantUpdateRequestData,
+	nil,
+	nil)
+
+var tenantUpdateRequestSingle = JSONSingle(
+	"TenantUpdateRequest",
+	"Holds a single request to start a tenant update",
+	tenantUpdateRequestData,
+	nil)
+
+// tenantUpdateRequestData represents the data of a request to start a cluster-wide tenant update
+var tenantUpdateRequestData = a.Type("TenantUpdateRequestData", func() {
+	a.Attribute("type", d.String, "type of the request", func() {
+		a.Enum("tenant_update_requests")
+	})
+	a.Attribute("id", d.String, "ID of the request", func() {
+		a.Example("29a37212-13e9-4d2d-986a-584bcc20245b")
+	})
+	a.Attribute("attributes", tenantUpdateRequestDataAttributes, "Attributes of the request")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "id", "attributes")
+})
+
+var tenantUpdateRequestDataAttributes = a.Type("TenantUpdateRequestDataAttributes", func() {
+	a.Attribute("cluster_url", d.String, "the URL of the OSO cluster to which the update should be limited, if any")
+	a.Attribute("env_type", d.String, "the environment type to which the update should be limited, if any")
+	a.Attribute("status", d.String, "the status of the request", func() {
+		a.Enum("pending", "approved", "failed", "rejected", "expired")
+	})
+	a.Attribute("requested_by", d.String, "the username of the user who requested to start the update")
+	a.Attribute("requested_at", d.DateTime, "the date and time when the update was requested")
+	a.Attribute("expires_at", d.DateTime, "the date and time after which the request cannot be approved anymore")
+	a.Attribute("reviewed_by", d.String, "the username of the user who approved or rejected the request, if any")
+	a.Attribute("reviewed_at", d.DateTime, "the date and time when the request was approved or rejected, if any")
+	a.Attribute("status_code", d.Integer, "the status of the response of the tenant service when the approved update was started (0 if no response was received)")
+	a.Required("status", "requested_by", "requested_at", "expires_at")
+})
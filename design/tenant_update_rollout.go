@@ -0,0 +1,275 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("tenant_update_rollout", func() {
+
+	a.BasePath("/tenants/update/rollouts")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List the staged rollouts of the tenant updates, in the order in which they were created")
+		a.Params(func() {
+			a.Param("active", d.Boolean, "whether only the running or paused rollouts should be listed", func() {
+				a.Default(true)
+			})
+		})
+		a.Response(d.OK, tenantUpdateRolloutList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Show a staged rollout of a tenant update, along with its stages")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the rollout")
+			a.Required("id")
+		})
+		a.Response(d.OK, tenantUpdateRolloutSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("progress", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id/progress"),
+		)
+		a.Description("Show the progress of a staged rollout of a tenant update, including the status of the update of its current stage")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the rollout")
+			a.Required("id")
+		})
+		a.Response(d.OK, tenantUpdateRolloutProgressSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description(`Create a staged rollout of a tenant update. The stages are started one after the other by the scheduler, each stage
+being started once the update of the previous one is over, with no more failed tenants than allowed, and once the time to wait after
+the previous stage is over. When the updates must be approved by another user, all the stages must be limited to a cluster.`)
+		a.Payload(createTenantUpdateRollout)
+		a.Response(d.Created, "/tenants/update/rollouts/.*", func() {
+			a.Media(tenantUpdateRolloutSingle)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("pause", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/pause"),
+		)
+		a.Description("Pause a running rollout, so that no stage is started until it is resumed (the update of the current stage, if any, is not stopped)")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the rollout")
+			a.Required("id")
+		})
+		a.Response(d.OK, tenantUpdateRolloutSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("resume", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/resume"),
+		)
+		a.Description("Resume a paused rollout")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the rollout")
+			a.Required("id")
+		})
+		a.Response(d.OK, tenantUpdateRolloutSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("abort", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/abort"),
+		)
+		a.Description("Abort a running or paused rollout, so that no stage is started anymore (the update of the current stage, if any, is not stopped)")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the rollout")
+			a.Required("id")
+		})
+		a.Response(d.OK, tenantUpdateRolloutSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var createTenantUpdateRollout = a.MediaType("application/vnd.createtenantupdaterollout+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("CreateTenantUpdateRollout")
+	a.Description("Create a staged rollout of a tenant update")
+	a.Attributes(func() {
+		a.Attribute("data", createTenantUpdateRolloutData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+// createTenantUpdateRolloutData represents the data of a rollout to create
+var createTenantUpdateRolloutData = a.Type("CreateTenantUpdateRolloutData", func() {
+	a.Attribute("type", d.String, "type of the rollout", func() {
+		a.Enum("tenant_update_rollouts")
+	})
+	a.Attribute("attributes", createTenantUpdateRolloutDataAttributes, "Attributes of the rollout")
+	a.Required("type", "attributes")
+})
+
+var createTenantUpdateRolloutDataAttributes = a.Type("CreateTenantUpdateRolloutDataAttributes", func() {
+	a.Attribute("stages", a.ArrayOf(createTenantUpdateRolloutStage), "the stages of the rollout, in the order in which they should be run", func() {
+		a.MinLength(1)
+	})
+	a.Required("stages")
+})
+
+// createTenantUpdateRolloutStage represents a stage of a rollout to create
+var createTenantUpdateRolloutStage = a.Type("CreateTenantUpdateRolloutStage", func() {
+	a.Attribute("cluster_url", d.String, "the URL of the OSO cluster the update of the stage should be limited to")
+	a.Attribute("env_type", d.String, "environment type the update of the stage should be executed for", func() {
+		a.Enum("user", "che", "jenkins", "stage", "run")
+	})
+	a.Attribute("max_failed_count", d.Integer, "the maximum number of tenants whose update may fail for the next stage to be started (0 if not specified)", func() {
+		a.Minimum(0)
+	})
+	a.Attribute("wait", d.Integer, "the number of seconds to wait after the update of the stage is over before the next stage is started (0 if not specified)", func() {
+		a.Minimum(0)
+	})
+})
+
+var tenantUpdateRolloutList = JSONList(
+	"TenantUpdateRollout",
+	"Holds the list of staged rollouts of the tenant updates",
+	tenantUpdateRolloutData,
+	nil,
+	nil)
+
+var tenantUpdateRolloutSingle = JSONSingle(
+	"TenantUpdateRollout",
+	"Holds a single staged rollout of a tenant update",
+	tenantUpdateRolloutData,
+	nil)
+
+// tenantUpdateRolloutData represents the data of a staged rollout of a tenant update
+var tenantUpdateRolloutData = a.Type("TenantUpdateRolloutData", func() {
+	a.Attribute("type", d.String, "type of the rollout", func() {
+		a.Enum("tenant_update_rollouts")
+	})
+	a.Attribute("id", d.String, "ID of the rollout", func() {
+		a.Example("7e1e5ba0-0d8b-4c64-b3b5-9f4f4e4c5d1a")
+	})
+	a.Attribute("attributes", tenantUpdateRolloutDataAttributes, "Attributes of the rollout")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "id", "attributes")
+})
+
+var tenantUpdateRolloutDataAttributes = a.Type("TenantUpdateRolloutDataAttributes", func() {
+	a.Attribute("status", d.String, "the status of the rollout", func() {
+		a.Enum("running", "paused", "completed", "failed", "aborted")
+	})
+	a.Attribute("current_stage", d.Integer, "the position of the stage which is running or about to be started, or of the last stage which was run if the rollout is over")
+	a.Attribute("reason", d.String, "the reason why the rollout failed, if it did")
+	a.Attribute("created_by", d.String, "the username of the user who created the rollout")
+	a.Attribute("created_at", d.DateTime, "the date and time when the rollout was created")
+	a.Attribute("paused_by", d.String, "the username of the user who paused the rollout, if it is paused")
+	a.Attribute("paused_at", d.DateTime, "the date and time when the rollout was paused, if it is paused")
+	a.Attribute("aborted_by", d.String, "the username of the user who aborted the rollout, if any")
+	a.Attribute("aborted_at", d.DateTime, "the date and time when the rollout was aborted, if it was")
+	a.Attribute("finished_at", d.DateTime, "the date and time when the rollout completed, failed or was aborted, if it is over")
+	a.Attribute("stages", a.ArrayOf(tenantUpdateRolloutStage), "the stages of the rollout, in the order in which they are run")
+	a.Required("status", "current_stage", "created_by", "created_at", "stages")
+})
+
+// tenantUpdateRolloutStage represents a stage of a staged rollout of a tenant update
+var tenantUpdateRolloutStage = a.Type("TenantUpdateRolloutStage", func() {
+	a.Attribute("position", d.Integer, "the position of the stage in the rollout, starting at 0")
+	a.Attribute("cluster_url", d.String, "the URL of the OSO cluster to which the update of the stage is limited, if any")
+	a.Attribute("env_type", d.String, "the environment type to which the update of the stage is limited, if any")
+	a.Attribute("max_failed_count", d.Integer, "the maximum number of tenants whose update may fail for the next stage to be started")
+	a.Attribute("wait", d.Integer, "the number of seconds to wait after the update of the stage is over before the next stage is started")
+	a.Attribute("status", d.String, "the status of the stage", func() {
+		a.Enum("pending", "starting", "running", "succeeded", "failed")
+	})
+	a.Attribute("job_id", d.String, "the ID of the tenant update job of the stage, once started")
+	a.Attribute("status_code", d.Integer, "the status of the response of the tenant service when the update of the stage was last started (0 if no response was received)")
+	a.Attribute("started_at", d.DateTime, "the date and time when the update of the stage was started, if it was")
+	a.Attribute("finished_at", d.DateTime, "the date and time when the stage succeeded or failed, if it is over")
+	a.Attribute("failed_count", d.Integer, "the number of tenants whose update failed, once the stage is over")
+	a.Required("position", "max_failed_count", "wait", "status")
+})
+
+var tenantUpdateRolloutProgressSingle = JSONSingle(
+	"TenantUpdateRolloutProgress",
+	"Holds the progress of a staged rollout of a tenant update",
+	tenantUpdateRolloutProgressData,
+	nil)
+
+// tenantUpdateRolloutProgressData represents the progress of a staged rollout of a tenant update
+var tenantUpdateRolloutProgressData = a.Type("TenantUpdateRolloutProgressData", func() {
+	a.Attribute("type", d.String, "type of the progress", func() {
+		a.Enum("tenant_update_rollout_progress")
+	})
+	a.Attribute("id", d.String, "ID of the rollout", func() {
+		a.Example("7e1e5ba0-0d8b-4c64-b3b5-9f4f4e4c5d1a")
+	})
+	a.Attribute("attributes", tenantUpdateRolloutProgressDataAttributes, "Attributes of the progress")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "id", "attributes")
+})
+
+var tenantUpdateRolloutProgressDataAttributes = a.Type("TenantUpdateRolloutProgressDataAttributes", func() {
+	a.Attribute("status", d.String, "the status of the rollout", func() {
+		a.Enum("running", "paused", "completed", "failed", "aborted")
+	})
+	a.Attribute("total_stages", d.Integer, "the number of stages of the rollout")
+	a.Attribute("succeeded_stages", d.Integer, "the number of stages which succeeded")
+	a.Attribute("current_stage", d.Integer, "the position of the stage which is running or about to be started, or of the last stage which was run if the rollout is over")
+	a.Attribute("current_stage_status", d.String, "the status of the current stage", func() {
+		a.Enum("pending", "starting", "running", "succeeded", "failed")
+	})
+	a.Attribute("current_job_id", d.String, "the ID of the tenant update job of the current stage, if it was started")
+	a.Attribute("current_job_status", d.String, "the status of the tenant update job of the current stage (as last refreshed from the tenant service), if it was started")
+	a.Attribute("failed_count", d.Integer, "the number of tenants whose update failed so far, in all the stages")
+	a.Attribute("reason", d.String, "the reason why the rollout failed, if it did")
+	a.Required("status", "total_stages", "succeeded_stages", "current_stage", "current_stage_status", "failed_count")
+})
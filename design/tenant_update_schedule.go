@@ -0,0 +1,153 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("tenant_update_schedule", func() {
+
+	a.BasePath("/tenants/update/schedules")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List the scheduled cluster-wide tenant updates, in the order in which they were scheduled")
+		a.Params(func() {
+			a.Param("active", d.Boolean, "whether only the schedules of the updates which will be started (again) should be listed", func() {
+				a.Default(true)
+			})
+		})
+		a.Response(d.OK, tenantUpdateScheduleList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Show a scheduled cluster-wide tenant update")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the schedule")
+			a.Required("id")
+		})
+		a.Response(d.OK, tenantUpdateScheduleSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description("Schedule a cluster-wide tenant update, started once at a given time or periodically according to a cron expression (in UTC). When the updates must be approved by another user, only the updates limited to a cluster can be scheduled.")
+		a.Payload(createTenantUpdateSchedule)
+		a.Response(d.Created, "/tenants/update/schedules/.*", func() {
+			a.Media(tenantUpdateScheduleSingle)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("cancel", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id"),
+		)
+		a.Description("Cancel a scheduled cluster-wide tenant update, so that it is not started anymore")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the schedule")
+			a.Required("id")
+		})
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var createTenantUpdateSchedule = a.MediaType("application/vnd.createtenantupdateschedule+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("CreateTenantUpdateSchedule")
+	a.Description("Schedule a tenant update")
+	a.Attributes(func() {
+		a.Attribute("data", createTenantUpdateScheduleData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+// createTenantUpdateScheduleData represents the data of a tenant update to schedule
+var createTenantUpdateScheduleData = a.Type("CreateTenantUpdateScheduleData", func() {
+	a.Attribute("type", d.String, "type of the schedule", func() {
+		a.Enum("tenant_update_schedules")
+	})
+	a.Attribute("attributes", createTenantUpdateScheduleDataAttributes, "Attributes of the schedule")
+	a.Required("type", "attributes")
+})
+
+var createTenantUpdateScheduleDataAttributes = a.Type("CreateTenantUpdateScheduleDataAttributes", func() {
+	a.Attribute("cluster_url", d.String, "the URL of the OSO cluster the update should be limited to")
+	a.Attribute("env_type", d.String, "environment type the update should be executed for", func() {
+		a.Enum("user", "che", "jenkins", "stage", "run")
+	})
+	a.Attribute("cron", d.String, "the cron expression (minute, hour, day of month, month and day of week, in UTC) of a recurring update", func() {
+		a.Example("0 2 * * 6")
+	})
+	a.Attribute("run_at", d.DateTime, "the date and time when a one-off update should be started (ignored if a cron expression is given)")
+})
+
+var tenantUpdateScheduleList = JSONList(
+	"TenantUpdateSchedule",
+	"Holds the list of scheduled tenant updates",
+	tenantUpdateScheduleData,
+	nil,
+	nil)
+
+var tenantUpdateScheduleSingle = JSONSingle(
+	"TenantUpdateSchedule",
+	"Holds a single scheduled tenant update",
+	tenantUpdateScheduleData,
+	nil)
+
+// tenantUpdateScheduleData represents the data of a scheduled tenant update
+var tenantUpdateScheduleData = a.Type("TenantUpdateScheduleData", func() {
+	a.Attribute("type", d.String, "type of the schedule", func() {
+		a.Enum("tenant_update_schedules")
+	})
+	a.Attribute("id", d.String, "ID of the schedule", func() {
+		a.Example("0b0aa4a4-8d8e-4d0e-9a8b-4bd0c5b7e7b4")
+	})
+	a.Attribute("attributes", tenantUpdateScheduleDataAttributes, "Attributes of the schedule")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "id", "attributes")
+})
+
+var tenantUpdateScheduleDataAttributes = a.Type("TenantUpdateScheduleDataAttributes", func() {
+	a.Attribute("cluster_url", d.String, "the URL of the OSO cluster to which the update is limited, if any")
+	a.Attribute("env_type", d.String, "the environment type to which the update is limited, if any")
+	a.Attribute("cron", d.String, "the cron expression of a recurring update, if any")
+	a.Attribute("next_run_at", d.DateTime, "the date and time when the update will be started next, unless the schedule is over or cancelled")
+	a.Attribute("last_run_at", d.DateTime, "the date and time when the update was last started, if any")
+	a.Attribute("last_run_status", d.Integer, "the status of the response of the tenant service when the update was last started (0 if no response was received)")
+	a.Attribute("created_by", d.String, "the username of the user who scheduled the update")
+	a.Attribute("created_at", d.DateTime, "the date and time when the update was scheduled")
+	a.Attribute("cancelled_by", d.String, "the username of the user who cancelled the schedule, if any")
+	a.Attribute("cancelled_at", d.DateTime, "the date and time when the schedule was cancelled, if any")
+	a.Required("created_by", "created_at")
+})
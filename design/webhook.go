@@ -0,0 +1,202 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("webhook", func() {
+
+	a.BasePath("/webhooks")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List all the webhooks")
+		a.Response(d.OK, webhookList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description("Show a webhook")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the webhook")
+			a.Required("id")
+		})
+		a.Response(d.OK, webhookSingle)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description("Register a webhook to which the audit log events are delivered")
+		a.Payload(createWebhook)
+		a.Response(d.Created, "/webhooks/.*", func() {
+			a.Media(webhookSingle)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id"),
+		)
+		a.Description("Delete a webhook, along with its delivery history")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the webhook")
+			a.Required("id")
+		})
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("list_deliveries", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id/deliveries"),
+		)
+		a.Description("List the last deliveries of the events to a webhook, most recent first")
+		a.Params(func() {
+			a.Param("id", d.UUID, "the ID of the webhook")
+			a.Param("status", d.String, "the status of the deliveries to list", func() {
+				a.Enum("pending", "delivering", "delivered", "dead")
+			})
+			a.Param("limit", d.Integer, "the maximum number of deliveries to list", func() {
+				a.Minimum(1)
+				a.Maximum(100)
+				a.Default(20)
+			})
+			a.Required("id")
+		})
+		a.Response(d.OK, webhookDeliveryList)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var createWebhook = a.MediaType("application/vnd.createwebhook+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("CreateWebhook")
+	a.Description("Register a webhook")
+	a.Attributes(func() {
+		a.Attribute("data", createWebhookData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+// createWebhookData represents the data of a webhook to register
+var createWebhookData = a.Type("CreateWebhookData", func() {
+	a.Attribute("type", d.String, "type of the webhook", func() {
+		a.Enum("webhooks")
+	})
+	a.Attribute("attributes", createWebhookDataAttributes, "Attributes of the webhook")
+	a.Required("type", "attributes")
+})
+
+var createWebhookDataAttributes = a.Type("CreateWebhookDataAttributes", func() {
+	a.Attribute("url", d.String, "the URL to which the events are posted", func() {
+		a.Pattern("^https?://")
+		a.Example("https://slack-bot.example.com/events")
+	})
+	a.Attribute("secret", d.String, "the secret with which the payloads are signed (HMAC-SHA256)", func() {
+		a.MinLength(16)
+	})
+	a.Attribute("event_types", a.ArrayOf(d.String), "the names of the types of the events to deliver (all events if empty)")
+	a.Required("url", "secret")
+})
+
+var webhookList = JSONList(
+	"Webhook",
+	"Holds the list of webhooks",
+	webhookData,
+	nil,
+	nil)
+
+var webhookSingle = JSONSingle(
+	"Webhook",
+	"Holds a single webhook",
+	webhookData,
+	nil)
+
+// webhookData represents the data of a webhook (its secret is never returned)
+var webhookData = a.Type("WebhookData", func() {
+	a.Attribute("type", d.String, "type of the webhook", func() {
+		a.Enum("webhooks")
+	})
+	a.Attribute("id", d.String, "ID of the webhook", func() {
+		a.Example("97e3bac6-eefe-4636-9bf4-20576a3a9aa8")
+	})
+	a.Attribute("attributes", webhookDataAttributes, "Attributes of the webhook")
+	a.Attribute("links", genericLinks)
+	a.Required("type", "id", "attributes")
+})
+
+var webhookDataAttributes = a.Type("WebhookDataAttributes", func() {
+	a.Attribute("url", d.String, "the URL to which the events are posted")
+	a.Attribute("event_types", a.ArrayOf(d.String), "the names of the types of the delivered events (all events if empty)")
+	a.Attribute("created_by", d.String, "the username of the user who registered the webhook")
+	a.Attribute("created_at", d.DateTime, "the date and time when the webhook was registered")
+	a.Required("url", "event_types", "created_by", "created_at")
+})
+
+var webhookDeliveryList = JSONList(
+	"WebhookDelivery",
+	"Holds the list of deliveries to a webhook",
+	webhookDeliveryData,
+	nil,
+	nil)
+
+// webhookDeliveryData represents the delivery of an event to a webhook
+var webhookDeliveryData = a.Type("WebhookDeliveryData", func() {
+	a.Attribute("type", d.String, "type of the delivery", func() {
+		a.Enum("webhook_deliveries")
+	})
+	a.Attribute("id", d.String, "ID of the delivery", func() {
+		a.Example("732a6a86-5a6b-425c-a4dc-344ee5107067")
+	})
+	a.Attribute("attributes", webhookDeliveryDataAttributes, "Attributes of the delivery")
+	a.Required("type", "id", "attributes")
+})
+
+var webhookDeliveryDataAttributes = a.Type("WebhookDeliveryDataAttributes", func() {
+	a.Attribute("event_id", d.String, "the ID of the delivered event (ie, of the audit log record)")
+	a.Attribute("event_type", d.String, "the name of the type of the delivered event")
+	a.Attribute("status", d.String, "the status of the delivery", func() {
+		a.Enum("pending", "delivering", "delivered", "dead")
+	})
+	a.Attribute("attempts", d.Integer, "the number of attempts to deliver the event")
+	a.Attribute("next_attempt_at", d.DateTime, "the date and time of the next attempt, if the delivery is pending or being delivered (in which case the event is delivered again if it was not delivered by then)")
+	a.Attribute("last_status_code", d.Integer, "the status of the response to the last attempt, if any")
+	a.Attribute("last_error", d.String, "the error which occurred during the last failed attempt, if any")
+	a.Attribute("delivered_at", d.DateTime, "the date and time when the event was delivered")
+	a.Attribute("created_at", d.DateTime, "the date and time when the delivery was scheduled")
+	a.Required("event_id", "event_type", "status", "attempts", "created_at")
+})
@@ -0,0 +1,27 @@
+// Package gormutil contains the helpers shared by the gorm repositories of the admin console.
+package gormutil
+
+import (
+	"database/sql"
+
+	"github.com/jinzhu/gorm"
+)
+
+// InTransaction calls the given function with a DB in a transaction: the given DB if it is already in a transaction,
+// or a new transaction which is committed if the function did not return any error, and rolled back otherwise.
+// Unlike `application.Transactional`, it lets a repository use a transaction of its own when it is not called within
+// the transaction of an application.
+func InTransaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if _, ok := db.CommonDB().(*sql.Tx); ok {
+		return fn(db)
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
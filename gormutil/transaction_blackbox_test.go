@@ -0,0 +1,68 @@
+package gormutil_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/gormutil"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TransactionBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+}
+
+func TestTransaction(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &TransactionBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *TransactionBlackboxTestSuite) TestInTransaction() {
+
+	s.T().Run("new transaction", func(t *testing.T) {
+		// when
+		err := gormutil.InTransaction(s.DB, func(tx *gorm.DB) error {
+			_, ok := tx.CommonDB().(*sql.Tx)
+			assert.True(t, ok)
+			return nil
+		})
+		// then
+		require.NoError(t, err)
+	})
+
+	s.T().Run("current transaction", func(t *testing.T) {
+		// given
+		tx := s.DB.Begin()
+		require.NoError(t, tx.Error)
+		defer tx.Rollback()
+		// when
+		err := gormutil.InTransaction(tx, func(db *gorm.DB) error {
+			assert.Equal(t, tx.CommonDB(), db.CommonDB())
+			return nil
+		})
+		// then
+		require.NoError(t, err)
+	})
+
+	s.T().Run("rolled back", func(t *testing.T) {
+		// when
+		err := gormutil.InTransaction(s.DB, func(tx *gorm.DB) error {
+			if err := tx.Exec("create table gormutil_rollback_test (id integer)").Error; err != nil {
+				return err
+			}
+			return errs.New("failure")
+		})
+		// then
+		require.EqualError(t, err, "failure")
+		assert.False(t, s.DB.HasTable("gormutil_rollback_test"))
+	})
+}
@@ -0,0 +1,17 @@
+// Package httputil contains the helpers shared by the HTTP clients of the admin console.
+package httputil
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+// MaxDrainedBodySize the maximum number of bytes read from a response body before the connection is closed instead of
+// being reused
+const MaxDrainedBodySize = 64 * 1024
+
+// DrainBody reads the rest of the given response body (up to `MaxDrainedBodySize` bytes) and discards it, so that the
+// connection can be reused once the body is closed
+func DrainBody(body io.Reader) {
+	io.Copy(ioutil.Discard, io.LimitReader(body, MaxDrainedBodySize))
+}
@@ -0,0 +1,32 @@
+package httputil_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/fabric8-services/admin-console/httputil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainBody(t *testing.T) {
+
+	t.Run("small body", func(t *testing.T) {
+		// given
+		body := strings.NewReader("foo")
+		// when
+		httputil.DrainBody(body)
+		// then
+		assert.Equal(t, 0, body.Len())
+	})
+
+	t.Run("large body", func(t *testing.T) {
+		// given
+		body := bytes.NewReader(make([]byte, httputil.MaxDrainedBodySize+10))
+		// when
+		httputil.DrainBody(body)
+		// then the rest of the body is not read
+		assert.Equal(t, 10, body.Len())
+	})
+}
@@ -11,9 +11,18 @@ import (
 
 	"github.com/fabric8-services/admin-console/app"
 	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/authorization"
 	"github.com/fabric8-services/admin-console/configuration"
 	"github.com/fabric8-services/admin-console/controller"
 	"github.com/fabric8-services/admin-console/migration"
+	"github.com/fabric8-services/admin-console/outbox"
+	"github.com/fabric8-services/admin-console/retention"
+	"github.com/fabric8-services/admin-console/scheduler"
+	"github.com/fabric8-services/admin-console/spool"
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/admin-console/webhook"
 	authsupport "github.com/fabric8-services/fabric8-common/auth"
 	"github.com/fabric8-services/fabric8-common/closeable"
 	"github.com/fabric8-services/fabric8-common/goamiddleware"
@@ -41,10 +50,12 @@ func main() {
 	var configFilePath string
 	var printConfig bool
 	var migrateDB bool
+	var verifyAuditLogs bool
 
 	flag.StringVar(&configFilePath, "config", "", "Path to the config file to read")
 	flag.BoolVar(&printConfig, "printConfig", false, "Prints the config (including merged environment variables) and exits")
 	flag.BoolVar(&migrateDB, "migrateDatabase", false, "Migrates the database to the newest version and exits.")
+	flag.BoolVar(&verifyAuditLogs, "verifyAuditLogs", false, "Verifies that the chain of audit log records was not tampered with and exits.")
 	flag.Parse()
 
 	config := configuration.New()
@@ -73,10 +84,35 @@ func main() {
 			"err": err,
 		}, "failed migration")
 	}
+	// Create the upcoming partitions of the audit logs table, and check them regularly
+	if _, err := migration.CreateAuditLogPartitions(db.DB(), time.Now(), config.GetAuditLogPartitionsAhead()); err != nil {
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "failed to create the upcoming audit log partitions")
+	}
 	// Nothing to here except exit, since the migration is already performed.
 	if migrateDB {
 		os.Exit(0)
 	}
+	go migration.MaintainAuditLogPartitions(context.Background(), db.DB(), config.GetAuditLogPartitionsInterval(), config.GetAuditLogPartitionsAhead())
+	// Verify the audit log chain and exit with a non-zero code if it is broken
+	if verifyAuditLogs {
+		brk, err := auditlog.NewRepository(db).VerifyChain(context.Background(), 0)
+		if err != nil {
+			log.Panic(nil, map[string]interface{}{
+				"err": err,
+			}, "failed to verify the audit log chain")
+		}
+		if brk != nil {
+			log.Error(nil, map[string]interface{}{
+				"chain_seq": brk.Seq,
+				"record_id": brk.ID,
+			}, "%s", brk.String())
+			os.Exit(1)
+		}
+		log.Info(nil, map[string]interface{}{}, "audit log chain is intact")
+		os.Exit(0)
+	}
 
 	// Initialize sentry client
 	// haltSentry, err := sentry.InitializeSentryClient(
@@ -108,6 +144,15 @@ func main() {
 	service.WithLogger(goalogrus.New(log.Logger()))
 
 	appDB := application.NewGormApplication(db)
+	// assign the 'admin' role to the users listed in the configuration, so that they can manage the other roles
+	for _, username := range config.GetAuthorizationAdmins() {
+		if err := appDB.Roles().Assign(context.Background(), username, "admin", "admin-console"); err != nil {
+			log.Error(nil, map[string]interface{}{
+				"err":      err,
+				"username": username,
+			}, "failed to assign the 'admin' role")
+		}
+	}
 	tokenManager, err := authsupport.DefaultManager(config)
 	if err != nil {
 		log.Panic(nil, map[string]interface{}{
@@ -120,6 +165,36 @@ func main() {
 	service.Use(jwtMiddlewareTokenContext)
 	service.Use(authsupport.InjectTokenManager(tokenManager))
 	service.Use(log.LogRequest(config.IsDeveloperModeEnabled()))
+	// Middleware that applies the failure policies when the audit log records of the admin actions cannot be stored
+	auditLogSpool, err := spool.New(config.GetAuditLogSpoolDir())
+	if err != nil {
+		log.Panic(nil, map[string]interface{}{
+			"err": err,
+			"dir": config.GetAuditLogSpoolDir(),
+		}, "failed to open the audit logs spool")
+	}
+	eventTypes, err := appDB.EventTypes().Names(context.Background())
+	if err != nil {
+		log.Panic(nil, map[string]interface{}{
+			"err": err,
+		}, "failed to load the event types")
+	}
+	failurePolicies, err := spool.ParsePolicies(config.GetAuditLogFailurePolicies(), eventTypes)
+	if err != nil {
+		log.Panic(nil, map[string]interface{}{
+			"err": err,
+		}, "invalid audit log failure policies")
+	}
+	service.Use(spool.InjectRecorder(spool.NewRecorder(auditLogSpool, failurePolicies,
+		spool.FailurePolicy(config.GetAuditLogDefaultFailurePolicy()), eventTypes)))
+	// Middleware that verifies that the user has the permission required by the requested action
+	claimPermissions, err := authorization.NewClaimPermissions(config.GetAuthorizationClaims())
+	if err != nil {
+		log.Panic(nil, map[string]interface{}{
+			"err": err,
+		}, "invalid authorization claims")
+	}
+	service.Use(authorization.Middleware(appDB.Roles(), claimPermissions, controller.RecordAccessDenied(config, appDB)))
 	app.UseJWTMiddleware(service, jwt.New(tokenManager.PublicKeys(), nil, app.NewJWTSecurity()))
 
 	// Mount the '/status' controller
@@ -131,14 +206,76 @@ func main() {
 	searchCtrl := controller.NewSearchController(service, config, appDB)
 	app.MountSearchController(service, searchCtrl)
 
-	// Mount the '/tenants/update' controller
-	tenantUpdateCtrl := controller.NewTenantUpdateController(service, config, appDB)
+	// Mount the '/tenants/update' controller, which calls the tenant service with a typed client (shared with the poller,
+	// the requests controller and the scheduler), and whose started updates are refreshed by polling the tenant service
+	// on behalf of the service account
+	tenantClient := tenant.NewClient(config, &http.Client{Timeout: config.GetTenantUpdateTimeout()})
+	serviceAccountTokens := scheduler.NewServiceAccountTokenSource(config, &http.Client{Timeout: config.GetTenantUpdateTimeout()})
+	go tenantupdate.NewPoller(config, appDB.TenantUpdateJobs(), serviceAccountTokens, tenantClient).Start(context.Background())
+	tenantUpdateCtrl := controller.NewTenantUpdateController(service, config, appDB, tenantClient)
 	app.MountTenantUpdateController(service, tenantUpdateCtrl)
 
-	// Mount the '/auditlogs/users' controller
-	auditLogsCtrl := controller.NewAuditLogsController(service, config, appDB)
+	// Mount the '/tenants/update/requests' controller, which starts the approved updates
+	tenantUpdateRequestsCtrl := controller.NewTenantUpdateRequestsController(service, config, appDB, tenantClient)
+	app.MountTenantUpdateRequestController(service, tenantUpdateRequestsCtrl)
+
+	// Mount the '/tenants/updates' controller
+	tenantUpdateJobsCtrl := controller.NewTenantUpdateJobsController(service, config, appDB)
+	app.MountTenantUpdateJobController(service, tenantUpdateJobsCtrl)
+
+	// Mount the '/tenants/update/schedules' controller, and start the scheduler on behalf of the service account (which
+	// also advances the staged rollouts)
+	tenantUpdateSchedulesCtrl := controller.NewTenantUpdateSchedulesController(service, config, appDB)
+	app.MountTenantUpdateScheduleController(service, tenantUpdateSchedulesCtrl)
+	go scheduler.NewWorker(config, appDB, serviceAccountTokens, tenantClient).Start(context.Background())
+
+	// Mount the '/tenants/update/rollouts' controller, whose stages are started by the scheduler
+	tenantUpdateRolloutsCtrl := controller.NewTenantUpdateRolloutsController(service, config, appDB)
+	app.MountTenantUpdateRolloutController(service, tenantUpdateRolloutsCtrl)
+
+	// Mount the '/auditlogs' controller, whose new records are notified to the clients streaming them
+	auditLogNotifier, err := auditlog.NewNotifier(config.GetPostgresConfigString())
+	if err != nil {
+		log.Panic(nil, map[string]interface{}{
+			"err": err,
+		}, "failed to listen to the audit log notifications")
+	}
+	go auditLogNotifier.Start(context.Background())
+	auditLogsCtrl := controller.NewAuditLogsController(service, config, appDB, auditLogNotifier)
 	app.MountAuditLogController(service, auditLogsCtrl)
 
+	// Mount the '/eventtypes' controller
+	eventTypesCtrl := controller.NewEventTypesController(service, config, appDB)
+	app.MountEventTypeController(service, eventTypesCtrl)
+
+	// Mount the '/roles' controller
+	rolesCtrl := controller.NewRolesController(service, config, appDB)
+	app.MountRoleController(service, rolesCtrl)
+
+	// Mount the '/webhooks' controller
+	webhooksCtrl := controller.NewWebhooksController(service, config, appDB)
+	app.MountWebhookController(service, webhooksCtrl)
+
+	// Start the audit logs retention worker
+	go retention.NewWorker(config, appDB).Start(context.Background())
+
+	// Start the worker which replays the spooled audit logs
+	go spool.NewWorker(auditLogSpool, appDB, config.GetAuditLogSpoolInterval()).Start(context.Background())
+
+	// Start the relay which publishes the audit log events
+	outboxSink, err := outbox.NewSink(config.GetAuditLogOutboxSink(), config.GetAuditLogOutboxFile(), config.GetAuditLogOutboxURL(),
+		&http.Client{Timeout: config.GetAuditLogOutboxTimeout()})
+	if err != nil {
+		log.Panic(nil, map[string]interface{}{
+			"err": err,
+		}, "invalid audit log outbox sink")
+	}
+	// the events are also scheduled for delivery to the webhooks
+	go outbox.NewRelay(config, appDB, outbox.Sinks(outbox.NewWebhookSink(appDB.Webhooks()), outboxSink)).Start(context.Background())
+
+	// Start the dispatcher which delivers the audit log events to the webhooks
+	go webhook.NewDispatcher(config, appDB.Webhooks(), webhook.NewHTTPClient(config.GetWebhookTimeout())).Start(context.Background())
+
 	log.Logger().Infoln("Git Commit SHA: ", app.Commit)
 	log.Logger().Infoln("UTC Build Time: ", app.BuildTime)
 	log.Logger().Infoln("UTC Start Time: ", app.StartTime)
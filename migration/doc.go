@@ -1,3 +1,8 @@
 package migration
 
 // package for database migration, when deploying a new schema
+//
+// The migrations require PostgreSQL 12 or later: the `011-audit-log-partitions.sql` migration partitions the `audit_log`
+// table by month, and changes its primary key from `audit_log_id` to `(audit_log_id, created_at)`, since the primary key
+// of a partitioned table must include its partition key. No foreign key references the `audit_log` table, and the
+// statements which update or delete a record match it on both its ID and its creation date.
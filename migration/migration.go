@@ -23,6 +23,33 @@ func Steps() Scripts {
 		{"003-audit-log-with-username.sql"},
 		{"004-deactivation-event-types.sql"},
 		{"005-list-audit-logs-event-type.sql"},
+		{"006-audit-log-filter-indexes.sql"},
+		{"007-audit-log-keyset-index.sql"},
+		{"008-export-audit-logs-event-type.sql"},
+		{"009-audit-log-hash-chain.sql"},
+		{"010-audit-log-archive.sql"},
+		{"011-audit-log-partitions.sql"},
+		{"012-event-type-registry.sql"},
+		{"013-event-type-params-schemas.sql"},
+		{"014-audit-log-idempotency-keys.sql"},
+		{"015-authorization.sql"},
+		{"016-access-denied-event-type.sql"},
+		{"017-audit-log-outcome.sql"},
+		{"018-audit-log-response.sql"},
+		{"019-audit-log-outbox.sql"},
+		{"020-webhooks.sql"},
+		{"021-audit-log-notify.sql"},
+		{"022-audit-log-aggregation.sql"},
+		{"023-tenant-update-jobs.sql"},
+		{"024-tenant-update-schedules.sql"},
+		{"025-tenant-update-requests.sql"},
+		{"026-tenant-update-rollouts.sql"},
+		{"027-audit-log-idempotency-key-hashes.sql"},
+		{"028-show-audit-log-event-type.sql"},
+		{"029-webhook-delivery-leases.sql"},
+		{"030-user-deactivation-event-type-name.sql"},
+		{"031-audit-log-outbox-leases.sql"},
+		{"032-audit-log-outbox-retries.sql"},
 	}
 }
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/fabric8-services/admin-console/migration"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/fabric8-services/fabric8-common/resource"
 
 	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -85,6 +87,8 @@ func (s *MigrationTestSuite) TestMigrate() {
 	dialect.SetDB(sqlDB)
 
 	s.T().Run("checkMigration001", checkMigration001)
+	s.T().Run("checkMigration011", checkMigration011)
+	s.T().Run("checkMigration030", checkMigration030)
 
 }
 
@@ -128,3 +132,82 @@ func checkMigration001(t *testing.T) {
 	})
 
 }
+
+func checkMigration011(t *testing.T) {
+	// given a record created before the partitioning
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:11])
+	require.NoError(t, err)
+	_, err = sqlDB.Exec(`INSERT INTO audit_log (audit_log_id, created_at, username, event_type_id, event_params)
+		VALUES ('4eb0b5a5-b3b8-4c0c-a2e8-1d0a4d3c2a2f', '2018-06-15 10:00:00+00', 'foo', '7aea0277-d6fa-4df9-8224-a27fa4096ec7', '{}')`)
+	require.NoError(t, err)
+	// when
+	err = migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:12])
+	// then
+	require.NoError(t, err)
+
+	t.Run("existing record moved to its partition", func(t *testing.T) {
+		// when
+		var partition string
+		err := sqlDB.QueryRow("SELECT tableoid::regclass::text FROM audit_log WHERE audit_log_id = '4eb0b5a5-b3b8-4c0c-a2e8-1d0a4d3c2a2f'").Scan(&partition)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "audit_log_2018_06", partition)
+	})
+
+	t.Run("create upcoming partitions", func(t *testing.T) {
+		// when (twice, to verify that existing partitions are skipped)
+		_, err := migration.CreateAuditLogPartitions(sqlDB, time.Date(2100, 12, 31, 23, 0, 0, 0, time.UTC), 1)
+		require.NoError(t, err)
+		names, err := migration.CreateAuditLogPartitions(sqlDB, time.Date(2100, 12, 31, 23, 0, 0, 0, time.UTC), 1)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"audit_log_2100_12", "audit_log_2101_01"}, names)
+		_, err = sqlDB.Exec(`INSERT INTO audit_log (created_at, username, event_type_id, event_params)
+			VALUES ('2101-01-01 00:00:00+00', 'foo', '7aea0277-d6fa-4df9-8224-a27fa4096ec7', '{}')`)
+		require.NoError(t, err)
+		var partition string
+		err = sqlDB.QueryRow("SELECT tableoid::regclass::text FROM audit_log WHERE created_at = '2101-01-01 00:00:00+00'").Scan(&partition)
+		require.NoError(t, err)
+		assert.Equal(t, "audit_log_2101_01", partition)
+	})
+
+	t.Run("move records from the default partition", func(t *testing.T) {
+		// given a record stored in the default partition, since its partition did not exist yet
+		_, err := sqlDB.Exec(`INSERT INTO audit_log (audit_log_id, created_at, username, event_type_id, event_params)
+			VALUES ('a6b1c7e2-3f0d-4e4b-9c1a-5d2e8f7b6a90', '2102-03-15 10:00:00+00', 'foo', '7aea0277-d6fa-4df9-8224-a27fa4096ec7', '{}')`)
+		require.NoError(t, err)
+		var partition string
+		err = sqlDB.QueryRow("SELECT tableoid::regclass::text FROM audit_log WHERE audit_log_id = 'a6b1c7e2-3f0d-4e4b-9c1a-5d2e8f7b6a90'").Scan(&partition)
+		require.NoError(t, err)
+		require.Equal(t, "audit_log_default", partition)
+		// when
+		names, err := migration.CreateAuditLogPartitions(sqlDB, time.Date(2102, 3, 1, 0, 0, 0, 0, time.UTC), 0)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"audit_log_2102_03"}, names)
+		err = sqlDB.QueryRow("SELECT tableoid::regclass::text FROM audit_log WHERE audit_log_id = 'a6b1c7e2-3f0d-4e4b-9c1a-5d2e8f7b6a90'").Scan(&partition)
+		require.NoError(t, err)
+		assert.Equal(t, "audit_log_2102_03", partition)
+		// and the default partition is still attached
+		_, err = sqlDB.Exec(`INSERT INTO audit_log (created_at, username, event_type_id, event_params)
+			VALUES ('2103-01-01 00:00:00+00', 'foo', '7aea0277-d6fa-4df9-8224-a27fa4096ec7', '{}')`)
+		require.NoError(t, err)
+		err = sqlDB.QueryRow("SELECT tableoid::regclass::text FROM audit_log WHERE created_at = '2103-01-01 00:00:00+00'").Scan(&partition)
+		require.NoError(t, err)
+		assert.Equal(t, "audit_log_default", partition)
+	})
+}
+
+func checkMigration030(t *testing.T) {
+	// when
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:31])
+	// then
+	require.NoError(t, err)
+	var description string
+	var schema sql.NullString
+	err = sqlDB.QueryRow("SELECT description, params_schema::text FROM event_type WHERE event_type_id = '777ede15-4b18-4720-bada-1519d1915f2e' AND name = 'user_deactivation'").
+		Scan(&description, &schema)
+	require.NoError(t, err)
+	assert.Equal(t, "A user account was deactivated", description)
+	assert.True(t, schema.Valid)
+}
@@ -0,0 +1,113 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/log"
+
+	errs "github.com/pkg/errors"
+)
+
+// partitionsLockID the key of the advisory lock held while a partition is created, so that concurrent instances
+// of the service do not try to move the same records out of the default partition
+const partitionsLockID = 0x706172746974 // "partit"
+
+// CreateAuditLogPartitions creates the monthly partitions of the `audit_log` table for the month of the given date
+// and for the `ahead` following months, unless they already exist. The months are in UTC.
+// The records which were stored in the default partition for one of these months (because its partition did not exist
+// yet) are moved to the new partition.
+// returns the names of the partitions
+func CreateAuditLogPartitions(db *sql.DB, now time.Time, ahead int) ([]string, error) {
+	now = now.UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	names := make([]string, 0, ahead+1)
+	for i := 0; i <= ahead; i++ {
+		from := month.AddDate(0, i, 0)
+		to := from.AddDate(0, 1, 0)
+		name := fmt.Sprintf("audit_log_%s", from.Format("2006_01"))
+		if err := createAuditLogPartition(db, name, from, to); err != nil {
+			return names, errs.Wrapf(err, "unable to create the audit log partition '%s'", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// createAuditLogPartition creates the partition of the `audit_log` table with the given name and bounds, unless it
+// already exists. A partition cannot be created while the default partition contains records within its bounds, so
+// in that case, the default partition is detached while these records are moved to the new partition.
+func createAuditLogPartition(db *sql.DB, name string, from, to time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", partitionsLockID); err != nil {
+		return err
+	}
+	var exists bool
+	if err := tx.QueryRow("SELECT to_regclass($1) IS NOT NULL", name).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	var pending bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM audit_log_default WHERE created_at >= $1 AND created_at < $2)", from, to).
+		Scan(&pending)
+	if err != nil {
+		return err
+	}
+	// partition bounds cannot be bind parameters
+	bounds := fmt.Sprintf("FROM ('%s') TO ('%s')", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE %s PARTITION OF audit_log FOR VALUES %s", name, bounds),
+	}
+	if pending {
+		// the records are moved while the new table is not attached yet, so that the insert triggers of the
+		// `audit_log` table do not fire again
+		statements = []string{
+			"ALTER TABLE audit_log DETACH PARTITION audit_log_default",
+			fmt.Sprintf("CREATE TABLE %s (LIKE audit_log INCLUDING DEFAULTS)", name),
+			fmt.Sprintf(`WITH moved AS (DELETE FROM audit_log_default WHERE created_at >= '%s' AND created_at < '%s' RETURNING *)
+				INSERT INTO %s SELECT * FROM moved`, from.Format(time.RFC3339), to.Format(time.RFC3339), name),
+			fmt.Sprintf("ALTER TABLE audit_log ATTACH PARTITION %s FOR VALUES %s", name, bounds),
+			"ALTER TABLE audit_log ATTACH PARTITION audit_log_default DEFAULT",
+		}
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// MaintainAuditLogPartitions creates the upcoming monthly partitions of the `audit_log` table at the given interval,
+// until the given context is done. See `CreateAuditLogPartitions`.
+func MaintainAuditLogPartitions(ctx context.Context, db *sql.DB, interval time.Duration, ahead int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			names, err := CreateAuditLogPartitions(db, time.Now(), ahead)
+			if err != nil {
+				// in the meantime, the records are stored in the default partition, and they are moved to their
+				// partition once it is created
+				log.Error(ctx, map[string]interface{}{
+					"err": err,
+				}, "failed to create the upcoming audit log partitions")
+				continue
+			}
+			log.Info(ctx, map[string]interface{}{
+				"partitions": names,
+			}, "audit log partitions are ready")
+		}
+	}
+}
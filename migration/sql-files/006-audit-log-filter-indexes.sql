@@ -0,0 +1,4 @@
+-- index to query the audit logs in a given time range, and sort them by creation date
+CREATE INDEX ix_auditlog_created_at ON audit_log USING btree (created_at);
+-- index to query the audit logs given some key/value pairs in their event params
+CREATE INDEX ix_auditlog_event_params ON audit_log USING gin (event_params jsonb_path_ops);
@@ -0,0 +1,3 @@
+-- index to browse the audit logs of a user with keyset pagination, ie, using the
+-- (created_at, audit_log_id) of the last record of the previous page as the lower bound
+CREATE INDEX ix_auditlog_username_created_at_id ON audit_log USING btree (username, created_at, audit_log_id);
@@ -0,0 +1 @@
+insert into event_type (event_type_id, name) values ('4c0d50d1-582d-418b-8d07-d96366a532ba', 'export_audit_logs');
\ No newline at end of file
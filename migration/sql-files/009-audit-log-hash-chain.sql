@@ -0,0 +1,15 @@
+-- chain the audit log records with hashes, to detect when a record was edited or deleted.
+-- records created before this migration are not part of the chain.
+ALTER TABLE audit_log ADD COLUMN chain_seq bigint;
+ALTER TABLE audit_log ADD COLUMN prev_hash text;
+ALTER TABLE audit_log ADD COLUMN hash text;
+CREATE UNIQUE INDEX uix_auditlog_chain_seq ON audit_log USING btree (chain_seq);
+
+-- the head of the chain, ie, the sequence number and hash of the last record.
+-- this table contains a single row, which is locked while a record is appended to the chain.
+CREATE TABLE audit_log_chain (
+    id int primary key CONSTRAINT single_row CHECK (id = 1),
+    seq bigint NOT NULL,
+    hash text NOT NULL
+);
+insert into audit_log_chain (id, seq, hash) values (1, 0, '');
@@ -0,0 +1,18 @@
+-- the audit log records which were archived (and deleted) by the retention worker.
+-- only the metadata and the hashes are kept, so the chain of records can still be verified,
+-- while the content of the records can be found in the archive file.
+CREATE TABLE audit_log_archived (
+    audit_log_id uuid primary key,
+    created_at timestamp with time zone,
+    event_type_id uuid,
+    chain_seq bigint,
+    prev_hash text,
+    hash text,
+    archive text NOT NULL,
+    archived_at timestamp with time zone NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX uix_auditlog_archived_chain_seq ON audit_log_archived USING btree (chain_seq);
+-- speeds up the lookup of the expired records of a given type
+CREATE INDEX idx_auditlog_event_type_created_at ON audit_log USING btree (event_type_id, created_at);
+
+insert into event_type (event_type_id, name) values ('505ee427-ed66-4a25-8d30-f06931ff2c32', 'archive_audit_logs');
@@ -0,0 +1,52 @@
+-- convert the audit_log table into a table partitioned by month on the `created_at` column.
+-- requires PostgreSQL 12 or later.
+-- The partitions are named `audit_log_YYYY_MM` and cover a month in UTC. The partitions of the
+-- upcoming months are created by the service at startup and then on a regular basis.
+ALTER TABLE audit_log RENAME TO audit_log_unpartitioned;
+
+CREATE TABLE audit_log (
+    audit_log_id uuid DEFAULT uuid_generate_v4() NOT NULL,
+    created_at timestamp with time zone NOT NULL default now(),
+    identity_id uuid,
+    event_type_id uuid NOT NULL,
+    event_params jsonb NOT NULL,
+    username text,
+    chain_seq bigint,
+    prev_hash text,
+    hash text
+) PARTITION BY RANGE (created_at);
+
+-- catches the records for which there is no monthly partition
+CREATE TABLE audit_log_default PARTITION OF audit_log DEFAULT;
+
+-- create the monthly partitions from the oldest record until 2 months ahead
+DO $$
+DECLARE
+    m timestamp;
+BEGIN
+    m := date_trunc('month', coalesce((select min(created_at) from audit_log_unpartitioned), now()) at time zone 'UTC');
+    WHILE m <= date_trunc('month', now() at time zone 'UTC') + interval '2 months' LOOP
+        EXECUTE format('CREATE TABLE %I PARTITION OF audit_log FOR VALUES FROM (%L) TO (%L)',
+            'audit_log_' || to_char(m, 'YYYY_MM'),
+            to_char(m, 'YYYY-MM-DD HH24:MI:SS') || '+00',
+            to_char(m + interval '1 month', 'YYYY-MM-DD HH24:MI:SS') || '+00');
+        m := m + interval '1 month';
+    END LOOP;
+END $$;
+
+INSERT INTO audit_log (audit_log_id, created_at, identity_id, event_type_id, event_params, username, chain_seq, prev_hash, hash)
+    SELECT audit_log_id, created_at, identity_id, event_type_id, event_params, username, chain_seq, prev_hash, hash
+    FROM audit_log_unpartitioned;
+
+DROP TABLE audit_log_unpartitioned;
+
+-- the primary key and unique indexes of a partitioned table must include the partition key
+ALTER TABLE audit_log ADD PRIMARY KEY (audit_log_id, created_at);
+ALTER TABLE audit_log ADD CONSTRAINT audit_logs_event_type_fk FOREIGN KEY (event_type_id) REFERENCES event_type (event_type_id);
+-- the chain sequence numbers are still unique since the records are appended while the head of the chain is locked
+CREATE INDEX ix_auditlog_chain_seq ON audit_log USING btree (chain_seq);
+CREATE INDEX ix_auditlog_username ON audit_log USING btree (username);
+CREATE INDEX ix_auditlog_created_at ON audit_log USING btree (created_at);
+CREATE INDEX ix_auditlog_event_params ON audit_log USING gin (event_params jsonb_path_ops);
+CREATE INDEX ix_auditlog_username_created_at_id ON audit_log USING btree (username, created_at, audit_log_id);
+CREATE INDEX idx_auditlog_event_type_created_at ON audit_log USING btree (event_type_id, created_at);
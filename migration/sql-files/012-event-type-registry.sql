@@ -0,0 +1,16 @@
+-- event types can be registered at runtime, with a description and a JSON schema for their params
+ALTER TABLE event_type ADD COLUMN description text;
+ALTER TABLE event_type ADD COLUMN params_schema jsonb;
+-- internal event types can only be recorded by the admin console itself
+ALTER TABLE event_type ADD COLUMN internal boolean NOT NULL DEFAULT false;
+ALTER TABLE event_type ADD COLUMN created_at timestamp with time zone NOT NULL DEFAULT now();
+
+UPDATE event_type SET internal = true, description = 'A user searched for other users' WHERE name = 'user_search';
+UPDATE event_type SET internal = true, description = 'A user viewed the status of the tenant update' WHERE name = 'show_tenant_update';
+UPDATE event_type SET internal = true, description = 'A user started a tenant update' WHERE name = 'start_tenant_update';
+UPDATE event_type SET internal = true, description = 'A user stopped the tenant update' WHERE name = 'stop_tenant_update';
+UPDATE event_type SET description = 'A user was notified that her account will be deactivated' WHERE name = 'user_deactivation_notification';
+UPDATE event_type SET description = 'A user account was deactivated' WHERE name = 'user_deactivation';
+UPDATE event_type SET internal = true, description = 'A user listed audit logs' WHERE name = 'list_audit_logs';
+UPDATE event_type SET internal = true, description = 'A user exported the audit logs of another user' WHERE name = 'export_audit_logs';
+UPDATE event_type SET internal = true, description = 'The expired audit logs were archived' WHERE name = 'archive_audit_logs';
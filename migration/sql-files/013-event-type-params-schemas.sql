@@ -0,0 +1,21 @@
+-- JSON schemas of the params of the built-in event types
+UPDATE event_type SET params_schema = '{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "query": {"type": "string", "maxLength": 256}
+  },
+  "required": ["query"],
+  "additionalProperties": false
+}' WHERE name = 'user_search';
+
+UPDATE event_type SET params_schema = '{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "notification_deactivation": {"type": "string", "maxLength": 64},
+    "scheduled_deactivation": {"type": "string", "maxLength": 64}
+  },
+  "additionalProperties": {"type": ["string", "number", "boolean"], "maxLength": 256},
+  "maxProperties": 16
+}' WHERE name IN ('user_deactivation', 'user_deactivation_notification');
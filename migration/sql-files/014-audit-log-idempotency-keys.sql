@@ -0,0 +1,8 @@
+-- the idempotency keys of the audit log records, so that retried requests do not record the same event twice.
+-- the keys are kept in a separate table because a unique constraint on the partitioned 'audit_log' table
+-- would have to include the 'created_at' partition key.
+CREATE TABLE audit_log_idempotency_key (
+    idempotency_key text primary key,
+    audit_log_id uuid NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
@@ -0,0 +1,52 @@
+-- roles, with the permissions they grant
+CREATE TABLE role (
+    role_id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    name text NOT NULL,
+    description text,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX uix_role_name ON role USING btree (name);
+
+CREATE TABLE role_permission (
+    role_id uuid NOT NULL REFERENCES role (role_id) ON DELETE CASCADE,
+    permission text NOT NULL,
+    PRIMARY KEY (role_id, permission)
+);
+
+-- the roles assigned to the users, given their username
+CREATE TABLE role_assignment (
+    username text NOT NULL,
+    role_id uuid NOT NULL REFERENCES role (role_id) ON DELETE CASCADE,
+    assigned_by text NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (username, role_id)
+);
+
+-- built-in roles
+INSERT INTO role (role_id, name, description) VALUES
+    ('1b9c0b0e-7f86-4d2c-9d4c-5bb8ec1bd3a1', 'admin', 'Manages the roles and their assignments, and can perform all actions'),
+    ('5ad8f6ea-3c0f-4a55-8e0c-1c4c2a0f5a6e', 'auditor', 'Lists and exports the audit logs'),
+    ('a4c2d97b-6e2b-4f67-a0f3-3f5a0f4cbf41', 'tenant-operator', 'Views, starts and stops the tenant updates'),
+    ('e0a6b3c4-54a0-4d5e-9a8a-8f1b6a5c7d02', 'support', 'Searches for users and lists their audit logs');
+
+INSERT INTO role_permission (role_id, permission) VALUES
+    ('1b9c0b0e-7f86-4d2c-9d4c-5bb8ec1bd3a1', 'manage_roles'),
+    ('1b9c0b0e-7f86-4d2c-9d4c-5bb8ec1bd3a1', 'search_users'),
+    ('1b9c0b0e-7f86-4d2c-9d4c-5bb8ec1bd3a1', 'show_tenant_update'),
+    ('1b9c0b0e-7f86-4d2c-9d4c-5bb8ec1bd3a1', 'start_tenant_update'),
+    ('1b9c0b0e-7f86-4d2c-9d4c-5bb8ec1bd3a1', 'stop_tenant_update'),
+    ('1b9c0b0e-7f86-4d2c-9d4c-5bb8ec1bd3a1', 'read_audit_logs'),
+    ('1b9c0b0e-7f86-4d2c-9d4c-5bb8ec1bd3a1', 'export_audit_logs'),
+    ('5ad8f6ea-3c0f-4a55-8e0c-1c4c2a0f5a6e', 'read_audit_logs'),
+    ('5ad8f6ea-3c0f-4a55-8e0c-1c4c2a0f5a6e', 'export_audit_logs'),
+    ('a4c2d97b-6e2b-4f67-a0f3-3f5a0f4cbf41', 'show_tenant_update'),
+    ('a4c2d97b-6e2b-4f67-a0f3-3f5a0f4cbf41', 'start_tenant_update'),
+    ('a4c2d97b-6e2b-4f67-a0f3-3f5a0f4cbf41', 'stop_tenant_update'),
+    ('e0a6b3c4-54a0-4d5e-9a8a-8f1b6a5c7d02', 'search_users'),
+    ('e0a6b3c4-54a0-4d5e-9a8a-8f1b6a5c7d02', 'read_audit_logs');
+
+-- events recorded when the roles are managed
+INSERT INTO event_type (event_type_id, name, description, internal) VALUES
+    ('366b6ac1-5c03-4162-afbb-4c22ff24d4bf', 'create_role', 'A user created a role', true),
+    ('513c1be2-f65e-4349-86b6-342dd2c0b726', 'assign_role', 'A user assigned a role to another user', true),
+    ('b489c6a1-107b-4f8c-a20a-01a28856ad47', 'revoke_role', 'A user revoked a role from another user', true);
@@ -0,0 +1,3 @@
+-- event recorded when a user is denied an action because she lacks the required permission
+INSERT INTO event_type (event_type_id, name, description, internal) VALUES
+    ('9c1f6d0e-7a3b-4e58-b2d4-63f0c8a1e5b7', 'access_denied', 'A user was denied an action', true);
@@ -0,0 +1,4 @@
+-- the outcome of the audited actions, set once the action completed (hence not covered by the hash of the records)
+ALTER TABLE audit_log ADD COLUMN status_code integer;
+ALTER TABLE audit_log ADD COLUMN outcome text;
+ALTER TABLE audit_log ADD COLUMN latency_ms bigint;
@@ -0,0 +1,4 @@
+-- the size of the response to the audited actions and the number of results they returned (if relevant),
+-- set once the action completed (hence not covered by the hash of the records)
+ALTER TABLE audit_log ADD COLUMN response_size bigint;
+ALTER TABLE audit_log ADD COLUMN result_count integer;
@@ -0,0 +1,13 @@
+-- the outbox of the audit log records, which are written in the same transaction as the records themselves
+-- and then published as events by the relay worker. The entries are deleted once they were published.
+CREATE TABLE audit_log_outbox (
+    seq bigserial primary key,
+    audit_log_id uuid NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    identity_id uuid,
+    username text,
+    event_type_id uuid NOT NULL,
+    event_params jsonb NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text
+);
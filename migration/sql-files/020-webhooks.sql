@@ -0,0 +1,44 @@
+-- the webhooks to which the audit log events are delivered
+CREATE TABLE webhook_subscription (
+    subscription_id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    url text NOT NULL,
+    secret text NOT NULL,
+    created_by text NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+-- the types of the events delivered to the webhooks (all events are delivered to the webhooks which have none)
+CREATE TABLE webhook_subscription_event_type (
+    subscription_id uuid NOT NULL REFERENCES webhook_subscription (subscription_id) ON DELETE CASCADE,
+    event_type_id uuid NOT NULL REFERENCES event_type (event_type_id),
+    PRIMARY KEY (subscription_id, event_type_id)
+);
+
+-- the deliveries of the events to the webhooks, which are kept as the delivery history of the webhooks
+CREATE TABLE webhook_delivery (
+    delivery_id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    subscription_id uuid NOT NULL REFERENCES webhook_subscription (subscription_id) ON DELETE CASCADE,
+    event_id uuid NOT NULL,
+    event_type text NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamp with time zone NOT NULL DEFAULT now(),
+    last_status_code integer,
+    last_error text,
+    delivered_at timestamp with time zone,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+-- an event is delivered at most once to each webhook (unless the delivery is retried)
+CREATE UNIQUE INDEX uix_webhook_delivery_subscription_event ON webhook_delivery USING btree (subscription_id, event_id);
+CREATE INDEX ix_webhook_delivery_subscription_created_at ON webhook_delivery USING btree (subscription_id, created_at);
+CREATE INDEX ix_webhook_delivery_pending ON webhook_delivery USING btree (next_attempt_at) WHERE status = 'pending';
+
+-- the webhooks are managed by the admins
+INSERT INTO role_permission (role_id, permission) VALUES
+    ('1b9c0b0e-7f86-4d2c-9d4c-5bb8ec1bd3a1', 'manage_webhooks');
+
+-- events recorded when the webhooks are managed
+INSERT INTO event_type (event_type_id, name, description, internal) VALUES
+    ('97e3bac6-eefe-4636-9bf4-20576a3a9aa8', 'create_webhook', 'A user registered a webhook', true),
+    ('732a6a86-5a6b-425c-a4dc-344ee5107067', 'delete_webhook', 'A user deleted a webhook', true);
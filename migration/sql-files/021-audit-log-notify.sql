@@ -0,0 +1,16 @@
+-- notify the listeners on the `audit_log` channel when a record is inserted (once the transaction is committed),
+-- with the sequence number of the record in the chain, so that the new records can be streamed to the clients
+CREATE OR REPLACE FUNCTION notify_audit_log() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('audit_log', coalesce(NEW.chain_seq::text, ''));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- row triggers on a partitioned table are cloned to all its partitions, including the upcoming ones
+CREATE TRIGGER audit_log_notify AFTER INSERT ON audit_log
+    FOR EACH ROW EXECUTE PROCEDURE notify_audit_log();
+
+-- event recorded when a user streams the audit logs
+INSERT INTO event_type (event_type_id, name, description, internal) VALUES
+    ('e3c1d3a5-0b6f-4f0e-a3b9-2f6f3c8a5d17', 'stream_audit_logs', 'A user streamed the new audit logs', true);
@@ -0,0 +1,10 @@
+-- covering indexes to count the audit logs in a given time range, grouped by event type and/or username, without
+-- reading the records themselves. They supersede the indexes on the same key columns.
+DROP INDEX ix_auditlog_created_at;
+CREATE INDEX ix_auditlog_created_at ON audit_log USING btree (created_at) INCLUDE (event_type_id, username);
+DROP INDEX idx_auditlog_event_type_created_at;
+CREATE INDEX ix_auditlog_event_type_created_at ON audit_log USING btree (event_type_id, created_at) INCLUDE (username);
+
+-- event recorded when a user aggregates the audit logs
+INSERT INTO event_type (event_type_id, name, description, internal) VALUES
+    ('ac4a1eaf-a3ee-45de-9974-559e3cb1e896', 'aggregate_audit_logs', 'A user counted the audit logs by group', true);
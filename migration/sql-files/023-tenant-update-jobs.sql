@@ -0,0 +1,17 @@
+-- the history of the cluster-wide tenant updates started via the admin console, whose status is refreshed
+-- from the tenant service until they are over
+CREATE TABLE tenant_update_job (
+    job_id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    cluster_url text,
+    env_type text,
+    status text NOT NULL,
+    started_by text NOT NULL,
+    started_at timestamp with time zone NOT NULL DEFAULT now(),
+    stop_requested_by text,
+    stop_requested_at timestamp with time zone,
+    finished_at timestamp with time zone,
+    failed_count integer NOT NULL DEFAULT 0,
+    refreshed_at timestamp with time zone
+);
+CREATE INDEX ix_tenant_update_job_started_at ON tenant_update_job USING btree (started_at);
+CREATE INDEX ix_tenant_update_job_running ON tenant_update_job USING btree (started_at) WHERE finished_at IS NULL;
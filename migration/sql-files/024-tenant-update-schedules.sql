@@ -0,0 +1,21 @@
+-- the tenant updates which are started by the scheduler, once or periodically (according to a cron expression)
+CREATE TABLE tenant_update_schedule (
+    schedule_id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    cluster_url text,
+    env_type text,
+    cron_expression text,
+    next_run_at timestamp with time zone,
+    last_run_at timestamp with time zone,
+    last_run_status integer,
+    created_by text NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    cancelled_by text,
+    cancelled_at timestamp with time zone
+);
+CREATE INDEX ix_tenant_update_schedule_next_run_at ON tenant_update_schedule USING btree (next_run_at) WHERE next_run_at IS NOT NULL;
+
+-- events recorded when the tenant updates are scheduled, started by the scheduler or cancelled
+INSERT INTO event_type (event_type_id, name, description, internal) VALUES
+    ('8f955651-17ae-4af7-885c-ef551274e61b', 'create_tenant_update_schedule', 'A user scheduled a tenant update', true),
+    ('605e24af-b7cd-43c8-b2de-db9884d080b9', 'execute_tenant_update_schedule', 'The scheduler started a scheduled tenant update', true),
+    ('b5178f26-3e68-4485-bc95-ffb769fb4de0', 'cancel_tenant_update_schedule', 'A user cancelled a scheduled tenant update', true);
@@ -0,0 +1,26 @@
+-- the requests to start a cluster-wide tenant update, which must be approved by another user before the update is
+-- started, when the approval of the tenant updates is required
+CREATE TABLE tenant_update_request (
+    request_id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    cluster_url text,
+    env_type text,
+    status text NOT NULL,
+    requested_by text NOT NULL,
+    requested_at timestamp with time zone NOT NULL DEFAULT now(),
+    expires_at timestamp with time zone NOT NULL,
+    reviewed_by text,
+    reviewed_at timestamp with time zone,
+    status_code integer
+);
+CREATE INDEX ix_tenant_update_request_requested_at ON tenant_update_request USING btree (requested_at);
+CREATE INDEX ix_tenant_update_request_pending ON tenant_update_request USING btree (expires_at) WHERE status = 'pending';
+
+INSERT INTO role_permission (role_id, permission) VALUES
+    ('1b9c0b0e-7f86-4d2c-9d4c-5bb8ec1bd3a1', 'approve_tenant_update');
+
+-- events recorded when the tenant updates are requested, approved, rejected or when their requests expire
+INSERT INTO event_type (event_type_id, name, description, internal) VALUES
+    ('29a37212-13e9-4d2d-986a-584bcc20245b', 'request_tenant_update', 'A user requested to start a tenant update, pending approval', true),
+    ('f59a55cc-4465-4654-9199-c8cc9a29de01', 'approve_tenant_update', 'A user approved the request of another user to start a tenant update', true),
+    ('4748e7b8-2a7d-496b-b5ea-62e378a205fa', 'reject_tenant_update', 'A user rejected the request of another user to start a tenant update', true),
+    ('6e6418aa-cd83-49ed-98d7-f7fcea5c1e39', 'expire_tenant_update_request', 'A request to start a tenant update expired before it was approved', true);
@@ -0,0 +1,41 @@
+-- the staged rollouts of the tenant updates, which are started cluster by cluster (or environment type by environment
+-- type) by the scheduler, each stage being started once the previous one succeeded
+CREATE TABLE tenant_update_rollout (
+    rollout_id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    status text NOT NULL,
+    current_stage integer NOT NULL DEFAULT 0,
+    reason text,
+    created_by text NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    paused_by text,
+    paused_at timestamp with time zone,
+    aborted_by text,
+    aborted_at timestamp with time zone,
+    finished_at timestamp with time zone
+);
+CREATE INDEX ix_tenant_update_rollout_running ON tenant_update_rollout USING btree (created_at) WHERE status = 'running';
+
+CREATE TABLE tenant_update_rollout_stage (
+    rollout_id uuid NOT NULL REFERENCES tenant_update_rollout(rollout_id) ON DELETE CASCADE,
+    position integer NOT NULL,
+    cluster_url text,
+    env_type text,
+    max_failed_count integer NOT NULL DEFAULT 0,
+    wait_seconds integer NOT NULL DEFAULT 0,
+    status text NOT NULL,
+    job_id uuid,
+    status_code integer,
+    started_at timestamp with time zone,
+    finished_at timestamp with time zone,
+    failed_count integer,
+    PRIMARY KEY (rollout_id, position)
+);
+
+-- events recorded when the rollouts are created, paused, resumed or aborted by a user, and when they are advanced by
+-- the scheduler
+INSERT INTO event_type (event_type_id, name, description, internal) VALUES
+    ('7854a1f8-2fbd-471b-b8e3-d5d68b1d972c', 'create_tenant_update_rollout', 'A user created a staged rollout of a tenant update', true),
+    ('f46a2787-d747-4266-bd3e-0207052b15fd', 'pause_tenant_update_rollout', 'A user paused a staged rollout of a tenant update', true),
+    ('0ae8cf3e-aa28-4262-9c3a-fcc736165ce4', 'resume_tenant_update_rollout', 'A user resumed a staged rollout of a tenant update', true),
+    ('c86780ad-0165-4101-a94c-e116fd3e7bd4', 'abort_tenant_update_rollout', 'A user aborted a staged rollout of a tenant update', true),
+    ('b427a522-e951-428d-ad74-19628635855a', 'advance_tenant_update_rollout', 'The scheduler started or completed a stage of a rollout of a tenant update', true);
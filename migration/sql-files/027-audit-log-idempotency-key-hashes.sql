@@ -0,0 +1,7 @@
+-- the hash of the payload of the record created with each idempotency key, so that a request which reuses a key
+-- with a different payload can be rejected. The hash of the keys stored before this migration is unknown.
+ALTER TABLE audit_log_idempotency_key ADD COLUMN payload_hash text;
+
+-- the keys are purged once they expired, or once their record was archived or deleted
+CREATE INDEX ix_audit_log_idempotency_key_created_at ON audit_log_idempotency_key USING btree (created_at);
+CREATE INDEX ix_audit_log_idempotency_key_audit_log_id ON audit_log_idempotency_key USING btree (audit_log_id);
@@ -0,0 +1,3 @@
+-- event recorded when a user shows a single audit log, which was previously recorded as a 'list_audit_logs' event
+INSERT INTO event_type (event_type_id, name, description, internal) VALUES
+    ('f870ea58-a31b-4667-af69-9d275e44c3e3', 'show_audit_log', 'A user showed a single audit log', true);
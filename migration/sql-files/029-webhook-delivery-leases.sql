@@ -0,0 +1,3 @@
+-- the deliveries which are being delivered are leased until their next attempt, and attempted again once their lease expired
+DROP INDEX ix_webhook_delivery_pending;
+CREATE INDEX ix_webhook_delivery_pending ON webhook_delivery USING btree (next_attempt_at) WHERE status IN ('pending', 'delivering');
@@ -0,0 +1,5 @@
+-- the 'user_deactivation' event type was registered with a typo, so its description and params schema were not applied
+UPDATE event_type SET name = 'user_deactivation' WHERE name = 'user_deactiuvation';
+UPDATE event_type SET description = 'A user account was deactivated', params_schema = (
+    SELECT params_schema FROM event_type WHERE name = 'user_deactivation_notification'
+) WHERE name = 'user_deactivation';
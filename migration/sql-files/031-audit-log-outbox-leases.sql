@@ -0,0 +1,3 @@
+-- the outbox entries are leased by the relay while they are published outside of any transaction, and published again
+-- once their lease expired
+ALTER TABLE audit_log_outbox ADD COLUMN claimed_until timestamp with time zone;
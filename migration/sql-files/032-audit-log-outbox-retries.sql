@@ -0,0 +1,5 @@
+-- the outbox entries which could not be published are retried after a delay, during which the next entries with the
+-- same partition key are held back, and they are marked as dead after too many failed attempts, so that they no longer
+-- hold back the next entries
+ALTER TABLE audit_log_outbox ADD COLUMN next_attempt_at timestamp with time zone;
+ALTER TABLE audit_log_outbox ADD COLUMN dead_at timestamp with time zone;
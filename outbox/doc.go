@@ -0,0 +1,3 @@
+// Package outbox contains the relay worker which publishes the entries of the audit log outbox as CloudEvents,
+// so that the other services can react to the admin actions, along with the sinks to which the events can be published.
+package outbox
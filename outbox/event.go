@@ -0,0 +1,63 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/fabric8-services/admin-console/auditlog"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// SpecVersion the version of the CloudEvents specification with which the events comply
+	SpecVersion = "1.0"
+	// TypePrefix the prefix of the type of the events, which is followed by the name of the audit log event type
+	TypePrefix = "io.fabric8.admin-console.auditlog."
+	// ContentType the content type of the events, in the structured mode of the CloudEvents HTTP binding
+	ContentType = "application/cloudevents+json"
+)
+
+// Event an audit log record, as a CloudEvent (see https://github.com/cloudevents/spec/blob/v1.0/spec.md)
+type Event struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject,omitempty"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	// PartitionKey the key of the events which must be delivered in order (see the "partitioning" extension)
+	PartitionKey string    `json:"partitionkey"`
+	Data         EventData `json:"data"`
+}
+
+// EventData the data of an audit log event
+type EventData struct {
+	IdentityID  string               `json:"identity_id,omitempty"`
+	Username    string               `json:"username,omitempty"`
+	EventType   string               `json:"event_type"`
+	EventParams auditlog.EventParams `json:"event_params"`
+}
+
+// NewEvent returns the event for the given outbox entry, whose event type has the given name
+func NewEvent(entry auditlog.OutboxEntry, eventType string, source string) Event {
+	data := EventData{
+		Username:    entry.Username,
+		EventType:   eventType,
+		EventParams: entry.EventParams,
+	}
+	if entry.IdentityID != uuid.Nil {
+		data.IdentityID = entry.IdentityID.String()
+	}
+	return Event{
+		SpecVersion:     SpecVersion,
+		ID:              entry.AuditLogID.String(),
+		Source:          source,
+		Type:            TypePrefix + eventType,
+		Subject:         entry.Username,
+		Time:            entry.CreatedAt.UTC(),
+		DataContentType: "application/json",
+		PartitionKey:    entry.PartitionKey(),
+		Data:            data,
+	}
+}
@@ -0,0 +1,126 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	publishedEventsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "admin_console_auditlog_published_events_total",
+		Help: "Number of audit log events published by the outbox relay.",
+	}, []string{"event_type"})
+	publishFailuresCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "admin_console_auditlog_publish_failures_total",
+		Help: "Number of failed attempts to publish an audit log event.",
+	})
+	pendingEventsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "admin_console_auditlog_outbox_pending_events",
+		Help: "Number of audit log events waiting to be published in the outbox.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(publishedEventsCounter, publishFailuresCounter, pendingEventsGauge)
+}
+
+// Configuration the configuration of the relay
+type Configuration interface {
+	GetAuditLogOutboxSource() string
+	GetAuditLogOutboxInterval() time.Duration
+	GetAuditLogOutboxBatchSize() int
+	GetAuditLogOutboxTimeout() time.Duration
+}
+
+// Relay publishes the entries of the audit log outbox to a sink, by batches. The entries are published at least once,
+// and in order for a given user: when an entry could not be published, the next entries of the same user are held back
+// until it is published during a subsequent run, after a delay which grows with each failed attempt. An entry which
+// failed too many times is marked as dead and no longer holds them back (see `auditlog.MaxPublishAttempts`).
+type Relay struct {
+	config Configuration
+	db     application.DB
+	sink   Sink
+}
+
+// NewRelay returns a new relay which publishes the outbox entries to the given sink
+func NewRelay(config Configuration, db application.DB, sink Sink) *Relay {
+	return &Relay{
+		config: config,
+		db:     db,
+		sink:   sink,
+	}
+}
+
+// Start runs the relay immediately and then at the configured interval, until the given context is done
+func (r *Relay) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.config.GetAuditLogOutboxInterval())
+	defer ticker.Stop()
+	for {
+		// errors are logged and the relay will try again during the next run
+		r.Run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run publishes the pending outbox entries, by batches, until the outbox is empty or an entry could not be published.
+// returns the number of published entries and the first error that occurred
+func (r *Relay) Run(ctx context.Context) (int, error) {
+	total, err := r.publish(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"published": total,
+		}, "failed to publish the audit log events")
+	} else if total > 0 {
+		log.Info(ctx, map[string]interface{}{
+			"published": total,
+		}, "published the audit log events")
+	}
+	if pending, err := r.db.AuditLogs().CountPending(ctx); err == nil {
+		pendingEventsGauge.Set(float64(pending))
+	}
+	return total, err
+}
+
+func (r *Relay) publish(ctx context.Context) (int, error) {
+	names, err := r.db.EventTypes().Names(ctx)
+	if err != nil {
+		return 0, err
+	}
+	source := r.config.GetAuditLogOutboxSource()
+	batchSize := r.config.GetAuditLogOutboxBatchSize()
+	// the entries of a batch are leased for as long as it may take to publish them all
+	lease := time.Duration(batchSize) * r.config.GetAuditLogOutboxTimeout()
+	total := 0
+	for {
+		count, err := r.db.AuditLogs().PublishPending(ctx, batchSize, lease, func(entry auditlog.OutboxEntry) error {
+			eventType, found := names[entry.EventTypeID]
+			if !found {
+				eventType = entry.EventTypeID.String()
+			}
+			if err := r.sink.Publish(ctx, NewEvent(entry, eventType, source)); err != nil {
+				publishFailuresCounter.Inc()
+				return err
+			}
+			publishedEventsCounter.WithLabelValues(eventType).Inc()
+			return nil
+		})
+		total += count
+		if err != nil {
+			return total, err
+		}
+		if count < batchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package outbox_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/outbox"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RelayBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	app application.DB
+}
+
+func TestRelay(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &RelayBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *RelayBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.app = application.NewGormApplication(s.DB)
+}
+
+type relayConfig struct{}
+
+func (c relayConfig) GetAuditLogOutboxSource() string {
+	return "/admin-console"
+}
+
+func (c relayConfig) GetAuditLogOutboxInterval() time.Duration {
+	return time.Second
+}
+
+func (c relayConfig) GetAuditLogOutboxBatchSize() int {
+	// large enough to reach the entries of the tests, even if the outbox contains other entries
+	return 100000
+}
+
+func (c relayConfig) GetAuditLogOutboxTimeout() time.Duration {
+	return time.Millisecond
+}
+
+// smallBatchRelayConfig a configuration whose batches are smaller than the number of entries held back by the tests
+type smallBatchRelayConfig struct {
+	relayConfig
+}
+
+func (c smallBatchRelayConfig) GetAuditLogOutboxBatchSize() int {
+	return 2
+}
+
+// onlyFor returns a function which fails the events whose partition key is not one of the given usernames,
+// so that the entries created by the other tests are held back rather than consumed
+func onlyFor(usernames ...string) func(outbox.Event) error {
+	return func(event outbox.Event) error {
+		for _, username := range usernames {
+			if event.PartitionKey == username {
+				return nil
+			}
+		}
+		return errs.New("not published by this test")
+	}
+}
+
+// eventsOf returns the IDs of the published events whose partition key is the given username, in order
+func eventsOf(sink *outbox.MemorySink, username string) []string {
+	ids := []string{}
+	for _, event := range sink.Events() {
+		if event.PartitionKey == username {
+			ids = append(ids, event.ID)
+		}
+	}
+	return ids
+}
+
+func (s *RelayBlackboxTestSuite) createRecords(t *testing.T, username string, count int) []string {
+	records := make([]*auditlog.AuditLog, count)
+	for i := range records {
+		records[i] = &auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"query": fmt.Sprintf("foo%d", i),
+			},
+		}
+	}
+	_, err := s.app.AuditLogs().CreateBatch(context.Background(), records)
+	require.NoError(t, err)
+	ids := make([]string, count)
+	for i, r := range records {
+		ids[i] = r.ID.String()
+	}
+	return ids
+}
+
+func (s *RelayBlackboxTestSuite) TestRun() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		username1 := fmt.Sprintf("user-%v", uuid.NewV4())
+		username2 := fmt.Sprintf("user-%v", uuid.NewV4())
+		ids1 := s.createRecords(t, username1, 3)
+		ids2 := s.createRecords(t, username2, 2)
+		sink := outbox.NewMemorySink()
+		sink.Fail = onlyFor(username1, username2)
+		relay := outbox.NewRelay(relayConfig{}, s.app, sink)
+		// when (the error about the entries of the other tests is ignored)
+		relay.Run(context.Background())
+		// then
+		assert.Equal(t, ids1, eventsOf(sink, username1))
+		assert.Equal(t, ids2, eventsOf(sink, username2))
+		for _, event := range sink.Events() {
+			if event.PartitionKey != username1 {
+				continue
+			}
+			assert.Equal(t, outbox.SpecVersion, event.SpecVersion)
+			assert.Equal(t, "/admin-console", event.Source)
+			assert.Equal(t, "io.fabric8.admin-console.auditlog.user_search", event.Type)
+			assert.Equal(t, username1, event.Subject)
+			assert.Equal(t, auditlog.UserSearchEvent, event.Data.EventType)
+		}
+
+		t.Run("not published again", func(t *testing.T) {
+			// when
+			relay.Run(context.Background())
+			// then
+			assert.Equal(t, ids1, eventsOf(sink, username1))
+			assert.Equal(t, ids2, eventsOf(sink, username2))
+		})
+	})
+
+	s.T().Run("held back", func(t *testing.T) {
+		// given
+		username1 := fmt.Sprintf("user-%v", uuid.NewV4())
+		username2 := fmt.Sprintf("user-%v", uuid.NewV4())
+		ids1 := s.createRecords(t, username1, 3)
+		ids2 := s.createRecords(t, username2, 2)
+		sink := outbox.NewMemorySink()
+		sink.Fail = func(event outbox.Event) error {
+			if event.ID == ids1[1] {
+				return errs.New("broker unavailable")
+			}
+			return onlyFor(username1, username2)(event)
+		}
+		relay := outbox.NewRelay(relayConfig{}, s.app, sink)
+		// when
+		_, err := relay.Run(context.Background())
+		// then the next entries of the first user were held back, but not those of the second user
+		require.Error(t, err)
+		assert.Equal(t, ids1[:1], eventsOf(sink, username1))
+		assert.Equal(t, ids2, eventsOf(sink, username2))
+		entries := []auditlog.OutboxEntry{}
+		err = s.DB.Where("username = ?", username1).Order("seq").Find(&entries).Error
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, ids1[1], entries[0].AuditLogID.String())
+		assert.Equal(t, 1, entries[0].Attempts)
+		assert.Equal(t, "broker unavailable", entries[0].LastError)
+		require.NotNil(t, entries[0].NextAttemptAt)
+		assert.True(t, entries[0].NextAttemptAt.After(time.Now()))
+		assert.Nil(t, entries[0].DeadAt)
+		assert.Equal(t, 0, entries[1].Attempts)
+
+		t.Run("not retried before the delay", func(t *testing.T) {
+			// given
+			sink.Fail = onlyFor(username1, username2)
+			// when
+			relay.Run(context.Background())
+			// then
+			assert.Equal(t, ids1[:1], eventsOf(sink, username1))
+		})
+
+		t.Run("published once the delay elapsed", func(t *testing.T) {
+			// given
+			sink.Fail = onlyFor(username1, username2)
+			err := s.DB.Model(&auditlog.OutboxEntry{}).Where("audit_log_id = ?", ids1[1]).
+				Update("next_attempt_at", time.Now().Add(-time.Second)).Error
+			require.NoError(t, err)
+			// when
+			relay.Run(context.Background())
+			// then
+			assert.Equal(t, ids1, eventsOf(sink, username1))
+			assert.Equal(t, ids2, eventsOf(sink, username2))
+		})
+	})
+
+	s.T().Run("held back keys do not starve the others", func(t *testing.T) {
+		// given more entries waiting behind a failed entry than the size of a batch
+		username1 := fmt.Sprintf("user-%v", uuid.NewV4())
+		username2 := fmt.Sprintf("user-%v", uuid.NewV4())
+		ids1 := s.createRecords(t, username1, 3)
+		ids2 := s.createRecords(t, username2, 2)
+		err := s.DB.Model(&auditlog.OutboxEntry{}).Where("audit_log_id = ?", ids1[0]).
+			Updates(map[string]interface{}{"attempts": 1, "next_attempt_at": time.Now().Add(time.Hour)}).Error
+		require.NoError(t, err)
+		// and the entries of the other tests are held back as well, so that they do not fill the batches
+		err = s.DB.Model(&auditlog.OutboxEntry{}).Where("coalesce(username, '') not in (?) and dead_at is null", []string{username1, username2}).
+			Update("next_attempt_at", time.Now().Add(time.Hour)).Error
+		require.NoError(t, err)
+		sink := outbox.NewMemorySink()
+		sink.Fail = onlyFor(username1, username2)
+		relay := outbox.NewRelay(smallBatchRelayConfig{}, s.app, sink)
+		// when
+		relay.Run(context.Background())
+		// then
+		assert.Empty(t, eventsOf(sink, username1))
+		assert.Equal(t, ids2, eventsOf(sink, username2))
+	})
+
+	s.T().Run("dead after too many attempts", func(t *testing.T) {
+		// given
+		username := fmt.Sprintf("user-%v", uuid.NewV4())
+		ids := s.createRecords(t, username, 2)
+		err := s.DB.Model(&auditlog.OutboxEntry{}).Where("audit_log_id = ?", ids[0]).
+			Update("attempts", auditlog.MaxPublishAttempts-1).Error
+		require.NoError(t, err)
+		sink := outbox.NewMemorySink()
+		sink.Fail = func(event outbox.Event) error {
+			if event.ID == ids[0] {
+				return errs.New("invalid event")
+			}
+			return onlyFor(username)(event)
+		}
+		relay := outbox.NewRelay(relayConfig{}, s.app, sink)
+		// when
+		_, err = relay.Run(context.Background())
+		// then
+		require.Error(t, err)
+		entry := auditlog.OutboxEntry{}
+		err = s.DB.Where("audit_log_id = ?", ids[0]).First(&entry).Error
+		require.NoError(t, err)
+		assert.Equal(t, auditlog.MaxPublishAttempts, entry.Attempts)
+		assert.NotNil(t, entry.DeadAt)
+
+		t.Run("no longer holds back the next entries", func(t *testing.T) {
+			// when
+			relay.Run(context.Background())
+			// then
+			assert.Equal(t, ids[1:], eventsOf(sink, username))
+		})
+	})
+
+	s.T().Run("leased by another relay", func(t *testing.T) {
+		// given an entry which is being published by another relay
+		username := fmt.Sprintf("user-%v", uuid.NewV4())
+		ids := s.createRecords(t, username, 2)
+		err := s.DB.Model(&auditlog.OutboxEntry{}).Where("audit_log_id = ?", ids[0]).
+			Update("claimed_until", time.Now().Add(time.Hour)).Error
+		require.NoError(t, err)
+		sink := outbox.NewMemorySink()
+		sink.Fail = onlyFor(username)
+		relay := outbox.NewRelay(relayConfig{}, s.app, sink)
+		// when
+		count, err := relay.Run(context.Background())
+		// then nothing is published until the lease expired
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+		assert.Empty(t, eventsOf(sink, username))
+
+		t.Run("published once the lease expired", func(t *testing.T) {
+			// given
+			err := s.DB.Model(&auditlog.OutboxEntry{}).Where("audit_log_id = ?", ids[0]).
+				Update("claimed_until", time.Now().Add(-time.Minute)).Error
+			require.NoError(t, err)
+			// when
+			relay.Run(context.Background())
+			// then
+			assert.Equal(t, ids, eventsOf(sink, username))
+		})
+	})
+}
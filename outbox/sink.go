@@ -0,0 +1,194 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fabric8-services/admin-console/httputil"
+
+	errs "github.com/pkg/errors"
+)
+
+// Sink publishes the events to a message broker (or to a file, etc.). The implementations must be safe for concurrent
+// use. An event which was successfully published is not published again, unless it was published more than once by
+// the relay (eg: when the relay stopped before the outbox was updated), so the consumers must be idempotent.
+// There is no AMQP or Kafka sink yet: the events can only reach such a broker through the HTTP sink and an HTTP bridge
+// in front of the broker (eg: a Knative broker or the Kafka REST proxy).
+type Sink interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// the kinds of sinks which can be configured
+const (
+	// NoSinkKind the events are discarded
+	NoSinkKind = "none"
+	// FileSinkKind the events are appended to a file, as NDJSON
+	FileSinkKind = "file"
+	// HTTPSinkKind the events are sent to an HTTP endpoint, in the structured mode of the CloudEvents HTTP binding
+	HTTPSinkKind = "http"
+)
+
+// NewSink returns the sink of the given kind, to which the events are published. The HTTP sink sends the events with the
+// given client, which must have a timeout since the relay holds a lease on the outbox entries while they are published.
+func NewSink(kind, file, url string, client *http.Client) (Sink, error) {
+	switch kind {
+	case NoSinkKind:
+		return DiscardSink{}, nil
+	case FileSinkKind:
+		if file == "" {
+			return nil, errs.New("missing path of the file to which the audit log events are published")
+		}
+		return NewFileSink(file), nil
+	case HTTPSinkKind:
+		if url == "" {
+			return nil, errs.New("missing url to which the audit log events are published")
+		}
+		return NewHTTPSink(url, client), nil
+	default:
+		return nil, errs.Errorf("invalid kind of sink for the audit log events: '%s'", kind)
+	}
+}
+
+// multiSink a sink which publishes the events to several sinks
+type multiSink []Sink
+
+// Sinks returns a sink which publishes the events to all the given sinks, in order. Publishing an event fails if it
+// could not be published to one of the sinks, in which case it will be published again to all the sinks.
+func Sinks(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+// Publish publishes the given event to all the sinks
+func (s multiSink) Publish(ctx context.Context, event Event) error {
+	for _, sink := range s {
+		if err := sink.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DiscardSink a sink which discards the events
+type DiscardSink struct{}
+
+// Publish does nothing
+func (s DiscardSink) Publish(ctx context.Context, event Event) error {
+	return nil
+}
+
+// MemorySink a sink which keeps the events in memory, for testing purpose
+type MemorySink struct {
+	mu     sync.Mutex
+	events []Event
+	// Fail if set, returns the error to return instead of publishing the given event
+	Fail func(event Event) error
+}
+
+// NewMemorySink returns a new, empty in-memory sink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{
+		events: []Event{},
+	}
+}
+
+// Publish appends the given event to the published events
+func (s *MemorySink) Publish(ctx context.Context, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Fail != nil {
+		if err := s.Fail(event); err != nil {
+			return err
+		}
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+// Events returns the events which were published so far, in order
+func (s *MemorySink) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event{}, s.events...)
+}
+
+// FileSink a sink which appends the events to a file, as NDJSON
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileSink returns a new sink which appends the events to the file at the given path
+func NewFileSink(path string) *FileSink {
+	return &FileSink{
+		path: path,
+	}
+}
+
+// Publish appends the given event to the file, which is synced before returning
+func (s *FileSink) Publish(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return errs.Wrap(err, "unable to publish the audit log event")
+	}
+	line = append(line, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return errs.Wrapf(err, "unable to publish the audit log event in '%s'", s.path)
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return errs.Wrapf(err, "unable to publish the audit log event in '%s'", s.path)
+	}
+	defer f.Close()
+	if _, err := f.Write(line); err != nil {
+		return errs.Wrapf(err, "unable to publish the audit log event in '%s'", s.path)
+	}
+	if err := f.Sync(); err != nil {
+		return errs.Wrapf(err, "unable to publish the audit log event in '%s'", s.path)
+	}
+	return errs.Wrapf(f.Close(), "unable to publish the audit log event in '%s'", s.path)
+}
+
+// HTTPSink a sink which sends the events to an HTTP endpoint (eg: a Knative broker or a Kafka bridge),
+// in the structured mode of the CloudEvents HTTP binding
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink returns a new sink which sends the events to the given URL with the given client
+func NewHTTPSink(url string, client *http.Client) *HTTPSink {
+	return &HTTPSink{
+		url:    url,
+		client: client,
+	}
+}
+
+// Publish sends the given event to the endpoint, and expects a 2xx response
+func (s *HTTPSink) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errs.Wrap(err, "unable to publish the audit log event")
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return errs.Wrapf(err, "unable to publish the audit log event to '%s'", s.url)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errs.Wrapf(err, "unable to publish the audit log event to '%s'", s.url)
+	}
+	defer resp.Body.Close()
+	httputil.DrainBody(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errs.Errorf("unable to publish the audit log event to '%s': unexpected response status: %s", s.url, resp.Status)
+	}
+	return nil
+}
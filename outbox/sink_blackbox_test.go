@@ -0,0 +1,187 @@
+package outbox_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/outbox"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gock "gopkg.in/h2non/gock.v1"
+)
+
+func newEvent(username string) outbox.Event {
+	return outbox.NewEvent(auditlog.OutboxEntry{
+		Seq:         1,
+		AuditLogID:  uuid.NewV4(),
+		CreatedAt:   time.Now(),
+		Username:    username,
+		EventTypeID: auditlog.StartTenantUpdate,
+		EventParams: auditlog.EventParams{
+			"cluster": "foo",
+		},
+	}, auditlog.StartTenantUpdateEvent, "/admin-console")
+}
+
+func TestNewEvent(t *testing.T) {
+
+	t.Run("with username", func(t *testing.T) {
+		// given
+		entry := auditlog.OutboxEntry{
+			AuditLogID:  uuid.NewV4(),
+			CreatedAt:   time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC),
+			IdentityID:  uuid.NewV4(),
+			Username:    "foo",
+			EventTypeID: auditlog.StartTenantUpdate,
+			EventParams: auditlog.EventParams{},
+		}
+		// when
+		event := outbox.NewEvent(entry, auditlog.StartTenantUpdateEvent, "/admin-console")
+		// then
+		assert.Equal(t, "1.0", event.SpecVersion)
+		assert.Equal(t, entry.AuditLogID.String(), event.ID)
+		assert.Equal(t, "io.fabric8.admin-console.auditlog.start_tenant_update", event.Type)
+		assert.Equal(t, "foo", event.Subject)
+		assert.Equal(t, "foo", event.PartitionKey)
+		assert.Equal(t, entry.CreatedAt, event.Time)
+		assert.Equal(t, entry.IdentityID.String(), event.Data.IdentityID)
+	})
+
+	t.Run("without username", func(t *testing.T) {
+		// given
+		entry := auditlog.OutboxEntry{
+			AuditLogID:  uuid.NewV4(),
+			IdentityID:  uuid.NewV4(),
+			EventTypeID: auditlog.UserSearch,
+		}
+		// when
+		event := outbox.NewEvent(entry, auditlog.UserSearchEvent, "/admin-console")
+		// then
+		assert.Empty(t, event.Subject)
+		assert.Equal(t, entry.IdentityID.String(), event.PartitionKey)
+	})
+}
+
+func TestFileSink(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "outbox")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events", "auditlogs.ndjson")
+	sink := outbox.NewFileSink(path)
+	events := []outbox.Event{newEvent("foo"), newEvent("bar")}
+	// when
+	for _, event := range events {
+		err := sink.Publish(context.Background(), event)
+		require.NoError(t, err)
+	}
+	// then
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	ids := []string{}
+	for scanner.Scan() {
+		event := outbox.Event{}
+		err := json.Unmarshal(scanner.Bytes(), &event)
+		require.NoError(t, err)
+		ids = append(ids, event.ID)
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, []string{events[0].ID, events[1].ID}, ids)
+}
+
+func TestHTTPSink(t *testing.T) {
+	defer gock.OffAll()
+	sink := outbox.NewHTTPSink("http://broker/default", http.DefaultClient)
+
+	t.Run("ok", func(t *testing.T) {
+		// given
+		event := newEvent("foo")
+		gock.New("http://broker").
+			Post("/default").
+			MatchHeader("Content-Type", "application/cloudevents\\+json").
+			BodyString(`"id":"` + event.ID + `"`).
+			Reply(http.StatusAccepted)
+		// when
+		err := sink.Publish(context.Background(), event)
+		// then
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		// given
+		gock.New("http://broker").
+			Post("/default").
+			Reply(http.StatusServiceUnavailable)
+		// when
+		err := sink.Publish(context.Background(), newEvent("foo"))
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "503")
+	})
+}
+
+func TestSinks(t *testing.T) {
+	// given
+	first := outbox.NewMemorySink()
+	second := outbox.NewMemorySink()
+	sink := outbox.Sinks(first, second)
+
+	t.Run("ok", func(t *testing.T) {
+		// when
+		err := sink.Publish(context.Background(), newEvent("foo"))
+		// then
+		require.NoError(t, err)
+		assert.Len(t, first.Events(), 1)
+		assert.Len(t, second.Events(), 1)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		// given
+		first.Fail = func(outbox.Event) error {
+			return fmt.Errorf("broker unavailable")
+		}
+		// when
+		err := sink.Publish(context.Background(), newEvent("foo"))
+		// then
+		require.Error(t, err)
+		assert.Len(t, second.Events(), 1) // not published to the next sinks
+	})
+}
+
+func TestNewSink(t *testing.T) {
+
+	t.Run("ok", func(t *testing.T) {
+		for kind, expected := range map[string]interface{}{
+			"none": outbox.DiscardSink{},
+			"file": &outbox.FileSink{},
+			"http": &outbox.HTTPSink{},
+		} {
+			sink, err := outbox.NewSink(kind, "/tmp/auditlogs.ndjson", "http://broker", &http.Client{Timeout: time.Second})
+			require.NoError(t, err)
+			assert.IsType(t, expected, sink)
+		}
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		_, err := outbox.NewSink("kafka", "", "", http.DefaultClient)
+		assert.EqualError(t, err, "invalid kind of sink for the audit log events: 'kafka'")
+		_, err = outbox.NewSink("file", "", "", http.DefaultClient)
+		assert.Error(t, err)
+		_, err = outbox.NewSink("http", "", "", http.DefaultClient)
+		assert.Error(t, err)
+	})
+}
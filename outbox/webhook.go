@@ -0,0 +1,39 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/fabric8-services/admin-console/webhook"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// WebhookSink a sink which schedules the delivery of the events to the webhooks which subscribed to their type.
+// The events are then delivered by the webhook dispatcher.
+type WebhookSink struct {
+	repo webhook.Repository
+}
+
+// NewWebhookSink returns a new sink which schedules the delivery of the events with the given repository
+func NewWebhookSink(repo webhook.Repository) *WebhookSink {
+	return &WebhookSink{
+		repo: repo,
+	}
+}
+
+// Publish schedules the delivery of the given event to the matching webhooks. Publishing an event which was already
+// published has no effect.
+func (s *WebhookSink) Publish(ctx context.Context, event Event) error {
+	id, err := uuid.FromString(event.ID)
+	if err != nil {
+		return errs.Wrapf(err, "invalid ID of audit log event: '%s'", event.ID)
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errs.Wrap(err, "unable to publish the audit log event")
+	}
+	_, err = s.repo.Enqueue(ctx, id, event.Data.EventType, payload)
+	return err
+}
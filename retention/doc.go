@@ -0,0 +1,3 @@
+// Package retention contains the background worker which archives and deletes the expired audit log records,
+// according to the retention policies of their event type.
+package retention
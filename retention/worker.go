@@ -0,0 +1,227 @@
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	errs "github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Username the username of the audit log records created by the retention worker
+const Username = "admin-console"
+
+var (
+	archivedRecordsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "admin_console_auditlog_archived_records_total",
+		Help: "Number of audit log records archived and deleted by the retention worker.",
+	}, []string{"event_type"})
+	runsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "admin_console_auditlog_retention_runs_total",
+		Help: "Number of runs of the audit logs retention worker.",
+	}, []string{"outcome"})
+	lastSuccessGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "admin_console_auditlog_retention_last_success_timestamp_seconds",
+		Help: "Time of the last successful run of the audit logs retention worker.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(archivedRecordsCounter, runsCounter, lastSuccessGauge)
+}
+
+// Configuration the configuration of the retention worker
+type Configuration interface {
+	GetAuditLogRetentionPolicies() map[string]time.Duration
+	GetAuditLogRetentionInterval() time.Duration
+	GetAuditLogRetentionBatchSize() int
+	GetAuditLogArchiveDir() string
+	GetAuditLogIdempotencyKeyTTL() time.Duration
+}
+
+// Worker archives and deletes the audit log records which are older than the maximum age configured for their event type.
+// The records are archived as gzip'd NDJSON files in the configured archive directory, one file per batch of records.
+// A record may be found in more than one archive if it could not be deleted after it was archived.
+// The worker also purges the expired idempotency keys of the audit log records.
+type Worker struct {
+	config Configuration
+	db     application.DB
+}
+
+// NewWorker returns a new retention worker
+func NewWorker(config Configuration, db application.DB) *Worker {
+	return &Worker{
+		config: config,
+		db:     db,
+	}
+}
+
+// Start runs the worker immediately and then at the configured interval, until the given context is done.
+// Does nothing if no retention policy is configured and if the idempotency keys never expire.
+func (w *Worker) Start(ctx context.Context) {
+	if len(w.config.GetAuditLogRetentionPolicies()) == 0 {
+		log.Info(ctx, map[string]interface{}{}, "no audit log retention policy configured, records will be kept forever")
+		if w.config.GetAuditLogIdempotencyKeyTTL() <= 0 {
+			return
+		}
+	}
+	ticker := time.NewTicker(w.config.GetAuditLogRetentionInterval())
+	defer ticker.Stop()
+	for {
+		// errors are logged and the worker will try again during the next run
+		w.Run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run archives and deletes all the expired audit log records, by batches, purges the expired idempotency keys, and records
+// the run as an audit log record.
+// Continues with the other event types when the records of an event type could not be archived.
+// returns the number of archived records, indexed by event type name, and the first error that occurred
+func (w *Worker) Run(ctx context.Context) (map[string]int, error) {
+	start := time.Now()
+	policies := w.config.GetAuditLogRetentionPolicies()
+	eventTypes := make([]string, 0, len(policies))
+	for eventType := range policies {
+		eventTypes = append(eventTypes, eventType)
+	}
+	sort.Strings(eventTypes)
+	counts := map[string]int{}
+	var runErr error
+	for _, eventType := range eventTypes {
+		count, err := w.archive(ctx, eventType, start.Add(-policies[eventType]), start)
+		counts[eventType] = count
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":        err,
+				"event_type": eventType,
+				"archived":   count,
+			}, "failed to archive the expired audit logs")
+			if runErr == nil {
+				runErr = err
+			}
+		}
+	}
+	purged := 0
+	if ttl := w.config.GetAuditLogIdempotencyKeyTTL(); ttl > 0 {
+		var err error
+		purged, err = w.db.AuditLogs().PurgeIdempotencyKeys(ctx, start.Add(-ttl))
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err": err,
+			}, "failed to purge the expired idempotency keys of the audit logs")
+			if runErr == nil {
+				runErr = err
+			}
+		}
+	}
+	params := auditlog.EventParams{
+		"archived":                counts,
+		"archive_dir":             w.config.GetAuditLogArchiveDir(),
+		"purged_idempotency_keys": purged,
+		"duration_ms":             time.Since(start).Nanoseconds() / int64(time.Millisecond),
+	}
+	if runErr != nil {
+		params["error"] = runErr.Error()
+	}
+	err := w.db.AuditLogs().Create(ctx, &auditlog.AuditLog{
+		EventTypeID: auditlog.ArchiveAuditLogs,
+		Username:    Username,
+		EventParams: params,
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "failed to record the audit logs archival")
+		if runErr == nil {
+			runErr = err
+		}
+	}
+	if runErr != nil {
+		runsCounter.WithLabelValues("failure").Inc()
+		return counts, runErr
+	}
+	runsCounter.WithLabelValues("success").Inc()
+	lastSuccessGauge.Set(float64(time.Now().Unix()))
+	log.Info(ctx, map[string]interface{}{
+		"archived": counts,
+	}, "archived the expired audit logs")
+	return counts, nil
+}
+
+// archive archives and deletes the records of the given event type which were created before the given date, by batches
+func (w *Worker) archive(ctx context.Context, eventType string, before time.Time, runAt time.Time) (int, error) {
+	t, err := w.db.EventTypes().LoadByName(ctx, eventType)
+	if err != nil {
+		return 0, errs.Wrapf(err, "unable to load the '%s' event type", eventType)
+	}
+	names, err := w.db.EventTypes().Names(ctx)
+	if err != nil {
+		return 0, err
+	}
+	dir := w.config.GetAuditLogArchiveDir()
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return 0, errs.Wrapf(err, "unable to create the audit logs archive directory '%s'", dir)
+	}
+	total := 0
+	for batch := 1; ; batch++ {
+		name := fmt.Sprintf("auditlogs-%s-%s-%04d.ndjson.gz", eventType, runAt.UTC().Format("20060102T150405Z"), batch)
+		count, err := w.db.AuditLogs().ArchiveExpired(ctx, t.ID, before, w.config.GetAuditLogRetentionBatchSize(), func(records []auditlog.AuditLog) (string, error) {
+			return name, writeArchive(filepath.Join(dir, name), names, records)
+		})
+		total += count
+		archivedRecordsCounter.WithLabelValues(eventType).Add(float64(count))
+		if err != nil {
+			return total, err
+		}
+		if count < w.config.GetAuditLogRetentionBatchSize() {
+			return total, nil
+		}
+	}
+}
+
+// writeArchive writes the given records in a gzip'd NDJSON file at the given path. The file is first written under
+// a temporary name and synced, then renamed, so that it is complete once it exists.
+func writeArchive(path string, eventTypes map[uuid.UUID]string, records []auditlog.AuditLog) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errs.Wrapf(err, "unable to create the audit logs archive '%s'", path)
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	exporter := auditlog.NewNDJSONExporter(gz, eventTypes)
+	for _, r := range records {
+		if err := exporter.Write(r); err != nil {
+			return err
+		}
+	}
+	if err := exporter.Flush(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return errs.Wrapf(err, "unable to write the audit logs archive '%s'", path)
+	}
+	if err := f.Sync(); err != nil {
+		return errs.Wrapf(err, "unable to write the audit logs archive '%s'", path)
+	}
+	if err := f.Close(); err != nil {
+		return errs.Wrapf(err, "unable to write the audit logs archive '%s'", path)
+	}
+	return errs.Wrapf(os.Rename(f.Name(), path), "unable to write the audit logs archive '%s'", path)
+}
@@ -0,0 +1,197 @@
+package retention_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/retention"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type WorkerBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	app application.DB
+}
+
+func TestWorker(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &WorkerBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *WorkerBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.app = application.NewGormApplication(s.DB)
+}
+
+type workerConfig struct {
+	policies          map[string]time.Duration
+	dir               string
+	idempotencyKeyTTL time.Duration
+}
+
+func (c workerConfig) GetAuditLogRetentionPolicies() map[string]time.Duration {
+	return c.policies
+}
+
+func (c workerConfig) GetAuditLogRetentionInterval() time.Duration {
+	return time.Hour
+}
+
+func (c workerConfig) GetAuditLogRetentionBatchSize() int {
+	return 2
+}
+
+func (c workerConfig) GetAuditLogArchiveDir() string {
+	return c.dir
+}
+
+func (c workerConfig) GetAuditLogIdempotencyKeyTTL() time.Duration {
+	return c.idempotencyKeyTTL
+}
+
+func (s *WorkerBlackboxTestSuite) TestRun() {
+	// given 3 expired records
+	username := fmt.Sprintf("user-%v", uuid.NewV4())
+	records := make([]auditlog.AuditLog, 3)
+	for i := range records {
+		records[i] = auditlog.AuditLog{
+			EventTypeID: auditlog.ExportAuditLogs,
+			Username:    username,
+			EventParams: auditlog.EventParams{
+				"idx": i,
+			},
+			IdempotencyKey: uuid.NewV4().String(),
+		}
+		err := s.app.AuditLogs().Create(context.Background(), &records[i])
+		require.NoError(s.T(), err)
+	}
+	dir, err := ioutil.TempDir("", "auditlogs-archive")
+	require.NoError(s.T(), err)
+	defer os.RemoveAll(dir)
+	w := retention.NewWorker(workerConfig{
+		policies: map[string]time.Duration{
+			auditlog.ExportAuditLogsEvent: time.Nanosecond,
+		},
+		dir: dir,
+	}, s.app)
+
+	s.T().Run("ok", func(t *testing.T) {
+		// when
+		counts, err := w.Run(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.True(t, counts[auditlog.ExportAuditLogsEvent] >= 3)
+		// verify that the records were deleted
+		for _, r := range records {
+			_, err := s.app.AuditLogs().LoadByID(context.Background(), r.ID)
+			require.Error(t, err)
+		}
+		// verify that the records were archived
+		archived := map[string]bool{}
+		files, err := filepath.Glob(filepath.Join(dir, "auditlogs-export_audit_logs-*.ndjson.gz"))
+		require.NoError(t, err)
+		for _, file := range files {
+			for _, id := range readArchive(t, file) {
+				archived[id] = true
+			}
+		}
+		for _, r := range records {
+			assert.True(t, archived[r.ID.String()], "record '%s' was not archived", r.ID)
+		}
+		// verify that the idempotency keys of the records were deleted along with them
+		for _, r := range records {
+			assertIdempotencyKeyPurged(t, s.app, r)
+		}
+		// verify that the run was recorded
+		runs, _, err := s.app.AuditLogs().List(context.Background(), auditlog.Filter{
+			EventTypes:     []string{auditlog.ArchiveAuditLogsEvent},
+			UsernamePrefix: retention.Username,
+			Sort:           auditlog.SortDescending,
+		}, 0, 1)
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Equal(t, float64(counts[auditlog.ExportAuditLogsEvent]), runs[0].EventParams["archived"].(map[string]interface{})[auditlog.ExportAuditLogsEvent])
+	})
+
+	s.T().Run("expired idempotency keys", func(t *testing.T) {
+		// given a record which is not archived, but whose idempotency key expired
+		record := auditlog.AuditLog{
+			EventTypeID:    auditlog.UserSearch,
+			Username:       username,
+			EventParams:    auditlog.EventParams{},
+			IdempotencyKey: uuid.NewV4().String(),
+		}
+		err := s.app.AuditLogs().Create(context.Background(), &record)
+		require.NoError(t, err)
+		w := retention.NewWorker(workerConfig{
+			dir:               dir,
+			idempotencyKeyTTL: time.Nanosecond,
+		}, s.app)
+		// when
+		_, err = w.Run(context.Background())
+		// then
+		require.NoError(t, err)
+		_, err = s.app.AuditLogs().LoadByID(context.Background(), record.ID)
+		require.NoError(t, err)
+		assertIdempotencyKeyPurged(t, s.app, record)
+	})
+
+	s.T().Run("unknown event type", func(t *testing.T) {
+		// given
+		w := retention.NewWorker(workerConfig{
+			policies: map[string]time.Duration{
+				"unknown": time.Nanosecond,
+			},
+			dir: dir,
+		}, s.app)
+		// when
+		_, err := w.Run(context.Background())
+		// then
+		require.Error(t, err)
+	})
+}
+
+// assertIdempotencyKeyPurged verifies that the idempotency key of the given record can be used again
+func assertIdempotencyKeyPurged(t *testing.T, db application.DB, record auditlog.AuditLog) {
+	retry := record
+	retry.ID = uuid.Nil
+	err := db.AuditLogs().Create(context.Background(), &retry)
+	require.NoError(t, err)
+	assert.NotEqual(t, record.ID, retry.ID, "the idempotency key of record '%s' was not purged", record.ID)
+}
+
+// readArchive returns the IDs of the records in the given archive
+func readArchive(t *testing.T, file string) []string {
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	ids := []string{}
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		record := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		ids = append(ids, record["id"].(string))
+	}
+	require.NoError(t, scanner.Err())
+	return ids
+}
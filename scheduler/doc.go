@@ -0,0 +1,4 @@
+// Package scheduler contains the background worker which starts the scheduled tenant updates and advances the staged
+// rollouts of the tenant updates on behalf of the service account of the admin console, along with the source of the
+// tokens of this service account.
+package scheduler
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	errs "github.com/pkg/errors"
+)
+
+// tokenExpiryMargin the delay before the expiry of a token after which a new token is obtained
+const tokenExpiryMargin = time.Minute
+
+// TokenSource provides the access tokens of the service account of the admin console
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenConfiguration the configuration of the service account
+type TokenConfiguration interface {
+	GetAuthServiceURL() string
+	GetServiceAccountID() string
+	GetServiceAccountSecret() string
+}
+
+// ServiceAccountTokenSource obtains the tokens of the service account from the auth service with the client credentials
+// grant, and keeps them until they are about to expire
+type ServiceAccountTokenSource struct {
+	config    TokenConfiguration
+	client    *http.Client
+	mux       sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewServiceAccountTokenSource returns a new token source which calls the auth service with the given HTTP client
+func NewServiceAccountTokenSource(config TokenConfiguration, client *http.Client) *ServiceAccountTokenSource {
+	return &ServiceAccountTokenSource{
+		config: config,
+		client: client,
+	}
+}
+
+// tokenResponse the response of the auth service to a token request
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	// ExpiresIn the lifetime of the token in seconds, which may be sent as a JSON string
+	ExpiresIn json.Number `json:"expires_in"`
+}
+
+// Token returns the current token of the service account, or obtains a new one if it is about to expire
+// returns an error if the service account is not configured or if the auth service did not return a token
+func (s *ServiceAccountTokenSource) Token(ctx context.Context) (string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.token != "" && time.Now().Before(s.expiresAt) {
+		return s.token, nil
+	}
+	if s.config.GetServiceAccountID() == "" {
+		return "", errs.New("no service account configured")
+	}
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", s.config.GetServiceAccountID())
+	form.Set("client_secret", s.config.GetServiceAccountSecret())
+	target := strings.TrimSuffix(s.config.GetAuthServiceURL(), "/") + "/api/token"
+	req, err := http.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errs.Wrapf(err, "unable to obtain a token for the service account from '%s'", target)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errs.Wrapf(err, "unable to obtain a token for the service account from '%s'", target)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errs.Errorf("unable to obtain a token for the service account: unexpected response status: %s", resp.Status)
+	}
+	var body tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", errs.Wrap(err, "unable to parse the token of the service account")
+	}
+	if body.AccessToken == "" {
+		return "", errs.New("no access token for the service account in the response of the auth service")
+	}
+	// the token is obtained again for each run if its lifetime is unknown
+	s.token = ""
+	if expiresIn, err := body.ExpiresIn.Int64(); err == nil {
+		s.token = body.AccessToken
+		s.expiresAt = time.Now().Add(time.Duration(expiresIn)*time.Second - tokenExpiryMargin)
+	}
+	return body.AccessToken, nil
+}
@@ -0,0 +1,311 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// Username the username of the audit log records created by the scheduler
+	Username = "admin-console"
+	// batchSize the maximum number of scheduled updates claimed in a single transaction
+	batchSize = 10
+	// stageStartLease the duration during which a stage of a rollout is claimed by the worker which is starting it,
+	// after which it is claimed again (eg: if the worker stopped before it recorded the outcome)
+	stageStartLease = 5 * time.Minute
+)
+
+// Configuration the configuration of the scheduler
+type Configuration interface {
+	GetTenantUpdateScheduleInterval() time.Duration
+	GetServiceAccountID() string
+}
+
+// Worker starts the scheduled tenant updates whose time has come, and advances the running rollouts stage by stage, on
+// behalf of the service account of the admin console.
+// Each attempt to start a scheduled update and each step of a rollout is recorded as an audit log record, and the
+// started updates are recorded in the history of the tenant update jobs, whose status is then refreshed by the poller
+// with the token of the service account.
+type Worker struct {
+	config Configuration
+	db     application.DB
+	tokens TokenSource
+	tenant tenant.Client
+}
+
+// NewWorker returns a new scheduler which calls the tenant service with the given client
+func NewWorker(config Configuration, db application.DB, tokens TokenSource, client tenant.Client) *Worker {
+	return &Worker{
+		config: config,
+		db:     db,
+		tokens: tokens,
+		tenant: client,
+	}
+}
+
+// Start runs the worker immediately and then at the configured interval, until the given context is done.
+// Does nothing if no service account is configured.
+func (w *Worker) Start(ctx context.Context) {
+	if w.config.GetServiceAccountID() == "" {
+		log.Warn(ctx, map[string]interface{}{}, "no service account configured, the scheduled tenant updates will not be started")
+		return
+	}
+	ticker := time.NewTicker(w.config.GetTenantUpdateScheduleInterval())
+	defer ticker.Stop()
+	for {
+		// errors are logged and the worker will try again during the next run
+		w.Run(ctx)
+		w.Advance(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run starts the scheduled tenant updates which are due, by batches. The schedules of each batch are claimed in a
+// short transaction, and their updates are then started one by one outside of it.
+// returns the number of scheduled updates which were attempted
+func (w *Worker) Run(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		var schedules []tenantupdate.Schedule
+		err := application.Transactional(w.db, func(appl application.Application) error {
+			var err error
+			schedules, err = appl.TenantUpdateSchedules().ClaimDue(ctx, time.Now(), batchSize)
+			return err
+		})
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":       err,
+				"attempted": total,
+			}, "failed to claim the scheduled tenant updates")
+			return total, err
+		}
+		for _, schedule := range schedules {
+			w.run(ctx, schedule)
+		}
+		total += len(schedules)
+		if len(schedules) < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// run starts the given claimed scheduled update, records the started update in the history of the tenant update jobs
+// on behalf of the user who scheduled it, and then records the outcome of the run in the schedule along with an audit
+// log record in the same transaction. Failures are only logged, since the schedule was already claimed.
+func (w *Worker) run(ctx context.Context, schedule tenantupdate.Schedule) {
+	params := auditlog.EventParams{
+		"schedule_id":  schedule.ID.String(),
+		"scheduled_by": schedule.CreatedBy,
+	}
+	if schedule.ClusterURL != nil {
+		params["clusterURL"] = *schedule.ClusterURL
+	}
+	if schedule.EnvType != nil {
+		params["envType"] = *schedule.EnvType
+	}
+	statusCode, err := w.startUpdate(ctx, schedule.ClusterURL, schedule.EnvType)
+	params["status_code"] = statusCode
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":         err,
+			"schedule_id": schedule.ID,
+		}, "failed to start the scheduled tenant update")
+		params["error"] = err.Error()
+	} else if jobID, err := w.recordJob(ctx, schedule.CreatedBy, schedule.ClusterURL, schedule.EnvType); err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":         err,
+			"schedule_id": schedule.ID,
+		}, "unable to record the started tenant update job")
+	} else {
+		params["job_id"] = jobID.String()
+	}
+	err = application.Transactional(w.db, func(appl application.Application) error {
+		if err := appl.TenantUpdateSchedules().RecordRun(ctx, schedule.ID, statusCode); err != nil {
+			return err
+		}
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: auditlog.ExecuteTenantUpdateSchedule,
+			Username:    Username,
+			EventParams: params,
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":         err,
+			"schedule_id": schedule.ID,
+		}, "failed to record the start of the scheduled tenant update")
+	}
+}
+
+// startUpdate starts a tenant update with the given scope using the token of the service account
+// returns the status of the response of the tenant service (or 0 if no response was received), and an error if the
+// token could not be obtained or if the update was not started
+func (w *Worker) startUpdate(ctx context.Context, clusterURL, envType *string) (int, error) {
+	token, err := w.tokens.Token(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return w.tenant.StartUpdate(ctx, "Bearer "+token, clusterURL, envType)
+}
+
+// recordJob stores the tenant update job with the given scope which was just started on behalf of the user with the
+// given username, in its own transaction
+// returns the ID of the job, or an error if it could not be stored
+func (w *Worker) recordJob(ctx context.Context, startedBy string, clusterURL, envType *string) (uuid.UUID, error) {
+	job := tenantupdate.Job{
+		ClusterURL: clusterURL,
+		EnvType:    envType,
+		Status:     tenant.StatusUpdating,
+		StartedBy:  startedBy,
+	}
+	err := application.Transactional(w.db, func(appl application.Application) error {
+		return appl.TenantUpdateJobs().Create(ctx, &job)
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return job.ID, nil
+}
+
+// Advance advances the running rollouts by one step each (if possible), ie, starts their current stage or completes
+// it once its update is over. The stages to start are claimed in a short transaction along with the stages which are
+// completed, and their updates are then started one by one outside of it. Each step is recorded as an audit log record.
+// returns the number of rollouts which were advanced
+func (w *Worker) Advance(ctx context.Context) (int, error) {
+	var starting []tenantupdate.Rollout
+	count := 0
+	err := application.Transactional(w.db, func(appl application.Application) error {
+		rollouts, err := appl.TenantUpdateRollouts().Advance(ctx, time.Now(), stageStartLease)
+		if err != nil {
+			return err
+		}
+		for _, rollout := range rollouts {
+			if rollout.Stages[rollout.CurrentStage].Status == tenantupdate.StageStarting {
+				// recorded once the update of the stage is started
+				starting = append(starting, rollout)
+				continue
+			}
+			err := appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+				EventTypeID: auditlog.AdvanceTenantUpdateRollout,
+				Username:    Username,
+				EventParams: rolloutEventParams(rollout),
+			})
+			if err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "failed to advance the tenant update rollouts")
+		return 0, err
+	}
+	for _, rollout := range starting {
+		if w.startStage(ctx, rollout, rollout.Stages[rollout.CurrentStage]) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// startStage starts the update of the given claimed stage of the rollout, records the started update in the history of
+// the tenant update jobs on behalf of the user who created the rollout, and then records the outcome in the stage
+// along with an audit log record in the same transaction. Failures are only logged, since the stage is claimed again
+// once its lease expired.
+// returns true if the rollout was advanced, ie, if the stage is not pending again because another update is ongoing
+func (w *Worker) startStage(ctx context.Context, rollout tenantupdate.Rollout, stage tenantupdate.Stage) bool {
+	var jobID *uuid.UUID
+	statusCode, err := w.startUpdate(ctx, stage.ClusterURL, stage.EnvType)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":        err,
+			"rollout_id": rollout.ID,
+			"stage":      stage.Position,
+		}, "failed to start the stage of the tenant update rollout")
+	} else if id, err := w.recordJob(ctx, rollout.CreatedBy, stage.ClusterURL, stage.EnvType); err != nil {
+		// the stage fails, since its update cannot be followed
+		log.Error(ctx, map[string]interface{}{
+			"err":        err,
+			"rollout_id": rollout.ID,
+			"stage":      stage.Position,
+		}, "unable to record the started tenant update job")
+	} else {
+		jobID = &id
+	}
+	advanced := false
+	err = application.Transactional(w.db, func(appl application.Application) error {
+		result, err := appl.TenantUpdateRollouts().RecordStart(ctx, rollout.ID, stage.Position, jobID, statusCode, time.Now())
+		if err != nil {
+			return err
+		}
+		if result.Stages[stage.Position].Status == tenantupdate.StagePending {
+			// another update is ongoing, the stage will be started again during the next run
+			return nil
+		}
+		advanced = true
+		return appl.AuditLogs().Create(ctx, &auditlog.AuditLog{
+			EventTypeID: auditlog.AdvanceTenantUpdateRollout,
+			Username:    Username,
+			EventParams: rolloutEventParams(result),
+		})
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":        err,
+			"rollout_id": rollout.ID,
+			"stage":      stage.Position,
+		}, "failed to record the start of the stage of the tenant update rollout")
+		return false
+	}
+	return advanced
+}
+
+// rolloutEventParams returns the params of the audit log record of a step of the given rollout
+func rolloutEventParams(rollout tenantupdate.Rollout) auditlog.EventParams {
+	stage := rollout.Stages[rollout.CurrentStage]
+	if rollout.Status == tenantupdate.RolloutRunning && stage.Status == tenantupdate.StagePending {
+		// the previous stage succeeded
+		stage = rollout.Stages[rollout.CurrentStage-1]
+	}
+	params := auditlog.EventParams{
+		"rollout_id":   rollout.ID.String(),
+		"created_by":   rollout.CreatedBy,
+		"status":       string(rollout.Status),
+		"stage":        stage.Position,
+		"stage_status": string(stage.Status),
+	}
+	if stage.ClusterURL != nil {
+		params["clusterURL"] = *stage.ClusterURL
+	}
+	if stage.EnvType != nil {
+		params["envType"] = *stage.EnvType
+	}
+	if stage.JobID != nil {
+		params["job_id"] = stage.JobID.String()
+	}
+	if stage.StatusCode != nil {
+		params["status_code"] = *stage.StatusCode
+	}
+	if stage.FailedCount != nil {
+		params["failed_count"] = *stage.FailedCount
+	}
+	if rollout.Reason != nil {
+		params["reason"] = *rollout.Reason
+	}
+	return params
+}
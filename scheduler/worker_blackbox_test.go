@@ -0,0 +1,404 @@
+package scheduler_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/scheduler"
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	testtenant "github.com/fabric8-services/admin-console/test/generated/tenant"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	gock "gopkg.in/h2non/gock.v1"
+)
+
+type WorkerBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	app application.DB
+}
+
+func TestWorker(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &WorkerBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *WorkerBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.app = application.NewGormApplication(s.DB)
+}
+
+type workerConfig struct{}
+
+func (c workerConfig) GetTenantUpdateScheduleInterval() time.Duration {
+	return time.Minute
+}
+
+func (c workerConfig) GetTenantUpdatePollInterval() time.Duration {
+	return time.Minute
+}
+
+func (c workerConfig) GetServiceAccountID() string {
+	return "admin-console"
+}
+
+func (c workerConfig) GetServiceAccountSecret() string {
+	return "secret"
+}
+
+func (c workerConfig) GetAuthServiceURL() string {
+	return "http://test-auth"
+}
+
+// staticTokenSource returns the same token, or an error if the token is empty
+type staticTokenSource string
+
+func (s staticTokenSource) Token(ctx context.Context) (string, error) {
+	if s == "" {
+		return "", errs.New("no token")
+	}
+	return string(s), nil
+}
+
+// newTenantClient returns a fake tenant service which responds to the requests of the service account to start an update
+// on one of the given clusters with the associated status (once), and rejects the other requests (eg: to start the stages
+// of the rollouts of other tests) with a `409 Conflict`. The clusters are removed from the given map once they were called.
+func newTenantClient(t *testing.T, responses map[string]int) *testtenant.ClientMock {
+	client := testtenant.NewClientMock(t)
+	client.StartUpdateFunc = func(ctx context.Context, authorization string, clusterURL, envType *string) (int, error) {
+		assert.Equal(t, "Bearer service-account-token", authorization)
+		statusCode := http.StatusConflict
+		if clusterURL != nil {
+			if c, found := responses[*clusterURL]; found {
+				statusCode = c
+				delete(responses, *clusterURL)
+			}
+		}
+		switch statusCode {
+		case http.StatusAccepted:
+			return statusCode, nil
+		case http.StatusConflict:
+			return statusCode, errors.NewDataConflictError("an update is already ongoing")
+		default:
+			return statusCode, errors.NewInternalError(ctx, errs.Errorf("unexpected response status: %d", statusCode))
+		}
+	}
+	return client
+}
+
+// newDueSchedule stores a one-off schedule of an update limited to a new cluster, and which is already due
+func (s *WorkerBlackboxTestSuite) newDueSchedule(t *testing.T) tenantupdate.Schedule {
+	clusterURL := fmt.Sprintf("https://cluster-%s.example.com", uuid.NewV4())
+	runAt := time.Now().Add(time.Hour)
+	schedule := tenantupdate.Schedule{
+		ClusterURL: &clusterURL,
+		NextRunAt:  &runAt,
+		CreatedBy:  fmt.Sprintf("user-%s", uuid.NewV4()),
+	}
+	err := s.app.TenantUpdateSchedules().Create(context.Background(), &schedule)
+	require.NoError(t, err)
+	err = s.DB.Model(&schedule).Update("next_run_at", time.Now().Add(-time.Minute)).Error
+	require.NoError(t, err)
+	return schedule
+}
+
+// executionRecord returns the audit log record of the execution of the given schedule
+func (s *WorkerBlackboxTestSuite) executionRecord(t *testing.T, schedule tenantupdate.Schedule) auditlog.AuditLog {
+	records := []auditlog.AuditLog{}
+	err := s.DB.Where("event_type_id = ? and event_params->>'schedule_id' = ?", auditlog.ExecuteTenantUpdateSchedule, schedule.ID.String()).
+		Find(&records).Error
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, scheduler.Username, records[0].Username)
+	assert.Equal(t, schedule.CreatedBy, records[0].EventParams["scheduled_by"])
+	assert.Equal(t, *schedule.ClusterURL, records[0].EventParams["clusterURL"])
+	return records[0]
+}
+
+func (s *WorkerBlackboxTestSuite) TestRun() {
+
+	s.T().Run("update started", func(t *testing.T) {
+		// given
+		schedule := s.newDueSchedule(t)
+		responses := map[string]int{*schedule.ClusterURL: http.StatusAccepted}
+		worker := scheduler.NewWorker(workerConfig{}, s.app, staticTokenSource("service-account-token"), newTenantClient(t, responses))
+		// when
+		count, err := worker.Run(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.True(t, count >= 1)
+		assert.Empty(t, responses)
+		result, err := s.app.TenantUpdateSchedules().Load(context.Background(), schedule.ID)
+		require.NoError(t, err)
+		assert.False(t, result.Active())
+		require.NotNil(t, result.LastRunStatus)
+		assert.Equal(t, http.StatusAccepted, *result.LastRunStatus)
+		record := s.executionRecord(t, schedule)
+		assert.NotContains(t, record.EventParams, "error")
+		// the job is recorded on behalf of the user who scheduled the update
+		jobID, err := uuid.FromString(fmt.Sprint(record.EventParams["job_id"]))
+		require.NoError(t, err)
+		job, err := s.app.TenantUpdateJobs().Load(context.Background(), jobID)
+		require.NoError(t, err)
+		assert.Equal(t, schedule.CreatedBy, job.StartedBy)
+		require.NotNil(t, job.ClusterURL)
+		assert.Equal(t, *schedule.ClusterURL, *job.ClusterURL)
+	})
+
+	s.T().Run("update rejected", func(t *testing.T) {
+		// given
+		schedule := s.newDueSchedule(t)
+		responses := map[string]int{*schedule.ClusterURL: http.StatusConflict}
+		worker := scheduler.NewWorker(workerConfig{}, s.app, staticTokenSource("service-account-token"), newTenantClient(t, responses))
+		// when
+		_, err := worker.Run(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, responses)
+		result, err := s.app.TenantUpdateSchedules().Load(context.Background(), schedule.ID)
+		require.NoError(t, err)
+		assert.False(t, result.Active())
+		require.NotNil(t, result.LastRunStatus)
+		assert.Equal(t, http.StatusConflict, *result.LastRunStatus)
+		record := s.executionRecord(t, schedule)
+		assert.Contains(t, record.EventParams, "error")
+		assert.NotContains(t, record.EventParams, "job_id")
+	})
+
+	s.T().Run("no token", func(t *testing.T) {
+		// given
+		schedule := s.newDueSchedule(t)
+		// the tenant service is not called
+		worker := scheduler.NewWorker(workerConfig{}, s.app, staticTokenSource(""), testtenant.NewClientMock(t))
+		// when
+		_, err := worker.Run(context.Background())
+		// then
+		require.NoError(t, err)
+		result, err := s.app.TenantUpdateSchedules().Load(context.Background(), schedule.ID)
+		require.NoError(t, err)
+		require.NotNil(t, result.LastRunStatus)
+		assert.Equal(t, 0, *result.LastRunStatus)
+		record := s.executionRecord(t, schedule)
+		assert.Equal(t, "no token", record.EventParams["error"])
+	})
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	defer gock.OffAll()
+
+	t.Run("ok", func(t *testing.T) {
+		// given
+		gock.New("http://test-auth").
+			Post("/api/token").
+			BodyString("client_id=admin-console").
+			Times(1).
+			Reply(http.StatusOK).
+			JSON(map[string]interface{}{
+				"access_token": "service-account-token",
+				"expires_in":   "3600",
+			})
+		tokens := scheduler.NewServiceAccountTokenSource(workerConfig{}, http.DefaultClient)
+		// when
+		token, err := tokens.Token(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "service-account-token", token)
+		assert.True(t, gock.IsDone())
+		// and the token is kept until it is about to expire
+		token, err = tokens.Token(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "service-account-token", token)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		// given
+		gock.New("http://test-auth").
+			Post("/api/token").
+			Reply(http.StatusUnauthorized)
+		tokens := scheduler.NewServiceAccountTokenSource(workerConfig{}, http.DefaultClient)
+		// when
+		_, err := tokens.Token(context.Background())
+		// then
+		require.Error(t, err)
+		assert.True(t, gock.IsDone())
+	})
+}
+
+// newRollout stores a rollout of an update on 2 new clusters, each stage allowing the given number of failed tenants
+func (s *WorkerBlackboxTestSuite) newRollout(t *testing.T, maxFailedCount int) tenantupdate.Rollout {
+	rollout := tenantupdate.Rollout{
+		CreatedBy: fmt.Sprintf("user-%s", uuid.NewV4()),
+	}
+	for i := 0; i < 2; i++ {
+		clusterURL := fmt.Sprintf("https://cluster-%s.example.com", uuid.NewV4())
+		rollout.Stages = append(rollout.Stages, tenantupdate.Stage{
+			ClusterURL:     &clusterURL,
+			MaxFailedCount: maxFailedCount,
+		})
+	}
+	err := s.app.TenantUpdateRollouts().Create(context.Background(), &rollout)
+	require.NoError(t, err)
+	return rollout
+}
+
+// advanceRecords returns the audit log records of the steps of the given rollout, in chronological order
+func (s *WorkerBlackboxTestSuite) advanceRecords(t *testing.T, rollout tenantupdate.Rollout) []auditlog.AuditLog {
+	records := []auditlog.AuditLog{}
+	err := s.DB.Where("event_type_id = ? and event_params->>'rollout_id' = ?", auditlog.AdvanceTenantUpdateRollout, rollout.ID.String()).
+		Order("created_at").
+		Find(&records).Error
+	require.NoError(t, err)
+	for _, r := range records {
+		assert.Equal(t, scheduler.Username, r.Username)
+		assert.Equal(t, rollout.CreatedBy, r.EventParams["created_by"])
+	}
+	return records
+}
+
+// finishStage reports that the update of the given stage of the rollout is over, with the given number of failed tenants
+func (s *WorkerBlackboxTestSuite) finishStage(t *testing.T, rolloutID uuid.UUID, position int, status tenant.Status, failedCount int) {
+	rollout, err := s.app.TenantUpdateRollouts().Load(context.Background(), rolloutID)
+	require.NoError(t, err)
+	require.NotNil(t, rollout.Stages[position].JobID)
+	err = s.app.TenantUpdateJobs().Refresh(context.Background(), *rollout.Stages[position].JobID, tenant.Update{
+		Status:      status,
+		FailedCount: failedCount,
+	})
+	require.NoError(t, err)
+}
+
+func (s *WorkerBlackboxTestSuite) TestAdvance() {
+	// the responses of the tenant service to the requests to start the stages of the rollouts, by cluster
+	responses := map[string]int{}
+	worker := scheduler.NewWorker(workerConfig{}, s.app, staticTokenSource("service-account-token"), newTenantClient(s.T(), responses))
+
+	s.T().Run("completed", func(t *testing.T) {
+		// given
+		rollout := s.newRollout(t, 2)
+		for _, stage := range rollout.Stages {
+			responses[*stage.ClusterURL] = http.StatusAccepted
+		}
+
+		// when the first stage is started
+		count, err := worker.Advance(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.True(t, count >= 1)
+		result, err := s.app.TenantUpdateRollouts().Load(context.Background(), rollout.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RolloutRunning, result.Status)
+		assert.Equal(t, tenantupdate.StageRunning, result.Stages[0].Status)
+		require.NotNil(t, result.Stages[0].JobID)
+		// the job is recorded on behalf of the user who created the rollout
+		job, err := s.app.TenantUpdateJobs().Load(context.Background(), *result.Stages[0].JobID)
+		require.NoError(t, err)
+		assert.Equal(t, rollout.CreatedBy, job.StartedBy)
+		require.NotNil(t, job.ClusterURL)
+		assert.Equal(t, *rollout.Stages[0].ClusterURL, *job.ClusterURL)
+
+		// when the first stage is over, with less failed tenants than allowed
+		s.finishStage(t, rollout.ID, 0, tenant.StatusFailed, 2)
+		_, err = worker.Advance(context.Background())
+		require.NoError(t, err)
+		// then the second stage becomes the current one
+		result, err = s.app.TenantUpdateRollouts().Load(context.Background(), rollout.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RolloutRunning, result.Status)
+		assert.Equal(t, 1, result.CurrentStage)
+		assert.Equal(t, tenantupdate.StageSucceeded, result.Stages[0].Status)
+		require.NotNil(t, result.Stages[0].FailedCount)
+		assert.Equal(t, 2, *result.Stages[0].FailedCount)
+
+		// when the second stage is started and is over
+		_, err = worker.Advance(context.Background())
+		require.NoError(t, err)
+		s.finishStage(t, rollout.ID, 1, tenant.StatusFinished, 0)
+		_, err = worker.Advance(context.Background())
+		require.NoError(t, err)
+		// then
+		assert.Empty(t, responses)
+		result, err = s.app.TenantUpdateRollouts().Load(context.Background(), rollout.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RolloutCompleted, result.Status)
+		assert.Equal(t, tenantupdate.StageSucceeded, result.Stages[1].Status)
+		assert.NotNil(t, result.FinishedAt)
+		records := s.advanceRecords(t, rollout)
+		require.Len(t, records, 4)
+		assert.Equal(t, "running", records[0].EventParams["stage_status"])
+		assert.Equal(t, "succeeded", records[1].EventParams["stage_status"])
+		assert.Equal(t, float64(2), records[1].EventParams["failed_count"])
+		assert.Equal(t, float64(1), records[2].EventParams["stage"])
+		assert.Equal(t, "completed", records[3].EventParams["status"])
+	})
+
+	s.T().Run("too many failed tenants", func(t *testing.T) {
+		// given
+		rollout := s.newRollout(t, 0)
+		responses[*rollout.Stages[0].ClusterURL] = http.StatusAccepted
+		_, err := worker.Advance(context.Background())
+		require.NoError(t, err)
+		s.finishStage(t, rollout.ID, 0, tenant.StatusFailed, 1)
+		// when
+		_, err = worker.Advance(context.Background())
+		// then
+		require.NoError(t, err)
+		result, err := s.app.TenantUpdateRollouts().Load(context.Background(), rollout.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RolloutFailed, result.Status)
+		assert.Equal(t, 0, result.CurrentStage)
+		assert.Equal(t, tenantupdate.StageFailed, result.Stages[0].Status)
+		assert.Equal(t, tenantupdate.StagePending, result.Stages[1].Status)
+		require.NotNil(t, result.Reason)
+		records := s.advanceRecords(t, rollout)
+		require.Len(t, records, 2)
+		assert.Equal(t, "failed", records[1].EventParams["status"])
+		assert.Equal(t, *result.Reason, records[1].EventParams["reason"])
+	})
+
+	s.T().Run("update not started", func(t *testing.T) {
+		// given
+		rollout := s.newRollout(t, 0)
+		responses[*rollout.Stages[0].ClusterURL] = http.StatusInternalServerError
+		// when
+		_, err := worker.Advance(context.Background())
+		// then
+		require.NoError(t, err)
+		result, err := s.app.TenantUpdateRollouts().Load(context.Background(), rollout.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RolloutFailed, result.Status)
+		assert.Equal(t, tenantupdate.StageFailed, result.Stages[0].Status)
+		assert.Nil(t, result.Stages[0].JobID)
+		require.NotNil(t, result.Stages[0].StatusCode)
+		assert.Equal(t, http.StatusInternalServerError, *result.Stages[0].StatusCode)
+	})
+
+	s.T().Run("paused", func(t *testing.T) {
+		// given
+		rollout := s.newRollout(t, 0)
+		_, err := s.app.TenantUpdateRollouts().Pause(context.Background(), rollout.ID, "pauser")
+		require.NoError(t, err)
+		// when
+		_, err = worker.Advance(context.Background())
+		// then
+		require.NoError(t, err)
+		result, err := s.app.TenantUpdateRollouts().Load(context.Background(), rollout.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RolloutPaused, result.Status)
+		assert.Equal(t, tenantupdate.StagePending, result.Stages[0].Status)
+		assert.Empty(t, s.advanceRecords(t, rollout))
+	})
+}
@@ -0,0 +1,12 @@
+// Package spool contains the failure policies which apply when an audit log record cannot be stored in the database,
+// along with the durable on-disk queue in which the records are spooled when the admin action proceeds anyway,
+// and the background worker which replays them once the database is back.
+//
+// The records which the database rejects when they are replayed are moved to the dead-letter file of the spool
+// directory, where they must be examined manually.
+//
+// Limitation: the OpenShift template does not mount a persistent volume on the spool directory, since each replica
+// would need its own volume (a StatefulSet) or a shared `ReadWriteMany` volume. The spool is therefore on the local
+// disk of the pod, and the records which are still spooled (or in the dead-letter file) when the pod is deleted are
+// lost. The `admin_console_auditlog_spooled_records_pending` gauge should be zero before a pod is deleted.
+package spool
@@ -0,0 +1,136 @@
+package spool
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// FailurePolicy the policy which applies to an admin action when its audit log record cannot be stored
+type FailurePolicy string
+
+const (
+	// FailClosed the action is refused
+	FailClosed FailurePolicy = "fail-closed"
+	// FailOpen the action proceeds, and the record is spooled until it can be stored
+	FailOpen FailurePolicy = "fail-open"
+)
+
+// Recorder applies the failure policy of their event type to the audit log records which cannot be stored
+type Recorder struct {
+	spool         *Spool
+	policies      map[uuid.UUID]FailurePolicy
+	defaultPolicy FailurePolicy
+	eventTypes    map[uuid.UUID]string
+}
+
+// NewRecorder returns a new recorder which applies the given policies, indexed by event type ID (or the default
+// policy for the other event types), and which spools the records in the given spool. The names of the event types
+// are used in the metrics.
+func NewRecorder(spool *Spool, policies map[uuid.UUID]FailurePolicy, defaultPolicy FailurePolicy, eventTypes map[uuid.UUID]string) *Recorder {
+	return &Recorder{
+		spool:         spool,
+		policies:      policies,
+		defaultPolicy: defaultPolicy,
+		eventTypes:    eventTypes,
+	}
+}
+
+// PolicyOf returns the failure policy of the given event type
+func (r *Recorder) PolicyOf(eventTypeID uuid.UUID) FailurePolicy {
+	if p, found := r.policies[eventTypeID]; found {
+		return p
+	}
+	return r.defaultPolicy
+}
+
+// HandleFailure applies the failure policy of the event type of the given record, which could not be stored because
+// of the given (possibly wrapped) error: returns the error if the policy is `fail-closed`, or nil if the policy is
+// `fail-open` and the record was spooled. Errors caused by the record itself (eg: an invalid record) are always
+// returned, as well as the error if the record could not be spooled either.
+func (r *Recorder) HandleFailure(ctx context.Context, record *auditlog.AuditLog, err error) error {
+	if !storageFailure(err) {
+		return err
+	}
+	if r == nil || r.PolicyOf(record.EventTypeID) != FailOpen {
+		return err
+	}
+	if spoolErr := r.spool.Push(record, r.eventTypes[record.EventTypeID]); spoolErr != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":           spoolErr,
+			"event_type_id": record.EventTypeID,
+		}, "unable to spool the audit log record")
+		return err
+	}
+	log.Warn(ctx, map[string]interface{}{
+		"err":           err,
+		"audit_log_id":  record.ID,
+		"event_type_id": record.EventTypeID,
+	}, "audit log record was spooled since it could not be stored")
+	return nil
+}
+
+// storageFailure returns true if the given error means that the database failed to store a record, rather than
+// the record being rejected. The errors returned by `application.Transactional` are wrapped, and the error is not
+// even an InternalError when the transaction could not begin (eg: the database is unreachable).
+func storageFailure(err error) bool {
+	switch errs.Cause(err).(type) {
+	case errors.BadParameterError, errors.NotFoundError, errors.DataConflictError, auditlog.ParamsValidationError:
+		return false
+	default:
+		return true
+	}
+}
+
+type recorderKey struct{}
+
+// InjectRecorder returns a goa middleware which stores the given recorder in the request context
+func InjectRecorder(r *Recorder) goa.Middleware {
+	return func(h goa.Handler) goa.Handler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			return h(WithRecorder(ctx, r), rw, req)
+		}
+	}
+}
+
+// WithRecorder returns a copy of the given context in which the given recorder is stored
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// HandleFailure applies the failure policy of the recorder stored in the given context (see `Recorder.HandleFailure`).
+// Without any recorder, the policy is `fail-closed`.
+func HandleFailure(ctx context.Context, record *auditlog.AuditLog, err error) error {
+	r, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return r.HandleFailure(ctx, record, err)
+}
+
+// ParsePolicies converts the given failure policies indexed by event type name (as provided by the configuration)
+// into policies indexed by event type ID, given the names of the event types
+// returns an error if an event type or a policy is unknown
+func ParsePolicies(policies map[string]string, eventTypes map[uuid.UUID]string) (map[uuid.UUID]FailurePolicy, error) {
+	ids := make(map[string]uuid.UUID, len(eventTypes))
+	for id, name := range eventTypes {
+		ids[name] = id
+	}
+	result := make(map[uuid.UUID]FailurePolicy, len(policies))
+	for name, policy := range policies {
+		id, found := ids[name]
+		if !found {
+			return nil, fmt.Errorf("unknown event type '%s'", name)
+		}
+		if p := FailurePolicy(policy); p != FailClosed && p != FailOpen {
+			return nil, fmt.Errorf("unknown failure policy '%s' for event type '%s'", policy, name)
+		}
+		result[id] = FailurePolicy(policy)
+	}
+	return result, nil
+}
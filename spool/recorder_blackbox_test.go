@@ -0,0 +1,147 @@
+package spool_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/spool"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleFailure(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sp, err := spool.New(dir)
+	require.NoError(t, err)
+	eventTypes := map[uuid.UUID]string{
+		auditlog.UserSearch:        auditlog.UserSearchEvent,
+		auditlog.StartTenantUpdate: auditlog.StartTenantUpdateEvent,
+	}
+	policies, err := spool.ParsePolicies(map[string]string{
+		auditlog.UserSearchEvent: "fail-open",
+	}, eventTypes)
+	require.NoError(t, err)
+	ctx := spool.WithRecorder(context.Background(), spool.NewRecorder(sp, policies, spool.FailClosed, eventTypes))
+	dbErr := errors.NewInternalError(context.Background(), errs.New("connection refused"))
+
+	t.Run("fail-open", func(t *testing.T) {
+		// given
+		record := &auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+			Username:    "foo",
+		}
+		// when
+		err := spool.HandleFailure(ctx, record, dbErr)
+		// then
+		require.NoError(t, err)
+		pending, err := sp.Len()
+		require.NoError(t, err)
+		assert.Equal(t, 1, pending)
+	})
+
+	t.Run("fail-open with a wrapped error", func(t *testing.T) {
+		// given
+		record := &auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+			Username:    "foo",
+		}
+		// when (as returned by `application.Transactional`)
+		err := spool.HandleFailure(ctx, record, errs.WithStack(dbErr))
+		// then
+		require.NoError(t, err)
+		pending, err := sp.Len()
+		require.NoError(t, err)
+		assert.Equal(t, 2, pending)
+	})
+
+	t.Run("fail-open when the transaction cannot begin", func(t *testing.T) {
+		// given
+		record := &auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+			Username:    "foo",
+		}
+		// when
+		err := spool.HandleFailure(ctx, record, errs.WithStack(errs.New("dial tcp: connection refused")))
+		// then
+		require.NoError(t, err)
+		pending, err := sp.Len()
+		require.NoError(t, err)
+		assert.Equal(t, 3, pending)
+	})
+
+	t.Run("fail-closed", func(t *testing.T) {
+		// given
+		record := &auditlog.AuditLog{
+			EventTypeID: auditlog.StartTenantUpdate,
+			Username:    "foo",
+		}
+		// when
+		err := spool.HandleFailure(ctx, record, dbErr)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.InternalError{}, err)
+		pending, err := sp.Len()
+		require.NoError(t, err)
+		assert.Equal(t, 3, pending)
+	})
+
+	t.Run("invalid record", func(t *testing.T) {
+		// given
+		record := &auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+		}
+		// when
+		err := spool.HandleFailure(ctx, record, errors.NewBadParameterErrorFromString("missing username"))
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, err)
+	})
+
+	t.Run("wrapped invalid record", func(t *testing.T) {
+		// given
+		record := &auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+		}
+		// when
+		err := spool.HandleFailure(ctx, record, errs.WithStack(errors.NewBadParameterErrorFromString("missing username")))
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		pending, err := sp.Len()
+		require.NoError(t, err)
+		assert.Equal(t, 3, pending)
+	})
+
+	t.Run("no recorder", func(t *testing.T) {
+		// given
+		record := &auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+			Username:    "foo",
+		}
+		// when
+		err := spool.HandleFailure(context.Background(), record, dbErr)
+		// then
+		require.Error(t, err)
+	})
+
+	t.Run("unknown event type", func(t *testing.T) {
+		// when
+		_, err := spool.ParsePolicies(map[string]string{
+			"foo": "fail-open",
+		}, eventTypes)
+		// then
+		require.Error(t, err)
+		assert.Equal(t, fmt.Sprintf("unknown event type '%s'", "foo"), err.Error())
+	})
+}
@@ -0,0 +1,377 @@
+package spool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	errs "github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	spooledRecordsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "admin_console_auditlog_spooled_records_total",
+		Help: "Number of audit log records spooled on disk because they could not be stored in the database.",
+	}, []string{"event_type"})
+	replayedRecordsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "admin_console_auditlog_replayed_records_total",
+		Help: "Number of spooled audit log records which were eventually stored in the database.",
+	})
+	spoolFailuresCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "admin_console_auditlog_spool_failures_total",
+		Help: "Number of failures while spooling or replaying audit log records.",
+	}, []string{"operation"})
+	pendingRecordsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "admin_console_auditlog_spooled_records_pending",
+		Help: "Number of spooled audit log records waiting to be stored in the database.",
+	})
+	deadLetterRecordsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "admin_console_auditlog_dead_letter_records_total",
+		Help: "Number of spooled audit log records moved to the dead-letter file because the database rejected them.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(spooledRecordsCounter, replayedRecordsCounter, spoolFailuresCounter, pendingRecordsGauge, deadLetterRecordsCounter)
+}
+
+const (
+	// recordFileExt the extension of the files of the spooled records
+	recordFileExt = ".json"
+	// corruptFileExt the extension given to the files of the spooled records which cannot be read
+	corruptFileExt = ".corrupt"
+	// replayBatchSize the maximum number of spooled records stored in the database at once
+	replayBatchSize = 100
+	// DeadLetterFileName the name of the file of the spool directory in which the records which cannot be stored in the
+	// database are moved, one JSON document per line
+	DeadLetterFileName = "dead-letter.jsonl"
+)
+
+// Spool a durable on-disk queue of audit log records: each record is written in its own file, which is synced to disk
+// before the record is considered as spooled, and which is deleted once the record was stored in the database.
+// The records are replayed in the order in which they were spooled, and a replayed record is dated from the time at
+// which it was spooled rather than from the time at which it was replayed.
+type Spool struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// New returns a spool which keeps the records in the given directory, which is created if needed
+func New(dir string) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errs.Wrapf(err, "unable to create the spool directory '%s'", dir)
+	}
+	s := &Spool{
+		dir: dir,
+	}
+	if files, err := s.files(); err == nil {
+		pendingRecordsGauge.Set(float64(len(files)))
+	}
+	return s, nil
+}
+
+// spooledRecord the content of a spooled record file
+type spooledRecord struct {
+	ID             uuid.UUID            `json:"id"`
+	IdentityID     uuid.UUID            `json:"identity_id"`
+	Username       string               `json:"username"`
+	EventTypeID    uuid.UUID            `json:"event_type_id"`
+	EventParams    auditlog.EventParams `json:"event_params"`
+	IdempotencyKey string               `json:"idempotency_key"`
+	StatusCode     *int                 `json:"status_code,omitempty"`
+	Outcome        auditlog.Outcome     `json:"outcome,omitempty"`
+	SpooledAt      time.Time            `json:"spooled_at"`
+}
+
+// Push writes the given record in the spool. The record is given an ID, as well as an idempotency key (unless it
+// already has one) so that it is stored only once even if it is replayed more than once.
+func (s *Spool) Push(record *auditlog.AuditLog, eventType string) error {
+	if record.ID == uuid.Nil {
+		record.ID = uuid.NewV4()
+	}
+	if record.IdempotencyKey == "" {
+		record.IdempotencyKey = "spool:" + record.ID.String()
+	}
+	now := time.Now()
+	content, err := json.Marshal(spooledRecord{
+		ID:             record.ID,
+		IdentityID:     record.IdentityID,
+		Username:       record.Username,
+		EventTypeID:    record.EventTypeID,
+		EventParams:    record.EventParams,
+		IdempotencyKey: record.IdempotencyKey,
+		StatusCode:     record.StatusCode,
+		Outcome:        record.Outcome,
+		SpooledAt:      now,
+	})
+	if err != nil {
+		spoolFailuresCounter.WithLabelValues("push").Inc()
+		return errs.Wrap(err, "unable to spool the audit log record")
+	}
+	// the name starts with the time, so that the records are replayed in order
+	name := fmt.Sprintf("%020d-%s%s", now.UnixNano(), record.ID, recordFileExt)
+	if err := writeFileSync(filepath.Join(s.dir, name), content); err != nil {
+		spoolFailuresCounter.WithLabelValues("push").Inc()
+		return errs.Wrap(err, "unable to spool the audit log record")
+	}
+	spooledRecordsCounter.WithLabelValues(eventType).Inc()
+	pendingRecordsGauge.Inc()
+	return nil
+}
+
+// writeFileSync writes the given content in a temporary file which is synced to disk and then renamed,
+// so that the file is either complete or missing if the process crashes
+func writeFileSync(path string, content []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// also sync the directory, so that the new entry is durable
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Len returns the number of records in the spool
+func (s *Spool) Len() (int, error) {
+	files, err := s.files()
+	return len(files), err
+}
+
+// files returns the names of the files of the spooled records, sorted in the order in which they were spooled
+func (s *Spool) files() ([]string, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to read the spool directory '%s'", s.dir)
+	}
+	files := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), recordFileExt) {
+			files = append(files, e.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Replay stores the spooled records in the database, by batches and in the order in which they were spooled, and removes
+// them from the spool. When a batch cannot be stored, its records are stored one by one: the records which the
+// database rejects are moved to the dead-letter file, so that they do not block the following ones, but the replay
+// stops as soon as the database is unavailable. Files which cannot be read are renamed with a `.corrupt` extension and
+// skipped.
+// returns the number of records which were stored, and the error that occurred, if any
+func (s *Spool) Replay(ctx context.Context, db application.DB) (int, error) {
+	// prevent concurrent replays from storing the same records twice
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := s.files()
+	if err != nil {
+		spoolFailuresCounter.WithLabelValues("replay").Inc()
+		return 0, err
+	}
+	replayed := 0
+	for start := 0; start < len(files); start += replayBatchSize {
+		end := start + replayBatchSize
+		if end > len(files) {
+			end = len(files)
+		}
+		names := []string{}
+		records := []*auditlog.AuditLog{}
+		for _, name := range files[start:end] {
+			record, err := s.read(name)
+			if err != nil {
+				log.Error(ctx, map[string]interface{}{
+					"err":  err,
+					"file": name,
+				}, "unable to read the spooled audit log record")
+				spoolFailuresCounter.WithLabelValues("replay").Inc()
+				if err := os.Rename(filepath.Join(s.dir, name), filepath.Join(s.dir, name+corruptFileExt)); err == nil {
+					pendingRecordsGauge.Dec()
+				}
+				continue
+			}
+			names = append(names, name)
+			records = append(records, record)
+		}
+		if len(records) == 0 {
+			continue
+		}
+		if err := store(ctx, db, records...); err == nil {
+			s.removeReplayed(ctx, names...)
+			replayed += len(records)
+			continue
+		}
+		spoolFailuresCounter.WithLabelValues("replay").Inc()
+		for i, record := range records {
+			err := store(ctx, db, record)
+			if err == nil {
+				s.removeReplayed(ctx, names[i])
+				replayed++
+				continue
+			}
+			if storageFailure(err) {
+				// the record is only rejected if the database is available (eg: a constraint is violated)
+				if err := store(ctx, db); err != nil {
+					return replayed, err
+				}
+			}
+			if err := s.moveToDeadLetter(ctx, names[i], err); err != nil {
+				spoolFailuresCounter.WithLabelValues("dead_letter").Inc()
+				return replayed, err
+			}
+		}
+	}
+	return replayed, nil
+}
+
+// store stores the given records in the database in a single transaction. With no record, it only verifies that
+// a transaction can begin and commit, ie, that the database is available. Nothing is stored if one of the records
+// conflicts with a record which was already stored with the same idempotency key.
+func store(ctx context.Context, db application.DB, records ...*auditlog.AuditLog) error {
+	return application.Transactional(db, func(appl application.Application) error {
+		if len(records) == 0 {
+			return nil
+		}
+		results, err := appl.AuditLogs().ReplayBatch(ctx, records)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			if result.Conflict != nil {
+				return result.Conflict
+			}
+		}
+		return nil
+	})
+}
+
+// removeReplayed removes the files of the given records which were stored in the database
+func (s *Spool) removeReplayed(ctx context.Context, names ...string) {
+	// the records are stored with an idempotency key, so failing to remove a file only causes a harmless replay
+	for _, name := range names {
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":  err,
+				"file": name,
+			}, "unable to remove the replayed audit log record from the spool")
+		}
+	}
+	replayedRecordsCounter.Add(float64(len(names)))
+	pendingRecordsGauge.Sub(float64(len(names)))
+}
+
+// deadLetter an entry of the dead-letter file
+type deadLetter struct {
+	File     string          `json:"file"`
+	Record   json.RawMessage `json:"record"`
+	Error    string          `json:"error"`
+	FailedAt time.Time       `json:"failed_at"`
+}
+
+// moveToDeadLetter appends the record of the file with the given name to the dead-letter file, along with the error
+// which prevented it from being stored, and removes the file from the spool
+func (s *Spool) moveToDeadLetter(ctx context.Context, name string, cause error) error {
+	content, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return errs.Wrapf(err, "unable to read the spooled audit log record '%s'", name)
+	}
+	entry, err := json.Marshal(deadLetter{
+		File:     name,
+		Record:   json.RawMessage(content),
+		Error:    cause.Error(),
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		return errs.Wrapf(err, "unable to move the spooled audit log record '%s' to the dead-letter file", name)
+	}
+	if err := appendFileSync(filepath.Join(s.dir, DeadLetterFileName), append(entry, '\n')); err != nil {
+		return errs.Wrapf(err, "unable to move the spooled audit log record '%s' to the dead-letter file", name)
+	}
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+		// the record would be appended again to the dead-letter file during the next replay
+		return errs.Wrapf(err, "unable to remove the spooled audit log record '%s'", name)
+	}
+	log.Error(ctx, map[string]interface{}{
+		"err":  cause,
+		"file": name,
+	}, "moved the spooled audit log record which cannot be stored to the dead-letter file")
+	deadLetterRecordsCounter.Inc()
+	pendingRecordsGauge.Dec()
+	return nil
+}
+
+// appendFileSync appends the given content to the file with the given path, which is created if needed,
+// and syncs it to disk
+func appendFileSync(path string, content []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// read returns the record spooled in the file with the given name
+func (s *Spool) read(name string) (*auditlog.AuditLog, error) {
+	content, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	var r spooledRecord
+	if err := json.Unmarshal(content, &r); err != nil {
+		return nil, err
+	}
+	return &auditlog.AuditLog{
+		ID:             r.ID,
+		IdentityID:     r.IdentityID,
+		Username:       r.Username,
+		EventTypeID:    r.EventTypeID,
+		EventParams:    r.EventParams,
+		IdempotencyKey: r.IdempotencyKey,
+		StatusCode:     r.StatusCode,
+		Outcome:        r.Outcome,
+		CreatedAt:      r.SpooledAt,
+	}, nil
+}
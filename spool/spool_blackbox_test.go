@@ -0,0 +1,253 @@
+package spool_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/spool"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SpoolBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	app application.DB
+}
+
+func TestSpool(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &SpoolBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *SpoolBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.app = application.NewGormApplication(s.DB)
+}
+
+func (s *SpoolBlackboxTestSuite) TestPushAndReplay() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		dir, err := ioutil.TempDir("", "spool")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		sp, err := spool.New(dir)
+		require.NoError(t, err)
+		username := fmt.Sprintf("user-%v", uuid.NewV4())
+		records := make([]*auditlog.AuditLog, 3)
+		for i := range records {
+			records[i] = &auditlog.AuditLog{
+				EventTypeID: auditlog.UserSearch,
+				Username:    username,
+				EventParams: auditlog.EventParams{
+					"query": fmt.Sprintf("foo%d", i),
+				},
+			}
+			err := sp.Push(records[i], auditlog.UserSearchEvent)
+			require.NoError(t, err)
+			assert.NotEqual(t, uuid.Nil, records[i].ID)
+			assert.NotEmpty(t, records[i].IdempotencyKey)
+		}
+		pending, err := sp.Len()
+		require.NoError(t, err)
+		assert.Equal(t, 3, pending)
+		// when
+		replayed, err := sp.Replay(context.Background(), s.app)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 3, replayed)
+		pending, err = sp.Len()
+		require.NoError(t, err)
+		assert.Equal(t, 0, pending)
+		logs, total, err := s.app.AuditLogs().ListByUsername(context.Background(), username, 0, 10)
+		require.NoError(t, err)
+		require.Equal(t, 3, total)
+		for i, l := range logs {
+			// records are replayed in order, and keep the ID they were given when they were spooled
+			assert.Equal(t, records[i].ID, l.ID)
+			assert.Equal(t, fmt.Sprintf("foo%d", i), l.EventParams["query"])
+		}
+	})
+
+	s.T().Run("dated from the time it was spooled", func(t *testing.T) {
+		// given a record which was spooled at a fixed time in the past
+		dir, err := ioutil.TempDir("", "spool")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		sp, err := spool.New(dir)
+		require.NoError(t, err)
+		username := fmt.Sprintf("user-%v", uuid.NewV4())
+		id := uuid.NewV4()
+		spooledAt := time.Date(2019, 1, 15, 10, 30, 0, 123456000, time.UTC)
+		content := fmt.Sprintf(`{"id":"%s","username":"%s","event_type_id":"%s","event_params":{"query":"foo"},"idempotency_key":"spool:%s","spooled_at":"%s"}`,
+			id, username, auditlog.UserSearch, id, spooledAt.Format(time.RFC3339Nano))
+		err = ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%020d-%s.json", spooledAt.UnixNano(), id)), []byte(content), 0600)
+		require.NoError(t, err)
+		// when
+		replayed, err := sp.Replay(context.Background(), s.app)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+		record, err := s.app.AuditLogs().LoadByID(context.Background(), id)
+		require.NoError(t, err)
+		assert.True(t, spooledAt.Equal(record.CreatedAt), "expected %v, got %v", spooledAt, record.CreatedAt)
+		// and the hash of the record covers its original creation date (the chain may be broken further by other tests)
+		brk, err := s.app.AuditLogs().VerifyChain(context.Background(), record.ChainSeq)
+		require.NoError(t, err)
+		if brk != nil {
+			assert.True(t, brk.Seq > record.ChainSeq, brk.String())
+		}
+	})
+
+	s.T().Run("replayed twice", func(t *testing.T) {
+		// given a record which was already stored (eg: the file could not be removed after a previous replay)
+		dir, err := ioutil.TempDir("", "spool")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		sp, err := spool.New(dir)
+		require.NoError(t, err)
+		username := fmt.Sprintf("user-%v", uuid.NewV4())
+		record := &auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+			Username:    username,
+		}
+		err = sp.Push(record, auditlog.UserSearchEvent)
+		require.NoError(t, err)
+		err = s.app.AuditLogs().Create(context.Background(), &auditlog.AuditLog{
+			EventTypeID:    record.EventTypeID,
+			Username:       username,
+			IdempotencyKey: record.IdempotencyKey,
+		})
+		require.NoError(t, err)
+		// when
+		replayed, err := sp.Replay(context.Background(), s.app)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+		total, err := s.app.AuditLogs().CountByUsername(context.Background(), username)
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+	})
+
+	s.T().Run("rejected record", func(t *testing.T) {
+		// given a record whose idempotency key was already used with another payload, spooled between 2 valid records
+		dir, err := ioutil.TempDir("", "spool")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		sp, err := spool.New(dir)
+		require.NoError(t, err)
+		username := fmt.Sprintf("user-%v", uuid.NewV4())
+		records := make([]*auditlog.AuditLog, 3)
+		for i := range records {
+			records[i] = &auditlog.AuditLog{
+				EventTypeID: auditlog.UserSearch,
+				Username:    username,
+				EventParams: auditlog.EventParams{
+					"query": fmt.Sprintf("foo%d", i),
+				},
+			}
+			err := sp.Push(records[i], auditlog.UserSearchEvent)
+			require.NoError(t, err)
+		}
+		err = s.app.AuditLogs().Create(context.Background(), &auditlog.AuditLog{
+			EventTypeID:    auditlog.UserSearch,
+			Username:       username,
+			IdempotencyKey: records[1].IdempotencyKey,
+			EventParams: auditlog.EventParams{
+				"query": "bar",
+			},
+		})
+		require.NoError(t, err)
+		// when
+		replayed, err := sp.Replay(context.Background(), s.app)
+		// then the other records are stored anyway
+		require.NoError(t, err)
+		assert.Equal(t, 2, replayed)
+		pending, err := sp.Len()
+		require.NoError(t, err)
+		assert.Equal(t, 0, pending)
+		logs, total, err := s.app.AuditLogs().ListByUsername(context.Background(), username, 0, 10)
+		require.NoError(t, err)
+		require.Equal(t, 3, total)
+		assert.Equal(t, "bar", logs[0].EventParams["query"])
+		assert.Equal(t, records[0].ID, logs[1].ID)
+		assert.Equal(t, records[2].ID, logs[2].ID)
+		// and the rejected record was moved to the dead-letter file
+		content, err := ioutil.ReadFile(filepath.Join(dir, spool.DeadLetterFileName))
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], records[1].ID.String())
+		assert.Contains(t, lines[0], "foo1")
+	})
+
+	s.T().Run("database unavailable", func(t *testing.T) {
+		// given
+		dir, err := ioutil.TempDir("", "spool")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		sp, err := spool.New(dir)
+		require.NoError(t, err)
+		err = sp.Push(&auditlog.AuditLog{
+			EventTypeID: auditlog.UserSearch,
+			Username:    fmt.Sprintf("user-%v", uuid.NewV4()),
+		}, auditlog.UserSearchEvent)
+		require.NoError(t, err)
+		// when
+		replayed, err := sp.Replay(context.Background(), unavailableDB{GormApplication: application.NewGormApplication(s.DB)})
+		// then the record is kept in the spool
+		require.Error(t, err)
+		assert.Equal(t, 0, replayed)
+		pending, err := sp.Len()
+		require.NoError(t, err)
+		assert.Equal(t, 1, pending)
+		_, err = os.Stat(filepath.Join(dir, spool.DeadLetterFileName))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	s.T().Run("corrupt file", func(t *testing.T) {
+		// given
+		dir, err := ioutil.TempDir("", "spool")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		sp, err := spool.New(dir)
+		require.NoError(t, err)
+		err = ioutil.WriteFile(filepath.Join(dir, "00000000000000000001-foo.json"), []byte("{"), 0600)
+		require.NoError(t, err)
+		// when
+		replayed, err := sp.Replay(context.Background(), s.app)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 0, replayed)
+		pending, err := sp.Len()
+		require.NoError(t, err)
+		assert.Equal(t, 0, pending)
+		_, err = os.Stat(filepath.Join(dir, "00000000000000000001-foo.json.corrupt"))
+		assert.NoError(t, err)
+	})
+}
+
+// unavailableDB a database whose transactions cannot begin, as if the database was unreachable
+type unavailableDB struct {
+	*application.GormApplication
+}
+
+func (db unavailableDB) BeginTransaction() (application.Transaction, error) {
+	return nil, errs.New("dial tcp: connection refused")
+}
@@ -0,0 +1,65 @@
+package spool
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/admin-console/application"
+	"github.com/fabric8-services/fabric8-common/log"
+)
+
+// Worker replays the spooled audit log records at a regular interval, until the spool is empty
+type Worker struct {
+	spool    *Spool
+	db       application.DB
+	interval time.Duration
+}
+
+// NewWorker returns a new worker which replays the records of the given spool at the given interval
+func NewWorker(spool *Spool, db application.DB, interval time.Duration) *Worker {
+	return &Worker{
+		spool:    spool,
+		db:       db,
+		interval: interval,
+	}
+}
+
+// Start runs the worker immediately and then at the configured interval, until the given context is done
+func (w *Worker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.Run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run replays the spooled records, if any
+func (w *Worker) Run(ctx context.Context) {
+	pending, err := w.spool.Len()
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the spooled audit log records")
+		return
+	}
+	if pending == 0 {
+		return
+	}
+	replayed, err := w.spool.Replay(ctx, w.db)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"pending":  pending,
+			"replayed": replayed,
+		}, "failed to replay the spooled audit log records")
+		return
+	}
+	log.Info(ctx, map[string]interface{}{
+		"replayed": replayed,
+	}, "replayed the spooled audit log records")
+}
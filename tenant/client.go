@@ -0,0 +1,215 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/admin-console/httputil"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	errs "github.com/pkg/errors"
+)
+
+// maxErrorBodySize the maximum number of bytes read from an error response of the tenant service
+const maxErrorBodySize = 64 * 1024
+
+// Configuration the configuration of the client
+type Configuration interface {
+	GetTenantServiceURL() string
+}
+
+// Status the status of a tenant update, as reported by the tenant service
+type Status string
+
+const (
+	// StatusUpdating the update is ongoing
+	StatusUpdating Status = "updating"
+	// StatusFinished the update finished
+	StatusFinished Status = "finished"
+	// StatusFailed the update finished, but some tenants could not be updated
+	StatusFailed Status = "failed"
+	// StatusKilled the update was stopped
+	StatusKilled Status = "killed"
+	// StatusIncomplete the update was interrupted before all tenants were processed
+	StatusIncomplete Status = "incomplete"
+)
+
+// IsValid returns true if the status is one of the known statuses
+func (s Status) IsValid() bool {
+	switch s {
+	case StatusUpdating, StatusFinished, StatusFailed, StatusKilled, StatusIncomplete:
+		return true
+	default:
+		return false
+	}
+}
+
+// Finished returns true if the update with this status is over
+func (s Status) Finished() bool {
+	return s != StatusUpdating
+}
+
+// Update the status of the ongoing or last tenant update, as reported by the tenant service
+type Update struct {
+	Status          Status
+	FailedCount     int
+	LastTimeUpdated *time.Time
+}
+
+// Client the client of the tenant update API of the tenant service. The requests are sent on behalf of the user
+// with the given authorization header, and the error responses of the service are converted into the
+// corresponding errors (eg: a `409 Conflict` into an `errors.DataConflictError`).
+type Client interface {
+	// ShowUpdate returns the status of the ongoing or last update, optionally limited to the given cluster
+	// and/or environment type
+	ShowUpdate(ctx context.Context, authorization string, clusterURL, envType *string) (Update, error)
+	// StartUpdate starts an update, optionally limited to the given cluster and/or environment type, and returns
+	// the status of the response of the tenant service (or 0 if no response was received)
+	StartUpdate(ctx context.Context, authorization string, clusterURL, envType *string) (int, error)
+	// StopUpdate stops the ongoing update
+	StopUpdate(ctx context.Context, authorization string) error
+}
+
+// NewClient returns a new client which calls the tenant service with the given HTTP client
+func NewClient(config Configuration, client *http.Client) Client {
+	return &httpClient{
+		config: config,
+		client: client,
+	}
+}
+
+type httpClient struct {
+	config Configuration
+	client *http.Client
+}
+
+// updateReport the response of the tenant service to a request for the status of the ongoing or last update
+type updateReport struct {
+	Data struct {
+		Attributes struct {
+			Status          *string    `json:"status"`
+			FailedCount     int        `json:"failed-count"`
+			LastTimeUpdated *time.Time `json:"last-time-updated"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// ShowUpdate implements Client
+func (c *httpClient) ShowUpdate(ctx context.Context, authorization string, clusterURL, envType *string) (Update, error) {
+	resp, err := c.do(ctx, http.MethodGet, authorization, clusterURL, envType)
+	if err != nil {
+		return Update{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Update{}, responseError(ctx, resp)
+	}
+	var body updateReport
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Update{}, errors.NewInternalError(ctx, errs.Wrap(err, "unable to parse the status of the tenant update"))
+	}
+	attrs := body.Data.Attributes
+	if attrs.Status == nil {
+		return Update{}, errors.NewInternalError(ctx, errs.New("missing status of the tenant update"))
+	}
+	update := Update{
+		Status:          Status(*attrs.Status),
+		FailedCount:     attrs.FailedCount,
+		LastTimeUpdated: attrs.LastTimeUpdated,
+	}
+	if !update.Status.IsValid() {
+		return Update{}, errors.NewInternalError(ctx, errs.Errorf("unknown status of the tenant update: '%s'", update.Status))
+	}
+	if update.FailedCount < 0 {
+		return Update{}, errors.NewInternalError(ctx, errs.Errorf("invalid number of failed tenant updates: %d", update.FailedCount))
+	}
+	return update, nil
+}
+
+// StartUpdate implements Client
+func (c *httpClient) StartUpdate(ctx context.Context, authorization string, clusterURL, envType *string) (int, error) {
+	resp, err := c.do(ctx, http.MethodPost, authorization, clusterURL, envType)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return resp.StatusCode, responseError(ctx, resp)
+	}
+	httputil.DrainBody(resp.Body)
+	return resp.StatusCode, nil
+}
+
+// StopUpdate implements Client
+func (c *httpClient) StopUpdate(ctx context.Context, authorization string) error {
+	resp, err := c.do(ctx, http.MethodDelete, authorization, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return responseError(ctx, resp)
+	}
+	httputil.DrainBody(resp.Body)
+	return nil
+}
+
+// do sends a request with the given method to the update endpoint of the tenant service
+func (c *httpClient) do(ctx context.Context, method, authorization string, clusterURL, envType *string) (*http.Response, error) {
+	query := url.Values{}
+	if clusterURL != nil {
+		query.Set("cluster_url", *clusterURL)
+	}
+	if envType != nil {
+		query.Set("env_type", *envType)
+	}
+	target := strings.TrimSuffix(c.config.GetTenantServiceURL(), "/") + "/api/update"
+	if len(query) > 0 {
+		target = target + "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, errs.Wrapf(err, "unable to call the tenant service with '%s %s'", method, target))
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, errs.Wrapf(err, "unable to call the tenant service with '%s %s'", method, target))
+	}
+	return resp, nil
+}
+
+// responseError converts the given error response of the tenant service into the corresponding error, whose message
+// is the detail of the first JSON-API error of the response (if any)
+func responseError(ctx context.Context, resp *http.Response) error {
+	detail := fmt.Sprintf("unexpected response status of the tenant service: %s", resp.Status)
+	var body struct {
+		Errors []struct {
+			Detail string `json:"detail"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxErrorBodySize)).Decode(&body); err == nil && len(body.Errors) > 0 && body.Errors[0].Detail != "" {
+		detail = body.Errors[0].Detail
+	}
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		return errors.NewBadParameterErrorFromString(detail)
+	case http.StatusUnauthorized:
+		return errors.NewUnauthorizedError(detail)
+	case http.StatusForbidden:
+		return errors.NewForbiddenError(detail)
+	case http.StatusConflict:
+		return errors.NewDataConflictError(detail)
+	default:
+		return errors.NewInternalError(ctx, errs.New(detail))
+	}
+}
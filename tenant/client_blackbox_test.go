@@ -0,0 +1,222 @@
+package tenant_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gock "gopkg.in/h2non/gock.v1"
+)
+
+type clientConfig struct{}
+
+func (c clientConfig) GetTenantServiceURL() string {
+	return "http://test-tenant/"
+}
+
+func TestShowUpdate(t *testing.T) {
+	defer gock.OffAll()
+	client := tenant.NewClient(clientConfig{}, http.DefaultClient)
+
+	t.Run("ok", func(t *testing.T) {
+		// given
+		gock.New("http://test-tenant").
+			Get("/api/update").
+			MatchHeader("Authorization", "Bearer foo").
+			Reply(http.StatusOK).
+			BodyString(`{"data":{"attributes":{"status":"finished","failed-count":2,"last-time-updated":"2018-06-01T10:00:00Z","file-versions":[]}}}`)
+		// when
+		update, err := client.ShowUpdate(context.Background(), "Bearer foo", nil, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, tenant.StatusFinished, update.Status)
+		assert.Equal(t, 2, update.FailedCount)
+		require.NotNil(t, update.LastTimeUpdated)
+		assert.Equal(t, time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC), update.LastTimeUpdated.UTC())
+	})
+
+	t.Run("ok with scope", func(t *testing.T) {
+		// given
+		clusterURL := "https://cluster1"
+		envType := "che"
+		gock.New("http://test-tenant").
+			Get("/api/update").
+			MatchParam("cluster_url", clusterURL).
+			MatchParam("env_type", envType).
+			Reply(http.StatusOK).
+			BodyString(`{"data":{"attributes":{"status":"updating","failed-count":0}}}`)
+		// when
+		update, err := client.ShowUpdate(context.Background(), "Bearer foo", &clusterURL, &envType)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, tenant.StatusUpdating, update.Status)
+		assert.Nil(t, update.LastTimeUpdated)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("unknown status", func(t *testing.T) {
+			// given
+			gock.New("http://test-tenant").
+				Get("/api/update").
+				Reply(http.StatusOK).
+				BodyString(`{"data":{"attributes":{"status":"whatever","failed-count":0}}}`)
+			// when
+			_, err := client.ShowUpdate(context.Background(), "Bearer foo", nil, nil)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.InternalError{}, err)
+		})
+
+		t.Run("missing status", func(t *testing.T) {
+			// given
+			gock.New("http://test-tenant").
+				Get("/api/update").
+				Reply(http.StatusOK).
+				BodyString(`{"data":{"attributes":{"failed-count":0}}}`)
+			// when
+			_, err := client.ShowUpdate(context.Background(), "Bearer foo", nil, nil)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.InternalError{}, err)
+		})
+
+		t.Run("unexpected payload", func(t *testing.T) {
+			// given
+			gock.New("http://test-tenant").
+				Get("/api/update").
+				Reply(http.StatusOK).
+				BodyString(`{"data":"whatever"}`)
+			// when
+			_, err := client.ShowUpdate(context.Background(), "Bearer foo", nil, nil)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.InternalError{}, err)
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			gock.New("http://test-tenant").
+				Get("/api/update").
+				Reply(http.StatusUnauthorized).
+				BodyString(`{"errors":[{"status":"401","detail":"invalid token"}]}`)
+			// when
+			_, err := client.ShowUpdate(context.Background(), "Bearer foo", nil, nil)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.UnauthorizedError{}, err)
+			assert.Contains(t, err.Error(), "invalid token")
+		})
+
+		t.Run("internal server error", func(t *testing.T) {
+			// given
+			gock.New("http://test-tenant").
+				Get("/api/update").
+				Reply(http.StatusInternalServerError)
+			// when
+			_, err := client.ShowUpdate(context.Background(), "Bearer foo", nil, nil)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.InternalError{}, err)
+		})
+	})
+}
+
+func TestStartUpdate(t *testing.T) {
+	defer gock.OffAll()
+	client := tenant.NewClient(clientConfig{}, http.DefaultClient)
+
+	t.Run("accepted", func(t *testing.T) {
+		// given
+		clusterURL := "https://cluster1"
+		gock.New("http://test-tenant").
+			Post("/api/update").
+			MatchHeader("Authorization", "Bearer foo").
+			MatchParam("cluster_url", clusterURL).
+			Reply(http.StatusAccepted)
+		// when
+		statusCode, err := client.StartUpdate(context.Background(), "Bearer foo", &clusterURL, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, statusCode)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("conflict", func(t *testing.T) {
+			// given
+			gock.New("http://test-tenant").
+				Post("/api/update").
+				Reply(http.StatusConflict).
+				BodyString(`{"errors":[{"status":"409","detail":"an update is already ongoing"}]}`)
+			// when
+			statusCode, err := client.StartUpdate(context.Background(), "Bearer foo", nil, nil)
+			// then
+			require.Error(t, err)
+			assert.Equal(t, http.StatusConflict, statusCode)
+			assert.IsType(t, errors.DataConflictError{}, err)
+			assert.Contains(t, err.Error(), "an update is already ongoing")
+		})
+
+		t.Run("bad request", func(t *testing.T) {
+			// given
+			gock.New("http://test-tenant").
+				Post("/api/update").
+				Reply(http.StatusBadRequest)
+			// when
+			_, err := client.StartUpdate(context.Background(), "Bearer foo", nil, nil)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			// given
+			gock.New("http://test-tenant").
+				Post("/api/update").
+				Reply(http.StatusForbidden)
+			// when
+			_, err := client.StartUpdate(context.Background(), "Bearer foo", nil, nil)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, err)
+		})
+	})
+}
+
+func TestStopUpdate(t *testing.T) {
+	defer gock.OffAll()
+	client := tenant.NewClient(clientConfig{}, http.DefaultClient)
+
+	t.Run("accepted", func(t *testing.T) {
+		// given
+		gock.New("http://test-tenant").
+			Delete("/api/update").
+			MatchHeader("Authorization", "Bearer foo").
+			Reply(http.StatusAccepted)
+		// when
+		err := client.StopUpdate(context.Background(), "Bearer foo")
+		// then
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		// given
+		gock.New("http://test-tenant").
+			Delete("/api/update").
+			Reply(http.StatusUnauthorized)
+		// when
+		err := client.StopUpdate(context.Background(), "Bearer foo")
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.UnauthorizedError{}, err)
+	})
+}
@@ -0,0 +1,3 @@
+// Package tenant contains the client of the tenant update API of the tenant service, which validates the responses
+// of the service so that the admin console exposes a stable contract even if the payloads of the tenant service change.
+package tenant
@@ -0,0 +1,158 @@
+package tenantupdate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+)
+
+// maxCronYears the maximum number of years after which the next occurrence of a cron expression is looked for
+const maxCronYears = 5
+
+// Cron a parsed cron expression, with the 5 standard fields (minute, hour, day of month, month and day of week),
+// evaluated in UTC. Each field is either `*`, a value, a range (`a-b`) or a list of those (`a,b-c`), optionally with
+// a step (`*/15`, `0-30/10`). The days of week are numbered from 0 (Sunday) to 7 (Sunday again).
+// As with the standard cron, a day matches if either the day of month or the day of week matches, when both are restricted.
+type Cron struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	// anyDayOfMonth whether the day of month field starts with `*`
+	anyDayOfMonth bool
+	// anyDayOfWeek whether the day of week field starts with `*`
+	anyDayOfWeek bool
+}
+
+// cronField the bounds of a field of a cron expression
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// ParseCron parses the given cron expression
+// returns a BadParameterError if the expression is invalid
+func ParseCron(expr string) (Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return Cron{}, errors.NewBadParameterErrorFromString(fmt.Sprintf("invalid cron expression '%s': expected 5 fields (minute, hour, day of month, month and day of week)", expr))
+	}
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return Cron{}, errors.NewBadParameterErrorFromString(fmt.Sprintf("invalid cron expression '%s': expected %s", expr, err.Error()))
+		}
+		bits[i] = b
+	}
+	c := Cron{
+		minutes:       bits[0],
+		hours:         bits[1],
+		daysOfMonth:   bits[2],
+		months:        bits[3],
+		daysOfWeek:    bits[4],
+		anyDayOfMonth: strings.HasPrefix(fields[2], "*"),
+		anyDayOfWeek:  strings.HasPrefix(fields[4], "*"),
+	}
+	// 7 is also Sunday
+	if c.daysOfWeek&(1<<7) != 0 {
+		c.daysOfWeek |= 1
+	}
+	return c, nil
+}
+
+// parseCronField parses a field of a cron expression into a bit set of the matching values
+func parseCronField(value string, field cronField) (uint64, error) {
+	var result uint64
+	for _, part := range strings.Split(value, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rng = part[:i]
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("a positive step in the %s field", field.name)
+			}
+			step = s
+		}
+		from, to := field.min, field.max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("a number, a range or '*' in the %s field", field.name)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("a number, a range or '*' in the %s field", field.name)
+				}
+			} else if step > 1 {
+				// `a/s` means from `a` to the max, every `s`
+				to = field.max
+			}
+			if from < field.min || to > field.max || from > to {
+				return 0, fmt.Errorf("values between %d and %d in the %s field", field.min, field.max, field.name)
+			}
+		}
+		for v := from; v <= to; v += step {
+			result |= 1 << uint(v)
+		}
+	}
+	return result, nil
+}
+
+// Next returns the first time (in UTC, at the start of a minute) strictly after the given time which matches the
+// cron expression, or the zero time if there is none in the next few years (eg: `0 0 30 2 *`)
+func (c Cron) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxCronYears, 0, 0)
+	for t.Before(limit) {
+		if c.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchesDay returns true if the day of the given time matches the day of month and day of week fields
+func (c Cron) matchesDay(t time.Time) bool {
+	dom := c.daysOfMonth&(1<<uint(t.Day())) != 0
+	dow := c.daysOfWeek&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.anyDayOfMonth && c.anyDayOfWeek:
+		return true
+	case c.anyDayOfMonth:
+		return dow
+	case c.anyDayOfWeek:
+		return dom
+	default:
+		return dom || dow
+	}
+}
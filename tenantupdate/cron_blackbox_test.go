@@ -0,0 +1,59 @@
+package tenantupdate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	// a Thursday
+	after := time.Date(2018, 10, 18, 10, 17, 30, 0, time.UTC)
+
+	testData := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2018, 10, 18, 10, 18, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2018, 10, 19, 2, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2018, 10, 18, 10, 30, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2018, 10, 18, 10, 25, 0, 0, time.UTC)},
+		{"30 3 * * 0", time.Date(2018, 10, 21, 3, 30, 0, 0, time.UTC)},
+		{"0 4 * * 7", time.Date(2018, 10, 21, 4, 0, 0, 0, time.UTC)},
+		{"0 1 * * 1-5", time.Date(2018, 10, 19, 1, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)},
+		// either the day of month or the day of week
+		{"0 0 13 * 5", time.Date(2018, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// never
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, d := range testData {
+		t.Run(d.expr, func(t *testing.T) {
+			// given
+			cron, err := tenantupdate.ParseCron(d.expr)
+			require.NoError(t, err)
+			// when
+			result := cron.Next(after)
+			// then
+			assert.Equal(t, d.expected, result)
+		})
+	}
+}
+
+func TestParseInvalidCron(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		t.Run(expr, func(t *testing.T) {
+			// when
+			_, err := tenantupdate.ParseCron(expr)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+	}
+}
@@ -0,0 +1,5 @@
+// Package tenantupdate contains the repositories of the history of the cluster-wide tenant updates started via the
+// admin console, of the scheduled tenant updates, of the requests to start a tenant update pending approval and of
+// the staged rollouts of the tenant updates, along with the poller which refreshes the status of the ongoing updates
+// from the tenant service.
+package tenantupdate
@@ -0,0 +1,201 @@
+package tenantupdate
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Job a cluster-wide tenant update started via the admin console
+type Job struct {
+	ID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:job_id"`
+	// ClusterURL the URL of the cluster to which the update is limited, if any
+	ClusterURL *string
+	// EnvType the type of environment to which the update is limited, if any
+	EnvType *string
+	Status  tenant.Status
+	// StartedBy the username of the user who started the update
+	StartedBy string
+	StartedAt time.Time
+	// StopRequestedBy the username of the user who requested to stop the update, if any
+	StopRequestedBy *string
+	StopRequestedAt *time.Time
+	FinishedAt      *time.Time
+	// FailedCount the number of tenants whose update failed
+	FailedCount int
+	// RefreshedAt the last time the status of the update was refreshed from the tenant service
+	RefreshedAt *time.Time
+}
+
+const (
+	jobTableName = "tenant_update_job"
+)
+
+// TableName implements gorm.tabler
+func (j Job) TableName() string {
+	return jobTableName
+}
+
+// Duration returns how long the job ran, or how long it has been running so far if it is not finished
+func (j Job) Duration() time.Duration {
+	if j.FinishedAt != nil {
+		return j.FinishedAt.Sub(j.StartedAt)
+	}
+	return time.Since(j.StartedAt)
+}
+
+// Repository provides functions to manage the history of the tenant update jobs
+type Repository interface {
+	Create(ctx context.Context, job *Job) error
+	Load(ctx context.Context, id uuid.UUID) (Job, error)
+	List(ctx context.Context, start int, limit int) ([]Job, int, error)
+	ListRunning(ctx context.Context) ([]Job, error)
+	RequestStop(ctx context.Context, username string) (int, error)
+	Refresh(ctx context.Context, id uuid.UUID, update tenant.Update) error
+}
+
+// NewRepository creates a GormRepository
+func NewRepository(db *gorm.DB) Repository {
+	return &GormRepository{
+		db: db,
+	}
+}
+
+// GormRepository implements Repository using gorm
+type GormRepository struct {
+	db *gorm.DB
+}
+
+// Create stores the given job, which is started now unless its start date is set. Since the tenant service only runs
+// a single update at a time, the jobs which were still running are marked as incomplete, since their final status will
+// never be reported.
+// returns BadParameterError if the job is nil or if its initiator or status is missing, or InternalError if something wrong happened
+func (r *GormRepository) Create(ctx context.Context, job *Job) error {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateJob", "create"}, time.Now())
+	if job == nil {
+		return errors.NewBadParameterErrorFromString("missing tenant update job to persist")
+	}
+	if job.StartedBy == "" {
+		return errors.NewBadParameterErrorFromString("missing initiator of the tenant update job")
+	}
+	if !job.Status.IsValid() {
+		return errors.NewBadParameterError("status", job.Status)
+	}
+	if job.ID == uuid.Nil {
+		job.ID = uuid.NewV4()
+	}
+	if job.StartedAt.IsZero() {
+		job.StartedAt = time.Now()
+	}
+	err := r.db.Model(&Job{}).Where("finished_at is null").Updates(map[string]interface{}{
+		"status":      tenant.StatusIncomplete,
+		"finished_at": gorm.Expr("greatest(started_at, ?)", job.StartedAt),
+	}).Error
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	if err := r.db.Create(job).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// Load returns the job with the given ID
+// returns NotFoundError or InternalError
+func (r *GormRepository) Load(ctx context.Context, id uuid.UUID) (Job, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateJob", "load"}, time.Now())
+	jobs := []Job{}
+	if err := r.db.Where("job_id = ?", id).Find(&jobs).Error; err != nil {
+		return Job{}, errors.NewInternalError(ctx, err)
+	}
+	if len(jobs) == 0 {
+		return Job{}, errors.NewNotFoundError("tenant update job", id.String())
+	}
+	return jobs[0], nil
+}
+
+// List returns a page of the jobs, most recent first, along with the total number of jobs
+// returns BadParameterError if the start or limit is invalid, or InternalError if something wrong happened
+func (r *GormRepository) List(ctx context.Context, start int, limit int) ([]Job, int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateJob", "list"}, time.Now())
+	if start < 0 {
+		return nil, 0, errors.NewBadParameterError("start", start)
+	}
+	if limit <= 0 {
+		return nil, 0, errors.NewBadParameterError("limit", limit)
+	}
+	var total int
+	if err := r.db.Model(&Job{}).Count(&total).Error; err != nil {
+		return nil, 0, errors.NewInternalError(ctx, err)
+	}
+	jobs := []Job{}
+	if err := r.db.Order("started_at desc, job_id").Offset(start).Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, 0, errors.NewInternalError(ctx, err)
+	}
+	return jobs, total, nil
+}
+
+// ListRunning returns the jobs which are not finished, oldest first
+// returns InternalError if something wrong happened
+func (r *GormRepository) ListRunning(ctx context.Context) ([]Job, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateJob", "list_running"}, time.Now())
+	jobs := []Job{}
+	if err := r.db.Where("finished_at is null").Order("started_at, job_id").Find(&jobs).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return jobs, nil
+}
+
+// RequestStop records that the user with the given username requested to stop the running jobs. The jobs remain
+// running until the tenant service reports that they are over.
+// returns the number of jobs whose stop was requested, or InternalError if something wrong happened
+func (r *GormRepository) RequestStop(ctx context.Context, username string) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateJob", "request_stop"}, time.Now())
+	result := r.db.Model(&Job{}).Where("finished_at is null and stop_requested_at is null").Updates(map[string]interface{}{
+		"stop_requested_by": username,
+		"stop_requested_at": time.Now(),
+	})
+	if result.Error != nil {
+		return 0, errors.NewInternalError(ctx, result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
+
+// Refresh updates the status and the number of failed tenants of the running job with the given ID, as reported by the tenant service.
+// The job is finished if the reported status is final, at the last time the status changed (or now, if unknown).
+// returns BadParameterError if the reported status is unknown, NotFoundError if there is no running job with the given ID,
+// or InternalError if something wrong happened
+func (r *GormRepository) Refresh(ctx context.Context, id uuid.UUID, update tenant.Update) error {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateJob", "refresh"}, time.Now())
+	if !update.Status.IsValid() {
+		return errors.NewBadParameterError("status", update.Status)
+	}
+	now := time.Now()
+	values := map[string]interface{}{
+		"status":       update.Status,
+		"failed_count": update.FailedCount,
+		"refreshed_at": now,
+	}
+	if update.Status.Finished() {
+		finishedAt := now
+		if update.LastTimeUpdated != nil {
+			finishedAt = *update.LastTimeUpdated
+		}
+		// the clocks of the tenant service and of the database may differ
+		values["finished_at"] = gorm.Expr("greatest(started_at, ?)", finishedAt)
+	}
+	result := r.db.Model(&Job{}).Where("job_id = ? and finished_at is null", id).Updates(values)
+	if result.Error != nil {
+		return errors.NewInternalError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("running tenant update job", id.String())
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package tenantupdate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type JobRepositoryBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	repo tenantupdate.Repository
+}
+
+func TestJobRepository(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &JobRepositoryBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *JobRepositoryBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.repo = tenantupdate.NewRepository(s.DB)
+}
+
+func newJob() *tenantupdate.Job {
+	envType := "che"
+	return &tenantupdate.Job{
+		EnvType:   &envType,
+		Status:    tenant.StatusUpdating,
+		StartedBy: fmt.Sprintf("user-%s", uuid.NewV4()),
+	}
+}
+
+func (s *JobRepositoryBlackboxTestSuite) TestCreate() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		job := newJob()
+		// when
+		err := s.repo.Create(context.Background(), job)
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.Load(context.Background(), job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, job.StartedBy, result.StartedBy)
+		assert.Nil(t, result.ClusterURL)
+		require.NotNil(t, result.EnvType)
+		assert.Equal(t, "che", *result.EnvType)
+		assert.Equal(t, tenant.StatusUpdating, result.Status)
+		assert.False(t, result.StartedAt.IsZero())
+		assert.Nil(t, result.FinishedAt)
+	})
+
+	s.T().Run("previous running job is incomplete", func(t *testing.T) {
+		// given
+		previous := newJob()
+		err := s.repo.Create(context.Background(), previous)
+		require.NoError(t, err)
+		// when
+		err = s.repo.Create(context.Background(), newJob())
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.Load(context.Background(), previous.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenant.StatusIncomplete, result.Status)
+		assert.NotNil(t, result.FinishedAt)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("missing initiator", func(t *testing.T) {
+			// given
+			job := newJob()
+			job.StartedBy = ""
+			// when
+			err := s.repo.Create(context.Background(), job)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("unknown status", func(t *testing.T) {
+			// given
+			job := newJob()
+			job.Status = "unknown"
+			// when
+			err := s.repo.Create(context.Background(), job)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+	})
+}
+
+func (s *JobRepositoryBlackboxTestSuite) TestLoadUnknown() {
+	// when
+	_, err := s.repo.Load(context.Background(), uuid.NewV4())
+	// then
+	require.Error(s.T(), err)
+	assert.IsType(s.T(), errors.NotFoundError{}, err)
+}
+
+func (s *JobRepositoryBlackboxTestSuite) TestList() {
+	// given
+	jobs := make([]*tenantupdate.Job, 3)
+	for i := range jobs {
+		jobs[i] = newJob()
+		// make sure the jobs are sorted, even if they are created within the same clock tick
+		jobs[i].StartedAt = time.Now().Add(time.Duration(i) * time.Millisecond)
+		err := s.repo.Create(context.Background(), jobs[i])
+		require.NoError(s.T(), err)
+	}
+
+	s.T().Run("most recent first", func(t *testing.T) {
+		// when
+		result, total, err := s.repo.List(context.Background(), 0, 2)
+		// then
+		require.NoError(t, err)
+		assert.True(t, total >= 3) // other jobs may have been created by other tests
+		require.Len(t, result, 2)
+		assert.Equal(t, jobs[2].ID, result[0].ID)
+		assert.Equal(t, jobs[1].ID, result[1].ID)
+	})
+
+	s.T().Run("next page", func(t *testing.T) {
+		// when
+		result, _, err := s.repo.List(context.Background(), 2, 1)
+		// then
+		require.NoError(t, err)
+		require.Len(t, result, 1)
+		assert.Equal(t, jobs[0].ID, result[0].ID)
+	})
+
+	s.T().Run("invalid limit", func(t *testing.T) {
+		// when
+		_, _, err := s.repo.List(context.Background(), 0, 0)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, err)
+	})
+}
+
+func (s *JobRepositoryBlackboxTestSuite) TestRequestStop() {
+	// given
+	job := newJob()
+	err := s.repo.Create(context.Background(), job)
+	require.NoError(s.T(), err)
+	// when
+	count, err := s.repo.RequestStop(context.Background(), "stopper")
+	// then
+	require.NoError(s.T(), err)
+	assert.True(s.T(), count >= 1) // other jobs may have been created by other tests
+	result, err := s.repo.Load(context.Background(), job.ID)
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), result.StopRequestedBy)
+	assert.Equal(s.T(), "stopper", *result.StopRequestedBy)
+	assert.NotNil(s.T(), result.StopRequestedAt)
+	// the job is still running until the tenant service reports that it is over
+	assert.Equal(s.T(), tenant.StatusUpdating, result.Status)
+	assert.Nil(s.T(), result.FinishedAt)
+}
+
+func (s *JobRepositoryBlackboxTestSuite) TestRefresh() {
+
+	s.T().Run("still updating", func(t *testing.T) {
+		// given
+		job := newJob()
+		err := s.repo.Create(context.Background(), job)
+		require.NoError(t, err)
+		// when
+		err = s.repo.Refresh(context.Background(), job.ID, tenant.Update{
+			Status:      tenant.StatusUpdating,
+			FailedCount: 2,
+		})
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.Load(context.Background(), job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenant.StatusUpdating, result.Status)
+		assert.Equal(t, 2, result.FailedCount)
+		assert.NotNil(t, result.RefreshedAt)
+		assert.Nil(t, result.FinishedAt)
+	})
+
+	s.T().Run("finished", func(t *testing.T) {
+		// given
+		job := newJob()
+		job.StartedAt = time.Now().Add(-time.Hour)
+		err := s.repo.Create(context.Background(), job)
+		require.NoError(t, err)
+		lastTimeUpdated := job.StartedAt.Add(10 * time.Minute)
+		// when
+		err = s.repo.Refresh(context.Background(), job.ID, tenant.Update{
+			Status:          tenant.StatusFailed,
+			FailedCount:     3,
+			LastTimeUpdated: &lastTimeUpdated,
+		})
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.Load(context.Background(), job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenant.StatusFailed, result.Status)
+		assert.Equal(t, 3, result.FailedCount)
+		require.NotNil(t, result.FinishedAt)
+		assert.True(t, lastTimeUpdated.Sub(*result.FinishedAt) < time.Millisecond)
+		assert.True(t, result.Duration() > 9*time.Minute && result.Duration() < 11*time.Minute)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("finished job", func(t *testing.T) {
+			// given
+			job := newJob()
+			err := s.repo.Create(context.Background(), job)
+			require.NoError(t, err)
+			err = s.repo.Refresh(context.Background(), job.ID, tenant.Update{Status: tenant.StatusFinished})
+			require.NoError(t, err)
+			// when
+			err = s.repo.Refresh(context.Background(), job.ID, tenant.Update{Status: tenant.StatusUpdating})
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.NotFoundError{}, err)
+		})
+
+		t.Run("unknown status", func(t *testing.T) {
+			// when
+			err := s.repo.Refresh(context.Background(), uuid.NewV4(), tenant.Update{Status: "unknown"})
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+	})
+}
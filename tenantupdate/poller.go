@@ -0,0 +1,111 @@
+package tenantupdate
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/log"
+)
+
+// Configuration the configuration of the poller
+type Configuration interface {
+	GetTenantUpdatePollInterval() time.Duration
+}
+
+// TokenSource provides the access tokens of the service account of the admin console
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// Poller refreshes the status of the running tenant update jobs from the tenant service, on behalf of the service
+// account of the admin console.
+type Poller struct {
+	config Configuration
+	repo   Repository
+	tokens TokenSource
+	client tenant.Client
+}
+
+// NewPoller returns a new poller which calls the tenant service with the given client and the tokens of the given
+// token source
+func NewPoller(config Configuration, repo Repository, tokens TokenSource, client tenant.Client) *Poller {
+	return &Poller{
+		config: config,
+		repo:   repo,
+		tokens: tokens,
+		client: client,
+	}
+}
+
+// Start runs the poller immediately and then at the configured interval, until the given context is done
+func (p *Poller) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.config.GetTenantUpdatePollInterval())
+	defer ticker.Stop()
+	for {
+		// errors are logged and the poller will try again during the next run
+		p.Run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run refreshes the status of the running jobs from the tenant service
+// returns the number of refreshed jobs
+func (p *Poller) Run(ctx context.Context) (int, error) {
+	jobs, err := p.repo.ListRunning(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "failed to list the running tenant update jobs")
+		return 0, err
+	}
+	if len(jobs) == 0 {
+		return 0, nil
+	}
+	token, err := p.tokens.Token(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to obtain a token for the service account to refresh the tenant update jobs")
+		return 0, err
+	}
+	authorization := "Bearer " + token
+	refreshed := 0
+	for _, job := range jobs {
+		update, err := p.client.ShowUpdate(ctx, authorization, job.ClusterURL, job.EnvType)
+		if rejected(err) {
+			log.Warn(ctx, map[string]interface{}{
+				"err":    err,
+				"job_id": job.ID,
+			}, "the token of the service account was rejected by the tenant service")
+			return refreshed, err
+		}
+		if err == nil {
+			err = p.repo.Refresh(ctx, job.ID, update)
+		}
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":    err,
+				"job_id": job.ID,
+			}, "failed to refresh the tenant update job")
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
+// rejected returns true if the given error means that the tenant service rejected the token of the service account
+func rejected(err error) bool {
+	switch err.(type) {
+	case errors.UnauthorizedError, errors.ForbiddenError:
+		return true
+	default:
+		return false
+	}
+}
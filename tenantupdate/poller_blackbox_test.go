@@ -0,0 +1,125 @@
+package tenantupdate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	testtenant "github.com/fabric8-services/admin-console/test/generated/tenant"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	errs "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type PollerBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	repo tenantupdate.Repository
+}
+
+func TestPoller(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &PollerBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *PollerBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.repo = tenantupdate.NewRepository(s.DB)
+}
+
+type pollerConfig struct{}
+
+func (c pollerConfig) GetTenantUpdatePollInterval() time.Duration {
+	return time.Second
+}
+
+// staticTokenSource returns the same token, or an error if the token is empty
+type staticTokenSource string
+
+func (s staticTokenSource) Token(ctx context.Context) (string, error) {
+	if s == "" {
+		return "", errs.New("no token")
+	}
+	return string(s), nil
+}
+
+func (s *PollerBlackboxTestSuite) TestRun() {
+
+	s.T().Run("no token for the service account", func(t *testing.T) {
+		// given
+		job := newJob()
+		err := s.repo.Create(context.Background(), job)
+		require.NoError(t, err)
+		// the tenant service is not called
+		client := testtenant.NewClientMock(t)
+		poller := tenantupdate.NewPoller(pollerConfig{}, s.repo, staticTokenSource(""), client)
+		// when
+		count, err := poller.Run(context.Background())
+		// then
+		require.Error(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	s.T().Run("job finished", func(t *testing.T) {
+		// given
+		job := newJob()
+		err := s.repo.Create(context.Background(), job)
+		require.NoError(t, err)
+		lastTimeUpdated := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+		client := testtenant.NewClientMock(t)
+		client.ShowUpdateFunc = func(ctx context.Context, authorization string, clusterURL, envType *string) (tenant.Update, error) {
+			assert.Equal(t, "Bearer service-account-token", authorization)
+			assert.Nil(t, clusterURL)
+			require.NotNil(t, envType)
+			assert.Equal(t, "che", *envType)
+			return tenant.Update{
+				Status:          tenant.StatusFailed,
+				FailedCount:     4,
+				LastTimeUpdated: &lastTimeUpdated,
+			}, nil
+		}
+		poller := tenantupdate.NewPoller(pollerConfig{}, s.repo, staticTokenSource("service-account-token"), client)
+		// when
+		count, err := poller.Run(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		result, err := s.repo.Load(context.Background(), job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenant.StatusFailed, result.Status)
+		assert.Equal(t, 4, result.FailedCount)
+		// the update was reported as finished before the job started, according to the clock of the database
+		require.NotNil(t, result.FinishedAt)
+		assert.Equal(t, result.StartedAt.Unix(), result.FinishedAt.Unix())
+	})
+
+	s.T().Run("token rejected", func(t *testing.T) {
+		// given
+		job := newJob()
+		err := s.repo.Create(context.Background(), job)
+		require.NoError(t, err)
+		calls := 0
+		client := testtenant.NewClientMock(t)
+		client.ShowUpdateFunc = func(ctx context.Context, authorization string, clusterURL, envType *string) (tenant.Update, error) {
+			calls++
+			return tenant.Update{}, errors.NewUnauthorizedError("token expired")
+		}
+		poller := tenantupdate.NewPoller(pollerConfig{}, s.repo, staticTokenSource("service-account-token"), client)
+		// when
+		_, err = poller.Run(context.Background())
+		// then the other jobs are not refreshed with the same token
+		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+		result, err := s.repo.Load(context.Background(), job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenant.StatusUpdating, result.Status)
+	})
+}
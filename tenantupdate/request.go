@@ -0,0 +1,246 @@
+package tenantupdate
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// RequestStatus the status of a request to start a tenant update
+type RequestStatus string
+
+const (
+	// RequestPending the request is waiting for its approval
+	RequestPending RequestStatus = "pending"
+	// RequestApproved the request was approved, and the tenant service accepted to start the update (or the update is
+	// being started, as long as the status of the response of the tenant service is not recorded)
+	RequestApproved RequestStatus = "approved"
+	// RequestFailed the request was approved, but the tenant service did not accept to start the update
+	// (eg: because another update was ongoing)
+	RequestFailed RequestStatus = "failed"
+	// RequestRejected the request was rejected
+	RequestRejected RequestStatus = "rejected"
+	// RequestExpired the request was neither approved nor rejected in time
+	RequestExpired RequestStatus = "expired"
+)
+
+// valid returns true if the status is known
+func (s RequestStatus) valid() bool {
+	switch s {
+	case RequestPending, RequestApproved, RequestFailed, RequestRejected, RequestExpired:
+		return true
+	}
+	return false
+}
+
+// Request a request to start a cluster-wide tenant update, which must be approved by another user before the update
+// is started
+type Request struct {
+	ID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:request_id"`
+	// ClusterURL the URL of the cluster to which the update should be limited, if any
+	ClusterURL *string
+	// EnvType the type of environment to which the update should be limited, if any
+	EnvType *string
+	Status  RequestStatus
+	// RequestedBy the username of the user who requested to start the update
+	RequestedBy string
+	RequestedAt time.Time
+	// ExpiresAt the time after which the request cannot be approved anymore
+	ExpiresAt time.Time
+	// ReviewedBy the username of the user who approved or rejected the request, if any
+	ReviewedBy *string
+	ReviewedAt *time.Time
+	// StatusCode the status of the response of the tenant service to the request to start the approved update,
+	// or 0 if no response was received, or nil if the update was not started (yet)
+	StatusCode *int
+}
+
+const (
+	requestTableName = "tenant_update_request"
+)
+
+// TableName implements gorm.tabler
+func (r Request) TableName() string {
+	return requestTableName
+}
+
+// RequestRepository provides functions to manage the requests to start a tenant update
+type RequestRepository interface {
+	Create(ctx context.Context, request *Request) error
+	Load(ctx context.Context, id uuid.UUID) (Request, error)
+	List(ctx context.Context, status *RequestStatus) ([]Request, error)
+	Review(ctx context.Context, id uuid.UUID, reviewer string, status RequestStatus) (Request, error)
+	RecordStart(ctx context.Context, id uuid.UUID, status RequestStatus, statusCode int) (Request, error)
+	Expire(ctx context.Context, now time.Time) ([]Request, error)
+}
+
+// NewRequestRepository creates a GormRequestRepository
+func NewRequestRepository(db *gorm.DB) RequestRepository {
+	return &GormRequestRepository{
+		db: db,
+	}
+}
+
+// GormRequestRepository implements RequestRepository using gorm
+type GormRequestRepository struct {
+	db *gorm.DB
+}
+
+// Create stores the given request, which is pending until its expiry time
+// returns BadParameterError if the initiator or the expiry time is missing, or InternalError if something wrong happened
+func (r *GormRequestRepository) Create(ctx context.Context, request *Request) error {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRequest", "create"}, time.Now())
+	if request == nil {
+		return errors.NewBadParameterErrorFromString("missing tenant update request to persist")
+	}
+	if request.RequestedBy == "" {
+		return errors.NewBadParameterErrorFromString("missing initiator of the tenant update request")
+	}
+	if request.ExpiresAt.IsZero() {
+		return errors.NewBadParameterErrorFromString("missing expiry time of the tenant update request")
+	}
+	if request.ID == uuid.Nil {
+		request.ID = uuid.NewV4()
+	}
+	if request.RequestedAt.IsZero() {
+		request.RequestedAt = time.Now()
+	}
+	request.Status = RequestPending
+	if err := r.db.Create(request).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// Load returns the request with the given ID
+// returns NotFoundError or InternalError
+func (r *GormRequestRepository) Load(ctx context.Context, id uuid.UUID) (Request, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRequest", "load"}, time.Now())
+	requests := []Request{}
+	if err := r.db.Where("request_id = ?", id).Find(&requests).Error; err != nil {
+		return Request{}, errors.NewInternalError(ctx, err)
+	}
+	if len(requests) == 0 {
+		return Request{}, errors.NewNotFoundError("tenant update request", id.String())
+	}
+	return requests[0], nil
+}
+
+// List returns the requests (only those with the given status, if specified), most recent first
+// returns BadParameterError if the status is unknown, or InternalError if something wrong happened
+func (r *GormRequestRepository) List(ctx context.Context, status *RequestStatus) ([]Request, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRequest", "list"}, time.Now())
+	db := r.db
+	if status != nil {
+		if !status.valid() {
+			return nil, errors.NewBadParameterError("status", *status)
+		}
+		db = db.Where("status = ?", *status)
+	}
+	requests := []Request{}
+	if err := db.Order("requested_at desc, request_id").Find(&requests).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return requests, nil
+}
+
+// Review stores the outcome of the review of the pending request with the given ID by the user with the given username,
+// ie, whether it was approved or rejected. The request is locked until it is updated, so this function must be called
+// within a transaction, and concurrent reviews of the same request are serialized. Once an approved request is stored,
+// the update should be started, and the response of the tenant service recorded with `RecordStart`.
+// returns the reviewed request, NotFoundError, ForbiddenError if the reviewer is the user who made the request,
+// DataConflictError if the request is not pending anymore or if it expired, BadParameterError if the outcome of the
+// review is invalid, or InternalError
+func (r *GormRequestRepository) Review(ctx context.Context, id uuid.UUID, reviewer string, status RequestStatus) (Request, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRequest", "review"}, time.Now())
+	if status != RequestApproved && status != RequestRejected {
+		return Request{}, errors.NewBadParameterError("status", status)
+	}
+	requests := []Request{}
+	if err := r.db.Set("gorm:query_option", "FOR UPDATE").Where("request_id = ?", id).Find(&requests).Error; err != nil {
+		return Request{}, errors.NewInternalError(ctx, err)
+	}
+	if len(requests) == 0 {
+		return Request{}, errors.NewNotFoundError("tenant update request", id.String())
+	}
+	request := requests[0]
+	if request.RequestedBy == reviewer {
+		return Request{}, errors.NewForbiddenError("a tenant update request must be reviewed by another user than its initiator")
+	}
+	if request.Status != RequestPending {
+		return Request{}, errors.NewDataConflictError("tenant update request is not pending anymore: " + id.String())
+	}
+	now := time.Now()
+	if !request.ExpiresAt.After(now) {
+		return Request{}, errors.NewDataConflictError("tenant update request has expired: " + id.String())
+	}
+	err := r.db.Model(&Request{}).Where("request_id = ?", id).Updates(map[string]interface{}{
+		"status":      status,
+		"reviewed_by": reviewer,
+		"reviewed_at": now,
+	}).Error
+	if err != nil {
+		return Request{}, errors.NewInternalError(ctx, err)
+	}
+	request.Status = status
+	request.ReviewedBy = &reviewer
+	request.ReviewedAt = &now
+	return request, nil
+}
+
+// RecordStart stores the status of the response of the tenant service when the update of the approved request with the
+// given ID was started, along with the new status of the request: `RequestApproved` if the update was started, or
+// `RequestFailed` otherwise
+// returns the updated request, NotFoundError, DataConflictError if the request is not approved or if its start was
+// already recorded, BadParameterError if the status is invalid, or InternalError
+func (r *GormRequestRepository) RecordStart(ctx context.Context, id uuid.UUID, status RequestStatus, statusCode int) (Request, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRequest", "recordStart"}, time.Now())
+	if status != RequestApproved && status != RequestFailed {
+		return Request{}, errors.NewBadParameterError("status", status)
+	}
+	result := r.db.Model(&Request{}).
+		Where("request_id = ? and status = ? and status_code is null", id, RequestApproved).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"status_code": statusCode,
+		})
+	if result.Error != nil {
+		return Request{}, errors.NewInternalError(ctx, result.Error)
+	}
+	request, err := r.Load(ctx, id)
+	if err != nil {
+		return Request{}, err
+	}
+	if result.RowsAffected == 0 {
+		return Request{}, errors.NewDataConflictError("tenant update request is not waiting for its update to start: " + id.String())
+	}
+	return request, nil
+}
+
+// Expire marks the pending requests which expired at the given time as such. The requests which are being reviewed
+// are skipped, so this function should be called within a transaction.
+// returns the expired requests, or InternalError if something wrong happened
+func (r *GormRequestRepository) Expire(ctx context.Context, now time.Time) ([]Request, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRequest", "expire"}, time.Now())
+	requests := []Request{}
+	err := r.db.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+		Where("status = ? and expires_at <= ?", RequestPending, now).
+		Order("expires_at").
+		Find(&requests).Error
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	for i, request := range requests {
+		err := r.db.Model(&Request{}).Where("request_id = ?", request.ID).Update("status", RequestExpired).Error
+		if err != nil {
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		requests[i].Status = RequestExpired
+	}
+	return requests, nil
+}
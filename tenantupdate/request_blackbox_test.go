@@ -0,0 +1,367 @@
+package tenantupdate_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RequestRepositoryBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	repo tenantupdate.RequestRepository
+}
+
+func TestRequestRepository(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &RequestRepositoryBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *RequestRepositoryBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.repo = tenantupdate.NewRequestRepository(s.DB)
+}
+
+// newRequest returns a request to start a tenant update which expires after the given delay
+func newRequest(expiry time.Duration) *tenantupdate.Request {
+	clusterURL := fmt.Sprintf("https://cluster-%s.example.com", uuid.NewV4())
+	return &tenantupdate.Request{
+		ClusterURL:  &clusterURL,
+		RequestedBy: fmt.Sprintf("user-%s", uuid.NewV4()),
+		ExpiresAt:   time.Now().Add(expiry),
+	}
+}
+
+func (s *RequestRepositoryBlackboxTestSuite) TestCreate() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		request := newRequest(time.Hour)
+		// when
+		err := s.repo.Create(context.Background(), request)
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.Load(context.Background(), request.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RequestPending, result.Status)
+		assert.Equal(t, request.RequestedBy, result.RequestedBy)
+		require.NotNil(t, result.ClusterURL)
+		assert.Equal(t, *request.ClusterURL, *result.ClusterURL)
+		assert.Nil(t, result.EnvType)
+		assert.False(t, result.RequestedAt.IsZero())
+		assert.WithinDuration(t, request.ExpiresAt, result.ExpiresAt, time.Millisecond)
+		assert.Nil(t, result.ReviewedBy)
+		assert.Nil(t, result.StatusCode)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("missing initiator", func(t *testing.T) {
+			// given
+			request := newRequest(time.Hour)
+			request.RequestedBy = ""
+			// when
+			err := s.repo.Create(context.Background(), request)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("missing expiry time", func(t *testing.T) {
+			// given
+			request := newRequest(time.Hour)
+			request.ExpiresAt = time.Time{}
+			// when
+			err := s.repo.Create(context.Background(), request)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+	})
+}
+
+func (s *RequestRepositoryBlackboxTestSuite) TestLoadUnknown() {
+	// when
+	_, err := s.repo.Load(context.Background(), uuid.NewV4())
+	// then
+	require.Error(s.T(), err)
+	assert.IsType(s.T(), errors.NotFoundError{}, err)
+}
+
+func (s *RequestRepositoryBlackboxTestSuite) TestList() {
+	// given
+	pending := newRequest(time.Hour)
+	err := s.repo.Create(context.Background(), pending)
+	require.NoError(s.T(), err)
+	approved := newRequest(time.Hour)
+	err = s.repo.Create(context.Background(), approved)
+	require.NoError(s.T(), err)
+	_, err = s.repo.Review(context.Background(), approved.ID, "reviewer", tenantupdate.RequestApproved)
+	require.NoError(s.T(), err)
+
+	s.T().Run("all", func(t *testing.T) {
+		// when
+		result, err := s.repo.List(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		ids := requestIDs(result)
+		assert.Contains(t, ids, pending.ID)
+		assert.Contains(t, ids, approved.ID)
+	})
+
+	s.T().Run("pending", func(t *testing.T) {
+		// given
+		status := tenantupdate.RequestPending
+		// when
+		result, err := s.repo.List(context.Background(), &status)
+		// then
+		require.NoError(t, err)
+		ids := requestIDs(result)
+		assert.Contains(t, ids, pending.ID)
+		assert.NotContains(t, ids, approved.ID)
+	})
+
+	s.T().Run("unknown status", func(t *testing.T) {
+		// given
+		status := tenantupdate.RequestStatus("unknown")
+		// when
+		_, err := s.repo.List(context.Background(), &status)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, err)
+	})
+}
+
+func requestIDs(requests []tenantupdate.Request) []uuid.UUID {
+	ids := make([]uuid.UUID, len(requests))
+	for i, r := range requests {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func (s *RequestRepositoryBlackboxTestSuite) TestReview() {
+
+	s.T().Run("approved", func(t *testing.T) {
+		// given
+		request := newRequest(time.Hour)
+		err := s.repo.Create(context.Background(), request)
+		require.NoError(t, err)
+		// when
+		result, err := s.repo.Review(context.Background(), request.ID, "reviewer", tenantupdate.RequestApproved)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RequestApproved, result.Status)
+		loaded, err := s.repo.Load(context.Background(), request.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RequestApproved, loaded.Status)
+		require.NotNil(t, loaded.ReviewedBy)
+		assert.Equal(t, "reviewer", *loaded.ReviewedBy)
+		assert.NotNil(t, loaded.ReviewedAt)
+		// the update is not started yet
+		assert.Nil(t, loaded.StatusCode)
+	})
+
+	s.T().Run("rejected", func(t *testing.T) {
+		// given
+		request := newRequest(time.Hour)
+		err := s.repo.Create(context.Background(), request)
+		require.NoError(t, err)
+		// when
+		_, err = s.repo.Review(context.Background(), request.ID, "reviewer", tenantupdate.RequestRejected)
+		// then
+		require.NoError(t, err)
+		loaded, err := s.repo.Load(context.Background(), request.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RequestRejected, loaded.Status)
+		assert.Nil(t, loaded.StatusCode)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("reviewed by the initiator", func(t *testing.T) {
+			// given
+			request := newRequest(time.Hour)
+			err := s.repo.Create(context.Background(), request)
+			require.NoError(t, err)
+			// when
+			_, err = s.repo.Review(context.Background(), request.ID, request.RequestedBy, tenantupdate.RequestApproved)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, err)
+			loaded, err := s.repo.Load(context.Background(), request.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tenantupdate.RequestPending, loaded.Status)
+		})
+
+		t.Run("already reviewed", func(t *testing.T) {
+			// given
+			request := newRequest(time.Hour)
+			err := s.repo.Create(context.Background(), request)
+			require.NoError(t, err)
+			_, err = s.repo.Review(context.Background(), request.ID, "reviewer", tenantupdate.RequestApproved)
+			require.NoError(t, err)
+			// when
+			_, err = s.repo.Review(context.Background(), request.ID, "another-reviewer", tenantupdate.RequestApproved)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, err)
+		})
+
+		t.Run("expired", func(t *testing.T) {
+			// given
+			request := newRequest(-time.Minute)
+			err := s.repo.Create(context.Background(), request)
+			require.NoError(t, err)
+			// when
+			_, err = s.repo.Review(context.Background(), request.ID, "reviewer", tenantupdate.RequestApproved)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, err)
+		})
+
+		t.Run("invalid outcome", func(t *testing.T) {
+			// given
+			request := newRequest(time.Hour)
+			err := s.repo.Create(context.Background(), request)
+			require.NoError(t, err)
+			// when
+			_, err = s.repo.Review(context.Background(), request.ID, "reviewer", tenantupdate.RequestFailed)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("unknown", func(t *testing.T) {
+			// when
+			_, err := s.repo.Review(context.Background(), uuid.NewV4(), "reviewer", tenantupdate.RequestApproved)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.NotFoundError{}, err)
+		})
+	})
+}
+
+func (s *RequestRepositoryBlackboxTestSuite) TestRecordStart() {
+
+	s.T().Run("started", func(t *testing.T) {
+		// given
+		request := newRequest(time.Hour)
+		err := s.repo.Create(context.Background(), request)
+		require.NoError(t, err)
+		_, err = s.repo.Review(context.Background(), request.ID, "reviewer", tenantupdate.RequestApproved)
+		require.NoError(t, err)
+		// when
+		result, err := s.repo.RecordStart(context.Background(), request.ID, tenantupdate.RequestApproved, http.StatusAccepted)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RequestApproved, result.Status)
+		require.NotNil(t, result.StatusCode)
+		assert.Equal(t, http.StatusAccepted, *result.StatusCode)
+		require.NotNil(t, result.ReviewedBy)
+		assert.Equal(t, "reviewer", *result.ReviewedBy)
+	})
+
+	s.T().Run("not started", func(t *testing.T) {
+		// given
+		request := newRequest(time.Hour)
+		err := s.repo.Create(context.Background(), request)
+		require.NoError(t, err)
+		_, err = s.repo.Review(context.Background(), request.ID, "reviewer", tenantupdate.RequestApproved)
+		require.NoError(t, err)
+		// when
+		result, err := s.repo.RecordStart(context.Background(), request.ID, tenantupdate.RequestFailed, http.StatusConflict)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RequestFailed, result.Status)
+		require.NotNil(t, result.StatusCode)
+		assert.Equal(t, http.StatusConflict, *result.StatusCode)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("pending", func(t *testing.T) {
+			// given
+			request := newRequest(time.Hour)
+			err := s.repo.Create(context.Background(), request)
+			require.NoError(t, err)
+			// when
+			_, err = s.repo.RecordStart(context.Background(), request.ID, tenantupdate.RequestApproved, http.StatusAccepted)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, err)
+		})
+
+		t.Run("already recorded", func(t *testing.T) {
+			// given
+			request := newRequest(time.Hour)
+			err := s.repo.Create(context.Background(), request)
+			require.NoError(t, err)
+			_, err = s.repo.Review(context.Background(), request.ID, "reviewer", tenantupdate.RequestApproved)
+			require.NoError(t, err)
+			_, err = s.repo.RecordStart(context.Background(), request.ID, tenantupdate.RequestApproved, http.StatusAccepted)
+			require.NoError(t, err)
+			// when
+			_, err = s.repo.RecordStart(context.Background(), request.ID, tenantupdate.RequestFailed, http.StatusConflict)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, err)
+		})
+
+		t.Run("invalid status", func(t *testing.T) {
+			// when
+			_, err := s.repo.RecordStart(context.Background(), uuid.NewV4(), tenantupdate.RequestRejected, http.StatusAccepted)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("unknown", func(t *testing.T) {
+			// when
+			_, err := s.repo.RecordStart(context.Background(), uuid.NewV4(), tenantupdate.RequestApproved, http.StatusAccepted)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.NotFoundError{}, err)
+		})
+	})
+}
+
+func (s *RequestRepositoryBlackboxTestSuite) TestExpire() {
+	// given
+	expired := newRequest(-time.Minute)
+	err := s.repo.Create(context.Background(), expired)
+	require.NoError(s.T(), err)
+	pending := newRequest(time.Hour)
+	err = s.repo.Create(context.Background(), pending)
+	require.NoError(s.T(), err)
+	// when
+	result, err := s.repo.Expire(context.Background(), time.Now())
+	// then
+	require.NoError(s.T(), err)
+	ids := requestIDs(result)
+	assert.Contains(s.T(), ids, expired.ID)
+	assert.NotContains(s.T(), ids, pending.ID)
+	loaded, err := s.repo.Load(context.Background(), expired.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), tenantupdate.RequestExpired, loaded.Status)
+	loaded, err = s.repo.Load(context.Background(), pending.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), tenantupdate.RequestPending, loaded.Status)
+	// and the expired requests are only returned once
+	result, err = s.repo.Expire(context.Background(), time.Now())
+	require.NoError(s.T(), err)
+	assert.NotContains(s.T(), requestIDs(result), expired.ID)
+}
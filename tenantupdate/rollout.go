@@ -0,0 +1,522 @@
+package tenantupdate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// RolloutStatus the status of a staged rollout of a tenant update
+type RolloutStatus string
+
+const (
+	// RolloutRunning the stages of the rollout are started one after the other by the scheduler
+	RolloutRunning RolloutStatus = "running"
+	// RolloutPaused no stage is started until the rollout is resumed (the ongoing stage, if any, is not interrupted)
+	RolloutPaused RolloutStatus = "paused"
+	// RolloutCompleted all the stages of the rollout succeeded
+	RolloutCompleted RolloutStatus = "completed"
+	// RolloutFailed a stage of the rollout failed, so the next ones were not started
+	RolloutFailed RolloutStatus = "failed"
+	// RolloutAborted the rollout was aborted by a user
+	RolloutAborted RolloutStatus = "aborted"
+)
+
+// Finished returns true if the rollout with this status is over
+func (s RolloutStatus) Finished() bool {
+	return s == RolloutCompleted || s == RolloutFailed || s == RolloutAborted
+}
+
+// StageStatus the status of a stage of a rollout
+type StageStatus string
+
+const (
+	// StagePending the update of the stage was not started yet
+	StagePending StageStatus = "pending"
+	// StageStarting the stage was claimed by the scheduler, which is starting its update
+	StageStarting StageStatus = "starting"
+	// StageRunning the update of the stage was started, and is not over yet
+	StageRunning StageStatus = "running"
+	// StageSucceeded the update of the stage is over, and the number of failed tenants is within the allowed limit
+	StageSucceeded StageStatus = "succeeded"
+	// StageFailed the update of the stage could not be started, was stopped or the number of failed tenants is above
+	// the allowed limit
+	StageFailed StageStatus = "failed"
+)
+
+// Rollout a tenant update rolled out by stages, each stage being a tenant update limited to a cluster and/or to an
+// environment type, which is only started once the previous stage succeeded
+type Rollout struct {
+	ID     uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:rollout_id"`
+	Status RolloutStatus
+	// CurrentStage the position of the stage which is running or about to be started, or of the last stage which
+	// was run if the rollout is over
+	CurrentStage int
+	// Reason the reason why the rollout failed, if it did
+	Reason *string
+	// CreatedBy the username of the user who created the rollout
+	CreatedBy string
+	CreatedAt time.Time
+	// PausedBy the username of the user who paused the rollout, if it is paused
+	PausedBy  *string
+	PausedAt  *time.Time
+	AbortedBy *string
+	AbortedAt *time.Time
+	// FinishedAt the time when the rollout completed, failed or was aborted
+	FinishedAt *time.Time
+	// Stages the stages of the rollout, in the order in which they are run
+	Stages []Stage `gorm:"-"`
+}
+
+const (
+	rolloutTableName      = "tenant_update_rollout"
+	rolloutStageTableName = "tenant_update_rollout_stage"
+)
+
+// TableName implements gorm.tabler
+func (r Rollout) TableName() string {
+	return rolloutTableName
+}
+
+// Stage a stage of a rollout, ie, a tenant update limited to a cluster and/or to an environment type
+type Stage struct {
+	RolloutID uuid.UUID `sql:"type:uuid"`
+	// Position the position of the stage in the rollout, starting at 0
+	Position int
+	// ClusterURL the URL of the cluster to which the update is limited, if any
+	ClusterURL *string
+	// EnvType the type of environment to which the update is limited, if any
+	EnvType *string
+	// MaxFailedCount the maximum number of tenants whose update may fail for the stage to succeed
+	MaxFailedCount int
+	// WaitSeconds the number of seconds to wait after the stage succeeded before the next stage is started
+	WaitSeconds int
+	Status      StageStatus
+	// JobID the ID of the tenant update job of the stage, once started
+	JobID *uuid.UUID `sql:"type:uuid"`
+	// StatusCode the status of the response of the tenant service when the update of the stage was last started,
+	// or 0 if no response was received
+	StatusCode *int
+	// StartedAt the time when the update of the stage was started, or when the stage was claimed by the scheduler
+	// while it is starting
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+	// FailedCount the number of tenants whose update failed, once the stage is over
+	FailedCount *int
+}
+
+// TableName implements gorm.tabler
+func (s Stage) TableName() string {
+	return rolloutStageTableName
+}
+
+// Progress the progress of a rollout
+type Progress struct {
+	Rollout Rollout
+	// SucceededStages the number of stages which succeeded
+	SucceededStages int
+	// FailedCount the number of tenants whose update failed so far, in all the stages
+	FailedCount int
+	// CurrentJob the job of the current stage of the rollout, if it was started
+	CurrentJob *Job
+}
+
+// RolloutRepository provides functions to manage the staged rollouts of the tenant updates
+type RolloutRepository interface {
+	Create(ctx context.Context, rollout *Rollout) error
+	Load(ctx context.Context, id uuid.UUID) (Rollout, error)
+	List(ctx context.Context, activeOnly bool) ([]Rollout, error)
+	Progress(ctx context.Context, id uuid.UUID) (Progress, error)
+	Pause(ctx context.Context, id uuid.UUID, username string) (Rollout, error)
+	Resume(ctx context.Context, id uuid.UUID, username string) (Rollout, error)
+	Abort(ctx context.Context, id uuid.UUID, username string) (Rollout, error)
+	Advance(ctx context.Context, now time.Time, lease time.Duration) ([]Rollout, error)
+	RecordStart(ctx context.Context, id uuid.UUID, position int, jobID *uuid.UUID, statusCode int, now time.Time) (Rollout, error)
+}
+
+// NewRolloutRepository creates a GormRolloutRepository
+func NewRolloutRepository(db *gorm.DB) RolloutRepository {
+	return &GormRolloutRepository{
+		db: db,
+	}
+}
+
+// GormRolloutRepository implements RolloutRepository using gorm
+type GormRolloutRepository struct {
+	db *gorm.DB
+}
+
+// Create stores the given rollout along with its stages, which are run in the given order. The rollout is running,
+// so its first stage is started during the next run of the scheduler.
+// returns BadParameterError if the initiator or the stages are missing, or if the limits of a stage are negative,
+// or InternalError if something wrong happened
+func (r *GormRolloutRepository) Create(ctx context.Context, rollout *Rollout) error {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRollout", "create"}, time.Now())
+	if rollout == nil {
+		return errors.NewBadParameterErrorFromString("missing tenant update rollout to persist")
+	}
+	if rollout.CreatedBy == "" {
+		return errors.NewBadParameterErrorFromString("missing initiator of the tenant update rollout")
+	}
+	if len(rollout.Stages) == 0 {
+		return errors.NewBadParameterErrorFromString("missing stages of the tenant update rollout")
+	}
+	for _, s := range rollout.Stages {
+		if s.MaxFailedCount < 0 {
+			return errors.NewBadParameterError("max_failed_count", s.MaxFailedCount)
+		}
+		if s.WaitSeconds < 0 {
+			return errors.NewBadParameterError("wait", s.WaitSeconds)
+		}
+	}
+	if rollout.ID == uuid.Nil {
+		rollout.ID = uuid.NewV4()
+	}
+	rollout.Status = RolloutRunning
+	rollout.CurrentStage = 0
+	if err := r.db.Create(rollout).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	for i := range rollout.Stages {
+		stage := &rollout.Stages[i]
+		stage.RolloutID = rollout.ID
+		stage.Position = i
+		stage.Status = StagePending
+		if err := r.db.Create(stage).Error; err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+	}
+	return nil
+}
+
+// Load returns the rollout with the given ID, along with its stages
+// returns NotFoundError or InternalError
+func (r *GormRolloutRepository) Load(ctx context.Context, id uuid.UUID) (Rollout, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRollout", "load"}, time.Now())
+	rollouts := []Rollout{}
+	if err := r.db.Where("rollout_id = ?", id).Find(&rollouts).Error; err != nil {
+		return Rollout{}, errors.NewInternalError(ctx, err)
+	}
+	if len(rollouts) == 0 {
+		return Rollout{}, errors.NewNotFoundError("tenant update rollout", id.String())
+	}
+	if err := r.loadStages(ctx, rollouts); err != nil {
+		return Rollout{}, err
+	}
+	return rollouts[0], nil
+}
+
+// List returns the rollouts (only the running or paused ones if `activeOnly` is true) along with their stages,
+// sorted by creation date
+// returns InternalError if something wrong happened
+func (r *GormRolloutRepository) List(ctx context.Context, activeOnly bool) ([]Rollout, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRollout", "list"}, time.Now())
+	db := r.db
+	if activeOnly {
+		db = db.Where("status in (?)", []RolloutStatus{RolloutRunning, RolloutPaused})
+	}
+	rollouts := []Rollout{}
+	if err := db.Order("created_at, rollout_id").Find(&rollouts).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	if err := r.loadStages(ctx, rollouts); err != nil {
+		return nil, err
+	}
+	return rollouts, nil
+}
+
+// loadStages loads the stages of the given rollouts, in a single query
+// returns InternalError if something wrong happened
+func (r *GormRolloutRepository) loadStages(ctx context.Context, rollouts []Rollout) error {
+	if len(rollouts) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(rollouts))
+	for i, ro := range rollouts {
+		ids[i] = ro.ID
+	}
+	stages := []Stage{}
+	if err := r.db.Where("rollout_id in (?)", ids).Order("rollout_id, position").Find(&stages).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	byRollout := map[uuid.UUID][]Stage{}
+	for _, s := range stages {
+		byRollout[s.RolloutID] = append(byRollout[s.RolloutID], s)
+	}
+	for i := range rollouts {
+		rollouts[i].Stages = byRollout[rollouts[i].ID]
+	}
+	return nil
+}
+
+// Progress returns the progress of the rollout with the given ID, including the status of the job of its
+// current stage (as last refreshed by the poller)
+// returns NotFoundError or InternalError
+func (r *GormRolloutRepository) Progress(ctx context.Context, id uuid.UUID) (Progress, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRollout", "progress"}, time.Now())
+	rollout, err := r.Load(ctx, id)
+	if err != nil {
+		return Progress{}, err
+	}
+	progress := Progress{
+		Rollout: rollout,
+	}
+	for _, s := range rollout.Stages {
+		if s.Status == StageSucceeded {
+			progress.SucceededStages++
+		}
+		if s.FailedCount != nil {
+			progress.FailedCount += *s.FailedCount
+		}
+	}
+	if current := rollout.Stages[rollout.CurrentStage]; current.JobID != nil {
+		job, err := NewRepository(r.db).Load(ctx, *current.JobID)
+		if err != nil {
+			return Progress{}, err
+		}
+		if current.FailedCount == nil {
+			// the stage is not over yet
+			progress.FailedCount += job.FailedCount
+		}
+		progress.CurrentJob = &job
+	}
+	return progress, nil
+}
+
+// Pause pauses the running rollout with the given ID on behalf of the user with the given username, so that no
+// stage is started until it is resumed. The ongoing stage, if any, is not interrupted.
+// returns the paused rollout, NotFoundError, DataConflictError if the rollout is not running, or InternalError
+func (r *GormRolloutRepository) Pause(ctx context.Context, id uuid.UUID, username string) (Rollout, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRollout", "pause"}, time.Now())
+	return r.transition(ctx, id, []RolloutStatus{RolloutRunning}, map[string]interface{}{
+		"status":    RolloutPaused,
+		"paused_by": username,
+		"paused_at": time.Now(),
+	})
+}
+
+// Resume resumes the paused rollout with the given ID, so that its next stage is started during the next run of the
+// scheduler
+// returns the resumed rollout, NotFoundError, DataConflictError if the rollout is not paused, or InternalError
+func (r *GormRolloutRepository) Resume(ctx context.Context, id uuid.UUID, username string) (Rollout, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRollout", "resume"}, time.Now())
+	return r.transition(ctx, id, []RolloutStatus{RolloutPaused}, map[string]interface{}{
+		"status":    RolloutRunning,
+		"paused_by": nil,
+		"paused_at": nil,
+	})
+}
+
+// Abort aborts the running or paused rollout with the given ID on behalf of the user with the given username, so that
+// no stage is started anymore. The ongoing stage, if any, is not interrupted.
+// returns the aborted rollout, NotFoundError, DataConflictError if the rollout is already over, or InternalError
+func (r *GormRolloutRepository) Abort(ctx context.Context, id uuid.UUID, username string) (Rollout, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRollout", "abort"}, time.Now())
+	now := time.Now()
+	return r.transition(ctx, id, []RolloutStatus{RolloutRunning, RolloutPaused}, map[string]interface{}{
+		"status":      RolloutAborted,
+		"aborted_by":  username,
+		"aborted_at":  now,
+		"finished_at": now,
+	})
+}
+
+// transition updates the rollout with the given ID with the given values, provided that its status is one of the
+// given statuses
+// returns the updated rollout, NotFoundError, DataConflictError if the rollout has another status, or InternalError
+func (r *GormRolloutRepository) transition(ctx context.Context, id uuid.UUID, from []RolloutStatus, values map[string]interface{}) (Rollout, error) {
+	result := r.db.Model(&Rollout{}).Where("rollout_id = ? and status in (?)", id, from).Updates(values)
+	if result.Error != nil {
+		return Rollout{}, errors.NewInternalError(ctx, result.Error)
+	}
+	rollout, err := r.Load(ctx, id)
+	if err != nil {
+		return Rollout{}, err
+	}
+	if result.RowsAffected == 0 {
+		return Rollout{}, errors.NewDataConflictError(fmt.Sprintf("tenant update rollout is %s: %s", rollout.Status, id.String()))
+	}
+	return rollout, nil
+}
+
+// Advance advances the running rollouts by (at most) one step each, at the given time:
+// - if the current stage is pending and the time to wait after the previous stage is over, it is claimed for the given
+// lease duration, ie, it is starting until its update is started outside of the transaction, and the outcome recorded
+// with `RecordStart`. A stage whose lease expired (eg: because the scheduler stopped while starting it) is claimed again.
+// - if the current stage is running and its job is over (as refreshed by the poller), the stage succeeds if the job
+// was not stopped and if the number of failed tenants is within the allowed limit, in which case the next stage
+// becomes the current one (or the rollout completes). Otherwise the stage and the rollout fail.
+// The rollouts are locked until they are updated, and locked rollouts are skipped, so this function must be called
+// within a transaction, and concurrent calls do not claim the same stages.
+// returns the rollouts which changed, as they are after the change (including those whose current stage is starting),
+// or an InternalError if something wrong happened while querying or updating the database
+func (r *GormRolloutRepository) Advance(ctx context.Context, now time.Time, lease time.Duration) ([]Rollout, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRollout", "advance"}, time.Now())
+	rollouts := []Rollout{}
+	err := r.db.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+		Where("status = ?", RolloutRunning).
+		Order("created_at, rollout_id").
+		Find(&rollouts).Error
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	if err := r.loadStages(ctx, rollouts); err != nil {
+		return nil, err
+	}
+	changed := []Rollout{}
+	for _, rollout := range rollouts {
+		ok, err := r.advance(ctx, &rollout, now, lease)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			changed = append(changed, rollout)
+		}
+	}
+	return changed, nil
+}
+
+// advance advances the given rollout by (at most) one step, and stores the changes
+// returns true if the rollout changed, or an error if something wrong happened
+func (r *GormRolloutRepository) advance(ctx context.Context, rollout *Rollout, now time.Time, lease time.Duration) (bool, error) {
+	stage := &rollout.Stages[rollout.CurrentStage]
+	switch stage.Status {
+	case StagePending:
+		if rollout.CurrentStage > 0 {
+			previous := rollout.Stages[rollout.CurrentStage-1]
+			if previous.FinishedAt != nil && now.Before(previous.FinishedAt.Add(time.Duration(previous.WaitSeconds)*time.Second)) {
+				return false, nil
+			}
+		}
+		stage.Status = StageStarting
+		stage.StartedAt = &now
+	case StageStarting:
+		if stage.StartedAt != nil && now.Before(stage.StartedAt.Add(lease)) {
+			// the stage is being started by another run of the scheduler
+			return false, nil
+		}
+		stage.StartedAt = &now
+	case StageRunning:
+		job, err := NewRepository(r.db).Load(ctx, *stage.JobID)
+		if err != nil {
+			return false, err
+		}
+		if !job.Status.Finished() {
+			return false, nil
+		}
+		failedCount := job.FailedCount
+		stage.FailedCount = &failedCount
+		stage.FinishedAt = job.FinishedAt
+		switch {
+		case job.Status != tenant.StatusFinished && job.Status != tenant.StatusFailed:
+			stage.Status = StageFailed
+			rollout.fail(now, fmt.Sprintf("the update of stage #%d is %s", stage.Position, job.Status))
+		case job.FailedCount > stage.MaxFailedCount:
+			stage.Status = StageFailed
+			rollout.fail(now, fmt.Sprintf("the update of %d tenant(s) failed in stage #%d, more than the %d allowed", job.FailedCount, stage.Position, stage.MaxFailedCount))
+		default:
+			stage.Status = StageSucceeded
+			if rollout.CurrentStage == len(rollout.Stages)-1 {
+				rollout.Status = RolloutCompleted
+				rollout.FinishedAt = &now
+			} else {
+				rollout.CurrentStage++
+			}
+		}
+	default:
+		// the stages which are over are never current while the rollout is running
+		return false, nil
+	}
+	if err := r.save(ctx, rollout, *stage); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RecordStart stores the outcome of the start of the update of the starting stage at the given position of the rollout
+// with the given ID, at the given time: the stage is running if the update was started (ie, if the ID of the job of
+// the started update is given), is pending again if the tenant service responded with a conflict (because another
+// update is ongoing), or fails otherwise, along with the rollout if it is still running. The rollout is locked until
+// it is updated, so this function must be called within a transaction.
+// returns the rollout as it is after the change, NotFoundError, DataConflictError if the stage is not starting, or
+// InternalError
+func (r *GormRolloutRepository) RecordStart(ctx context.Context, id uuid.UUID, position int, jobID *uuid.UUID, statusCode int, now time.Time) (Rollout, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateRollout", "record_start"}, time.Now())
+	rollouts := []Rollout{}
+	if err := r.db.Set("gorm:query_option", "FOR UPDATE").Where("rollout_id = ?", id).Find(&rollouts).Error; err != nil {
+		return Rollout{}, errors.NewInternalError(ctx, err)
+	}
+	if len(rollouts) == 0 {
+		return Rollout{}, errors.NewNotFoundError("tenant update rollout", id.String())
+	}
+	if err := r.loadStages(ctx, rollouts); err != nil {
+		return Rollout{}, err
+	}
+	rollout := rollouts[0]
+	if position < 0 || position >= len(rollout.Stages) || rollout.Stages[position].Status != StageStarting {
+		return Rollout{}, errors.NewDataConflictError(fmt.Sprintf("stage #%d of the tenant update rollout is not starting: %s", position, id.String()))
+	}
+	stage := &rollout.Stages[position]
+	switch {
+	case jobID != nil:
+		stage.Status = StageRunning
+		stage.JobID = jobID
+		stage.StartedAt = &now
+	case statusCode == http.StatusConflict:
+		// another update is ongoing, the stage will be started again during the next run
+		stage.Status = StagePending
+		stage.StartedAt = nil
+	default:
+		stage.Status = StageFailed
+		stage.FinishedAt = &now
+		if rollout.Status == RolloutRunning {
+			rollout.fail(now, fmt.Sprintf("the update of stage #%d could not be started (status %d)", stage.Position, statusCode))
+		}
+	}
+	stage.StatusCode = &statusCode
+	if err := r.save(ctx, &rollout, *stage); err != nil {
+		return Rollout{}, err
+	}
+	return rollout, nil
+}
+
+// save stores the changes of the given rollout and of the given stage
+// returns InternalError if something wrong happened
+func (r *GormRolloutRepository) save(ctx context.Context, rollout *Rollout, stage Stage) error {
+	err := r.db.Model(&Stage{}).Where("rollout_id = ? and position = ?", stage.RolloutID, stage.Position).Updates(map[string]interface{}{
+		"status":       stage.Status,
+		"job_id":       stage.JobID,
+		"status_code":  stage.StatusCode,
+		"started_at":   stage.StartedAt,
+		"finished_at":  stage.FinishedAt,
+		"failed_count": stage.FailedCount,
+	}).Error
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	err = r.db.Model(&Rollout{}).Where("rollout_id = ?", rollout.ID).Updates(map[string]interface{}{
+		"status":        rollout.Status,
+		"current_stage": rollout.CurrentStage,
+		"reason":        rollout.Reason,
+		"finished_at":   rollout.FinishedAt,
+	}).Error
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// fail marks the rollout as failed at the given time, for the given reason
+func (r *Rollout) fail(now time.Time, reason string) {
+	r.Status = RolloutFailed
+	r.Reason = &reason
+	r.FinishedAt = &now
+}
@@ -0,0 +1,411 @@
+package tenantupdate_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/tenant"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RolloutRepositoryBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	repo tenantupdate.RolloutRepository
+	jobs tenantupdate.Repository
+}
+
+func TestRolloutRepository(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &RolloutRepositoryBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *RolloutRepositoryBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.repo = tenantupdate.NewRolloutRepository(s.DB)
+	s.jobs = tenantupdate.NewRepository(s.DB)
+}
+
+// newRollout returns a rollout of an update on 2 new clusters, with the given time to wait after the first stage
+func newRollout(waitSeconds int) *tenantupdate.Rollout {
+	rollout := &tenantupdate.Rollout{
+		CreatedBy: fmt.Sprintf("user-%s", uuid.NewV4()),
+	}
+	for i := 0; i < 2; i++ {
+		clusterURL := fmt.Sprintf("https://cluster-%s.example.com", uuid.NewV4())
+		rollout.Stages = append(rollout.Stages, tenantupdate.Stage{
+			ClusterURL:  &clusterURL,
+			WaitSeconds: waitSeconds,
+		})
+	}
+	return rollout
+}
+
+// startFunc starts the update of the given stage of the rollout, and returns the ID of the job recorded for the started
+// update (or nil if the update was not started), along with the status of the response of the tenant service
+type startFunc func(rollout tenantupdate.Rollout, stage tenantupdate.Stage) (*uuid.UUID, int)
+
+// startStages returns a function which starts the stages of the given rollout only, by recording a new job, and
+// which responds with a conflict for the other rollouts
+func (s *RolloutRepositoryBlackboxTestSuite) startStages(t *testing.T, rolloutID uuid.UUID) startFunc {
+	return func(rollout tenantupdate.Rollout, stage tenantupdate.Stage) (*uuid.UUID, int) {
+		if rollout.ID != rolloutID {
+			return nil, http.StatusConflict
+		}
+		job := tenantupdate.Job{
+			ClusterURL: stage.ClusterURL,
+			Status:     tenant.StatusUpdating,
+			StartedBy:  rollout.CreatedBy,
+		}
+		require.NoError(t, s.jobs.Create(context.Background(), &job))
+		return &job.ID, http.StatusAccepted
+	}
+}
+
+// advance advances the running rollouts in a transaction, and then starts the claimed stages with the given function
+// and records the outcome, as the scheduler does
+// returns the given rollout, if it changed
+func (s *RolloutRepositoryBlackboxTestSuite) advance(t *testing.T, rolloutID uuid.UUID, now time.Time, start startFunc) *tenantupdate.Rollout {
+	tx := s.DB.Begin()
+	require.NoError(t, tx.Error)
+	defer tx.Rollback()
+	rollouts, err := tenantupdate.NewRolloutRepository(tx).Advance(context.Background(), now, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit().Error)
+	var result *tenantupdate.Rollout
+	for _, r := range rollouts {
+		if stage := r.Stages[r.CurrentStage]; stage.Status == tenantupdate.StageStarting {
+			jobID, statusCode := start(r, stage)
+			r, err = s.repo.RecordStart(context.Background(), r.ID, stage.Position, jobID, statusCode, now)
+			require.NoError(t, err)
+			if r.Stages[stage.Position].Status == tenantupdate.StagePending {
+				// the stage will be started again during the next run
+				continue
+			}
+		}
+		if r.ID == rolloutID {
+			changed := r
+			result = &changed
+		}
+	}
+	return result
+}
+
+func (s *RolloutRepositoryBlackboxTestSuite) TestCreate() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		rollout := newRollout(30)
+		rollout.Stages[0].MaxFailedCount = 3
+		// when
+		err := s.repo.Create(context.Background(), rollout)
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.Load(context.Background(), rollout.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RolloutRunning, result.Status)
+		assert.Equal(t, 0, result.CurrentStage)
+		assert.Equal(t, rollout.CreatedBy, result.CreatedBy)
+		assert.False(t, result.CreatedAt.IsZero())
+		require.Len(t, result.Stages, 2)
+		for i, stage := range result.Stages {
+			assert.Equal(t, i, stage.Position)
+			assert.Equal(t, *rollout.Stages[i].ClusterURL, *stage.ClusterURL)
+			assert.Equal(t, 30, stage.WaitSeconds)
+			assert.Equal(t, tenantupdate.StagePending, stage.Status)
+			assert.Nil(t, stage.JobID)
+		}
+		assert.Equal(t, 3, result.Stages[0].MaxFailedCount)
+		assert.Equal(t, 0, result.Stages[1].MaxFailedCount)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("missing initiator", func(t *testing.T) {
+			// given
+			rollout := newRollout(0)
+			rollout.CreatedBy = ""
+			// when
+			err := s.repo.Create(context.Background(), rollout)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("missing stages", func(t *testing.T) {
+			// given
+			rollout := newRollout(0)
+			rollout.Stages = nil
+			// when
+			err := s.repo.Create(context.Background(), rollout)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("negative max failed count", func(t *testing.T) {
+			// given
+			rollout := newRollout(0)
+			rollout.Stages[1].MaxFailedCount = -1
+			// when
+			err := s.repo.Create(context.Background(), rollout)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+	})
+}
+
+func (s *RolloutRepositoryBlackboxTestSuite) TestLoadUnknown() {
+	// when
+	_, err := s.repo.Load(context.Background(), uuid.NewV4())
+	// then
+	require.Error(s.T(), err)
+	assert.IsType(s.T(), errors.NotFoundError{}, err)
+}
+
+func (s *RolloutRepositoryBlackboxTestSuite) TestTransitions() {
+
+	s.T().Run("pause, resume and abort", func(t *testing.T) {
+		// given
+		rollout := newRollout(0)
+		require.NoError(t, s.repo.Create(context.Background(), rollout))
+		// when
+		paused, err := s.repo.Pause(context.Background(), rollout.ID, "pauser")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RolloutPaused, paused.Status)
+		require.NotNil(t, paused.PausedBy)
+		assert.Equal(t, "pauser", *paused.PausedBy)
+		assert.Len(t, paused.Stages, 2)
+		// when
+		resumed, err := s.repo.Resume(context.Background(), rollout.ID, "resumer")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RolloutRunning, resumed.Status)
+		assert.Nil(t, resumed.PausedBy)
+		assert.Nil(t, resumed.PausedAt)
+		// when
+		aborted, err := s.repo.Abort(context.Background(), rollout.ID, "aborter")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RolloutAborted, aborted.Status)
+		require.NotNil(t, aborted.AbortedBy)
+		assert.Equal(t, "aborter", *aborted.AbortedBy)
+		assert.NotNil(t, aborted.FinishedAt)
+		// and the aborted rollout is not listed as active anymore
+		active, err := s.repo.List(context.Background(), true)
+		require.NoError(t, err)
+		assert.NotContains(t, rolloutIDs(active), rollout.ID)
+		all, err := s.repo.List(context.Background(), false)
+		require.NoError(t, err)
+		assert.Contains(t, rolloutIDs(all), rollout.ID)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("resume a running rollout", func(t *testing.T) {
+			// given
+			rollout := newRollout(0)
+			require.NoError(t, s.repo.Create(context.Background(), rollout))
+			// when
+			_, err := s.repo.Resume(context.Background(), rollout.ID, "resumer")
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, err)
+		})
+
+		t.Run("pause an aborted rollout", func(t *testing.T) {
+			// given
+			rollout := newRollout(0)
+			require.NoError(t, s.repo.Create(context.Background(), rollout))
+			_, err := s.repo.Abort(context.Background(), rollout.ID, "aborter")
+			require.NoError(t, err)
+			// when
+			_, err = s.repo.Pause(context.Background(), rollout.ID, "pauser")
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, err)
+		})
+
+		t.Run("unknown", func(t *testing.T) {
+			// when
+			_, err := s.repo.Abort(context.Background(), uuid.NewV4(), "aborter")
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.NotFoundError{}, err)
+		})
+	})
+}
+
+func rolloutIDs(rollouts []tenantupdate.Rollout) []uuid.UUID {
+	ids := make([]uuid.UUID, len(rollouts))
+	for i, r := range rollouts {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func (s *RolloutRepositoryBlackboxTestSuite) TestAdvance() {
+
+	s.T().Run("wait after stage", func(t *testing.T) {
+		// given
+		rollout := newRollout(600)
+		require.NoError(t, s.repo.Create(context.Background(), rollout))
+		now := time.Now()
+		result := s.advance(t, rollout.ID, now, s.startStages(t, rollout.ID))
+		require.NotNil(t, result)
+		err := s.jobs.Refresh(context.Background(), *result.Stages[0].JobID, tenant.Update{
+			Status: tenant.StatusFinished,
+		})
+		require.NoError(t, err)
+		result = s.advance(t, rollout.ID, now, s.startStages(t, rollout.ID))
+		require.NotNil(t, result)
+		require.Equal(t, 1, result.CurrentStage)
+		// when the time to wait after the first stage is not over
+		result = s.advance(t, rollout.ID, now.Add(time.Minute), s.startStages(t, rollout.ID))
+		// then the second stage is not started
+		assert.Nil(t, result)
+		// when the time to wait is over
+		result = s.advance(t, rollout.ID, now.Add(11*time.Minute), s.startStages(t, rollout.ID))
+		// then the second stage is started
+		require.NotNil(t, result)
+		assert.Equal(t, tenantupdate.StageRunning, result.Stages[1].Status)
+	})
+
+	s.T().Run("another update is ongoing", func(t *testing.T) {
+		// given
+		rollout := newRollout(0)
+		require.NoError(t, s.repo.Create(context.Background(), rollout))
+		// when
+		result := s.advance(t, rollout.ID, time.Now(), func(tenantupdate.Rollout, tenantupdate.Stage) (*uuid.UUID, int) {
+			return nil, http.StatusConflict
+		})
+		// then the stage remains pending
+		assert.Nil(t, result)
+		loaded, err := s.repo.Load(context.Background(), rollout.ID)
+		require.NoError(t, err)
+		assert.Equal(t, tenantupdate.RolloutRunning, loaded.Status)
+		assert.Equal(t, tenantupdate.StagePending, loaded.Stages[0].Status)
+	})
+
+	s.T().Run("update not started", func(t *testing.T) {
+		// given
+		rollout := newRollout(0)
+		require.NoError(t, s.repo.Create(context.Background(), rollout))
+		// when
+		result := s.advance(t, rollout.ID, time.Now(), func(r tenantupdate.Rollout, _ tenantupdate.Stage) (*uuid.UUID, int) {
+			if r.ID != rollout.ID {
+				return nil, http.StatusConflict
+			}
+			return nil, http.StatusInternalServerError
+		})
+		// then
+		require.NotNil(t, result)
+		assert.Equal(t, tenantupdate.RolloutFailed, result.Status)
+		assert.Equal(t, tenantupdate.StageFailed, result.Stages[0].Status)
+		require.NotNil(t, result.Stages[0].StatusCode)
+		assert.Equal(t, http.StatusInternalServerError, *result.Stages[0].StatusCode)
+	})
+
+	s.T().Run("stage being started", func(t *testing.T) {
+		// given
+		rollout := newRollout(0)
+		require.NoError(t, s.repo.Create(context.Background(), rollout))
+		now := time.Now()
+		claimed, err := s.repo.Advance(context.Background(), now, time.Minute)
+		require.NoError(t, err)
+		require.Contains(t, rolloutIDs(claimed), rollout.ID)
+		loaded, err := s.repo.Load(context.Background(), rollout.ID)
+		require.NoError(t, err)
+		require.Equal(t, tenantupdate.StageStarting, loaded.Stages[0].Status)
+		// when the stage is still claimed
+		claimed, err = s.repo.Advance(context.Background(), now.Add(30*time.Second), time.Minute)
+		// then it is not claimed again
+		require.NoError(t, err)
+		assert.NotContains(t, rolloutIDs(claimed), rollout.ID)
+		// when its lease expired (eg: because the scheduler stopped while starting it)
+		result := s.advance(t, rollout.ID, now.Add(2*time.Minute), s.startStages(t, rollout.ID))
+		// then it is claimed and started again
+		require.NotNil(t, result)
+		assert.Equal(t, tenantupdate.StageRunning, result.Stages[0].Status)
+		// and its start cannot be recorded twice
+		_, err = s.repo.RecordStart(context.Background(), rollout.ID, 0, nil, http.StatusAccepted, now)
+		require.Error(t, err)
+		assert.IsType(t, errors.DataConflictError{}, err)
+	})
+
+	s.T().Run("update stopped", func(t *testing.T) {
+		// given
+		rollout := newRollout(0)
+		require.NoError(t, s.repo.Create(context.Background(), rollout))
+		result := s.advance(t, rollout.ID, time.Now(), s.startStages(t, rollout.ID))
+		require.NotNil(t, result)
+		err := s.jobs.Refresh(context.Background(), *result.Stages[0].JobID, tenant.Update{
+			Status: tenant.StatusKilled,
+		})
+		require.NoError(t, err)
+		// when
+		result = s.advance(t, rollout.ID, time.Now(), s.startStages(t, rollout.ID))
+		// then
+		require.NotNil(t, result)
+		assert.Equal(t, tenantupdate.RolloutFailed, result.Status)
+		assert.Equal(t, tenantupdate.StageFailed, result.Stages[0].Status)
+		require.NotNil(t, result.Reason)
+		assert.Contains(t, *result.Reason, "killed")
+	})
+}
+
+func rolloutIDs(rollouts []tenantupdate.Rollout) []uuid.UUID {
+	ids := make([]uuid.UUID, len(rollouts))
+	for i, r := range rollouts {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func (s *RolloutRepositoryBlackboxTestSuite) TestProgress() {
+	// given
+	rollout := newRollout(0)
+	rollout.Stages[0].MaxFailedCount = 5
+	require.NoError(s.T(), s.repo.Create(context.Background(), rollout))
+	result := s.advance(s.T(), rollout.ID, time.Now(), s.startStages(s.T(), rollout.ID))
+	require.NotNil(s.T(), result)
+	err := s.jobs.Refresh(context.Background(), *result.Stages[0].JobID, tenant.Update{
+		Status:      tenant.StatusFailed,
+		FailedCount: 2,
+	})
+	require.NoError(s.T(), err)
+	result = s.advance(s.T(), rollout.ID, time.Now(), s.startStages(s.T(), rollout.ID))
+	require.NotNil(s.T(), result)
+	result = s.advance(s.T(), rollout.ID, time.Now(), s.startStages(s.T(), rollout.ID))
+	require.NotNil(s.T(), result)
+	err = s.jobs.Refresh(context.Background(), *result.Stages[1].JobID, tenant.Update{
+		Status:      tenant.StatusUpdating,
+		FailedCount: 1,
+	})
+	require.NoError(s.T(), err)
+	// when
+	progress, err := s.repo.Progress(context.Background(), rollout.ID)
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), tenantupdate.RolloutRunning, progress.Rollout.Status)
+	assert.Equal(s.T(), 1, progress.Rollout.CurrentStage)
+	assert.Equal(s.T(), 1, progress.SucceededStages)
+	assert.Equal(s.T(), 3, progress.FailedCount)
+	require.NotNil(s.T(), progress.CurrentJob)
+	assert.Equal(s.T(), *result.Stages[1].JobID, progress.CurrentJob.ID)
+	assert.Equal(s.T(), tenant.StatusUpdating, progress.CurrentJob.Status)
+}
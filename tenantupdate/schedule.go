@@ -0,0 +1,226 @@
+package tenantupdate
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Schedule a tenant update which is started at a given time (once), or periodically according to a cron expression
+type Schedule struct {
+	ID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:schedule_id"`
+	// ClusterURL the URL of the cluster to which the update is limited, if any
+	ClusterURL *string
+	// EnvType the type of environment to which the update is limited, if any
+	EnvType *string
+	// CronExpression the cron expression of a recurring update, evaluated in UTC (nil for a one-off update)
+	CronExpression *string
+	// NextRunAt the next time the update will be started, or nil if the schedule is over or cancelled
+	NextRunAt *time.Time
+	LastRunAt *time.Time
+	// LastRunStatus the status of the response of the tenant service to the last request to start the update,
+	// or 0 if no response was received, or nil while the update is being started
+	LastRunStatus *int
+	// CreatedBy the username of the user who scheduled the update
+	CreatedBy   string
+	CreatedAt   time.Time
+	CancelledBy *string
+	CancelledAt *time.Time
+}
+
+const (
+	scheduleTableName = "tenant_update_schedule"
+)
+
+// TableName implements gorm.tabler
+func (s Schedule) TableName() string {
+	return scheduleTableName
+}
+
+// Active returns true if the update will be started (again)
+func (s Schedule) Active() bool {
+	return s.NextRunAt != nil
+}
+
+// ScheduleRepository provides functions to manage the scheduled tenant updates
+type ScheduleRepository interface {
+	Create(ctx context.Context, schedule *Schedule) error
+	Load(ctx context.Context, id uuid.UUID) (Schedule, error)
+	List(ctx context.Context, activeOnly bool) ([]Schedule, error)
+	Cancel(ctx context.Context, id uuid.UUID, username string) (Schedule, error)
+	ClaimDue(ctx context.Context, now time.Time, limit int) ([]Schedule, error)
+	RecordRun(ctx context.Context, id uuid.UUID, status int) error
+}
+
+// NewScheduleRepository creates a GormScheduleRepository
+func NewScheduleRepository(db *gorm.DB) ScheduleRepository {
+	return &GormScheduleRepository{
+		db: db,
+	}
+}
+
+// GormScheduleRepository implements ScheduleRepository using gorm
+type GormScheduleRepository struct {
+	db *gorm.DB
+}
+
+// Create stores the given schedule. A recurring update is first started at the next time matching its cron expression,
+// and a one-off update is started at the time given in `NextRunAt`.
+// returns BadParameterError if the initiator is missing, if the cron expression is invalid or never matches, or if neither
+// a cron expression nor a future time is given, or InternalError if something wrong happened
+func (r *GormScheduleRepository) Create(ctx context.Context, schedule *Schedule) error {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateSchedule", "create"}, time.Now())
+	if schedule == nil {
+		return errors.NewBadParameterErrorFromString("missing tenant update schedule to persist")
+	}
+	if schedule.CreatedBy == "" {
+		return errors.NewBadParameterErrorFromString("missing initiator of the tenant update schedule")
+	}
+	now := time.Now()
+	switch {
+	case schedule.CronExpression != nil:
+		cron, err := ParseCron(*schedule.CronExpression)
+		if err != nil {
+			return err
+		}
+		next := cron.Next(now)
+		if next.IsZero() {
+			return errors.NewBadParameterError("cron", *schedule.CronExpression)
+		}
+		schedule.NextRunAt = &next
+	case schedule.NextRunAt != nil:
+		if !schedule.NextRunAt.After(now) {
+			return errors.NewBadParameterError("run_at", *schedule.NextRunAt)
+		}
+	default:
+		return errors.NewBadParameterErrorFromString("missing cron expression or time of the scheduled tenant update")
+	}
+	if schedule.ID == uuid.Nil {
+		schedule.ID = uuid.NewV4()
+	}
+	if err := r.db.Create(schedule).Error; err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// Load returns the schedule with the given ID
+// returns NotFoundError or InternalError
+func (r *GormScheduleRepository) Load(ctx context.Context, id uuid.UUID) (Schedule, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateSchedule", "load"}, time.Now())
+	schedules := []Schedule{}
+	if err := r.db.Where("schedule_id = ?", id).Find(&schedules).Error; err != nil {
+		return Schedule{}, errors.NewInternalError(ctx, err)
+	}
+	if len(schedules) == 0 {
+		return Schedule{}, errors.NewNotFoundError("tenant update schedule", id.String())
+	}
+	return schedules[0], nil
+}
+
+// List returns the schedules (only the active ones if `activeOnly` is true), sorted by creation date
+// returns InternalError if something wrong happened
+func (r *GormScheduleRepository) List(ctx context.Context, activeOnly bool) ([]Schedule, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateSchedule", "list"}, time.Now())
+	db := r.db
+	if activeOnly {
+		db = db.Where("next_run_at is not null")
+	}
+	schedules := []Schedule{}
+	if err := db.Order("created_at, schedule_id").Find(&schedules).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return schedules, nil
+}
+
+// Cancel cancels the schedule with the given ID on behalf of the user with the given username, so that the update
+// is not started anymore
+// returns the cancelled schedule, NotFoundError, DataConflictError if the schedule is already over or cancelled,
+// or InternalError
+func (r *GormScheduleRepository) Cancel(ctx context.Context, id uuid.UUID, username string) (Schedule, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateSchedule", "cancel"}, time.Now())
+	result := r.db.Model(&Schedule{}).Where("schedule_id = ? and next_run_at is not null", id).Updates(map[string]interface{}{
+		"next_run_at":  nil,
+		"cancelled_by": username,
+		"cancelled_at": time.Now(),
+	})
+	if result.Error != nil {
+		return Schedule{}, errors.NewInternalError(ctx, result.Error)
+	}
+	schedule, err := r.Load(ctx, id)
+	if err != nil {
+		return Schedule{}, err
+	}
+	if result.RowsAffected == 0 {
+		return Schedule{}, errors.NewDataConflictError("tenant update schedule is already over or cancelled: " + id.String())
+	}
+	return schedule, nil
+}
+
+// ClaimDue claims at most `limit` schedules whose next run is due at the given time, in the order of their next run,
+// by storing the given time as their last run along with the time of their next run (if the schedule is recurring),
+// so that their updates can be started outside of the transaction, and their outcome recorded with `RecordRun`.
+// A missed run (eg: while the admin console was down) is only done once.
+// The schedules are locked until they are updated, and locked schedules are skipped, so this function must be called
+// within a transaction, and concurrent calls do not claim the same schedules.
+// returns the claimed schedules, a BadParameterError if the `limit` is invalid, or an InternalError if something wrong
+// happened while querying or updating the database
+func (r *GormScheduleRepository) ClaimDue(ctx context.Context, now time.Time, limit int) ([]Schedule, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateSchedule", "claim_due"}, time.Now())
+	if limit <= 0 {
+		return nil, errors.NewBadParameterError("limit", limit)
+	}
+	schedules := []Schedule{}
+	err := r.db.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+		Where("next_run_at <= ?", now).
+		Order("next_run_at, created_at").
+		Limit(limit).
+		Find(&schedules).Error
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	for i, s := range schedules {
+		var next *time.Time
+		if s.CronExpression != nil {
+			// the expression was validated when the schedule was created
+			if cron, err := ParseCron(*s.CronExpression); err == nil {
+				if n := cron.Next(now); !n.IsZero() {
+					next = &n
+				}
+			}
+		}
+		err := r.db.Model(&Schedule{}).Where("schedule_id = ?", s.ID).Updates(map[string]interface{}{
+			"next_run_at":     next,
+			"last_run_at":     now,
+			"last_run_status": nil,
+		}).Error
+		if err != nil {
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		runAt := now
+		schedules[i].NextRunAt = next
+		schedules[i].LastRunAt = &runAt
+		schedules[i].LastRunStatus = nil
+	}
+	return schedules, nil
+}
+
+// RecordRun stores the status of the response of the tenant service when the update of the claimed schedule with the
+// given ID was started (or 0 if no response was received)
+// returns NotFoundError or InternalError
+func (r *GormScheduleRepository) RecordRun(ctx context.Context, id uuid.UUID, status int) error {
+	defer goa.MeasureSince([]string{"goa", "db", "tenantUpdateSchedule", "record_run"}, time.Now())
+	result := r.db.Model(&Schedule{}).Where("schedule_id = ?", id).Update("last_run_status", status)
+	if result.Error != nil {
+		return errors.NewInternalError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("tenant update schedule", id.String())
+	}
+	return nil
+}
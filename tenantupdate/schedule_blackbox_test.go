@@ -0,0 +1,336 @@
+package tenantupdate_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/tenantupdate"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ScheduleRepositoryBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	repo tenantupdate.ScheduleRepository
+}
+
+func TestScheduleRepository(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &ScheduleRepositoryBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *ScheduleRepositoryBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.repo = tenantupdate.NewScheduleRepository(s.DB)
+}
+
+// newOneOffSchedule returns a schedule of an update which should be started in the given delay
+func newOneOffSchedule(delay time.Duration) *tenantupdate.Schedule {
+	envType := "jenkins"
+	runAt := time.Now().Add(delay)
+	return &tenantupdate.Schedule{
+		EnvType:   &envType,
+		NextRunAt: &runAt,
+		CreatedBy: fmt.Sprintf("user-%s", uuid.NewV4()),
+	}
+}
+
+// newRecurringSchedule returns a schedule of an update which should be started according to the given cron expression
+func newRecurringSchedule(cron string) *tenantupdate.Schedule {
+	return &tenantupdate.Schedule{
+		CronExpression: &cron,
+		CreatedBy:      fmt.Sprintf("user-%s", uuid.NewV4()),
+	}
+}
+
+func (s *ScheduleRepositoryBlackboxTestSuite) TestCreate() {
+
+	s.T().Run("one-off", func(t *testing.T) {
+		// given
+		schedule := newOneOffSchedule(time.Hour)
+		// when
+		err := s.repo.Create(context.Background(), schedule)
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.Load(context.Background(), schedule.ID)
+		require.NoError(t, err)
+		assert.Equal(t, schedule.CreatedBy, result.CreatedBy)
+		require.NotNil(t, result.EnvType)
+		assert.Equal(t, "jenkins", *result.EnvType)
+		assert.Nil(t, result.CronExpression)
+		require.NotNil(t, result.NextRunAt)
+		assert.WithinDuration(t, *schedule.NextRunAt, *result.NextRunAt, time.Millisecond)
+		assert.True(t, result.Active())
+		assert.False(t, result.CreatedAt.IsZero())
+	})
+
+	s.T().Run("recurring", func(t *testing.T) {
+		// given
+		schedule := newRecurringSchedule("0 2 * * *")
+		// when
+		err := s.repo.Create(context.Background(), schedule)
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.Load(context.Background(), schedule.ID)
+		require.NoError(t, err)
+		require.NotNil(t, result.CronExpression)
+		assert.Equal(t, "0 2 * * *", *result.CronExpression)
+		require.NotNil(t, result.NextRunAt)
+		assert.True(t, result.NextRunAt.After(time.Now()))
+		assert.Equal(t, 2, result.NextRunAt.UTC().Hour())
+		assert.Equal(t, 0, result.NextRunAt.UTC().Minute())
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("missing initiator", func(t *testing.T) {
+			// given
+			schedule := newOneOffSchedule(time.Hour)
+			schedule.CreatedBy = ""
+			// when
+			err := s.repo.Create(context.Background(), schedule)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("past time", func(t *testing.T) {
+			// given
+			schedule := newOneOffSchedule(-time.Hour)
+			// when
+			err := s.repo.Create(context.Background(), schedule)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("missing time and cron expression", func(t *testing.T) {
+			// given
+			schedule := newOneOffSchedule(time.Hour)
+			schedule.NextRunAt = nil
+			// when
+			err := s.repo.Create(context.Background(), schedule)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("invalid cron expression", func(t *testing.T) {
+			// when
+			err := s.repo.Create(context.Background(), newRecurringSchedule("0 2 * *"))
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("cron expression never matching", func(t *testing.T) {
+			// when
+			err := s.repo.Create(context.Background(), newRecurringSchedule("0 0 30 2 *"))
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+	})
+}
+
+func (s *ScheduleRepositoryBlackboxTestSuite) TestLoadUnknown() {
+	// when
+	_, err := s.repo.Load(context.Background(), uuid.NewV4())
+	// then
+	require.Error(s.T(), err)
+	assert.IsType(s.T(), errors.NotFoundError{}, err)
+}
+
+func (s *ScheduleRepositoryBlackboxTestSuite) TestList() {
+	// given
+	active := newOneOffSchedule(time.Hour)
+	err := s.repo.Create(context.Background(), active)
+	require.NoError(s.T(), err)
+	cancelled := newOneOffSchedule(time.Hour)
+	err = s.repo.Create(context.Background(), cancelled)
+	require.NoError(s.T(), err)
+	_, err = s.repo.Cancel(context.Background(), cancelled.ID, "admin")
+	require.NoError(s.T(), err)
+
+	s.T().Run("active only", func(t *testing.T) {
+		// when
+		result, err := s.repo.List(context.Background(), true)
+		// then
+		require.NoError(t, err)
+		ids := scheduleIDs(result)
+		assert.Contains(t, ids, active.ID)
+		assert.NotContains(t, ids, cancelled.ID)
+	})
+
+	s.T().Run("all", func(t *testing.T) {
+		// when
+		result, err := s.repo.List(context.Background(), false)
+		// then
+		require.NoError(t, err)
+		ids := scheduleIDs(result)
+		assert.Contains(t, ids, active.ID)
+		assert.Contains(t, ids, cancelled.ID)
+	})
+}
+
+func scheduleIDs(schedules []tenantupdate.Schedule) []uuid.UUID {
+	ids := make([]uuid.UUID, len(schedules))
+	for i, s := range schedules {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+func (s *ScheduleRepositoryBlackboxTestSuite) TestCancel() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		schedule := newOneOffSchedule(time.Hour)
+		err := s.repo.Create(context.Background(), schedule)
+		require.NoError(t, err)
+		// when
+		result, err := s.repo.Cancel(context.Background(), schedule.ID, "admin")
+		// then
+		require.NoError(t, err)
+		assert.False(t, result.Active())
+		require.NotNil(t, result.CancelledBy)
+		assert.Equal(t, "admin", *result.CancelledBy)
+		assert.NotNil(t, result.CancelledAt)
+	})
+
+	s.T().Run("already cancelled", func(t *testing.T) {
+		// given
+		schedule := newOneOffSchedule(time.Hour)
+		err := s.repo.Create(context.Background(), schedule)
+		require.NoError(t, err)
+		_, err = s.repo.Cancel(context.Background(), schedule.ID, "admin")
+		require.NoError(t, err)
+		// when
+		_, err = s.repo.Cancel(context.Background(), schedule.ID, "admin")
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.DataConflictError{}, err)
+	})
+
+	s.T().Run("unknown", func(t *testing.T) {
+		// when
+		_, err := s.repo.Cancel(context.Background(), uuid.NewV4(), "admin")
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+}
+
+func (s *ScheduleRepositoryBlackboxTestSuite) TestClaimDue() {
+
+	// claimDue claims the schedules which are due at the given time within a transaction, and returns them by ID
+	claimDue := func(t *testing.T, now time.Time) map[uuid.UUID]tenantupdate.Schedule {
+		tx := s.DB.Begin()
+		require.NoError(t, tx.Error)
+		schedules, err := tenantupdate.NewScheduleRepository(tx).ClaimDue(context.Background(), now, 100)
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit().Error)
+		claimed := map[uuid.UUID]tenantupdate.Schedule{}
+		for _, schedule := range schedules {
+			claimed[schedule.ID] = schedule
+		}
+		return claimed
+	}
+
+	s.T().Run("one-off", func(t *testing.T) {
+		// given
+		schedule := newOneOffSchedule(time.Minute)
+		err := s.repo.Create(context.Background(), schedule)
+		require.NoError(t, err)
+		now := time.Now().Add(2 * time.Minute)
+		// when
+		claimed := claimDue(t, now)
+		// then
+		require.Contains(t, claimed, schedule.ID)
+		assert.False(t, claimed[schedule.ID].Active())
+		result, err := s.repo.Load(context.Background(), schedule.ID)
+		require.NoError(t, err)
+		assert.False(t, result.Active())
+		require.NotNil(t, result.LastRunAt)
+		assert.WithinDuration(t, now, *result.LastRunAt, time.Millisecond)
+		// the outcome of the run is not known yet
+		assert.Nil(t, result.LastRunStatus)
+		// and the schedule is not claimed again
+		claimed = claimDue(t, now.Add(time.Minute))
+		assert.NotContains(t, claimed, schedule.ID)
+	})
+
+	s.T().Run("recurring", func(t *testing.T) {
+		// given
+		schedule := newRecurringSchedule("*/10 * * * *")
+		err := s.repo.Create(context.Background(), schedule)
+		require.NoError(t, err)
+		now := schedule.NextRunAt.Add(time.Minute)
+		// when
+		claimed := claimDue(t, now)
+		// then
+		assert.Contains(t, claimed, schedule.ID)
+		result, err := s.repo.Load(context.Background(), schedule.ID)
+		require.NoError(t, err)
+		require.NotNil(t, result.NextRunAt)
+		assert.WithinDuration(t, schedule.NextRunAt.Add(10*time.Minute), *result.NextRunAt, time.Millisecond)
+	})
+
+	s.T().Run("not due yet", func(t *testing.T) {
+		// given
+		schedule := newOneOffSchedule(time.Hour)
+		err := s.repo.Create(context.Background(), schedule)
+		require.NoError(t, err)
+		// when
+		claimed := claimDue(t, time.Now())
+		// then
+		assert.NotContains(t, claimed, schedule.ID)
+	})
+
+	s.T().Run("invalid limit", func(t *testing.T) {
+		// when
+		_, err := s.repo.ClaimDue(context.Background(), time.Now(), 0)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, err)
+	})
+}
+
+func (s *ScheduleRepositoryBlackboxTestSuite) TestRecordRun() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		schedule := newOneOffSchedule(time.Minute)
+		err := s.repo.Create(context.Background(), schedule)
+		require.NoError(t, err)
+		_, err = s.repo.ClaimDue(context.Background(), time.Now().Add(2*time.Minute), 100)
+		require.NoError(t, err)
+		// when
+		err = s.repo.RecordRun(context.Background(), schedule.ID, http.StatusConflict)
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.Load(context.Background(), schedule.ID)
+		require.NoError(t, err)
+		require.NotNil(t, result.LastRunStatus)
+		assert.Equal(t, http.StatusConflict, *result.LastRunStatus)
+	})
+
+	s.T().Run("unknown", func(t *testing.T) {
+		// when
+		err := s.repo.RecordRun(context.Background(), uuid.NewV4(), http.StatusAccepted)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+}
@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	errs "github.com/pkg/errors"
+)
+
+// internalNetworks the networks of the private, shared and unique local addresses, to which the events are not delivered
+var internalNetworks = []*net.IPNet{
+	mustParseCIDR("10.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("172.16.0.0/12"),
+	mustParseCIDR("192.168.0.0/16"),
+	mustParseCIDR("fc00::/7"),
+}
+
+func mustParseCIDR(value string) *net.IPNet {
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// isInternalAddress returns true if the given IP address is a loopback, link-local, unspecified or private address
+func isInternalAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewHTTPClient returns an HTTP client with the given timeout, which refuses to connect to the webhooks whose host
+// resolves to an internal address (see `isInternalAddress`). The host is resolved once, and the connection is made to
+// the verified address, so that a second resolution cannot return another address. No proxy is used.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				host, port, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+				if err != nil {
+					return nil, err
+				}
+				if len(ips) == 0 {
+					return nil, errs.Errorf("no address found for the host of the webhook '%s'", host)
+				}
+				for _, ip := range ips {
+					if isInternalAddress(ip.IP) {
+						return nil, errs.Errorf("the host of the webhook '%s' resolves to an internal address: %s", host, ip.IP)
+					}
+				}
+				return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+			},
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
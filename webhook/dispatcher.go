@@ -0,0 +1,188 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/fabric8-services/admin-console/httputil"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	errs "github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// SignatureHeader the header which holds the HMAC-SHA256 signature of the payload, computed with the secret
+	// of the webhook, as `sha256=<hex digest>`
+	SignatureHeader = "X-Admin-Console-Signature"
+	// EventHeader the header which holds the name of the type of the delivered event
+	EventHeader = "X-Admin-Console-Event"
+	// DeliveryHeader the header which holds the ID of the delivery, which is the same when a delivery is retried
+	DeliveryHeader = "X-Admin-Console-Delivery"
+
+	// minBackoff the delay before the first retry of a failed delivery, which doubles after each attempt
+	minBackoff = 30 * time.Second
+	// maxBackoff the maximum delay between 2 attempts to deliver an event
+	maxBackoff = time.Hour
+	// maxRecordedError the maximum length of the error recorded for a failed attempt
+	maxRecordedError = 1024
+)
+
+var (
+	deliveriesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "admin_console_webhook_deliveries_total",
+		Help: "Number of attempts to deliver an audit log event to a webhook.",
+	}, []string{"status"})
+)
+
+func init() {
+	prometheus.MustRegister(deliveriesCounter)
+}
+
+// Sign returns the signature of the given payload with the given secret, as sent in the `SignatureHeader`
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before the next attempt to deliver an event, given the number of failed attempts so far
+func Backoff(attempts int) time.Duration {
+	delay := minBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// Configuration the configuration of the dispatcher
+type Configuration interface {
+	GetWebhookDispatchInterval() time.Duration
+	GetWebhookBatchSize() int
+	GetWebhookMaxAttempts() int
+	GetWebhookTimeout() time.Duration
+}
+
+// Dispatcher delivers the pending events to the webhooks, by batches. A failed delivery is retried with an exponential
+// backoff, until the maximum number of attempts is reached: the delivery is then dead (ie, dead-lettered) and it is
+// only kept in the delivery history of the webhook.
+type Dispatcher struct {
+	config Configuration
+	repo   Repository
+	client *http.Client
+}
+
+// NewDispatcher returns a new dispatcher which delivers the events with the given HTTP client
+func NewDispatcher(config Configuration, repo Repository, client *http.Client) *Dispatcher {
+	return &Dispatcher{
+		config: config,
+		repo:   repo,
+		client: client,
+	}
+}
+
+// Start runs the dispatcher immediately and then at the configured interval, until the given context is done
+func (d *Dispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.config.GetWebhookDispatchInterval())
+	defer ticker.Stop()
+	for {
+		// errors are logged and the dispatcher will try again during the next run
+		d.Run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run attempts to deliver the pending events whose next attempt is due, by batches
+// returns the number of attempted deliveries
+func (d *Dispatcher) Run(ctx context.Context) (int, error) {
+	batchSize := d.config.GetWebhookBatchSize()
+	// the deliveries of a batch are leased for as long as it may take to send them all
+	lease := time.Duration(batchSize) * d.config.GetWebhookTimeout()
+	total := 0
+	for {
+		count, err := d.repo.DeliverDue(ctx, batchSize, lease, func(subscription Subscription, delivery *Delivery) {
+			d.deliver(ctx, subscription, delivery)
+		})
+		total += count
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":       err,
+				"attempted": total,
+			}, "failed to deliver the events to the webhooks")
+			return total, err
+		}
+		if count < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// deliver sends the payload of the given delivery to the webhook, and updates the delivery according to the response
+func (d *Dispatcher) deliver(ctx context.Context, subscription Subscription, delivery *Delivery) {
+	delivery.Attempts++
+	statusCode, err := d.send(ctx, subscription, delivery)
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+	if err == nil {
+		now := time.Now()
+		delivery.Status = DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		deliveriesCounter.WithLabelValues(string(DeliveryDelivered)).Inc()
+		return
+	}
+	delivery.LastError = err.Error()
+	if len(delivery.LastError) > maxRecordedError {
+		delivery.LastError = delivery.LastError[:maxRecordedError]
+	}
+	if delivery.Attempts >= d.config.GetWebhookMaxAttempts() {
+		delivery.Status = DeliveryDead
+		log.Warn(ctx, map[string]interface{}{
+			"err":         err,
+			"webhook_id":  subscription.ID,
+			"delivery_id": delivery.ID,
+			"attempts":    delivery.Attempts,
+			"event_type":  delivery.EventType,
+		}, "giving up delivering the event to the webhook")
+		deliveriesCounter.WithLabelValues(string(DeliveryDead)).Inc()
+		return
+	}
+	delivery.NextAttemptAt = time.Now().Add(Backoff(delivery.Attempts))
+	deliveriesCounter.WithLabelValues("failed").Inc()
+}
+
+// send posts the signed payload to the webhook
+// returns the status of the response (if any), and an error if the request failed or if the response status is not 2xx
+func (d *Dispatcher) send(ctx context.Context, subscription Subscription, delivery *Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, errs.Wrapf(err, "unable to deliver the event to '%s'", subscription.URL)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, delivery.Payload))
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, errs.Wrapf(err, "unable to deliver the event to '%s'", subscription.URL)
+	}
+	defer resp.Body.Close()
+	httputil.DrainBody(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errs.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,174 @@
+package webhook_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/webhook"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	gock "gopkg.in/h2non/gock.v1"
+)
+
+type DispatcherBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	repo webhook.Repository
+}
+
+func TestDispatcher(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &DispatcherBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *DispatcherBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.repo = webhook.NewRepository(s.DB)
+}
+
+type dispatcherConfig struct{}
+
+func (c dispatcherConfig) GetWebhookDispatchInterval() time.Duration {
+	return time.Second
+}
+
+func (c dispatcherConfig) GetWebhookBatchSize() int {
+	return 100
+}
+
+func (c dispatcherConfig) GetWebhookMaxAttempts() int {
+	return 2
+}
+
+func (c dispatcherConfig) GetWebhookTimeout() time.Duration {
+	return time.Second
+}
+
+func TestSign(t *testing.T) {
+	// see https://en.wikipedia.org/wiki/HMAC#Examples
+	assert.Equal(t, "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		webhook.Sign("key", []byte("The quick brown fox jumps over the lazy dog")))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, webhook.Backoff(1))
+	assert.Equal(t, time.Minute, webhook.Backoff(2))
+	assert.Equal(t, 2*time.Minute, webhook.Backoff(3))
+	assert.Equal(t, time.Hour, webhook.Backoff(10))
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	// given a webhook which listens on the loopback interface
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client := webhook.NewHTTPClient(time.Second)
+	// when
+	_, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+	// then
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "resolves to an internal address")
+}
+
+// enqueue registers a webhook for the `start_tenant_update` events and enqueues such an event
+func (s *DispatcherBlackboxTestSuite) enqueue(t *testing.T) *webhook.Subscription {
+	subscription := newSubscription(auditlog.StartTenantUpdateEvent)
+	err := s.repo.Create(context.Background(), subscription)
+	require.NoError(t, err)
+	payload := []byte(`{"id":"` + uuid.NewV4().String() + `"}`)
+	_, err = s.repo.Enqueue(context.Background(), uuid.NewV4(), auditlog.StartTenantUpdateEvent, payload)
+	require.NoError(t, err)
+	return subscription
+}
+
+func (s *DispatcherBlackboxTestSuite) delivery(t *testing.T, subscription *webhook.Subscription) webhook.Delivery {
+	deliveries, err := s.repo.ListDeliveries(context.Background(), subscription.ID, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	return deliveries[0]
+}
+
+func (s *DispatcherBlackboxTestSuite) TestRun() {
+	defer gock.OffAll()
+	dispatcher := webhook.NewDispatcher(dispatcherConfig{}, s.repo, http.DefaultClient)
+
+	s.T().Run("delivered", func(t *testing.T) {
+		// given
+		subscription := s.enqueue(t)
+		// the payload is signed as it is stored (and normalized) in the database
+		payload := s.delivery(t, subscription).Payload
+		gock.New(subscription.URL).
+			Post("").
+			MatchHeader(webhook.SignatureHeader, webhook.Sign(subscription.Secret, payload)).
+			MatchHeader(webhook.EventHeader, auditlog.StartTenantUpdateEvent).
+			Reply(http.StatusNoContent)
+		// when
+		_, err := dispatcher.Run(context.Background())
+		// then
+		require.NoError(t, err)
+		d := s.delivery(t, subscription)
+		assert.Equal(t, webhook.DeliveryDelivered, d.Status)
+		assert.Equal(t, 1, d.Attempts)
+		require.NotNil(t, d.LastStatusCode)
+		assert.Equal(t, http.StatusNoContent, *d.LastStatusCode)
+		assert.NotNil(t, d.DeliveredAt)
+	})
+
+	s.T().Run("retried then dead", func(t *testing.T) {
+		// given
+		subscription := s.enqueue(t)
+		gock.New(subscription.URL).
+			Post("").
+			Times(2).
+			Reply(http.StatusInternalServerError)
+		// when
+		_, err := dispatcher.Run(context.Background())
+		// then
+		require.NoError(t, err)
+		d := s.delivery(t, subscription)
+		assert.Equal(t, webhook.DeliveryPending, d.Status)
+		assert.Equal(t, 1, d.Attempts)
+		require.NotNil(t, d.LastStatusCode)
+		assert.Equal(t, http.StatusInternalServerError, *d.LastStatusCode)
+		assert.Contains(t, d.LastError, "500")
+		assert.True(t, d.NextAttemptAt.After(time.Now()))
+
+		t.Run("not retried before the next attempt", func(t *testing.T) {
+			// when
+			_, err := dispatcher.Run(context.Background())
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 1, s.delivery(t, subscription).Attempts)
+		})
+
+		t.Run("dead after the maximum number of attempts", func(t *testing.T) {
+			// given
+			err := s.DB.Model(&webhook.Delivery{}).Where("subscription_id = ?", subscription.ID).
+				Update("next_attempt_at", time.Now().Add(-time.Second)).Error
+			require.NoError(t, err)
+			// when
+			_, err = dispatcher.Run(context.Background())
+			// then
+			require.NoError(t, err)
+			d := s.delivery(t, subscription)
+			assert.Equal(t, webhook.DeliveryDead, d.Status)
+			assert.Equal(t, 2, d.Attempts)
+			dead := webhook.DeliveryDead
+			deliveries, err := s.repo.ListDeliveries(context.Background(), subscription.ID, &dead, 10)
+			require.NoError(t, err)
+			assert.Len(t, deliveries, 1)
+		})
+	})
+}
@@ -0,0 +1,3 @@
+// Package webhook contains the repository of the webhooks to which the audit log events are delivered,
+// along with the dispatcher which delivers the signed events and retries the failed deliveries.
+package webhook
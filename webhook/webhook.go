@@ -0,0 +1,390 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/admin-console/gormutil"
+	"github.com/fabric8-services/fabric8-common/closeable"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Subscription a webhook to which the audit log events are delivered
+type Subscription struct {
+	ID  uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:subscription_id"`
+	URL string
+	// Secret the secret with which the payloads are signed
+	Secret string
+	// EventTypes the names of the types of the events delivered to the webhook (all events are delivered if empty),
+	// stored in the `webhook_subscription_event_type` table
+	EventTypes []string `gorm:"-"`
+	CreatedBy  string
+	CreatedAt  time.Time
+}
+
+// DeliveryStatus the status of the delivery of an event to a webhook
+type DeliveryStatus string
+
+const (
+	// DeliveryPending the event has not been delivered yet, and will be (again)
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryInProgress the event is being delivered, and the delivery is leased until its next attempt
+	DeliveryInProgress DeliveryStatus = "delivering"
+	// DeliveryDelivered the event was delivered
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead the event could not be delivered after the maximum number of attempts, and will not be retried
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// Delivery the delivery of an event to a webhook
+type Delivery struct {
+	ID             uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:delivery_id"`
+	SubscriptionID uuid.UUID `sql:"type:uuid"`
+	// EventID the ID of the event, ie, the ID of the audit log record
+	EventID   uuid.UUID `sql:"type:uuid"`
+	EventType string
+	// Payload the JSON payload which is delivered
+	Payload       []byte `sql:"type:jsonb"`
+	Status        DeliveryStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	// LastStatusCode the status of the response to the last attempt, if any
+	LastStatusCode *int
+	// LastError the error which occurred during the last failed attempt, if any
+	LastError   string
+	DeliveredAt *time.Time
+	CreatedAt   time.Time
+}
+
+const (
+	subscriptionTableName          = "webhook_subscription"
+	subscriptionEventTypeTableName = "webhook_subscription_event_type"
+	deliveryTableName              = "webhook_delivery"
+)
+
+// TableName implements gorm.tabler
+func (s Subscription) TableName() string {
+	return subscriptionTableName
+}
+
+// TableName implements gorm.tabler
+func (d Delivery) TableName() string {
+	return deliveryTableName
+}
+
+// subscriptionEventType a type of the events delivered to a webhook
+type subscriptionEventType struct {
+	SubscriptionID uuid.UUID `sql:"type:uuid" gorm:"primary_key"`
+	EventTypeID    uuid.UUID `sql:"type:uuid" gorm:"primary_key"`
+}
+
+// TableName implements gorm.tabler
+func (t subscriptionEventType) TableName() string {
+	return subscriptionEventTypeTableName
+}
+
+// DeliverFunc delivers the given event to the given webhook, and updates the status, attempts, etc. of the delivery
+type DeliverFunc func(subscription Subscription, delivery *Delivery)
+
+// Repository provides functions to manage the webhooks and the deliveries of the events
+type Repository interface {
+	Create(ctx context.Context, subscription *Subscription) error
+	List(ctx context.Context) ([]Subscription, error)
+	Load(ctx context.Context, id uuid.UUID) (Subscription, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	Enqueue(ctx context.Context, eventID uuid.UUID, eventType string, payload []byte) (int, error)
+	DeliverDue(ctx context.Context, limit int, lease time.Duration, deliver DeliverFunc) (int, error)
+	ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, status *DeliveryStatus, limit int) ([]Delivery, error)
+}
+
+// NewRepository creates a GormRepository
+func NewRepository(db *gorm.DB) Repository {
+	return &GormRepository{
+		db: db,
+	}
+}
+
+// GormRepository implements Repository using gorm
+type GormRepository struct {
+	db *gorm.DB
+}
+
+// Create stores the given webhook along with the types of the events delivered to it, in a single transaction
+// returns BadParameterError if the URL or secret is missing, if the URL is not an HTTPS URL or if its host is an internal
+// address, or if an event type is unknown, or InternalError if something wrong happened
+func (r *GormRepository) Create(ctx context.Context, subscription *Subscription) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook", "create"}, time.Now())
+	if subscription == nil {
+		return errors.NewBadParameterErrorFromString("missing webhook to persist")
+	}
+	if err := validateURL(subscription.URL); err != nil {
+		return err
+	}
+	if subscription.Secret == "" {
+		return errors.NewBadParameterErrorFromString("missing secret of the webhook")
+	}
+	eventTypeIDs, err := r.eventTypeIDs(ctx, subscription.EventTypes)
+	if err != nil {
+		return err
+	}
+	if subscription.ID == uuid.Nil {
+		subscription.ID = uuid.NewV4()
+	}
+	err = gormutil.InTransaction(r.db, func(tx *gorm.DB) error {
+		if err := tx.Create(subscription).Error; err != nil {
+			return err
+		}
+		for _, id := range eventTypeIDs {
+			if err := tx.Create(&subscriptionEventType{SubscriptionID: subscription.ID, EventTypeID: id}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// validateURL checks that the given URL of a webhook is an HTTPS URL whose host is not an internal address, so that the
+// webhooks cannot be used to send requests to the services of the cluster. The host names are only resolved (and
+// checked again) when the events are delivered, see `NewHTTPClient`.
+func validateURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return errors.NewBadParameterErrorFromString(fmt.Sprintf("the URL of the webhook must be an HTTPS URL: '%s'", value))
+	}
+	if host := u.Hostname(); host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.NewBadParameterErrorFromString(fmt.Sprintf("the host of the webhook must not be an internal address: '%s'", value))
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && isInternalAddress(ip) {
+		return errors.NewBadParameterErrorFromString(fmt.Sprintf("the host of the webhook must not be an internal address: '%s'", value))
+	}
+	return nil
+}
+
+// eventTypeIDs returns the IDs of the event types with the given names
+// returns BadParameterError if an event type is unknown, or InternalError if something wrong happened
+func (r *GormRepository) eventTypeIDs(ctx context.Context, names []string) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(names))
+	for _, name := range names {
+		var id uuid.UUID
+		err := r.db.Raw("select event_type_id from event_type where name = ?", name).Row().Scan(&id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, errors.NewBadParameterError("event_types", name)
+			}
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// List returns all the webhooks, sorted by creation date
+// returns InternalError if something wrong happened
+func (r *GormRepository) List(ctx context.Context) ([]Subscription, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook", "list"}, time.Now())
+	subscriptions := []Subscription{}
+	if err := r.db.Order("created_at, subscription_id").Find(&subscriptions).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return r.loadEventTypes(ctx, subscriptions)
+}
+
+// Load returns the webhook with the given ID
+// returns NotFoundError or InternalError
+func (r *GormRepository) Load(ctx context.Context, id uuid.UUID) (Subscription, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook", "load"}, time.Now())
+	subscriptions := []Subscription{}
+	if err := r.db.Where("subscription_id = ?", id).Find(&subscriptions).Error; err != nil {
+		return Subscription{}, errors.NewInternalError(ctx, err)
+	}
+	if len(subscriptions) == 0 {
+		return Subscription{}, errors.NewNotFoundError("webhook", id.String())
+	}
+	subscriptions, err := r.loadEventTypes(ctx, subscriptions)
+	if err != nil {
+		return Subscription{}, err
+	}
+	return subscriptions[0], nil
+}
+
+// Delete deletes the webhook with the given ID, along with its deliveries
+// returns NotFoundError or InternalError
+func (r *GormRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook", "delete"}, time.Now())
+	result := r.db.Where("subscription_id = ?", id).Delete(&Subscription{})
+	if result.Error != nil {
+		return errors.NewInternalError(ctx, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("webhook", id.String())
+	}
+	return nil
+}
+
+// Enqueue schedules the delivery of the given event to the webhooks which subscribed to its type.
+// Enqueuing an event which was already enqueued has no effect.
+// returns the number of scheduled deliveries, or InternalError if something wrong happened
+func (r *GormRepository) Enqueue(ctx context.Context, eventID uuid.UUID, eventType string, payload []byte) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook", "enqueue"}, time.Now())
+	result := r.db.Exec(fmt.Sprintf(`insert into %[1]s (subscription_id, event_id, event_type, payload, status)
+		select s.subscription_id, ?::uuid, ?, ?::jsonb, ? from %[2]s s
+		where not exists (select 1 from %[3]s t where t.subscription_id = s.subscription_id)
+		or exists (select 1 from %[3]s t join event_type e on e.event_type_id = t.event_type_id
+			where t.subscription_id = s.subscription_id and e.name = ?)
+		on conflict (subscription_id, event_id) do nothing`, deliveryTableName, subscriptionTableName, subscriptionEventTypeTableName),
+		eventID, eventType, string(payload), DeliveryPending, eventType)
+	if result.Error != nil {
+		return 0, errors.NewInternalError(ctx, result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
+
+// DeliverDue passes at most `limit` pending deliveries whose next attempt is due to the given `deliver` function, oldest first,
+// and then stores their updated status. The deliveries are claimed (ie, leased for the given duration) before they are passed
+// to the `deliver` function, so concurrent calls do not deliver the same events, and a delivery whose lease expired
+// (eg: because the dispatcher stopped while delivering it) is attempted again. No transaction is open during the deliveries.
+// returns the number of attempted deliveries, a BadParameterError if the `limit` is invalid (negative), or an InternalError
+// if something wrong happened while querying or updating the database
+func (r *GormRepository) DeliverDue(ctx context.Context, limit int, lease time.Duration, deliver DeliverFunc) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook", "deliver_due"}, time.Now())
+	if limit <= 0 {
+		return 0, errors.NewBadParameterError("limit", limit)
+	}
+	deliveries, err := r.claimDue(ctx, limit, lease)
+	if err != nil {
+		return 0, err
+	}
+	subscriptions := map[uuid.UUID]Subscription{}
+	for i := range deliveries {
+		d := &deliveries[i]
+		s, found := subscriptions[d.SubscriptionID]
+		if !found {
+			if err := r.db.Where("subscription_id = ?", d.SubscriptionID).First(&s).Error; err != nil {
+				return i, errors.NewInternalError(ctx, err)
+			}
+			subscriptions[d.SubscriptionID] = s
+		}
+		deliver(s, d)
+		if err := r.saveAttempt(ctx, d); err != nil {
+			return i + 1, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// claimDue leases at most `limit` pending deliveries whose next attempt is due (or whose lease expired), oldest first,
+// until the end of the given duration. The locked deliveries are skipped, so concurrent calls do not claim the same deliveries.
+// returns the claimed deliveries, which are still pending until their updated status is stored
+func (r *GormRepository) claimDue(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	deliveries := []Delivery{}
+	err := gormutil.InTransaction(r.db, func(tx *gorm.DB) error {
+		err := tx.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+			Where("status in (?, ?) and next_attempt_at <= ?", DeliveryPending, DeliveryInProgress, time.Now()).
+			Order("next_attempt_at, created_at").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		ids := make([]uuid.UUID, len(deliveries))
+		for i, d := range deliveries {
+			ids[i] = d.ID
+		}
+		return tx.Model(&Delivery{}).Where("delivery_id in (?)", ids).Updates(map[string]interface{}{
+			"status":          DeliveryInProgress,
+			"next_attempt_at": time.Now().Add(lease),
+		}).Error
+	})
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	for i := range deliveries {
+		deliveries[i].Status = DeliveryPending
+	}
+	return deliveries, nil
+}
+
+// saveAttempt stores the status, attempts, etc. of the given delivery after an attempt, which ends its lease
+func (r *GormRepository) saveAttempt(ctx context.Context, d *Delivery) error {
+	err := gormutil.InTransaction(r.db, func(tx *gorm.DB) error {
+		return tx.Model(d).Updates(map[string]interface{}{
+			"status":           d.Status,
+			"attempts":         d.Attempts,
+			"next_attempt_at":  d.NextAttemptAt,
+			"last_status_code": d.LastStatusCode,
+			"last_error":       d.LastError,
+			"delivered_at":     d.DeliveredAt,
+		}).Error
+	})
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the last `limit` deliveries to the given webhook, most recent first, optionally with the given status
+// returns a BadParameterError if the `limit` is invalid (negative), or an InternalError if something wrong happened
+func (r *GormRepository) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, status *DeliveryStatus, limit int) ([]Delivery, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook", "list_deliveries"}, time.Now())
+	if limit <= 0 {
+		return nil, errors.NewBadParameterError("limit", limit)
+	}
+	db := r.db.Where("subscription_id = ?", subscriptionID)
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	deliveries := []Delivery{}
+	if err := db.Order("created_at desc, delivery_id").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return deliveries, nil
+}
+
+// loadEventTypes loads the names of the event types of the given webhooks
+func (r *GormRepository) loadEventTypes(ctx context.Context, subscriptions []Subscription) ([]Subscription, error) {
+	if len(subscriptions) == 0 {
+		return subscriptions, nil
+	}
+	ids := make([]uuid.UUID, len(subscriptions))
+	for i, s := range subscriptions {
+		ids[i] = s.ID
+	}
+	rows, err := r.db.Raw(fmt.Sprintf(`select t.subscription_id, e.name from %s t join event_type e on e.event_type_id = t.event_type_id
+		where t.subscription_id in (?) order by e.name`, subscriptionEventTypeTableName), ids).Rows()
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	defer closeable.Close(ctx, rows)
+	bySubscription := map[uuid.UUID][]string{}
+	for rows.Next() {
+		var id uuid.UUID
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		bySubscription[id] = append(bySubscription[id], name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	for i := range subscriptions {
+		subscriptions[i].EventTypes = bySubscription[subscriptions[i].ID]
+		if subscriptions[i].EventTypes == nil {
+			subscriptions[i].EventTypes = []string{}
+		}
+	}
+	return subscriptions, nil
+}
@@ -0,0 +1,293 @@
+package webhook_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/admin-console/configuration"
+	"github.com/fabric8-services/admin-console/webhook"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type WebhookRepositoryBlackboxTestSuite struct {
+	testsuite.DBTestSuite
+	repo webhook.Repository
+}
+
+func TestWebhookRepository(t *testing.T) {
+	resource.Require(t, resource.Database)
+	config := configuration.New()
+	suite.Run(t, &WebhookRepositoryBlackboxTestSuite{DBTestSuite: testsuite.NewDBTestSuite(config)})
+}
+
+func (s *WebhookRepositoryBlackboxTestSuite) SetupSuite() {
+	s.DBTestSuite.SetupSuite()
+	s.repo = webhook.NewRepository(s.DB)
+}
+
+func newSubscription(eventTypes ...string) *webhook.Subscription {
+	return &webhook.Subscription{
+		URL:        fmt.Sprintf("https://hooks/%s", uuid.NewV4()),
+		Secret:     "a-very-secret-secret",
+		EventTypes: eventTypes,
+		CreatedBy:  "admin",
+	}
+}
+
+func (s *WebhookRepositoryBlackboxTestSuite) TestCreate() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		subscription := newSubscription(auditlog.StartTenantUpdateEvent, auditlog.StopTenantUpdateEvent)
+		// when
+		err := s.repo.Create(context.Background(), subscription)
+		// then
+		require.NoError(t, err)
+		result, err := s.repo.Load(context.Background(), subscription.ID)
+		require.NoError(t, err)
+		assert.Equal(t, subscription.URL, result.URL)
+		assert.Equal(t, []string{auditlog.StartTenantUpdateEvent, auditlog.StopTenantUpdateEvent}, result.EventTypes)
+		assert.Equal(t, "admin", result.CreatedBy)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("unknown event type", func(t *testing.T) {
+			// when
+			err := s.repo.Create(context.Background(), newSubscription("do_anything"))
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("not an HTTPS URL", func(t *testing.T) {
+			// given
+			subscription := newSubscription()
+			subscription.URL = "http://hooks/foo"
+			// when
+			err := s.repo.Create(context.Background(), subscription)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+
+		t.Run("internal address", func(t *testing.T) {
+			for _, u := range []string{"https://localhost:8089/api", "https://127.0.0.1/hook", "https://10.1.2.3/hook", "https://169.254.169.254/latest/meta-data", "https://[::1]/hook"} {
+				t.Run(u, func(t *testing.T) {
+					// given
+					subscription := newSubscription()
+					subscription.URL = u
+					// when
+					err := s.repo.Create(context.Background(), subscription)
+					// then
+					require.Error(t, err)
+					assert.IsType(t, errors.BadParameterError{}, err)
+				})
+			}
+		})
+
+		t.Run("missing secret", func(t *testing.T) {
+			// given
+			subscription := newSubscription()
+			subscription.Secret = ""
+			// when
+			err := s.repo.Create(context.Background(), subscription)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+		})
+	})
+}
+
+func (s *WebhookRepositoryBlackboxTestSuite) TestDelete() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		subscription := newSubscription()
+		err := s.repo.Create(context.Background(), subscription)
+		require.NoError(t, err)
+		// when
+		err = s.repo.Delete(context.Background(), subscription.ID)
+		// then
+		require.NoError(t, err)
+		_, err = s.repo.Load(context.Background(), subscription.ID)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// when
+		err := s.repo.Delete(context.Background(), uuid.NewV4())
+		// then
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+}
+
+func (s *WebhookRepositoryBlackboxTestSuite) TestEnqueue() {
+	// given
+	all := newSubscription()
+	err := s.repo.Create(context.Background(), all)
+	require.NoError(s.T(), err)
+	starts := newSubscription(auditlog.StartTenantUpdateEvent)
+	err = s.repo.Create(context.Background(), starts)
+	require.NoError(s.T(), err)
+
+	s.T().Run("matching event type", func(t *testing.T) {
+		// given
+		eventID := uuid.NewV4()
+		// when
+		count, err := s.repo.Enqueue(context.Background(), eventID, auditlog.StartTenantUpdateEvent, []byte(`{"foo":"bar"}`))
+		// then
+		require.NoError(t, err)
+		assert.True(t, count >= 2) // other webhooks may have been registered by other tests
+		for _, subscription := range []*webhook.Subscription{all, starts} {
+			deliveries, err := s.repo.ListDeliveries(context.Background(), subscription.ID, nil, 10)
+			require.NoError(t, err)
+			require.Len(t, deliveries, 1)
+			assert.Equal(t, eventID, deliveries[0].EventID)
+			assert.Equal(t, webhook.DeliveryPending, deliveries[0].Status)
+			assert.JSONEq(t, `{"foo":"bar"}`, string(deliveries[0].Payload))
+		}
+
+		t.Run("enqueued again", func(t *testing.T) {
+			// when
+			_, err := s.repo.Enqueue(context.Background(), eventID, auditlog.StartTenantUpdateEvent, []byte(`{"foo":"bar"}`))
+			// then
+			require.NoError(t, err)
+			deliveries, err := s.repo.ListDeliveries(context.Background(), starts.ID, nil, 10)
+			require.NoError(t, err)
+			assert.Len(t, deliveries, 1)
+		})
+	})
+
+	s.T().Run("other event type", func(t *testing.T) {
+		// when
+		_, err := s.repo.Enqueue(context.Background(), uuid.NewV4(), auditlog.StopTenantUpdateEvent, []byte(`{}`))
+		// then
+		require.NoError(t, err)
+		deliveries, err := s.repo.ListDeliveries(context.Background(), all.ID, nil, 10)
+		require.NoError(t, err)
+		assert.Len(t, deliveries, 2)
+		deliveries, err = s.repo.ListDeliveries(context.Background(), starts.ID, nil, 10)
+		require.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+	})
+
+	// the webhooks are deleted, so that their deliveries are not attempted by the dispatcher tests
+	for _, subscription := range []*webhook.Subscription{all, starts} {
+		err := s.repo.Delete(context.Background(), subscription.ID)
+		require.NoError(s.T(), err)
+	}
+}
+
+func (s *WebhookRepositoryBlackboxTestSuite) TestDeliverDue() {
+	// given
+	subscription := newSubscription(auditlog.StartTenantUpdateEvent)
+	err := s.repo.Create(context.Background(), subscription)
+	require.NoError(s.T(), err)
+	// enqueue enqueues an event for the webhook and returns the ID of the event
+	enqueue := func(t *testing.T) uuid.UUID {
+		eventID := uuid.NewV4()
+		_, err := s.repo.Enqueue(context.Background(), eventID, auditlog.StartTenantUpdateEvent, []byte(`{}`))
+		require.NoError(t, err)
+		return eventID
+	}
+	// delivery returns the delivery of the given event to the webhook
+	delivery := func(t *testing.T, eventID uuid.UUID) webhook.Delivery {
+		deliveries, err := s.repo.ListDeliveries(context.Background(), subscription.ID, nil, 100)
+		require.NoError(t, err)
+		for _, d := range deliveries {
+			if d.EventID == eventID {
+				return d
+			}
+		}
+		require.Fail(t, "delivery not found", "event ID: %s", eventID)
+		return webhook.Delivery{}
+	}
+
+	s.T().Run("claimed while delivered", func(t *testing.T) {
+		// given
+		eventID := enqueue(t)
+		var claimed webhook.Delivery
+		var redelivered bool
+		// when
+		_, err := s.repo.DeliverDue(context.Background(), 100, time.Minute, func(_ webhook.Subscription, d *webhook.Delivery) {
+			if d.EventID != eventID {
+				return // other deliveries are left pending
+			}
+			claimed = delivery(t, eventID)
+			// a concurrent call does not deliver the same event
+			_, err := s.repo.DeliverDue(context.Background(), 100, time.Minute, func(_ webhook.Subscription, other *webhook.Delivery) {
+				redelivered = redelivered || other.EventID == eventID
+			})
+			require.NoError(t, err)
+			now := time.Now()
+			d.Attempts++
+			d.Status = webhook.DeliveryDelivered
+			d.DeliveredAt = &now
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, webhook.DeliveryInProgress, claimed.Status)
+		assert.True(t, claimed.NextAttemptAt.After(time.Now()))
+		assert.False(t, redelivered)
+		d := delivery(t, eventID)
+		assert.Equal(t, webhook.DeliveryDelivered, d.Status)
+		assert.Equal(t, 1, d.Attempts)
+	})
+
+	s.T().Run("lease expired", func(t *testing.T) {
+		// given a delivery whose lease expired
+		eventID := enqueue(t)
+		err := s.DB.Model(&webhook.Delivery{}).Where("subscription_id = ? and event_id = ?", subscription.ID, eventID).
+			Updates(map[string]interface{}{
+				"status":          webhook.DeliveryInProgress,
+				"next_attempt_at": time.Now().Add(-time.Second),
+			}).Error
+		require.NoError(t, err)
+		var attempted *webhook.Delivery
+		// when
+		_, err = s.repo.DeliverDue(context.Background(), 100, time.Minute, func(_ webhook.Subscription, d *webhook.Delivery) {
+			if d.EventID == eventID {
+				attempted = d
+			}
+		})
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, attempted)
+		// the delivery is still pending, since it was not delivered
+		assert.Equal(t, webhook.DeliveryPending, attempted.Status)
+		assert.Equal(t, webhook.DeliveryPending, delivery(t, eventID).Status)
+	})
+
+	s.T().Run("leased", func(t *testing.T) {
+		// given a delivery which is being delivered
+		eventID := enqueue(t)
+		err := s.DB.Model(&webhook.Delivery{}).Where("subscription_id = ? and event_id = ?", subscription.ID, eventID).
+			Updates(map[string]interface{}{
+				"status":          webhook.DeliveryInProgress,
+				"next_attempt_at": time.Now().Add(time.Minute),
+			}).Error
+		require.NoError(t, err)
+		// when
+		_, err = s.repo.DeliverDue(context.Background(), 100, time.Minute, func(_ webhook.Subscription, d *webhook.Delivery) {
+			assert.NotEqual(t, eventID, d.EventID)
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, webhook.DeliveryInProgress, delivery(t, eventID).Status)
+	})
+
+	// the webhook is deleted, so that its deliveries are not attempted by the dispatcher tests
+	err = s.repo.Delete(context.Background(), subscription.ID)
+	require.NoError(s.T(), err)
+}